@@ -0,0 +1,222 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v3"
+)
+
+// configFileName is the name of the optional file holding default values for options
+const configFileName = ".autoswe.yaml"
+
+// configFileAnnotation marks options set by a config file, with the file's path, so that
+// they can be told apart from options given on the command line
+const configFileAnnotation = "autoswe_config_file"
+
+// configFilePaths returns the config files to read, most important first: the one given by
+// --config, or else those in the root directory and the home directory
+func configFilePaths() []string {
+	if configPath != "" {
+		return []string{configPath}
+	}
+
+	paths := []string{filepath.Join(rootDir, configFileName)}
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, configFileName))
+	}
+	return paths
+}
+
+// configure applies the config files to the command's options, then sets up logging as the
+// options ask, redacting the API keys. Every command does this before anything else.
+func configure(cmd *cobra.Command) error {
+	if err := applyConfigFiles(cmd, configFilePaths()); err != nil {
+		return err
+	}
+
+	// Keys that don't look like well known ones are still kept out of the logs
+	for _, key := range []string{anthropicKey, geminiKey, openAIKey} {
+		log.AddSecret(key)
+	}
+
+	format, err := log.ParseFormat(logFormat)
+	if err != nil {
+		return err
+	}
+
+	level, err := logLevelOption(cmd)
+	if err != nil {
+		return err
+	}
+
+	return log.InitWithConfig(log.Config{Format: format, Level: level})
+}
+
+// logLevelOption returns the log level chosen by --log-level, --verbose or --quiet, of which
+// only one may be given on the command line, and only one may be set by the config files. An
+// option given on the command line overrides one from a config file.
+func logLevelOption(cmd *cobra.Command) (zapcore.Level, error) {
+	var fromCommandLine, fromConfigFile []string
+	for _, name := range []string{"log-level", "verbose", "quiet"} {
+		flag := cmd.Flags().Lookup(name)
+		switch {
+		case flag == nil || !flag.Changed:
+		case flag.Annotations[configFileAnnotation] != nil:
+			fromConfigFile = append(fromConfigFile, name)
+		default:
+			fromCommandLine = append(fromCommandLine, name)
+		}
+	}
+
+	chosen := fromCommandLine
+	if len(chosen) == 0 {
+		chosen = fromConfigFile
+	}
+	if len(chosen) > 1 {
+		given := make([]string, len(chosen))
+		for idx, name := range chosen {
+			given[idx] = "--" + name
+		}
+		return 0, fmt.Errorf("only one of %s can be given", strings.Join(given, ", "))
+	}
+
+	switch {
+	case len(chosen) == 0:
+	case chosen[0] == "verbose" && verbose:
+		return zapcore.DebugLevel, nil
+	case chosen[0] == "quiet" && quiet:
+		return zapcore.WarnLevel, nil
+	}
+
+	level, err := zapcore.ParseLevel(logLevel)
+	if err != nil {
+		return 0, fmt.Errorf("invalid log level: %w", err)
+	}
+	return level, nil
+}
+
+// applyConfigFiles sets options from each config file that exists. Keys are option names,
+// such as anthropic-key or disable-tool, and lists set repeatable options. An option is
+// only set by the first file that has it, and options given on the command line are never
+// overridden. Environment variables only provide defaults, so the files take precedence.
+func applyConfigFiles(cmd *cobra.Command, paths []string) error {
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		if seen[abs] {
+			continue
+		}
+		seen[abs] = true
+
+		required := configPath != "" && path == configPath
+		if err := applyConfigFile(cmd, path, required); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyConfigFile sets options from a single config file, which is skipped if it doesn't
+// exist unless it is required
+func applyConfigFile(cmd *cobra.Command, path string, required bool) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var options map[string]any
+	if err := yaml.Unmarshal(data, &options); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	flags := cmd.Flags()
+	for _, name := range names {
+		flag := flags.Lookup(name)
+		if flag == nil {
+			// Options for other commands are fine, but typos should be caught
+			if !isKnownOption(cmd.Root(), name) {
+				return fmt.Errorf("%s: unknown option %q", path, name)
+			}
+			continue
+		}
+
+		if flag.Changed {
+			continue
+		}
+
+		values, err := optionValues(options[name])
+		if err != nil {
+			return fmt.Errorf("%s: invalid value for %s: %w", path, name, err)
+		}
+
+		for _, value := range values {
+			if err := flags.Set(name, value); err != nil {
+				return fmt.Errorf("%s: invalid value for %s: %w", path, name, err)
+			}
+		}
+		if err := flags.SetAnnotation(name, configFileAnnotation, []string{path}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// optionValues converts a config file value to the strings it would be given as on the
+// command line, one for each time the option would be repeated
+func optionValues(value any) ([]string, error) {
+	switch value := value.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if _, ok := item.(map[string]any); ok {
+				return nil, fmt.Errorf("list items must be values, not maps")
+			}
+			values = append(values, fmt.Sprint(item))
+		}
+		return values, nil
+	case map[string]any:
+		return nil, fmt.Errorf("expected a value or list, not a map")
+	default:
+		return []string{fmt.Sprint(value)}, nil
+	}
+}
+
+// isKnownOption reports whether the command, or any of its subcommands, accepts the named
+// option
+func isKnownOption(cmd *cobra.Command, name string) bool {
+	if cmd.Flags().Lookup(name) != nil {
+		return true
+	}
+
+	for _, sub := range cmd.Commands() {
+		if isKnownOption(sub, name) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestApplyConfigFiles(t *testing.T) {
+	var (
+		anthropic string
+		model     string
+		maxCost   float64
+		disabled  []string
+	)
+
+	root := &cobra.Command{Use: "autoswe"}
+	root.PersistentFlags().StringVar(&anthropic, "anthropic-key", "from-env", "")
+	root.PersistentFlags().StringVar(&model, "query-model", "", "")
+
+	task := &cobra.Command{Use: "task", Run: func(*cobra.Command, []string) {}}
+	task.Flags().Float64Var(&maxCost, "max-cost", 0, "")
+	task.Flags().StringArrayVar(&disabled, "disable-tool", nil, "")
+	root.AddCommand(task)
+
+	index := &cobra.Command{Use: "index", Run: func(*cobra.Command, []string) {}}
+	root.AddCommand(index)
+
+	dir := t.TempDir()
+	project := filepath.Join(dir, "project.yaml")
+	require.NoError(t, os.WriteFile(project, []byte("query-model: gemini-2.0-pro\nmax-cost: 2.5\ndisable-tool:\n  - exec\n  - fs_rm\n"), 0644))
+	home := filepath.Join(dir, "home.yaml")
+	require.NoError(t, os.WriteFile(home, []byte("anthropic-key: from-file\nquery-model: gemini-1.5-flash\n"), 0644))
+
+	require.NoError(t, task.ParseFlags([]string{"--max-cost", "1"}))
+	require.NoError(t, applyConfigFiles(task, []string{project, filepath.Join(dir, "missing.yaml"), home}))
+
+	// The file beats the environment, the first file wins and the command line beats both
+	assert.Equal(t, "from-file", anthropic)
+	assert.Equal(t, "gemini-2.0-pro", model)
+	assert.Equal(t, 1.0, maxCost)
+	assert.Equal(t, []string{"exec", "fs_rm"}, disabled)
+
+	// Options for other commands are skipped, but unknown ones are rejected
+	require.NoError(t, applyConfigFiles(index, []string{project}))
+
+	typo := filepath.Join(dir, "typo.yaml")
+	require.NoError(t, os.WriteFile(typo, []byte("max-costs: 2\n"), 0644))
+	assert.ErrorContains(t, applyConfigFiles(task, []string{typo}), `unknown option "max-costs"`)
+}
+
+func TestLogLevelOption(t *testing.T) {
+	dir := t.TempDir()
+	warnFile := filepath.Join(dir, "warn.yaml")
+	require.NoError(t, os.WriteFile(warnFile, []byte("log-level: warn\n"), 0644))
+	verboseFile := filepath.Join(dir, "verbose.yaml")
+	require.NoError(t, os.WriteFile(verboseFile, []byte("verbose: true\n"), 0644))
+	bothFile := filepath.Join(dir, "both.yaml")
+	require.NoError(t, os.WriteFile(bothFile, []byte("verbose: true\nquiet: true\n"), 0644))
+
+	tests := []struct {
+		name    string
+		args    []string
+		files   []string
+		want    zapcore.Level
+		wantErr string
+	}{
+		{name: "default", want: zapcore.InfoLevel},
+		{name: "command line", args: []string{"-v"}, want: zapcore.DebugLevel},
+		{name: "config file", files: []string{warnFile}, want: zapcore.WarnLevel},
+		{name: "command line overrides a config file", args: []string{"-v"}, files: []string{warnFile}, want: zapcore.DebugLevel},
+		{name: "command line level overrides verbose in a config file", args: []string{"--log-level", "error"}, files: []string{verboseFile}, want: zapcore.ErrorLevel},
+		{name: "conflicting command line options", args: []string{"-v", "-q"}, wantErr: "only one of --verbose, --quiet can be given"},
+		{name: "conflicting config file options", files: []string{bothFile}, wantErr: "only one of --verbose, --quiet can be given"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logLevel, verbose, quiet = "info", false, false
+
+			cmd := &cobra.Command{Use: "task", Run: func(*cobra.Command, []string) {}}
+			cmd.Flags().StringVar(&logLevel, "log-level", "info", "")
+			cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "")
+			cmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "")
+
+			require.NoError(t, cmd.ParseFlags(tt.args))
+			require.NoError(t, applyConfigFiles(cmd, tt.files))
+
+			level, err := logLevelOption(cmd)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, level)
+		})
+	}
+}
@@ -1,14 +1,31 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/russellhaering/autoswe/pkg/autoswe"
+	"github.com/russellhaering/autoswe/pkg/doctor"
+	"github.com/russellhaering/autoswe/pkg/index"
 	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/pricing"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/russellhaering/autoswe/pkg/retry"
+	"github.com/russellhaering/autoswe/pkg/tools/astgrep"
+	"github.com/russellhaering/autoswe/pkg/tools/fs"
+	"github.com/russellhaering/autoswe/pkg/tools/git"
+	"github.com/russellhaering/autoswe/pkg/tools/registry"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 var (
@@ -20,43 +37,302 @@ var (
 		Use:   "autoswe",
 		Short: "A tool for AI-assisted Go software engineering",
 		Long:  `autoswe is a command-line tool that uses AI to assist with Go software engineering tasks. It provides various commands for code analysis, indexing, and task automation.`,
-		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+		// Errors are printed by main, so that secrets can be redacted
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			if err := configure(cmd); err != nil {
+				return err
+			}
+
+			// The root command only checks keys, which doesn't need the manager
+			if cmd == cmd.Root() {
+				return nil
+			}
+
+			filterMode, err := index.ParseFilterMode(queryFilter)
+			if err != nil {
+				return err
+			}
+
+			indexMode, err := index.ParseIndexMode(indexModeName)
+			if err != nil {
+				return err
+			}
+
+			astGrepMode, err := astgrep.ParseMode(astGrepModeName)
+			if err != nil {
+				return err
+			}
+
+			indexBackend, err := index.ParseBackend(indexBackendName)
+			if err != nil {
+				return err
+			}
+
+			provider, err := index.ParseProvider(modelProvider)
+			if err != nil {
+				return err
+			}
+
+			prices := pricing.Defaults
+			if pricesPath != "" {
+				if prices, err = pricing.Load(pricesPath); err != nil {
+					return err
+				}
+			}
+
+			var chunkers map[string]index.Chunker
+			if astChunking {
+				chunkers = index.DefaultChunkers()
+			}
+
+			// Print the assistant's text as it is generated, unless only the last line of the
+			// result was asked for
+			var onText autoswe.TextHandler
+			if !noStream && taskVerbosity != string(autoswe.VerbosityQuiet) {
+				onText = func(delta string) {
+					fmt.Print(delta)
+				}
+			}
+
 			_manager, _, err := initializeManager(context.Background(), autoswe.Config{
-				GeminiAPIKey:      autoswe.GeminiAPIKey(geminiKey),
-				AnthropicAPIKey:   autoswe.AnthropicAPIKey(anthropicKey),
+				GeminiAPIKey:    autoswe.GeminiAPIKey(geminiKey),
+				AnthropicAPIKey: autoswe.AnthropicAPIKey(anthropicKey),
+				Keys: autoswe.KeyCheckConfig{
+					RequireAnthropic: cmd.Annotations[runsTasksAnnotation] == "true",
+					// Planning and estimating an index update don't call Gemini
+					RequireGemini: provider == index.ProviderGemini && !indexDryRun && !indexEstimate,
+					Probe:         true,
+				},
 				RootDir:           autoswe.RootDir(rootDir),
 				ExtraContextPaths: extraContextPaths,
+				IncludePaths:      includePaths,
+				SkipIndexUpdate:   indexDryRun || indexEstimate || skipIndexUpdate,
+				Index: index.Config{
+					FilterMode:          filterMode,
+					MinSimilarity:       queryMinSimilarity,
+					SimilarityThreshold: querySimilarityThreshold,
+					MinResults:          queryMinResults,
+					MaxResults:          queryMaxResults,
+					IncludeScores:       showScores,
+					KeywordWeight:       keywordWeight,
+					RecordAnalytics:     queryAnalytics,
+					EmbedPaths:          embedPaths,
+					Chunkers:            chunkers,
+					IndexMode:           indexMode,
+					Backend:             indexBackend,
+					QdrantURL:           qdrantURL,
+					QdrantCollection:    qdrantCollection,
+					EmbeddingCache:      embeddingCache,
+					Provider:            provider,
+					OpenAIBaseURL:       openAIBaseURL,
+					OpenAIAPIKey:        openAIKey,
+					EmbeddingModel:      embeddingModel,
+					SummaryModel:        summaryModel,
+					QueryModel:          queryModel,
+					Prices:              prices,
+					Progress:            indexProgress(os.Stderr, isTerminal(os.Stderr)),
+				},
+				CommitIdentity: git.CommitIdentity{
+					AuthorName:  gitAuthorName,
+					AuthorEmail: gitAuthorEmail,
+					Sign:        gitSign,
+				},
+				ASTGrepMode: astGrepMode,
+				Grep: fs.GrepConfig{
+					MaxFileSize: grepMaxFileSize,
+				},
+				Fetch: fs.FetchConfig{
+					MaxBytes: fetchMaxBytes,
+				},
+				Patch: fs.PatchConfig{
+					Model: patchModel,
+				},
+				Tools: registry.ToolsConfig{
+					Enabled:        enabledTools,
+					Disabled:       disabledTools,
+					MaxResultBytes: toolMaxResultBytes,
+				},
+				Retry: retry.Config{
+					MaxAttempts: geminiMaxAttempts,
+				},
+				Prices: prices,
+				OnText: onText,
+				Budget: autoswe.BudgetConfig{
+					MaxIterations:      maxIterations,
+					MaxCostUSD:         maxCost,
+					MaxDelegationDepth: maxDelegationDepth,
+				},
+				History: autoswe.HistoryConfig{
+					ElideAfterTurns: elideAfterTurns,
+					ElideMinBytes:   elideMinBytes,
+				},
 			})
 			if err != nil {
-				return fmt.Errorf("failed to initialize manager: %w", err)
+				return fmt.Errorf("failed to initialize manager: %w", withKeyHint(err))
 			}
 
 			manager = &_manager
 			return nil
 		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if !checkKeysOnly {
+				return cmd.Help()
+			}
+
+			provider, err := index.ParseProvider(modelProvider)
+			if err != nil {
+				return err
+			}
+
+			err = autoswe.CheckKeys(cmd.Context(), autoswe.Config{
+				GeminiAPIKey:    autoswe.GeminiAPIKey(geminiKey),
+				AnthropicAPIKey: autoswe.AnthropicAPIKey(anthropicKey),
+				Keys: autoswe.KeyCheckConfig{
+					RequireAnthropic: true,
+					RequireGemini:    provider == index.ProviderGemini,
+					Probe:            true,
+				},
+			})
+			if err != nil {
+				return withKeyHint(err)
+			}
+
+			fmt.Println("API keys OK")
+			return nil
+		},
 	}
 
 	// Configuration flags
-	geminiKey         string
-	rootDir           string
-	anthropicKey      string
-	extraContextPaths []string
+	geminiKey                string
+	rootDir                  string
+	anthropicKey             string
+	extraContextPaths        []string
+	includePaths             []string
+	indexDryRun              bool
+	indexEstimate            bool
+	queryFilter              string
+	queryMinSimilarity       float64
+	querySimilarityThreshold float64
+	queryMinResults          int
+	queryMaxResults          int
+	showScores               bool
+	gitAuthorName            string
+	gitAuthorEmail           string
+	gitSign                  bool
+	elideAfterTurns          int
+	elideMinBytes            int
+	queryAnalytics           bool
+	embedPaths               bool
+	astChunking              bool
+	keywordWeight            float64
+	indexModeName            string
+	astGrepModeName          string
+	grepMaxFileSize          int64
+	fetchMaxBytes            int
+	taskVerbosity            string
+	skipIndexUpdate          bool
+	contextFiles             []string
+	indexBackendName         string
+	qdrantURL                string
+	qdrantCollection         string
+	embeddingCache           bool
+	enabledTools             []string
+	disabledTools            []string
+	toolMaxResultBytes       int
+	geminiMaxAttempts        int
+	transcriptPath           string
+	resumePath               string
+	resumeFrom               int
+	resumeMessage            string
+	indexWatch               bool
+	indexWatchMode           string
+	indexWatchInterval       time.Duration
+	indexCompact             bool
+	embeddingModel           string
+	summaryModel             string
+	queryModel               string
+	patchModel               string
+	modelProvider            string
+	openAIBaseURL            string
+	openAIKey                string
+	pricesPath               string
+	maxIterations            int
+	maxCost                  float64
+	maxDelegationDepth       int
+	noStream                 bool
+	configPath               string
+	checkKeysOnly            bool
+	logFormat                string
+	logLevel                 string
+	verbose                  bool
+	quiet                    bool
 )
 
+// runsTasksAnnotation marks commands that run tasks, and so need a valid Anthropic API key
+const runsTasksAnnotation = "runs-tasks"
+
 func init() {
 	// Global flags
 	rootCmd.PersistentFlags().StringVar(&geminiKey, "gemini-key", os.Getenv("GOOGLE_API_KEY"), "Gemini API key")
+	rootCmd.PersistentFlags().IntVar(&geminiMaxAttempts, "gemini-max-attempts", retry.DefaultMaxAttempts, "most times a Gemini call is attempted when it is rate limited or fails with a server error")
+	rootCmd.PersistentFlags().StringVar(&modelProvider, "model-provider", string(index.ProviderGemini), "service used for indexing and codebase queries: gemini or openai (any OpenAI-compatible API)")
+	rootCmd.PersistentFlags().StringVar(&openAIBaseURL, "openai-base-url", index.DefaultOpenAIBaseURL, "address of the OpenAI-compatible API used with --model-provider openai, such as http://localhost:11434/v1 for Ollama")
+	rootCmd.PersistentFlags().StringVar(&openAIKey, "openai-key", os.Getenv("OPENAI_API_KEY"), "API key for the OpenAI-compatible API, if it needs one")
+	rootCmd.PersistentFlags().StringVar(&embeddingModel, "embedding-model", "", "model used for embeddings, "+index.DefaultEmbeddingModel+" or "+index.DefaultOpenAIEmbeddingModel+" by default (changing it requires rebuilding the index)")
+	rootCmd.PersistentFlags().StringVar(&summaryModel, "summary-model", "", "model used to summarize files while indexing, "+index.DefaultSummaryModel+" or "+index.DefaultOpenAIChatModel+" by default")
+	rootCmd.PersistentFlags().StringVar(&queryModel, "query-model", "", "model used to answer codebase queries, "+index.DefaultQueryModel+" or "+index.DefaultOpenAIChatModel+" by default")
+	rootCmd.PersistentFlags().StringVar(&patchModel, "patch-model", fs.DefaultPatchModel, "Gemini model used to apply patches that can't be applied directly")
+	rootCmd.PersistentFlags().StringVar(&pricesPath, "prices", "", "JSON file of model prices used to report task costs and estimate indexing costs, merged over the built-in prices, e.g. {\"claude-3-7-sonnet\": {\"input_per_mtok\": 3, \"output_per_mtok\": 15}}")
 	rootCmd.PersistentFlags().StringVar(&rootDir, "root", ".", "root directory to operate on")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", string(log.FormatConsole), "how log entries are written: console, or json for CI and log collectors")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "least severe level that is logged: debug, info, warn or error")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "log debug details, the same as --log-level debug")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "only log warnings and errors, the same as --log-level warn")
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "YAML file of default option values, instead of "+configFileName+" in the root and home directories")
 	rootCmd.PersistentFlags().StringVar(&anthropicKey, "anthropic-key", os.Getenv("ANTHROPIC_API_KEY"), "Anthropic API key")
+	rootCmd.PersistentFlags().StringArrayVar(&includePaths, "include-path", nil,
+		"Path or glob to always index and allow access to, even if ignore rules exclude it. Can be specified multiple times.")
+	rootCmd.PersistentFlags().StringVar(&gitAuthorName, "git-author-name", "", "author and committer name for commits made by autoswe")
+	rootCmd.PersistentFlags().StringVar(&gitAuthorEmail, "git-author-email", "", "author and committer email for commits made by autoswe")
+	rootCmd.PersistentFlags().BoolVar(&gitSign, "git-sign", false, "GPG-sign commits made by autoswe")
+	rootCmd.PersistentFlags().StringVar(&astGrepModeName, "ast-grep-mode", string(astgrep.ModeAuto), "how to run ast-grep: auto, docker or local")
+	rootCmd.PersistentFlags().Int64Var(&grepMaxFileSize, "grep-max-file-size", fs.DefaultGrepMaxFileSize, "files larger than this many bytes are skipped by fs_grep (0 for no limit)")
+	rootCmd.PersistentFlags().IntVar(&fetchMaxBytes, "fetch-max-bytes", fs.DefaultFetchMaxBytes, "most bytes of a file returned by a single fs_fetch, which pages through larger files (0 for no limit)")
+	rootCmd.PersistentFlags().StringVar(&queryFilter, "query-filter", string(index.FilterModeThreshold), "how to filter semantic search results: threshold or adaptive")
+	rootCmd.PersistentFlags().Float64Var(&queryMinSimilarity, "query-min-similarity", index.DefaultMinSimilarity, "similarity the best search result must reach for a semantic query to be answered (negative to disable)")
+	rootCmd.PersistentFlags().Float64Var(&querySimilarityThreshold, "query-similarity-threshold", index.DefaultSimilarityThreshold, "similarity a search result needs to be used by a query in threshold filter mode (negative to keep every result)")
+	rootCmd.PersistentFlags().IntVar(&queryMinResults, "query-min-results", index.DefaultMinResults, "number of search results threshold filter mode keeps, even if they are below the threshold (negative to disable)")
+	rootCmd.PersistentFlags().Float64Var(&keywordWeight, "keyword-weight", 0, "share of each search result's score given to matching the query's keywords, from 0 (similarity only) to 1, so that queries naming an identifier find its definition")
+	rootCmd.PersistentFlags().IntVar(&queryMaxResults, "query-max-results", index.DefaultMaxResults, "most search results used to answer a query")
+	rootCmd.PersistentFlags().StringVar(&indexBackendName, "index-backend", string(index.BackendBolt), "where to store the index: bolt (on disk), memory (rebuilt every run) or qdrant (requires a build with -tags qdrant)")
+	rootCmd.PersistentFlags().StringVar(&qdrantURL, "qdrant-url", index.DefaultQdrantURL, "address of the Qdrant server used by the qdrant index backend")
+	rootCmd.PersistentFlags().StringVar(&qdrantCollection, "qdrant-collection", index.DefaultQdrantCollection, "Qdrant collection used by the qdrant index backend")
+	rootCmd.PersistentFlags().BoolVar(&embedPaths, "embed-paths", false, "include file paths in indexed content so queries can match file names (requires rebuilding the index)")
+	rootCmd.PersistentFlags().StringVar(&indexModeName, "index-mode", string(index.IndexModeSummary), "what is embedded for each chunk: summary, code, or both, so that queries naming symbols match (requires rebuilding the index)")
+	rootCmd.PersistentFlags().BoolVar(&astChunking, "ast-chunking", false, "split Go files into a chunk per declaration by parsing them, instead of asking the summary model")
+	rootCmd.PersistentFlags().BoolVar(&queryAnalytics, "query-analytics", false, "record each semantic query to a local analytics store")
+	rootCmd.PersistentFlags().BoolVar(&embeddingCache, "embedding-cache", false, "cache embeddings locally so repeated queries and unchanged content aren't embedded again")
+
+	rootCmd.PersistentFlags().StringArrayVar(&enabledTools, "enable-tool", nil,
+		"Tool to make available, disabling every tool not listed. Can be specified multiple times.")
+	rootCmd.PersistentFlags().StringArrayVar(&disabledTools, "disable-tool", nil,
+		"Tool to make unavailable, eg exec or fs_rm. Can be specified multiple times.")
+	rootCmd.PersistentFlags().IntVar(&toolMaxResultBytes, "tool-max-result-bytes", registry.DefaultMaxResultBytes, "tool results larger than this many bytes are truncated, keeping their start and end (0 for no limit)")
+
+	rootCmd.Flags().BoolVar(&checkKeysOnly, "check", false, "check that the API keys are set and accepted, then exit")
 
 	// Add commands
 	rootCmd.AddCommand(newIndexCmd())
 	rootCmd.AddCommand(newContextCmd())
 	rootCmd.AddCommand(newTaskCmd())
 	rootCmd.AddCommand(newCommitCmd())
+	rootCmd.AddCommand(newChatCmd())
+	rootCmd.AddCommand(newIgnoreCmd())
+	rootCmd.AddCommand(newDoctorCmd())
 
-	// Initialize logger
-	if err := log.Init(true); err != nil {
+	// Initialize logger, until the options are parsed and configure sets it up as they ask
+	if err := log.InitWithConfig(log.Config{Format: log.FormatConsole, Level: zapcore.InfoLevel}); err != nil {
 		// Can't use log.Error here since logger isn't initialized
 		fmt.Fprintf(os.Stderr, "Error initializing logger: %v\n", err)
 		os.Exit(1)
@@ -64,8 +340,13 @@ func init() {
 }
 
 func main() {
+	// Cancel running tools, such as exec containers, on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Initialize the command
-	if err := rootCmd.Execute(); err != nil {
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", log.Redact(err.Error()))
 		log.Error("Failed to execute command", zap.Error(err))
 		os.Exit(1)
 	}
@@ -87,6 +368,26 @@ func newIndexCmd() *cobra.Command {
 This command will scan the codebase, split files into chunks, and create embeddings
 for semantic search capabilities.`,
 		RunE: func(cmd *cobra.Command, _ []string) error {
+			if indexDryRun {
+				plan, err := manager.Indexer.PlanUpdate(cmd.Context())
+				if err != nil {
+					return fmt.Errorf("failed to plan index update: %w", err)
+				}
+
+				printUpdatePlan(plan)
+				return nil
+			}
+
+			if indexEstimate {
+				estimate, err := manager.Indexer.EstimateUpdate(cmd.Context())
+				if err != nil {
+					return fmt.Errorf("failed to estimate index update: %w", err)
+				}
+
+				fmt.Println(estimate)
+				return nil
+			}
+
 			// Build or update the index
 			log.Info("Building/updating code index")
 			if err := manager.Indexer.UpdateIndex(cmd.Context()); err != nil {
@@ -94,16 +395,337 @@ for semantic search capabilities.`,
 			}
 
 			log.Info("Index updated successfully")
+
+			if indexCompact {
+				result, err := manager.Indexer.Compact(cmd.Context())
+				if err != nil {
+					return err
+				}
+
+				fmt.Printf("Compacted index from %s to %s\n", formatSize(result.SizeBefore), formatSize(result.SizeAfter))
+			}
+
+			if indexWatch {
+				mode, err := index.ParseWatchMode(indexWatchMode)
+				if err != nil {
+					return err
+				}
+
+				return manager.Indexer.Watch(cmd.Context(), index.WatchConfig{
+					Mode:     mode,
+					Interval: indexWatchInterval,
+				})
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&indexDryRun, "dry-run", false, "report which files would be indexed or removed without updating the index")
+	cmd.Flags().BoolVar(&indexEstimate, "estimate", false, "estimate the model calls and cost of updating the index without updating it")
+	cmd.Flags().BoolVar(&indexCompact, "compact", false, "rewrite the index file after updating it, to reclaim the space left by deleted documents")
+	cmd.Flags().BoolVar(&indexWatch, "watch", false, "keep updating the index as files change, until interrupted")
+	cmd.Flags().StringVar(&indexWatchMode, "watch-mode", string(index.WatchModeAuto),
+		"how --watch notices changes: auto or poll (poll works on network mounts and in containers)")
+	cmd.Flags().DurationVar(&indexWatchInterval, "watch-interval", index.DefaultWatchInterval,
+		"how often --watch polls for changes")
+
+	cmd.AddCommand(newIndexAnalyticsCmd())
+	cmd.AddCommand(newIndexVerifyCmd())
+	cmd.AddCommand(newIndexWarmCmd())
+
+	return cmd
+}
+
+// newIndexAnalyticsCmd creates the index analytics command
+func newIndexAnalyticsCmd() *cobra.Command {
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:   "analytics",
+		Short: "Summarize recorded queries",
+		Long: `Summarize the queries recorded with --query-analytics, showing the most common
+queries and those with low recall.`,
+		// The analytics store is read directly, so there is no need to initialize the manager
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			return configure(cmd)
+		},
+		RunE: func(_ *cobra.Command, _ []string) error {
+			store := index.NewAnalyticsStore(filepath.Join(index.StoragePath, index.AnalyticsFileName))
+
+			records, err := store.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load query analytics: %w", err)
+			}
+
+			printAnalyticsSummary(index.SummarizeQueries(records, limit))
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVarP(&limit, "limit", "n", 10, "maximum number of queries to show in each section")
+
+	return cmd
+}
+
+// newIndexVerifyCmd creates the index verify command
+func newIndexVerifyCmd() *cobra.Command {
+	var repair bool
+
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Check the index for inconsistent entries",
+		Long: `Check that every indexed file has all of its chunks, and that every chunk belongs
+to an indexed file. Inconsistencies are usually left behind when autoswe is killed while
+updating the index. With --repair, the affected files are removed from the index and
+re-indexed.`,
+		// Verify the index as it is on disk, rather than after the usual startup update
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			skipIndexUpdate = true
+			return rootCmd.PersistentPreRunE(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			report, err := manager.Indexer.Verify(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("failed to verify index: %w", err)
+			}
+
+			fmt.Printf("Checked %d files and %d chunks\n", report.Files, report.Chunks)
+			if len(report.Inconsistencies) == 0 {
+				fmt.Println("No inconsistencies found")
+				return nil
+			}
+
+			fmt.Printf("Inconsistent files (%d):\n", len(report.Inconsistencies))
+			for _, inconsistency := range report.Inconsistencies {
+				fmt.Printf("  %s (%s)\n", index.ComputeID(inconsistency.Namespace, inconsistency.Path, -1), inconsistency.Problem)
+			}
+
+			if !repair {
+				return fmt.Errorf("index has %d inconsistent files, run with --repair to fix them", len(report.Inconsistencies))
+			}
+
+			if err := manager.Indexer.Repair(cmd.Context(), report); err != nil {
+				return fmt.Errorf("failed to repair index: %w", err)
+			}
+
+			fmt.Printf("Repaired %d files\n", len(report.Inconsistencies))
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&repair, "repair", false, "remove and re-index inconsistent files")
+
+	return cmd
+}
+
+// newIndexWarmCmd creates the index warm command
+func newIndexWarmCmd() *cobra.Command {
+	var (
+		queriesFile   string
+		fromAnalytics bool
+		limit         int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "warm [query...]",
+		Short: "Prefetch embeddings for likely queries",
+		Long: `Embed anticipated queries into the embedding cache, so that running them later
+doesn't wait on the embedding model. Queries can be given as arguments, read from a file
+with one query per line, or taken from the most common queries recorded with
+--query-analytics. Implies --embedding-cache.`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			skipIndexUpdate = true
+			embeddingCache = true
+			return rootCmd.PersistentPreRunE(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			queries := args
+
+			if queriesFile != "" {
+				content, err := os.ReadFile(queriesFile)
+				if err != nil {
+					return fmt.Errorf("failed to read queries: %w", err)
+				}
+				queries = append(queries, strings.Split(string(content), "\n")...)
+			}
+
+			if fromAnalytics {
+				store := index.NewAnalyticsStore(filepath.Join(index.StoragePath, index.AnalyticsFileName))
+
+				records, err := store.Load()
+				if err != nil {
+					return fmt.Errorf("failed to load query analytics: %w", err)
+				}
+
+				for _, stats := range index.SummarizeQueries(records, limit).Common {
+					queries = append(queries, stats.Query)
+				}
+			}
+
+			if len(queries) == 0 {
+				return fmt.Errorf("no queries to warm, pass them as arguments, with --file or with --from-analytics")
+			}
+
+			result, err := manager.Indexer.Warm(cmd.Context(), queries)
+			if err != nil {
+				return fmt.Errorf("failed to warm embedding cache: %w", err)
+			}
+
+			fmt.Printf("Embedded %d queries (%d already cached)\n", result.Embedded, result.Cached)
 			return nil
 		},
 	}
 
+	cmd.Flags().StringVarP(&queriesFile, "file", "f", "", "file containing queries to warm, one per line")
+	cmd.Flags().BoolVar(&fromAnalytics, "from-analytics", false, "warm the most common queries recorded with --query-analytics")
+	cmd.Flags().IntVarP(&limit, "limit", "n", 50, "maximum number of queries to take from the analytics store")
+
 	return cmd
 }
 
+// printAnalyticsSummary prints the common and low recall queries
+func printAnalyticsSummary(summary index.AnalyticsSummary) {
+	fmt.Printf("Total queries: %d\n", summary.TotalQueries)
+
+	fmt.Printf("\nMost common queries (%d):\n", len(summary.Common))
+	for _, s := range summary.Common {
+		fmt.Printf("  %4d  %q (avg hits %.1f, avg top score %.2f)\n", s.Count, s.Query, s.AvgHits, s.AvgTopScore)
+	}
+
+	fmt.Printf("\nLow recall queries (%d):\n", len(summary.LowRecall))
+	for _, s := range summary.LowRecall {
+		fmt.Printf("  %4d  %q (answered %d, avg top score %.2f)\n", s.Count, s.Query, s.Answered, s.AvgTopScore)
+	}
+}
+
+// printUpdatePlan prints the files that an index update would add, update and delete
+func printUpdatePlan(plan *index.UpdatePlan) {
+	fmt.Printf("Files to add (%d):\n", len(plan.Add))
+	for _, f := range plan.Add {
+		fmt.Printf("  %s\n", index.ComputeID(f.Namespace, f.Path, -1))
+	}
+
+	fmt.Printf("Files to update (%d):\n", len(plan.Update))
+	for _, f := range plan.Update {
+		fmt.Printf("  %s (%s)\n", index.ComputeID(f.Namespace, f.Path, -1), f.Reason)
+	}
+
+	fmt.Printf("Files to delete (%d):\n", len(plan.Delete))
+	for _, f := range plan.Delete {
+		fmt.Printf("  %s\n", index.ComputeID(f.Namespace, f.Path, -1))
+	}
+}
+
+// newIgnoreCmd creates the ignore command
+func newIgnoreCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ignore",
+		Short: "Inspect the ignore rules",
+		// The ignore rules are read directly, so there is no need to initialize the manager
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			return configure(cmd)
+		},
+	}
+
+	cmd.AddCommand(newIgnorePreviewCmd())
+
+	return cmd
+}
+
+// newDoctorCmd creates the doctor command
+func newDoctorCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the external programs the tools depend on",
+		Long: `Check that the programs the enabled tools run, such as git, go, goimports, golangci-lint,
+ast-grep and the Docker daemon, are installed and working. Missing programs are reported
+with a hint on how to install them, along with the tools that will be unavailable.`,
+		// Only the tool options are needed, so there is no need to initialize the manager
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			return configure(cmd)
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			astGrepMode, err := astgrep.ParseMode(astGrepModeName)
+			if err != nil {
+				return err
+			}
+
+			report := doctor.Check(cmd.Context(), doctor.Config{
+				Tools: registry.ToolsConfig{
+					Enabled:  enabledTools,
+					Disabled: disabledTools,
+				},
+				ASTGrepMode: astGrepMode,
+			})
+
+			printDoctorReport(report)
+			if len(report.Unavailable) > 0 {
+				// The report already explains what is missing
+				cmd.SilenceUsage = true
+				return fmt.Errorf("%d tools are unavailable", len(report.Unavailable))
+			}
+
+			return nil
+		},
+	}
+}
+
+// printDoctorReport prints the outcome of each check, with install hints for those that failed
+func printDoctorReport(report *doctor.Report) {
+	for _, result := range report.Results {
+		if result.Err == nil {
+			fmt.Printf("ok       %s (%s)\n", result.Dependency.Name, result.Version)
+			continue
+		}
+
+		fmt.Printf("missing  %s: %v\n", result.Dependency.Name, result.Err)
+		fmt.Printf("         %s\n", result.Dependency.Hint)
+	}
+
+	if len(report.Unavailable) > 0 {
+		fmt.Printf("\nUnavailable tools: %s\n", strings.Join(report.Unavailable, ", "))
+	}
+}
+
+// newIgnorePreviewCmd creates the ignore preview command
+func newIgnorePreviewCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "preview",
+		Short: "Show what the ignore rules exclude",
+		Long: `Apply the .autosweignore rules and the default rules to the repository, and list the
+files and directories they exclude along with the rule responsible for each, so that the
+rules can be tuned before indexing.`,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			preview, err := repo.NewRepoFS(rootDir).PreviewIgnore(includePaths...)
+			if err != nil {
+				return fmt.Errorf("failed to preview ignore rules: %w", err)
+			}
+
+			printIgnorePreview(preview)
+			return nil
+		},
+	}
+}
+
+// printIgnorePreview prints the excluded paths, and the number of files that would be indexed
+func printIgnorePreview(preview *repo.IgnorePreview) {
+	fmt.Printf("Excluded (%d):\n", len(preview.Excluded))
+	for _, exclusion := range preview.Excluded {
+		path := exclusion.Path
+		if exclusion.IsDir {
+			path += "/"
+		}
+		fmt.Printf("  %s (%s, %s)\n", path, exclusion.Rule, exclusion.Source)
+	}
+
+	fmt.Printf("Files to index: %d\n", preview.IncludedFiles)
+}
+
 // newContextCmd creates the query command
 func newContextCmd() *cobra.Command {
 	var limit int
+	var namespace string
 
 	cmd := &cobra.Command{
 		Use:   `context "search query"`,
@@ -111,11 +733,17 @@ func newContextCmd() *cobra.Command {
 		Long:  `Search the semantic code index using natural language queries, and display the raw results in the form that would be exposed to the LLM`,
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			result, err := manager.Indexer.Query(cmd.Context(), args[0])
+			result, err := manager.Indexer.Query(cmd.Context(), args[0], index.QueryOptions{Namespace: namespace})
 			if err != nil {
 				return fmt.Errorf("failed to query index: %w", err)
 			}
 
+			if len(result.Scores) > 0 {
+				fmt.Println("Scores:")
+				printScores(os.Stdout, result.Scores, keywordWeight > 0)
+				fmt.Println()
+			}
+
 			fmt.Println("Answer:")
 			fmt.Println()
 			fmt.Println(result.Answer)
@@ -126,6 +754,12 @@ func newContextCmd() *cobra.Command {
 
 	// Add flags
 	cmd.Flags().IntVarP(&limit, "limit", "n", 10, "maximum number of results to return")
+	cmd.Flags().StringVar(&namespace, "namespace", "", "only search this namespace: "+index.RepoNamespace+" for code or "+index.ExtraContextNamespace+" for --extra-context (all namespaces by default)")
+	cmd.Flags().BoolVar(&showScores, "debug-scores", false, "print the ID and similarity of every search result considered, and whether it was kept, before the answer, to help tune --query-similarity-threshold")
+	cmd.Flags().BoolVar(&showScores, "show-scores", false, "")
+	_ = cmd.Flags().MarkDeprecated("show-scores", "use --debug-scores instead")
+	cmd.Flags().StringArrayVar(&extraContextPaths, "extra-context", nil,
+		"Path to an additional file, or directory of text files, to include in the semantic search context. Can be specified multiple times.")
 
 	return cmd
 }
@@ -133,29 +767,225 @@ func newContextCmd() *cobra.Command {
 // newTaskCmd creates the task command
 func newTaskCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "task \"<task description>\"",
-		Short: "Run an AI-assisted task",
+		Use:         "task \"<task description>\"",
+		Annotations: map[string]string{runsTasksAnnotation: "true"},
+		Short:       "Run an AI-assisted task",
 		Long: `Run an AI-assisted task using Claude to help solve software engineering problems.
-The task description should be a clear, natural language description of what you want to accomplish.`,
-		Args: cobra.ExactArgs(1),
+The task description should be a clear, natural language description of what you want to accomplish.
+
+With --transcript, the conversation is saved as the task runs. If the task fails, it can be
+continued with --resume, optionally from an earlier turn with --from, and with --message to
+tell the model what was fixed before it continues.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if resumePath != "" {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			response, err := manager.ExecuteTask(cmd.Context(), args[0])
+			verbosity, err := autoswe.ParseVerbosity(taskVerbosity)
+			if err != nil {
+				return err
+			}
+
+			var task *autoswe.Task
+			if resumePath != "" {
+				task, err = resumeTask()
+			} else {
+				task, err = manager.PrepareTask(args[0], contextFiles...)
+				if err == nil {
+					task.TranscriptPath = transcriptPath
+				}
+			}
+			if err != nil {
+				return err
+			}
+
+			result, err := manager.RunTask(cmd.Context(), task)
 			if err != nil {
 				return fmt.Errorf("failed to execute task: %w", err)
 			}
 
-			fmt.Println()
-			fmt.Println()
-			fmt.Println("Task Complete")
-			fmt.Println()
-			fmt.Println(response)
+			if verbosity != autoswe.VerbosityQuiet {
+				fmt.Println()
+				fmt.Println()
+				if result.BudgetExhausted != "" {
+					fmt.Println("Task Stopped")
+				} else {
+					fmt.Println("Task Complete")
+				}
+			}
+
+			// The response has already been printed if it was streamed
+			output := result.Format(verbosity)
+			if !noStream && verbosity != autoswe.VerbosityQuiet {
+				output = result.FormatStreamed(verbosity)
+			}
+			if output != "" {
+				if verbosity != autoswe.VerbosityQuiet {
+					fmt.Println()
+				}
+				fmt.Println(output)
+			}
+
+			if verbosity != autoswe.VerbosityQuiet {
+				fmt.Println()
+				fmt.Printf("Usage: %s\n", result.Usage)
+			}
+
+			// Exit with an error, so unattended runs can tell the task didn't finish
+			if result.BudgetExhausted != "" {
+				return fmt.Errorf("task stopped early: %s", result.BudgetExhausted)
+			}
 
 			return nil
 		},
 	}
 
+	cmd.Flags().IntVar(&maxIterations, "max-iterations", 0,
+		"Stop the task after this many requests to the model, or each delegated task after this many of its own (0 for no limit)")
+	cmd.Flags().Float64Var(&maxCost, "max-cost", 0,
+		"Stop the task once it, and the tasks it delegates to, have spent this many US dollars (0 for no limit)")
+	cmd.Flags().IntVar(&maxDelegationDepth, "max-delegation-depth", autoswe.DefaultMaxDelegationDepth,
+		"How many levels deep the task may delegate to sub-tasks")
+	cmd.Flags().BoolVar(&noStream, "no-stream", false,
+		"Print the result once the task completes, instead of printing the assistant's text as it is generated")
+	cmd.Flags().StringVar(&transcriptPath, "transcript", "",
+		"Path to save the task's conversation to, so that it can be resumed")
+	cmd.Flags().StringVar(&resumePath, "resume", "",
+		"Path to a saved transcript to resume instead of starting a new task")
+	cmd.Flags().IntVar(&resumeFrom, "from", 0,
+		"Turn of the resumed transcript to take again, discarding it and everything after it (0 continues from the end)")
+	cmd.Flags().StringVar(&resumeMessage, "message", "",
+		"Message to add to the resumed transcript before continuing, such as a description of what was fixed")
+	cmd.Flags().StringArrayVar(&contextFiles, "context-file", nil,
+		"Path to a file whose contents are included in the task's first message. Can be specified multiple times.")
+	cmd.Flags().StringVar(&taskVerbosity, "verbosity", string(autoswe.VerbosityNormal),
+		"How much of the result to print: quiet (last line only), normal (final response) or verbose (final response and tool calls)")
+
 	cmd.Flags().StringArrayVar(&extraContextPaths, "extra-context", nil,
-		"Path to additional files to include in the semantic search context. Can be specified multiple times.")
+		"Path to an additional file, or directory of text files, to include in the semantic search context. Can be specified multiple times.")
+	cmd.Flags().IntVar(&elideAfterTurns, "elide-tool-results-after", 0,
+		"Replace large tool results with a placeholder once they are this many turns old (0 keeps them all)")
+	cmd.Flags().IntVar(&elideMinBytes, "elide-tool-results-min-size", autoswe.DefaultElideMinBytes,
+		"Minimum size in bytes of a tool result before it can be elided")
+
+	return cmd
+}
+
+// keyFlags names the flag that sets each API key, for error messages
+var keyFlags = map[string]string{
+	autoswe.AnthropicKeyEnv: "anthropic-key",
+	autoswe.GeminiKeyEnv:    "gemini-key",
+}
+
+// withKeyHint adds how to set the key to errors about a missing or invalid API key
+func withKeyHint(err error) error {
+	var keyErr *autoswe.KeyError
+	if !errors.As(err, &keyErr) {
+		return err
+	}
+
+	flag := keyFlags[keyErr.Env]
+	return fmt.Errorf("%w (set %s, pass --%s, or add %s to %s)", err, keyErr.Env, flag, flag, configFileName)
+}
+
+// resumeTask loads the transcript given by --resume, truncated to --from if set. The resumed
+// task saves its progress to --transcript if set, or back to the transcript it resumed.
+func resumeTask() (*autoswe.Task, error) {
+	if len(contextFiles) > 0 {
+		return nil, fmt.Errorf("--context-file can't be used with --resume")
+	}
+
+	transcript, err := autoswe.LoadTranscript(resumePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if resumeFrom != 0 {
+		if err := transcript.Truncate(resumeFrom); err != nil {
+			return nil, err
+		}
+	}
+
+	task, err := transcript.Resume(resumeMessage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resume %s: %w", resumePath, err)
+	}
+
+	task.TranscriptPath = transcriptPath
+	if task.TranscriptPath == "" {
+		task.TranscriptPath = resumePath
+	}
+
+	return task, nil
+}
+
+// newChatCmd creates the chat command
+func newChatCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:         "chat [\"<first message>\"]",
+		Annotations: map[string]string{runsTasksAnnotation: "true"},
+		Short:       "Work on a task interactively",
+		Long: `Work on a task interactively. After each response, type a follow-up to steer the task.
+The conversation continues where it left off, so the model keeps its context between turns.
+Type /exit, or press Ctrl-D, to quit.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			chat := manager.NewChat(contextFiles...)
+			input := bufio.NewScanner(os.Stdin)
+
+			message := ""
+			if len(args) > 0 {
+				message = args[0]
+			}
+
+			for {
+				for strings.TrimSpace(message) == "" {
+					fmt.Print("> ")
+					if !input.Scan() {
+						fmt.Println()
+						return input.Err()
+					}
+					message = input.Text()
+				}
+
+				if strings.TrimSpace(message) == "/exit" {
+					return nil
+				}
+
+				result, err := chat.Send(cmd.Context(), message)
+				if err != nil {
+					return err
+				}
+
+				// A streamed response has already been printed
+				if noStream {
+					fmt.Println(result.Response)
+				}
+				if result.BudgetExhausted != "" {
+					fmt.Printf("Turn stopped early: %s\n", result.BudgetExhausted)
+				}
+
+				fmt.Println()
+				fmt.Printf("Usage: %s\n", chat.Usage)
+				fmt.Println()
+
+				message = ""
+			}
+		},
+	}
+
+	cmd.Flags().BoolVar(&noStream, "no-stream", false,
+		"Print each response once it is complete, instead of printing the assistant's text as it is generated")
+	cmd.Flags().StringArrayVar(&contextFiles, "context-file", nil,
+		"Path to a file whose contents are included in the first message. Can be specified multiple times.")
+	cmd.Flags().IntVar(&maxIterations, "max-iterations", 0,
+		"Stop each turn after this many requests to the model (0 for no limit)")
+	cmd.Flags().Float64Var(&maxCost, "max-cost", 0,
+		"Stop each turn once it has spent this many US dollars (0 for no limit)")
+	cmd.Flags().StringArrayVar(&extraContextPaths, "extra-context", nil,
+		"Path to an additional file, or directory of text files, to include in the semantic search context. Can be specified multiple times.")
 
 	return cmd
 }
@@ -163,8 +993,9 @@ The task description should be a clear, natural language description of what you
 // newCommitCmd creates the commit command
 func newCommitCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "commit",
-		Short: "Create a commit with an AI-generated message",
+		Use:         "commit",
+		Annotations: map[string]string{runsTasksAnnotation: "true"},
+		Short:       "Create a commit with an AI-generated message",
 		Long: `Create a git commit with an automatically generated message that summarizes the changes.
 This command will analyze the current git diff and create a descriptive commit message.`,
 		RunE: func(cmd *cobra.Command, _ []string) error {
@@ -180,15 +1011,21 @@ This command will analyze the current git diff and create a descriptive commit m
 3. If there are no changes, inform the user
 4. Complete the task with a status message`
 
-			response, err := manager.ExecuteTask(cmd.Context(), commitPrompt)
+			result, err := manager.ExecuteTask(cmd.Context(), commitPrompt)
 			if err != nil {
 				return fmt.Errorf("failed to process commit: %w", err)
 			}
 
-			fmt.Println(response)
+			// A streamed response has already been printed
+			if noStream {
+				fmt.Println(result.Response)
+			}
 			return nil
 		},
 	}
 
+	cmd.Flags().BoolVar(&noStream, "no-stream", false,
+		"Print the response once the commit is made, instead of printing the assistant's text as it is generated")
+
 	return cmd
 }
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/russellhaering/autoswe/pkg/index"
+)
+
+// progressBarWidth is the number of characters in the index progress bar
+const progressBarWidth = 30
+
+// isTerminal reports whether f is a terminal, rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// indexProgress returns a progress callback that reports index updates to w. On a terminal a
+// progress bar is redrawn as each file is indexed, otherwise a line is written per file.
+func indexProgress(w io.Writer, terminal bool) index.ProgressFunc {
+	return func(progress index.UpdateProgress) {
+		if progress.Done {
+			if progress.Total == 0 {
+				return
+			}
+			if terminal {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "Indexed %d files (%d unchanged, %d failed, %d deleted)\n",
+				progress.Indexed, progress.Unchanged, progress.Failed, progress.Deleted)
+			return
+		}
+
+		done := progress.Indexed + progress.Failed
+		if !terminal {
+			fmt.Fprintf(w, "Indexed %d/%d files\n", done, progress.Total)
+			return
+		}
+
+		filled := progressBarWidth * done / progress.Total
+		fmt.Fprintf(w, "\r[%s%s] %d/%d files",
+			strings.Repeat("#", filled), strings.Repeat(".", progressBarWidth-filled), done, progress.Total)
+	}
+}
+
+// formatSize formats a number of bytes for people to read, eg 1.5 MiB
+func formatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+// printScores writes a table of search results' scores, with their keyword scores if
+// keyword is set
+func printScores(w io.Writer, scores []index.ResultScore, keyword bool) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	header := "  SIMILARITY\t"
+	if keyword {
+		header += "KEYWORD\t"
+	}
+	fmt.Fprintln(tw, header+"STATUS\tLINES\tID")
+
+	for _, score := range scores {
+		status := "dropped"
+		if score.Kept {
+			status = "kept"
+		}
+
+		row := fmt.Sprintf("  %.3f\t", score.Similarity)
+		if keyword {
+			row += fmt.Sprintf("%.3f\t", score.KeywordScore)
+		}
+		fmt.Fprintf(tw, "%s%s\t%d-%d\t%s\n", row, status, score.StartLine, score.EndLine, score.ID)
+	}
+
+	tw.Flush()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/index"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexProgress(t *testing.T) {
+	var plain strings.Builder
+	report := indexProgress(&plain, false)
+	report(index.UpdateProgress{Total: 2, Indexed: 1})
+	report(index.UpdateProgress{Total: 2, Indexed: 1, Failed: 1})
+	report(index.UpdateProgress{Total: 2, Indexed: 1, Failed: 1, Unchanged: 5, Done: true})
+	assert.Equal(t, "Indexed 1/2 files\nIndexed 2/2 files\nIndexed 1 files (5 unchanged, 1 failed, 0 deleted)\n", plain.String())
+
+	var bar strings.Builder
+	report = indexProgress(&bar, true)
+	report(index.UpdateProgress{Total: 3, Indexed: 1})
+	assert.Equal(t, "\r[##########....................] 1/3 files", bar.String())
+
+	// An update with nothing to index stays quiet
+	var quiet strings.Builder
+	indexProgress(&quiet, true)(index.UpdateProgress{Unchanged: 5, Done: true})
+	assert.Empty(t, quiet.String())
+}
+
+func TestFormatSize(t *testing.T) {
+	assert.Equal(t, "512 B", formatSize(512))
+	assert.Equal(t, "1.5 KiB", formatSize(1536))
+	assert.Equal(t, "20.0 MiB", formatSize(20<<20))
+}
+
+func TestPrintScores(t *testing.T) {
+	scores := []index.ResultScore{
+		{ID: "repo:auth.go#0", StartLine: 1, EndLine: 10, Similarity: 0.8123, KeywordScore: 1, Kept: true},
+		{ID: "repo:db.go#12", StartLine: 140, EndLine: 162, Similarity: 0.21, Kept: false},
+	}
+
+	var plain strings.Builder
+	printScores(&plain, scores, false)
+	assert.Equal(t, ""+
+		"  SIMILARITY  STATUS   LINES    ID\n"+
+		"  0.812       kept     1-10     repo:auth.go#0\n"+
+		"  0.210       dropped  140-162  repo:db.go#12\n", plain.String())
+
+	var keyword strings.Builder
+	printScores(&keyword, scores, true)
+	assert.Contains(t, keyword.String(), "  0.812       1.000    kept     1-10     repo:auth.go#0\n")
+}
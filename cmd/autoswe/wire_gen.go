@@ -16,6 +16,7 @@ import (
 	"github.com/russellhaering/autoswe/pkg/tools/format"
 	"github.com/russellhaering/autoswe/pkg/tools/fs"
 	"github.com/russellhaering/autoswe/pkg/tools/git"
+	"github.com/russellhaering/autoswe/pkg/tools/goenv"
 	"github.com/russellhaering/autoswe/pkg/tools/lint"
 	"github.com/russellhaering/autoswe/pkg/tools/query"
 	"github.com/russellhaering/autoswe/pkg/tools/registry"
@@ -26,15 +27,21 @@ import (
 
 func initializeManager(ctx context.Context, config autoswe.Config) (autoswe.Manager, func(), error) {
 	geminiAPIKey := config.GeminiAPIKey
-	client, cleanup, err := autoswe.ProvideGemini(ctx, geminiAPIKey)
+	keyCheckConfig := config.Keys
+	client, cleanup, err := autoswe.ProvideGemini(ctx, geminiAPIKey, keyCheckConfig)
 	if err != nil {
 		return autoswe.Manager{}, nil, err
 	}
 	anthropicAPIKey := config.AnthropicAPIKey
-	anthropicClient := autoswe.ProvideAnthropic(ctx, anthropicAPIKey)
+	anthropicClient, err := autoswe.ProvideAnthropic(ctx, anthropicAPIKey, keyCheckConfig)
+	if err != nil {
+		cleanup()
+		return autoswe.Manager{}, nil, err
+	}
+	agentModel := autoswe.ProvideAgentModel(anthropicClient)
 	autosweRootDir := config.RootDir
 	repositoryFS := autoswe.ProvideRepoFS(autosweRootDir)
-	filteredFS, err := autoswe.ProvideFilteredFS(ctx, repositoryFS)
+	filteredFS, err := autoswe.ProvideFilteredFS(ctx, repositoryFS, config)
 	if err != nil {
 		cleanup()
 		return autoswe.Manager{}, nil, err
@@ -44,35 +51,87 @@ func initializeManager(ctx context.Context, config autoswe.Config) (autoswe.Mana
 		cleanup()
 		return autoswe.Manager{}, nil, err
 	}
-	tool := &astgrep.Tool{}
+	toolsConfig := config.Tools
+	mode := config.ASTGrepMode
+	tool := &astgrep.Tool{
+		Mode: mode,
+	}
 	buildTool := &build.Tool{}
 	fetchTool := &dependencies.FetchTool{}
 	listTool := &dependencies.ListTool{}
 	execTool := &exec.Tool{}
 	formatTool := &format.Tool{}
+	envTool := &goenv.EnvTool{
+		FilteredFS: filteredFS,
+		RepoFS:     repositoryFS,
+	}
 	commandTool := &git.CommandTool{
 		RepoFS: repositoryFS,
 	}
+	commitIdentity := config.CommitIdentity
 	commitTool := &git.CommitTool{
+		RepoFS:   repositoryFS,
+		Identity: commitIdentity,
+	}
+	blameTool := &git.BlameTool{
+		RepoFS: repositoryFS,
+	}
+	logTool := &git.LogTool{
+		RepoFS: repositoryFS,
+	}
+	branchTool := &git.BranchTool{
 		RepoFS: repositoryFS,
 	}
 	lintTool := &lint.Tool{}
 	testTool := &test.Tool{}
 	queryTool := &query.Tool{
 		Indexer: indexer,
+		RepoFS:  repositoryFS,
+	}
+	codeSearchTool := &query.CodeSearchTool{
+		Indexer: indexer,
+		RepoFS:  repositoryFS,
+	}
+	summarizeFileTool := &query.SummarizeFileTool{
+		Indexer: indexer,
 	}
+	listNamespacesTool := &query.ListNamespacesTool{
+		Indexer: indexer,
+	}
+	indexListTool := &query.IndexListTool{
+		Indexer: indexer,
+	}
+	indexStatsTool := &query.IndexStatsTool{
+		Indexer: indexer,
+	}
+	fetchConfig := config.Fetch
 	fsFetchTool := &fs.FetchTool{
 		FilteredFS: filteredFS,
+		Config:     fetchConfig,
 	}
+	grepConfig := config.Grep
 	grepTool := &fs.GrepTool{
 		FilteredFS: filteredFS,
+		RepoFS:     repositoryFS,
+		Config:     grepConfig,
 	}
 	fsListTool := &fs.ListTool{
 		FilteredFS: filteredFS,
 	}
+	retryConfig := config.Retry
+	patchConfig := config.Patch
 	patchTool := &fs.PatchTool{
 		Gemini:     client,
 		FilteredFS: filteredFS,
+		Retry:      retryConfig,
+		Config:     patchConfig,
+	}
+	multiPatchTool := &fs.MultiPatchTool{
+		FilteredFS: filteredFS,
+	}
+	tryPatchTool := &fs.TryPatchTool{
+		RepoFS:     repositoryFS,
+		FilteredFS: filteredFS,
 	}
 	putTool := &fs.PutTool{
 		FilteredFS: filteredFS,
@@ -80,14 +139,31 @@ func initializeManager(ctx context.Context, config autoswe.Config) (autoswe.Mana
 	rmTool := &fs.RmTool{
 		FilteredFS: filteredFS,
 	}
-	toolRegistry := registry.ProvideToolRegistry(tool, buildTool, fetchTool, listTool, execTool, formatTool, commandTool, commitTool, lintTool, testTool, queryTool, fsFetchTool, grepTool, fsListTool, patchTool, putTool, rmTool)
+	moveTool := &fs.MoveTool{
+		FilteredFS: filteredFS,
+	}
+	mkdirTool := &fs.MkdirTool{
+		FilteredFS: filteredFS,
+	}
+	configRefTool := &fs.ConfigRefTool{
+		FilteredFS: filteredFS,
+	}
+	toolRegistry := registry.ProvideToolRegistry(toolsConfig, tool, buildTool, fetchTool, listTool, execTool, formatTool, envTool, commandTool, commitTool, blameTool, logTool, branchTool, lintTool, testTool, queryTool, codeSearchTool, summarizeFileTool, listNamespacesTool, indexListTool, indexStatsTool, fsFetchTool, grepTool, fsListTool, patchTool, multiPatchTool, tryPatchTool, putTool, rmTool, moveTool, mkdirTool, configRefTool)
+	historyConfig := config.History
+	table := config.Prices
+	budgetConfig := config.Budget
+	textHandler := config.OnText
 	autosweManager := autoswe.Manager{
-		GeminiClient:    client,
-		AnthropicClient: anthropicClient,
-		RepoFS:          repositoryFS,
-		FilteredFS:      filteredFS,
-		Indexer:         indexer,
-		ToolRegistry:    toolRegistry,
+		GeminiClient: client,
+		Model:        agentModel,
+		RepoFS:       repositoryFS,
+		FilteredFS:   filteredFS,
+		Indexer:      indexer,
+		ToolRegistry: toolRegistry,
+		History:      historyConfig,
+		Prices:       table,
+		Budget:       budgetConfig,
+		OnText:       textHandler,
 	}
 	return autosweManager, func() {
 		cleanup2()
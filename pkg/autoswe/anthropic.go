@@ -0,0 +1,165 @@
+package autoswe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	anthropic "github.com/anthropics/anthropic-sdk-go"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"go.uber.org/zap"
+)
+
+// DefaultAnthropicMaxTokens is the longest response requested from Claude by default
+const DefaultAnthropicMaxTokens = 8192
+
+// AnthropicModel is an AgentModel backed by Claude
+type AnthropicModel struct {
+	Client *anthropic.Client
+
+	// Model is the Claude model to use
+	// Default: anthropic.ModelClaude3_7SonnetLatest
+	Model anthropic.Model
+
+	// MaxTokens is the longest response to request
+	// Default: DefaultAnthropicMaxTokens
+	MaxTokens int64
+}
+
+// ProvideAgentModel returns the model used to run tasks
+func ProvideAgentModel(client *anthropic.Client) AgentModel {
+	return &AnthropicModel{Client: client}
+}
+
+// Send implements AgentModel
+func (m *AnthropicModel) Send(ctx context.Context, req ModelRequest) (*ModelResponse, error) {
+	model := m.Model
+	if model == "" {
+		model = anthropic.ModelClaude3_7SonnetLatest
+	}
+
+	maxTokens := m.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultAnthropicMaxTokens
+	}
+
+	messages := make([]anthropic.MessageParam, 0, len(req.Messages))
+	for _, msg := range req.Messages {
+		param, err := toAnthropicMessage(msg)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, param)
+	}
+
+	tools := make([]anthropic.ToolUnionUnionParam, 0, len(req.Tools))
+	for _, tool := range req.Tools {
+		tools = append(tools, anthropic.ToolParam{
+			Name:        anthropic.F(tool.Name),
+			Description: anthropic.F(tool.Description),
+			InputSchema: anthropic.F(interface{}(tool.InputSchema)),
+		})
+	}
+
+	params := anthropic.MessageNewParams{
+		Model:     anthropic.F(model),
+		MaxTokens: anthropic.Int(maxTokens),
+		System: anthropic.F([]anthropic.TextBlockParam{
+			anthropic.NewTextBlock(req.System),
+		}),
+		Messages: anthropic.F(messages),
+		Tools:    anthropic.F(tools),
+	}
+
+	var message *anthropic.Message
+	var err error
+	if req.OnText != nil {
+		message, err = m.stream(ctx, params, req.OnText)
+	} else {
+		message, err = m.Client.Messages.New(ctx, params)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &ModelResponse{
+		Model: string(message.Model),
+		Usage: Usage{
+			InputTokens:  message.Usage.InputTokens,
+			OutputTokens: message.Usage.OutputTokens,
+		},
+	}
+
+	for _, block := range message.Content {
+		switch block := block.AsUnion().(type) {
+		case anthropic.TextBlock:
+			resp.Content = append(resp.Content, NewTextBlock(block.Text))
+		case anthropic.ToolUseBlock:
+			resp.Content = append(resp.Content, NewToolUseBlock(block.ID, block.Name, block.Input))
+		default:
+			log.Warn("Received unexpected block type", zap.Any("block", block))
+		}
+	}
+
+	return resp, nil
+}
+
+// stream requests a message with the streaming API, passing text deltas to onText as they
+// arrive and returning the message once it is complete
+func (m *AnthropicModel) stream(ctx context.Context, params anthropic.MessageNewParams, onText TextHandler) (*anthropic.Message, error) {
+	stream := m.Client.Messages.NewStreaming(ctx, params)
+	defer stream.Close()
+
+	message := &anthropic.Message{}
+	wroteText := false
+	for stream.Next() {
+		event := stream.Current()
+		if err := message.Accumulate(event); err != nil {
+			return nil, fmt.Errorf("failed to read streamed message: %w", err)
+		}
+
+		switch event := event.AsUnion().(type) {
+		case anthropic.ContentBlockStartEvent:
+			// Separate consecutive text blocks, as they are when the message is printed whole
+			if event.ContentBlock.Type == anthropic.ContentBlockStartEventContentBlockTypeText && wroteText {
+				onText("\n")
+			}
+		case anthropic.ContentBlockDeltaEvent:
+			if delta, ok := event.Delta.AsUnion().(anthropic.TextDelta); ok && delta.Text != "" {
+				onText(delta.Text)
+				wroteText = true
+			}
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// toAnthropicMessage converts a message to its Claude API form
+func toAnthropicMessage(msg Message) (anthropic.MessageParam, error) {
+	blocks := make([]anthropic.ContentBlockParamUnion, 0, len(msg.Content))
+	for _, block := range msg.Content {
+		switch block.Type {
+		case BlockText:
+			blocks = append(blocks, anthropic.NewTextBlock(block.Text))
+		case BlockToolUse:
+			input := block.Input
+			if len(input) == 0 {
+				input = json.RawMessage(`{}`)
+			}
+			blocks = append(blocks, anthropic.NewToolUseBlockParam(block.ID, block.Name, input))
+		case BlockToolResult:
+			blocks = append(blocks, anthropic.NewToolResultBlock(block.ToolUseID, block.Content, block.IsError))
+		default:
+			return anthropic.MessageParam{}, fmt.Errorf("unsupported content block type %q", block.Type)
+		}
+	}
+
+	return anthropic.MessageParam{
+		Role:    anthropic.F(anthropic.MessageParamRole(msg.Role)),
+		Content: anthropic.F(blocks),
+	}, nil
+}
@@ -0,0 +1,107 @@
+package autoswe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	anthropic "github.com/anthropics/anthropic-sdk-go"
+	anthropicoption "github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/tools/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// streamEvents serves each response as a stream of server-sent events
+func streamEvents(t *testing.T, responses ...[]string) *AnthropicModel {
+	t.Helper()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Less(t, requests, len(responses), "unexpected request")
+
+		var req struct {
+			Stream bool `json:"stream"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.True(t, req.Stream, "expected a streaming request")
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range responses[requests] {
+			var typed struct {
+				Type string `json:"type"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(event), &typed))
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", typed.Type, event)
+		}
+		requests++
+	}))
+	t.Cleanup(server.Close)
+
+	client := anthropic.NewClient(
+		anthropicoption.WithBaseURL(server.URL),
+		anthropicoption.WithAPIKey("test"),
+		anthropicoption.WithMaxRetries(0),
+	)
+	return &AnthropicModel{Client: client}
+}
+
+const streamStart = `{"type":"message_start","message":{"id":"msg","type":"message","role":"assistant","model":"claude-3-7-sonnet-latest","content":[],"usage":{"input_tokens":10,"output_tokens":1}}}`
+
+func TestExecuteTaskStreaming(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	model := streamEvents(t,
+		[]string{
+			streamStart,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Let me "}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"look."}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"call-1","name":"fs_fetch","input":{}}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"path\":"}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"main.go\"}"}}`,
+			`{"type":"content_block_stop","index":1}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":5}}`,
+			`{"type":"message_stop"}`,
+		},
+		[]string{
+			streamStart,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Looks fine."}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":5}}`,
+			`{"type":"message_stop"}`,
+		},
+	)
+
+	var streamed strings.Builder
+	var toolInput json.RawMessage
+	manager := &Manager{
+		Model:        model,
+		ToolRegistry: &registry.ToolRegistry{},
+		OnText: func(delta string) {
+			streamed.WriteString(delta)
+		},
+		executeTool: func(_ context.Context, call registry.ToolCall) (string, error) {
+			// Tool calls only run once their input has been received in full
+			toolInput = call.Input
+			assert.Equal(t, "Let me look.\n", streamed.String())
+			return "package main", nil
+		},
+	}
+
+	result, err := manager.ExecuteTask(context.Background(), "check main.go")
+	require.NoError(t, err)
+
+	assert.Equal(t, "Let me look.\nLooks fine.\n", streamed.String())
+	assert.JSONEq(t, `{"path":"main.go"}`, string(toolInput))
+	assert.Equal(t, "Looks fine.", result.Response)
+	assert.Equal(t, int64(20), result.Usage.InputTokens)
+	assert.Equal(t, int64(10), result.Usage.OutputTokens)
+}
@@ -0,0 +1,35 @@
+package autoswe
+
+import "fmt"
+
+// BudgetConfig limits how much work a task may do, so that a task that keeps calling tools
+// can't run forever. Zero values disable the iteration and cost limits.
+type BudgetConfig struct {
+	// MaxIterations is the most requests a task, or each task it delegates to, may make to
+	// the model
+	MaxIterations int
+
+	// MaxCostUSD is the most a task may spend, including the tasks it delegates to. The
+	// request that crosses the limit is allowed to finish, so a task can overspend by up to
+	// the cost of one request.
+	MaxCostUSD float64
+
+	// MaxDelegationDepth is how many levels deep tasks may delegate to sub-tasks. Tasks at
+	// the deepest level aren't offered the delegate_task tool.
+	// Default: DefaultMaxDelegationDepth
+	MaxDelegationDepth int
+}
+
+// exhausted returns why a task that has made the given number of requests and spent the
+// given amount must stop, or an empty string if it can continue
+func (b BudgetConfig) exhausted(iterations int, spentUSD float64) string {
+	if b.MaxIterations > 0 && iterations >= b.MaxIterations {
+		return fmt.Sprintf("reached the limit of %d iterations", b.MaxIterations)
+	}
+
+	if b.MaxCostUSD > 0 && spentUSD >= b.MaxCostUSD {
+		return fmt.Sprintf("spent $%.2f of the $%.2f limit", spentUSD, b.MaxCostUSD)
+	}
+
+	return ""
+}
@@ -0,0 +1,105 @@
+package autoswe
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/pricing"
+	"github.com/russellhaering/autoswe/pkg/tools/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteTaskBudget(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	runaway := []string{
+		`[{"type":"text","text":"Checking a.go"},{"type":"tool_use","id":"call-1","name":"fs_fetch","input":{"path":"a.go"}}]`,
+		`[{"type":"text","text":"Checking b.go"},{"type":"tool_use","id":"call-2","name":"fs_fetch","input":{"path":"b.go"}}]`,
+		`[{"type":"text","text":"Checking c.go"},{"type":"tool_use","id":"call-3","name":"fs_fetch","input":{"path":"c.go"}}]`,
+	}
+
+	tests := []struct {
+		name     string
+		budget   BudgetConfig
+		requests int
+		reason   string
+		response string
+	}{
+		{
+			name:     "iterations",
+			budget:   BudgetConfig{MaxIterations: 2},
+			requests: 2,
+			reason:   "reached the limit of 2 iterations",
+			response: "Checking b.go",
+		},
+		{
+			// Each request costs 10*$3000/M + 5*$20000/M = $0.13
+			name:     "cost",
+			budget:   BudgetConfig{MaxCostUSD: 0.05},
+			requests: 1,
+			reason:   "spent $0.13 of the $0.05 limit",
+			response: "Checking a.go",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := stubAnthropic(t, runaway...)
+			manager := &Manager{
+				Model:        stub.Model,
+				ToolRegistry: &registry.ToolRegistry{},
+				Budget:       tt.budget,
+				Prices:       pricing.Table{"claude": {InputPerMTok: 3000, OutputPerMTok: 20000}},
+				executeTool: func(context.Context, registry.ToolCall) (string, error) {
+					return "ok", nil
+				},
+			}
+
+			result, err := manager.ExecuteTask(context.Background(), "check everything")
+			require.NoError(t, err)
+
+			assert.Len(t, stub.Requests, tt.requests)
+			assert.Equal(t, tt.reason, result.BudgetExhausted)
+			assert.Len(t, result.ToolCalls, tt.requests, "the work done so far should be reported")
+			assert.Equal(t, tt.response, result.Response)
+		})
+	}
+}
+
+func TestDelegationDepthLimit(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	delegate := json.RawMessage(`{"task":"go deeper"}`)
+	model := &scriptedModel{
+		turns: [][]ContentBlock{
+			{NewToolUseBlock("call-1", "delegate_task", delegate)},
+			// The sub-task is at the limit, so delegating again fails
+			{NewToolUseBlock("call-2", "delegate_task", delegate)},
+			{NewTextBlock("Did it myself")},
+			{NewTextBlock("Done")},
+		},
+	}
+
+	manager := &Manager{
+		Model:        model,
+		ToolRegistry: &registry.ToolRegistry{},
+		Budget:       BudgetConfig{MaxDelegationDepth: 1},
+	}
+
+	result, err := manager.ExecuteTask(context.Background(), "dig in")
+	require.NoError(t, err)
+	assert.Equal(t, "Done", result.Response)
+
+	require.Len(t, model.requests, 4)
+	assert.Equal(t, "delegate_task", model.requests[0].Tools[0].Name)
+	assert.Empty(t, model.requests[1].Tools, "the sub-task should not be offered delegate_task")
+
+	refused := model.requests[2].Messages[2].Content[0]
+	assert.True(t, refused.IsError)
+	assert.Contains(t, refused.Content, "delegation depth limit of 1 reached")
+
+	assert.Equal(t, NewToolResultBlock("call-1", "Did it myself", false), model.requests[3].Messages[2].Content[0])
+}
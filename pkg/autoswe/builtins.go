@@ -3,43 +3,92 @@ package autoswe
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"strings"
 
-	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/invopop/jsonschema"
 	"github.com/russellhaering/autoswe/pkg/log"
 	"github.com/russellhaering/autoswe/pkg/tools/registry"
+	"github.com/russellhaering/autoswe/pkg/tools/toolerr"
 	"go.uber.org/zap"
+
+	_ "embed"
 )
 
+//go:embed delegate.md
+var delegateTaskDescription string
+
+// DefaultMaxDelegationDepth is how many levels deep tasks may delegate when
+// BudgetConfig.MaxDelegationDepth is unset
+const DefaultMaxDelegationDepth = 3
+
 type DelegateTaskInput struct {
 	Task string `json:"task" jsonschema_description:"Detailed description of the task to delegate"`
 }
 
+// delegateTask runs a sub-task with a fresh conversation, returning its final response. To
+// stop a model from delegating forever, tasks may only be nested as deep as the budget's
+// MaxDelegationDepth.
 func (m *Manager) delegateTask(ctx context.Context, toolCall registry.ToolCall) (string, error) {
 	var input DelegateTaskInput
 
 	if err := json.Unmarshal(toolCall.Input, &input); err != nil {
-		return "", fmt.Errorf("failed to unmarshal delegate task input: %w", err)
+		return "", toolerr.New(toolerr.InvalidInput, "failed to unmarshal delegate task input: %w", err)
+	}
+
+	if strings.TrimSpace(input.Task) == "" {
+		return "", toolerr.New(toolerr.InvalidInput, "task is required")
+	}
+
+	parent := parentTaskScope(ctx)
+	if parent != nil && !m.canDelegate(parent.depth) {
+		return "", toolerr.New(toolerr.InvalidInput, "delegation depth limit of %d reached, complete the task yourself", m.maxDelegationDepth())
 	}
 
 	log.Info("delegating task", zap.String("task", input.Task))
 
-	return m.ExecuteTask(ctx, input.Task)
+	result, err := m.ExecuteTask(ctx, input.Task)
+	if err != nil {
+		return "", err
+	}
+
+	if parent != nil {
+		parent.usage.merge(result.Usage)
+	}
+
+	return result.Format(VerbosityNormal), nil
+}
+
+// maxDelegationDepth returns how deep tasks may be nested
+func (m *Manager) maxDelegationDepth() int {
+	if m.Budget.MaxDelegationDepth > 0 {
+		return m.Budget.MaxDelegationDepth
+	}
+	return DefaultMaxDelegationDepth
+}
+
+// canDelegate reports whether a task at the given depth, where the top-level task is at
+// depth 0, may delegate
+func (m *Manager) canDelegate(depth int) bool {
+	return depth < m.maxDelegationDepth()
 }
 
-func (m *Manager) getToolParams() []anthropic.ToolUnionUnionParam {
-	toolParams := m.ToolRegistry.GetToolParams()
+// toolDefinitions describes the registry's tools along with the built-in tools available to a
+// task at the given depth
+func (m *Manager) toolDefinitions(depth int) []registry.ToolDefinition {
+	tools := m.ToolRegistry.ToolDefinitions()
+	if !m.canDelegate(depth) {
+		return tools
+	}
 
 	reflector := jsonschema.Reflector{
 		DoNotReference: true, // Embed the schema directly instead of using $defs
 	}
 
-	toolParams = append(toolParams, anthropic.ToolParam{
-		Name:        anthropic.String("delegate_task"),
-		Description: anthropic.String("Delegate a task to an expert assistant"),
-		InputSchema: anthropic.F(interface{}(reflector.Reflect(DelegateTaskInput{}))),
+	tools = append(tools, registry.ToolDefinition{
+		Name:        "delegate_task",
+		Description: delegateTaskDescription,
+		InputSchema: reflector.Reflect(DelegateTaskInput{}),
 	})
 
-	return toolParams
+	return tools
 }
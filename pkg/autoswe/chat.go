@@ -0,0 +1,50 @@
+package autoswe
+
+import (
+	"context"
+	"fmt"
+)
+
+// Chat is a task steered by the user, who answers each of its responses with a follow-up.
+// Every turn continues the same conversation, so the model keeps the context it has built up.
+type Chat struct {
+	manager      *Manager
+	contextFiles []string
+
+	// Task is the conversation so far, nil until the first message is sent
+	Task *Task
+
+	// Usage is the total usage of every turn
+	Usage TokenUsage
+}
+
+// NewChat starts a chat. The contents of any contextFiles are included in the first message.
+func (m *Manager) NewChat(contextFiles ...string) *Chat {
+	return &Chat{
+		manager:      m,
+		contextFiles: contextFiles,
+	}
+}
+
+// Send adds a message from the user and runs the task until the model responds without
+// calling any tools. The budget applies to each turn separately.
+func (c *Chat) Send(ctx context.Context, message string) (*TaskResult, error) {
+	if c.Task == nil {
+		task, err := c.manager.PrepareTask(message, c.contextFiles...)
+		if err != nil {
+			return nil, err
+		}
+		c.Task = task
+	} else {
+		c.Task.AddMessage(message)
+	}
+
+	result, err := c.manager.RunTask(ctx, c.Task)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run turn: %w", err)
+	}
+
+	c.Usage.merge(result.Usage)
+
+	return result, nil
+}
@@ -0,0 +1,51 @@
+package autoswe
+
+import (
+	"context"
+	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/tools/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChat(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	stub := stubAnthropic(t,
+		`[{"type":"tool_use","id":"call-1","name":"fs_fetch","input":{"path":"main.go"}}]`,
+		`[{"type":"text","text":"main.go starts the server"}]`,
+		`[{"type":"text","text":"It listens on :8080"}]`,
+	)
+	manager := &Manager{
+		Model:        stub.Model,
+		ToolRegistry: &registry.ToolRegistry{},
+		executeTool: func(context.Context, registry.ToolCall) (string, error) {
+			return "package main", nil
+		},
+	}
+
+	chat := manager.NewChat()
+
+	result, err := chat.Send(context.Background(), "What does main.go do?")
+	require.NoError(t, err)
+	assert.Equal(t, "main.go starts the server", result.Response)
+
+	result, err = chat.Send(context.Background(), "Which port?")
+	require.NoError(t, err)
+	assert.Equal(t, "It listens on :8080", result.Response)
+	assert.Equal(t, 1, result.Usage.Requests, "each result should report only its own turn")
+
+	// The follow-up continues the conversation, rather than starting a new one
+	require.Len(t, stub.Requests, 3)
+	followUp := stub.Requests[2].Messages
+	require.Len(t, followUp, 5)
+	assert.Equal(t, "What does main.go do?", followUp[0].Content[0].Text)
+	assert.Equal(t, "main.go starts the server", followUp[3].Content[0].Text)
+	assert.Equal(t, "user", followUp[4].Role)
+	assert.Equal(t, "Which port?", followUp[4].Content[0].Text)
+
+	assert.Equal(t, 3, chat.Usage.Requests)
+	assert.Equal(t, int64(30), chat.Usage.InputTokens)
+}
@@ -0,0 +1,119 @@
+package autoswe
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// DefaultElideMinBytes is the smallest tool result that will be elided when no size is configured
+const DefaultElideMinBytes = 1024
+
+// HistoryConfig controls how much of a task's conversation history is retained verbatim
+type HistoryConfig struct {
+	// ElideAfterTurns replaces large tool results with a short placeholder once this many
+	// assistant turns have followed them. Zero disables eliding.
+	ElideAfterTurns int
+
+	// ElideMinBytes is the size at which a tool result becomes eligible for eliding.
+	// Default: DefaultElideMinBytes
+	ElideMinBytes int
+}
+
+// elideToolResults replaces the content of old, large tool results with a placeholder.
+// The tool_result blocks themselves are kept so that every tool_use remains paired with
+// its result.
+func elideToolResults(messages []Message, config HistoryConfig) {
+	if config.ElideAfterTurns <= 0 {
+		return
+	}
+
+	minBytes := config.ElideMinBytes
+	if minBytes <= 0 {
+		minBytes = DefaultElideMinBytes
+	}
+
+	toolUses := make(map[string]ContentBlock)
+	assistantTurns := 0
+	for _, msg := range messages {
+		if msg.Role != RoleAssistant {
+			continue
+		}
+
+		assistantTurns++
+		for _, block := range msg.Content {
+			if block.Type == BlockToolUse {
+				toolUses[block.ID] = block
+			}
+		}
+	}
+
+	turnsSeen := 0
+	for i, msg := range messages {
+		if msg.Role == RoleAssistant {
+			turnsSeen++
+			continue
+		}
+
+		// Results are answered by the assistant turn that follows them, so a result
+		// followed by N assistant turns is N turns old
+		if assistantTurns-turnsSeen < config.ElideAfterTurns {
+			break
+		}
+
+		var blocks []ContentBlock
+		for j, block := range msg.Content {
+			if block.Type != BlockToolResult {
+				continue
+			}
+
+			size := len(block.Content)
+			if size < minBytes {
+				continue
+			}
+
+			// Copy the blocks rather than modifying them in place, since they may be shared
+			// with a clone of the conversation
+			if blocks == nil {
+				blocks = make([]ContentBlock, len(msg.Content))
+				copy(blocks, msg.Content)
+			}
+
+			placeholder := elidedPlaceholder(toolUses[block.ToolUseID], size)
+			blocks[j] = NewToolResultBlock(block.ToolUseID, placeholder, block.IsError)
+		}
+
+		if blocks != nil {
+			messages[i].Content = blocks
+		}
+	}
+}
+
+// elidedPlaceholder describes an elided tool result, e.g. "[output of fs_fetch foo.go, 2.3KB, elided]"
+func elidedPlaceholder(toolUse ContentBlock, size int) string {
+	name := toolUse.Name
+	if name == "" {
+		name = "tool"
+	}
+
+	if path := toolInputPath(toolUse.Input); path != "" {
+		name += " " + path
+	}
+
+	return fmt.Sprintf("[output of %s, %.1fKB, elided]", name, float64(size)/1024)
+}
+
+// toolInputPath returns the path argument of a tool call, if it has one
+func toolInputPath(input json.RawMessage) string {
+	if len(input) == 0 {
+		return ""
+	}
+
+	var args struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal(input, &args); err != nil {
+		return ""
+	}
+
+	return args.Path
+}
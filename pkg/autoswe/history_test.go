@@ -0,0 +1,71 @@
+package autoswe
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestElideToolResults(t *testing.T) {
+	large := strings.Repeat("x", 2048)
+
+	messages := []Message{
+		NewUserMessage(NewTextBlock("do the thing")),
+	}
+	for turn := 1; turn <= 4; turn++ {
+		id := fmt.Sprintf("call-%d", turn)
+		input := json.RawMessage(fmt.Sprintf(`{"path":"file%d.go"}`, turn))
+		messages = append(messages,
+			NewAssistantMessage(NewToolUseBlock(id, "fs_fetch", input)),
+			NewUserMessage(
+				NewToolResultBlock(id, large, false),
+			),
+		)
+	}
+	// A small result in an old turn is left alone
+	messages[2].Content = append(messages[2].Content, NewToolResultBlock("small", "ok", false))
+
+	elideToolResults(messages, HistoryConfig{ElideAfterTurns: 2})
+
+	require.Len(t, messages, 9)
+	assert.Equal(t, "[output of fs_fetch file1.go, 2.0KB, elided]", resultText(t, messages[2], 0))
+	assert.Equal(t, "ok", resultText(t, messages[2], 1))
+	assert.Equal(t, "[output of fs_fetch file2.go, 2.0KB, elided]", resultText(t, messages[4], 0))
+	assert.Equal(t, large, resultText(t, messages[6], 0))
+	assert.Equal(t, large, resultText(t, messages[8], 0))
+
+	// Every tool_use is still paired with a tool_result
+	for i := 1; i < len(messages); i += 2 {
+		use := messages[i].Content[0]
+		require.Equal(t, BlockToolUse, use.Type)
+		result := messages[i+1].Content[0]
+		require.Equal(t, BlockToolResult, result.Type)
+		assert.Equal(t, use.ID, result.ToolUseID)
+	}
+}
+
+func TestElideToolResultsDisabled(t *testing.T) {
+	large := strings.Repeat("x", 2048)
+	messages := []Message{
+		NewAssistantMessage(NewToolUseBlock("call", "test", json.RawMessage(`{}`))),
+		NewUserMessage(NewToolResultBlock("call", large, false)),
+		NewAssistantMessage(NewTextBlock("done")),
+	}
+
+	elideToolResults(messages, HistoryConfig{})
+
+	assert.Equal(t, large, resultText(t, messages[1], 0))
+}
+
+func resultText(t *testing.T, msg Message, idx int) string {
+	t.Helper()
+
+	result := msg.Content[idx]
+	require.Equal(t, BlockToolResult, result.Type)
+
+	return result.Content
+}
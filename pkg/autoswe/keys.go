@@ -0,0 +1,103 @@
+package autoswe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
+)
+
+const (
+	// AnthropicKeyEnv is the environment variable holding the Anthropic API key
+	AnthropicKeyEnv = "ANTHROPIC_API_KEY"
+
+	// GeminiKeyEnv is the environment variable holding the Gemini API key
+	GeminiKeyEnv = "GOOGLE_API_KEY"
+)
+
+// KeyCheckConfig selects which API keys are checked before any other work is done, so that a
+// missing or invalid key is reported up front rather than by the first call that needs it
+type KeyCheckConfig struct {
+	// RequireAnthropic is set when Claude will be used, to run tasks
+	RequireAnthropic bool
+
+	// RequireGemini is set when Gemini will be used, for indexing and codebase queries
+	RequireGemini bool
+
+	// Probe makes a cheap call with each required key, to check that it is accepted
+	Probe bool
+}
+
+// KeyError reports an API key that is missing or could not be verified
+type KeyError struct {
+	// Env is the environment variable that normally holds the key
+	Env string
+
+	// Err is why the key was rejected, or nil if it is missing
+	Err error
+}
+
+func (e *KeyError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("missing %s", e.Env)
+	}
+	return fmt.Sprintf("invalid %s: %v", e.Env, e.Err)
+}
+
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+// checkAnthropicKey checks the key used by client, if the config requires it, by listing a
+// single model
+func checkAnthropicKey(ctx context.Context, client *anthropic.Client, key AnthropicAPIKey, config KeyCheckConfig) error {
+	if !config.RequireAnthropic {
+		return nil
+	}
+
+	if key == "" {
+		return &KeyError{Env: AnthropicKeyEnv}
+	}
+
+	if config.Probe {
+		if _, err := client.Models.List(ctx, anthropic.ModelListParams{Limit: anthropic.F(int64(1))}); err != nil {
+			return &KeyError{Env: AnthropicKeyEnv, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// checkGeminiKey checks the key used by client, if the config requires it, by listing a
+// single model. A missing key is caught before the client is created.
+func checkGeminiKey(ctx context.Context, client *genai.Client, config KeyCheckConfig) error {
+	if !config.RequireGemini {
+		return nil
+	}
+
+	if config.Probe {
+		if _, err := client.ListModels(ctx).Next(); err != nil && !errors.Is(err, iterator.Done) {
+			return &KeyError{Env: GeminiKeyEnv, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// CheckKeys checks the API keys required by the config, without doing any other work
+func CheckKeys(ctx context.Context, config Config) error {
+	if _, err := ProvideAnthropic(ctx, config.AnthropicAPIKey, config.Keys); err != nil {
+		return err
+	}
+
+	_, cleanup, err := ProvideGemini(ctx, config.GeminiAPIKey, config.Keys)
+	if err != nil {
+		return err
+	}
+	cleanup()
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package autoswe
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	anthropic "github.com/anthropics/anthropic-sdk-go"
+	anthropicoption "github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckAnthropicKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/models", r.URL.Path)
+
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("X-Api-Key") != "valid" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":[],"has_more":false}`))
+	}))
+	defer server.Close()
+
+	check := func(key AnthropicAPIKey, config KeyCheckConfig) error {
+		client := anthropic.NewClient(
+			anthropicoption.WithBaseURL(server.URL),
+			anthropicoption.WithAPIKey(string(key)),
+			anthropicoption.WithMaxRetries(0),
+		)
+		return checkAnthropicKey(context.Background(), client, key, config)
+	}
+
+	required := KeyCheckConfig{RequireAnthropic: true, Probe: true}
+	require.NoError(t, check("valid", required))
+	require.NoError(t, check("", KeyCheckConfig{Probe: true}), "keys that aren't required aren't checked")
+
+	var keyErr *KeyError
+	require.ErrorAs(t, check("", required), &keyErr)
+	assert.Equal(t, "missing ANTHROPIC_API_KEY", keyErr.Error())
+
+	err := check("wrong", required)
+	require.ErrorAs(t, err, &keyErr)
+	assert.ErrorContains(t, err, "invalid ANTHROPIC_API_KEY")
+	assert.ErrorContains(t, err, "401")
+}
+
+func TestProvideGeminiMissingKey(t *testing.T) {
+	_, _, err := ProvideGemini(context.Background(), "", KeyCheckConfig{RequireGemini: true})
+
+	var keyErr *KeyError
+	require.ErrorAs(t, err, &keyErr)
+	assert.Equal(t, GeminiKeyEnv, keyErr.Env)
+}
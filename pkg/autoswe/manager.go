@@ -2,6 +2,7 @@ package autoswe
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 
 	"github.com/anthropics/anthropic-sdk-go"
@@ -10,7 +11,12 @@ import (
 	"github.com/google/wire"
 	"github.com/russellhaering/autoswe/pkg/index"
 	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/pricing"
 	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/russellhaering/autoswe/pkg/retry"
+	"github.com/russellhaering/autoswe/pkg/tools/astgrep"
+	"github.com/russellhaering/autoswe/pkg/tools/fs"
+	"github.com/russellhaering/autoswe/pkg/tools/git"
 	"github.com/russellhaering/autoswe/pkg/tools/registry"
 	"go.uber.org/zap"
 	googleoption "google.golang.org/api/option"
@@ -22,7 +28,14 @@ type (
 	RootDir         string
 )
 
-func ProvideGemini(ctx context.Context, geminiAPIKey GeminiAPIKey) (*genai.Client, func(), error) {
+// ProvideGemini creates the Gemini client, after checking its key if the key check config
+// requires it
+func ProvideGemini(ctx context.Context, geminiAPIKey GeminiAPIKey, keys KeyCheckConfig) (*genai.Client, func(), error) {
+	// Without a key, the client would look for other credentials and fail confusingly
+	if keys.RequireGemini && geminiAPIKey == "" {
+		return nil, nil, &KeyError{Env: GeminiKeyEnv}
+	}
+
 	client, err := genai.NewClient(ctx, googleoption.WithAPIKey(string(geminiAPIKey)))
 	if err != nil {
 		return nil, nil, err
@@ -35,11 +48,18 @@ func ProvideGemini(ctx context.Context, geminiAPIKey GeminiAPIKey) (*genai.Clien
 		}
 	}
 
+	if err := checkGeminiKey(ctx, client, keys); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
 	return client, cleanup, nil
 }
 
-func ProvideAnthropic(_ context.Context, anthropicAPIKey AnthropicAPIKey) *anthropic.Client {
-	return anthropic.NewClient(
+// ProvideAnthropic creates the Claude client, after checking its key if the key check config
+// requires it
+func ProvideAnthropic(ctx context.Context, anthropicAPIKey AnthropicAPIKey, keys KeyCheckConfig) (*anthropic.Client, error) {
+	client := anthropic.NewClient(
 		anthropicoption.WithAPIKey(string(anthropicAPIKey)),
 		anthropicoption.WithMiddleware(func(req *http.Request, next anthropicoption.MiddlewareNext) (*http.Response, error) {
 			resp, err := next(req)
@@ -58,14 +78,20 @@ func ProvideAnthropic(_ context.Context, anthropicAPIKey AnthropicAPIKey) *anthr
 		// We need lots of retries to deal with rate limits - we certainly don't want to give up
 		anthropicoption.WithMaxRetries(20),
 	)
+
+	if err := checkAnthropicKey(ctx, client, anthropicAPIKey, keys); err != nil {
+		return nil, err
+	}
+
+	return client, nil
 }
 
 func ProvideRepoFS(rootDir RootDir) *repo.RepositoryFS {
 	return repo.NewRepoFS(string(rootDir))
 }
 
-func ProvideFilteredFS(_ context.Context, rfs *repo.RepositoryFS) (repo.FilteredFS, error) {
-	return rfs.Filter()
+func ProvideFilteredFS(_ context.Context, rfs *repo.RepositoryFS, config Config) (repo.FilteredFS, error) {
+	return rfs.Filter(config.IncludePaths...)
 }
 
 func ProvideIndexer(ctx context.Context, gemini *genai.Client, rfs repo.FilteredFS, config Config) (*index.Indexer, func(), error) {
@@ -74,20 +100,20 @@ func ProvideIndexer(ctx context.Context, gemini *genai.Client, rfs repo.Filtered
 		index.RepoNamespace: rfs,
 	}
 
-	// Add extra context files if provided
+	// Add extra context files and directories if provided
 	if len(config.ExtraContextPaths) > 0 {
-		log.Info("Adding extra context files", zap.Strings("paths", config.ExtraContextPaths))
+		log.Info("Adding extra context", zap.Strings("paths", config.ExtraContextPaths))
 
 		// Create virtual filesystem
 		virtualFS := repo.NewVirtualFS()
 
-		// Add each file to the virtual filesystem
+		// Add each file or directory to the virtual filesystem
 		for _, path := range config.ExtraContextPaths {
 			if err := virtualFS.AddFile(path); err != nil {
-				log.Warn("Failed to add extra context file", zap.String("path", path), zap.Error(err))
+				log.Warn("Failed to add extra context", zap.String("path", path), zap.Error(err))
 				continue
 			}
-			log.Debug("Added extra context file", zap.String("path", path))
+			log.Debug("Added extra context", zap.String("path", path))
 		}
 
 		// Create filtered virtual filesystem
@@ -100,11 +126,28 @@ func ProvideIndexer(ctx context.Context, gemini *genai.Client, rfs repo.Filtered
 		fsContextMap[index.ExtraContextNamespace] = filteredVirtualFS
 	}
 
-	indexer, err := index.NewIndexer(ctx, gemini, fsContextMap)
+	indexConfig := config.Index
+	indexConfig.Retry = config.Retry
+
+	embedder, generator, err := index.NewModels(gemini, indexConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	store, err := index.OpenStore(indexConfig, index.NewEmbeddingFunc(embedder, config.Retry))
 	if err != nil {
 		return nil, nil, err
 	}
 
+	indexer := index.NewIndexer(generator, store, fsContextMap, indexConfig)
+
+	if !config.SkipIndexUpdate {
+		if err := indexer.UpdateIndex(ctx); err != nil {
+			indexer.Close()
+			return nil, nil, fmt.Errorf("failed to update index: %w", err)
+		}
+	}
+
 	cleanup := func() {
 		err := indexer.Close()
 		if err != nil {
@@ -120,16 +163,72 @@ type Config struct {
 	AnthropicAPIKey   AnthropicAPIKey
 	RootDir           RootDir
 	ExtraContextPaths []string
+
+	// IncludePaths are paths or globs that are always indexed and readable, even if the
+	// ignore rules would exclude them
+	IncludePaths []string
+
+	// SkipIndexUpdate disables the index update that normally runs on startup
+	SkipIndexUpdate bool
+
+	// Index configures indexing and querying
+	Index index.Config
+
+	// CommitIdentity sets the default author identity and signing behavior for commits
+	CommitIdentity git.CommitIdentity
+
+	// ASTGrepMode selects whether ast-grep runs from a local binary or in Docker
+	ASTGrepMode astgrep.Mode
+
+	// Grep limits the files searched by the grep tool
+	Grep fs.GrepConfig
+
+	// Fetch limits the content returned by the fetch tool
+	Fetch fs.FetchConfig
+
+	// Patch configures the AI fallback used when a patch can't be applied directly
+	Patch fs.PatchConfig
+
+	// History controls how much of a task's conversation is retained verbatim
+	History HistoryConfig
+
+	// Tools selects which tools are available to the AI
+	Tools registry.ToolsConfig
+
+	// Budget limits the work a task may do before it is stopped
+	Budget BudgetConfig
+
+	// Prices are used to report the cost of tasks
+	// Default: pricing.Defaults
+	Prices pricing.Table
+
+	// OnText, if set, receives the assistant's text as it is generated. Only the top-level
+	// task's text is streamed, not that of the tasks it delegates to.
+	OnText TextHandler
+
+	// Keys selects which API keys are checked before any other work is done
+	Keys KeyCheckConfig
+
+	// Retry controls how Gemini calls are retried when they are rate limited or fail with a
+	// server error
+	Retry retry.Config
 }
 
 // Manager handles centralized client instantiation and access
 type Manager struct {
-	GeminiClient    *genai.Client
-	AnthropicClient *anthropic.Client
-	RepoFS          *repo.RepositoryFS
-	FilteredFS      repo.FilteredFS
-	Indexer         *index.Indexer
-	ToolRegistry    *registry.ToolRegistry
+	GeminiClient *genai.Client
+	Model        AgentModel
+	RepoFS       *repo.RepositoryFS
+	FilteredFS   repo.FilteredFS
+	Indexer      *index.Indexer
+	ToolRegistry *registry.ToolRegistry
+	History      HistoryConfig
+	Prices       pricing.Table
+	Budget       BudgetConfig
+	OnText       TextHandler
+
+	// executeTool overrides registry tool execution, for testing
+	executeTool func(ctx context.Context, call registry.ToolCall) (string, error) `wire:"-"`
 }
 
 var ProvideManager = wire.Struct(new(Manager), "*")
@@ -141,9 +240,10 @@ func (m *Manager) Close() error {
 }
 
 var ProviderSet = wire.NewSet(
-	wire.FieldsOf(new(Config), "GeminiAPIKey", "AnthropicAPIKey", "RootDir", "ExtraContextPaths"),
+	wire.FieldsOf(new(Config), "GeminiAPIKey", "AnthropicAPIKey", "RootDir", "ExtraContextPaths", "CommitIdentity", "History", "ASTGrepMode", "Grep", "Fetch", "Patch", "Tools", "Retry", "Prices", "Budget", "OnText", "Keys"),
 	ProvideGemini,
 	ProvideAnthropic,
+	ProvideAgentModel,
 	ProvideRepoFS,
 	ProvideFilteredFS,
 	ProvideIndexer,
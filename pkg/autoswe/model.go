@@ -0,0 +1,112 @@
+package autoswe
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/russellhaering/autoswe/pkg/tools/registry"
+)
+
+// Role is the author of a message in a task's conversation
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+)
+
+// BlockType is the kind of content in a content block
+type BlockType string
+
+const (
+	BlockText       BlockType = "text"
+	BlockToolUse    BlockType = "tool_use"
+	BlockToolResult BlockType = "tool_result"
+)
+
+// ContentBlock is a piece of a message: text, a tool call made by the model, or the result
+// of a tool call. The fields used depend on the type.
+type ContentBlock struct {
+	Type BlockType `json:"type"`
+
+	// Text is set for text blocks
+	Text string `json:"text,omitempty"`
+
+	// ID, Name and Input are set for tool_use blocks
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// ToolUseID, Content and IsError are set for tool_result blocks
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+	IsError   bool   `json:"is_error,omitempty"`
+}
+
+// NewTextBlock creates a text block
+func NewTextBlock(text string) ContentBlock {
+	return ContentBlock{Type: BlockText, Text: text}
+}
+
+// NewToolUseBlock creates a block calling a tool
+func NewToolUseBlock(id, name string, input json.RawMessage) ContentBlock {
+	return ContentBlock{Type: BlockToolUse, ID: id, Name: name, Input: input}
+}
+
+// NewToolResultBlock creates a block holding the result of the tool call with the given ID
+func NewToolResultBlock(toolUseID, content string, isError bool) ContentBlock {
+	return ContentBlock{Type: BlockToolResult, ToolUseID: toolUseID, Content: content, IsError: isError}
+}
+
+// Message is a turn in a task's conversation
+type Message struct {
+	Role    Role           `json:"role"`
+	Content []ContentBlock `json:"content"`
+}
+
+// NewUserMessage creates a user message from the given blocks
+func NewUserMessage(blocks ...ContentBlock) Message {
+	return Message{Role: RoleUser, Content: blocks}
+}
+
+// NewAssistantMessage creates an assistant message from the given blocks
+func NewAssistantMessage(blocks ...ContentBlock) Message {
+	return Message{Role: RoleAssistant, Content: blocks}
+}
+
+// ModelRequest asks the model for the next assistant turn of a conversation
+type ModelRequest struct {
+	System   string
+	Messages []Message
+	Tools    []registry.ToolDefinition
+
+	// OnText, if set, asks for the response to be streamed, and receives its text as it is
+	// generated. Tool calls are only returned once the response is complete.
+	OnText TextHandler
+}
+
+// TextHandler receives text from the model as it is generated
+type TextHandler func(delta string)
+
+// ModelResponse is an assistant turn, along with the tokens it used
+type ModelResponse struct {
+	Content []ContentBlock
+	Usage   Usage
+
+	// Model is the name of the model that responded, used to price its usage
+	Model string
+}
+
+// Usage counts the tokens used by a request
+type Usage struct {
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+}
+
+// AgentModel is an LLM that drives tasks, by responding to a conversation with text and
+// tool calls. Implementations translate to and from their provider's API, so the task
+// loop doesn't depend on any one provider.
+type AgentModel interface {
+	// Send returns the next assistant turn. Models that can't stream may ignore req.OnText.
+	Send(ctx context.Context, req ModelRequest) (*ModelResponse, error)
+}
@@ -0,0 +1,103 @@
+package autoswe
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Verbosity controls how much of a task's result is shown
+type Verbosity string
+
+const (
+	// VerbosityQuiet shows only the last line of the final response, for scripting
+	VerbosityQuiet Verbosity = "quiet"
+	// VerbosityNormal shows the final response
+	VerbosityNormal Verbosity = "normal"
+	// VerbosityVerbose shows the final response followed by a summary of the tool calls made
+	VerbosityVerbose Verbosity = "verbose"
+)
+
+// ParseVerbosity parses a verbosity name, returning an error for unknown levels
+func ParseVerbosity(name string) (Verbosity, error) {
+	switch verbosity := Verbosity(name); verbosity {
+	case VerbosityQuiet, VerbosityNormal, VerbosityVerbose:
+		return verbosity, nil
+	case "":
+		return VerbosityNormal, nil
+	default:
+		return "", fmt.Errorf("unknown verbosity %q (expected %q, %q or %q)", name, VerbosityQuiet, VerbosityNormal, VerbosityVerbose)
+	}
+}
+
+// ToolCallSummary describes a tool call made while executing a task
+type ToolCallSummary struct {
+	Name  string
+	Path  string
+	Error bool
+}
+
+// TaskResult is the outcome of executing a task
+type TaskResult struct {
+	// Response is the final text response from the model
+	Response string
+
+	// ToolCalls are the tool calls made by the task, in order. Calls made by delegated
+	// tasks are not included.
+	ToolCalls []ToolCallSummary
+
+	// Usage is the tokens used by the task and the tasks it delegated to, and their cost
+	Usage TokenUsage
+
+	// BudgetExhausted is set to the reason the task was stopped early, if it ran out of
+	// budget. Response is then the model's latest text rather than a final answer.
+	BudgetExhausted string
+}
+
+// Format renders the result at the given verbosity
+func (r *TaskResult) Format(verbosity Verbosity) string {
+	response := strings.TrimSpace(r.Response)
+	if r.BudgetExhausted != "" {
+		response = strings.TrimSpace(fmt.Sprintf("Budget exhausted: %s\n\n%s", r.BudgetExhausted, response))
+	}
+
+	switch verbosity {
+	case VerbosityQuiet:
+		lines := strings.Split(response, "\n")
+		return strings.TrimSpace(lines[len(lines)-1])
+	case VerbosityVerbose:
+		return response + "\n\n" + r.formatToolCalls()
+	default:
+		return response
+	}
+}
+
+// FormatStreamed renders the parts of the result that aren't shown when the assistant's text
+// is streamed as it is generated: why the task stopped early, if it did, and the tool calls
+// at VerbosityVerbose. It is empty if there is nothing more to show.
+func (r *TaskResult) FormatStreamed(verbosity Verbosity) string {
+	var parts []string
+	if r.BudgetExhausted != "" {
+		parts = append(parts, "Budget exhausted: "+r.BudgetExhausted)
+	}
+	if verbosity == VerbosityVerbose {
+		parts = append(parts, r.formatToolCalls())
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// formatToolCalls lists the tool calls made by the task
+func (r *TaskResult) formatToolCalls() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Tool calls (%d):\n", len(r.ToolCalls))
+	for _, call := range r.ToolCalls {
+		sb.WriteString("  " + call.Name)
+		if call.Path != "" {
+			sb.WriteString(" " + call.Path)
+		}
+		if call.Error {
+			sb.WriteString(" (failed)")
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
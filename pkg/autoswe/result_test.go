@@ -0,0 +1,143 @@
+package autoswe
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	anthropic "github.com/anthropics/anthropic-sdk-go"
+	anthropicoption "github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/pricing"
+	"github.com/russellhaering/autoswe/pkg/tools/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubRequest is the part of a messages request checked by tests
+type stubRequest struct {
+	Messages []struct {
+		Role    string `json:"role"`
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	} `json:"messages"`
+}
+
+// anthropicStub is a fake Anthropic API that records the requests made to it
+type anthropicStub struct {
+	Client   *anthropic.Client
+	Model    AgentModel
+	Requests []stubRequest
+}
+
+// stubAnthropic serves the given assistant message contents in order, one per request
+func stubAnthropic(t *testing.T, contents ...string) *anthropicStub {
+	t.Helper()
+
+	stub := &anthropicStub{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Less(t, len(stub.Requests), len(contents), "unexpected request")
+		content := contents[len(stub.Requests)]
+
+		var req stubRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		stub.Requests = append(stub.Requests, req)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id":          "msg",
+			"type":        "message",
+			"role":        "assistant",
+			"model":       "claude-3-7-sonnet-latest",
+			"content":     json.RawMessage(content),
+			"stop_reason": "end_turn",
+			"usage":       map[string]int{"input_tokens": 10, "output_tokens": 5},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	stub.Client = anthropic.NewClient(
+		anthropicoption.WithBaseURL(server.URL),
+		anthropicoption.WithAPIKey("test"),
+		anthropicoption.WithMaxRetries(0),
+	)
+	stub.Model = &AnthropicModel{Client: stub.Client}
+
+	return stub
+}
+
+func TestExecuteTaskVerbosity(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	manager := &Manager{
+		Model: stubAnthropic(t,
+			`[{"type":"text","text":"Let me look."},{"type":"tool_use","id":"call-1","name":"fs_fetch","input":{"path":"main.go"}}]`,
+			`[{"type":"text","text":"I read main.go.\nEverything looks fine.\nStatus: done"}]`,
+		).Model,
+		ToolRegistry: &registry.ToolRegistry{},
+	}
+
+	result, err := manager.ExecuteTask(context.Background(), "check main.go")
+	require.NoError(t, err)
+
+	assert.Equal(t, []ToolCallSummary{{Name: "fs_fetch", Path: "main.go", Error: true}}, result.ToolCalls)
+
+	assert.Equal(t, "Status: done", result.Format(VerbosityQuiet))
+	assert.Equal(t, "I read main.go.\nEverything looks fine.\nStatus: done", result.Format(VerbosityNormal))
+	assert.Equal(t, "I read main.go.\nEverything looks fine.\nStatus: done\n\nTool calls (1):\n  fs_fetch main.go (failed)", result.Format(VerbosityVerbose))
+
+	// The streamed response isn't repeated
+	assert.Equal(t, "", result.FormatStreamed(VerbosityNormal))
+	assert.Equal(t, "Tool calls (1):\n  fs_fetch main.go (failed)", result.FormatStreamed(VerbosityVerbose))
+
+	result.BudgetExhausted = "cost limit reached"
+	assert.Equal(t, "Budget exhausted: cost limit reached", result.FormatStreamed(VerbosityNormal))
+}
+
+func TestParseVerbosity(t *testing.T) {
+	verbosity, err := ParseVerbosity("")
+	require.NoError(t, err)
+	assert.Equal(t, VerbosityNormal, verbosity)
+
+	verbosity, err = ParseVerbosity("quiet")
+	require.NoError(t, err)
+	assert.Equal(t, VerbosityQuiet, verbosity)
+
+	_, err = ParseVerbosity("loud")
+	assert.Error(t, err)
+}
+
+func TestExecuteTaskUsage(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	manager := &Manager{
+		Model: stubAnthropic(t,
+			`[{"type":"tool_use","id":"call-1","name":"delegate_task","input":{"task":"read main.go"}}]`,
+			`[{"type":"text","text":"main.go is fine"}]`,
+			`[{"type":"text","text":"Done"}]`,
+		).Model,
+		ToolRegistry: &registry.ToolRegistry{},
+		Prices:       pricing.Table{"claude-3-7-sonnet": {InputPerMTok: 3, OutputPerMTok: 15}},
+	}
+
+	result, err := manager.ExecuteTask(context.Background(), "check main.go")
+	require.NoError(t, err)
+
+	// The delegated task's request is included along with the task's own two
+	assert.Equal(t, 3, result.Usage.Requests)
+	assert.Equal(t, int64(30), result.Usage.InputTokens)
+	assert.Equal(t, int64(15), result.Usage.OutputTokens)
+	assert.InDelta(t, (30*3+15*15)/1e6, result.Usage.CostUSD, 1e-12)
+	assert.Empty(t, result.Usage.UnpricedModels)
+}
+
+func TestTokenUsageUnpriced(t *testing.T) {
+	var usage TokenUsage
+	usage.add(pricing.Defaults, "gpt-4o", Usage{InputTokens: 100})
+	assert.Equal(t, []string{"gpt-4o"}, usage.UnpricedModels)
+	assert.Zero(t, usage.CostUSD)
+}
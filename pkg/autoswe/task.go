@@ -2,112 +2,260 @@ package autoswe
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
 
-	anthropic "github.com/anthropics/anthropic-sdk-go"
 	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/pricing"
 	"github.com/russellhaering/autoswe/pkg/prompts"
 	"github.com/russellhaering/autoswe/pkg/tools/registry"
 	"go.uber.org/zap"
 )
 
+// MaxContextFileBytes is the maximum total size of the files included in a task's first message
+const MaxContextFileBytes = 256 * 1024
+
 // Task represents a single task with its conversation context
 type Task struct {
 	SystemPrompt string
 	Description  string
-	Messages     []anthropic.MessageParam
+	Messages     []Message
+
+	// TranscriptPath, if set, is where the conversation is saved before each turn, so that
+	// the task can be resumed if it fails
+	TranscriptPath string
+}
+
+// AddMessage adds a message from the user, to be answered when the task is next run
+func (t *Task) AddMessage(text string) {
+	t.Messages = appendUserText(t.Messages, text)
+}
+
+// appendUserText adds text from the user to a conversation. It is added to the last user
+// message if there is one, such as the tool results of a task stopped by its budget, rather
+// than after it, so that turns keep alternating.
+func appendUserText(messages []Message, text string) []Message {
+	block := NewTextBlock(text)
+	if last := len(messages) - 1; last >= 0 && messages[last].Role == RoleUser {
+		messages[last].Content = append(messages[last].Content, block)
+		return messages
+	}
+	return append(messages, NewUserMessage(block))
 }
 
 // Clone creates a copy of the task's messages for a new context
-func (t *Task) Clone() []anthropic.MessageParam {
-	messages := make([]anthropic.MessageParam, len(t.Messages))
+func (t *Task) Clone() []Message {
+	messages := make([]Message, len(t.Messages))
 	copy(messages, t.Messages)
 	return messages
 }
 
-func (m *Manager) ExecuteTask(ctx context.Context, description string) (string, error) {
+// ExecuteTask runs a task to completion, returning the final response along with a summary
+// of the tool calls that were made. The contents of any contextFiles are included in the
+// first message, so the model doesn't need to find them.
+func (m *Manager) ExecuteTask(ctx context.Context, description string, contextFiles ...string) (*TaskResult, error) {
+	task, err := m.PrepareTask(description, contextFiles...)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.RunTask(ctx, task)
+}
+
+// PrepareTask creates a task without running it, so that it can be configured first
+func (m *Manager) PrepareTask(description string, contextFiles ...string) (*Task, error) {
 	task := NewTask(description, prompts.System)
+
+	if len(contextFiles) > 0 {
+		prompt, err := m.withContextFiles(description, contextFiles)
+		if err != nil {
+			return nil, err
+		}
+
+		task.Messages = []Message{
+			NewUserMessage(NewTextBlock(prompt)),
+		}
+	}
+
+	return task, nil
+}
+
+// RunTask runs a prepared or resumed task to completion
+func (m *Manager) RunTask(ctx context.Context, task *Task) (*TaskResult, error) {
 	return m.processTask(ctx, task)
 }
 
+// withContextFiles prepends the contents of the given files to a task description, each
+// under a header naming its path
+func (m *Manager) withContextFiles(description string, paths []string) (string, error) {
+	var sb strings.Builder
+	total := 0
+
+	sb.WriteString("The following files are relevant to the task:\n\n")
+	for _, path := range paths {
+		content, err := fs.ReadFile(m.FilteredFS, path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read context file: %w", err)
+		}
+
+		total += len(content)
+		if total > MaxContextFileBytes {
+			return "", fmt.Errorf("context files exceed the %dKB limit at %s", MaxContextFileBytes/1024, path)
+		}
+
+		fmt.Fprintf(&sb, "<file path=%q>\n%s", path, content)
+		if len(content) > 0 && content[len(content)-1] != '\n' {
+			sb.WriteString("\n")
+		}
+		sb.WriteString("</file>\n\n")
+	}
+
+	sb.WriteString(description)
+
+	return sb.String(), nil
+}
+
+// errTaskFinished is the cause of cancellation for the context of a task that has returned
+var errTaskFinished = errors.New("task finished")
+
 // ProcessTask handles a single task and any subtasks it creates
-func (m *Manager) processTask(ctx context.Context, task *Task) (string, error) {
+func (m *Manager) processTask(ctx context.Context, task *Task) (*TaskResult, error) {
 	log.Info("Processing task", zap.String("description", task.Description))
 
-	toolParams := m.getToolParams()
-
-	for {
-		message, err := m.AnthropicClient.Messages.New(ctx, anthropic.MessageNewParams{
-			Model:     anthropic.F(anthropic.ModelClaude3_7SonnetLatest),
-			MaxTokens: anthropic.Int(8192),
-			System: anthropic.F([]anthropic.TextBlockParam{
-				anthropic.NewTextBlock(task.SystemPrompt),
-			}),
-			Messages: anthropic.F(task.Messages),
-			Tools:    anthropic.F(toolParams),
+	// Tools run under a scope owned by the task, which is cancelled however the task ends so
+	// that no work it started outlives it
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(errTaskFinished)
+
+	result := &TaskResult{}
+
+	prices := m.Prices
+	if prices == nil {
+		prices = pricing.Defaults
+	}
+
+	// Delegated tasks add their usage to this task's, and count what it spent against the budget
+	ctx, scope := withTaskScope(ctx, &result.Usage)
+	tools := m.toolDefinitions(scope.depth)
+
+	// Delegated tasks run inside a tool call, so streaming their text would interleave it with
+	// the parent task's
+	var onText TextHandler
+	if scope.depth == 0 {
+		onText = m.OnText
+	}
+
+	for iteration := 0; ; iteration++ {
+		elideToolResults(task.Messages, m.History)
+		saveTranscript(task)
+
+		if reason := m.Budget.exhausted(iteration, scope.spentUSD()); reason != "" {
+			log.Warn("Stopping task, budget exhausted", zap.String("reason", reason))
+			result.BudgetExhausted = reason
+			return result, nil
+		}
+
+		resp, err := m.Model.Send(ctx, ModelRequest{
+			System:   task.SystemPrompt,
+			Messages: task.Messages,
+			Tools:    tools,
+			OnText:   onText,
 		})
 		if err != nil {
-			return "", fmt.Errorf("failed to get message: %w", err)
+			return nil, fmt.Errorf("failed to get message: %w", err)
 		}
 
-		// Log cost information if usage data is available
-		if message.Usage.InputTokens != 0 || message.Usage.OutputTokens != 0 {
-			inputTokens := float64(message.Usage.InputTokens)
-			outputTokens := float64(message.Usage.OutputTokens)
-
-			inputCost := (inputTokens / 1000.0) * 0.003
-			outputCost := (outputTokens / 1000.0) * 0.015
-			totalCost := inputCost + outputCost
+		// End each streamed turn's text with a newline, so the next turn starts on its own line
+		if onText != nil && slices.ContainsFunc(resp.Content, func(block ContentBlock) bool {
+			return block.Type == BlockText
+		}) {
+			onText("\n")
+		}
 
+		result.Usage.add(prices, resp.Model, resp.Usage)
+		if cost, ok := prices.Cost(resp.Model, resp.Usage.InputTokens, resp.Usage.OutputTokens); ok {
 			log.Info("Inference cost",
-				zap.Int64("input_tokens", message.Usage.InputTokens),
-				zap.Int64("output_tokens", message.Usage.OutputTokens),
-				zap.Float64("total_cost_usd", totalCost))
+				zap.String("model", resp.Model),
+				zap.Int64("input_tokens", resp.Usage.InputTokens),
+				zap.Int64("output_tokens", resp.Usage.OutputTokens),
+				zap.Float64("total_cost_usd", cost))
+		} else {
+			log.Debug("No price known for model", zap.String("model", resp.Model))
 		}
 
-		if len(message.Content) == 0 {
-			log.Warn("Received empty assistant response", zap.Any("message", message))
+		if len(resp.Content) == 0 {
+			log.Warn("Received empty assistant response")
 			continue
 		}
 
 		log.Debug("Received assistant response")
 
-		task.Messages = append(task.Messages, message.ToParam())
+		task.Messages = append(task.Messages, NewAssistantMessage(resp.Content...))
 		initialMessageCount := len(task.Messages)
 
-		for _, block := range message.Content {
-			switch block := block.AsUnion().(type) {
-			case anthropic.TextBlock:
+		for _, block := range resp.Content {
+			switch block.Type {
+			case BlockText:
 				log.Info("Assistant response", zap.String("text", block.Text))
-			case anthropic.ToolUseBlock:
-				responseMessage, err := m.handleToolUse(ctx, block)
+
+				// Keep the latest text, so a task stopped by its budget reports how far it got
+				result.Response = block.Text
+			case BlockToolUse:
+				responseMessage, summary, err := m.handleToolUse(ctx, block)
 				if err != nil {
-					return "", fmt.Errorf("failed to handle tool use: %w", err)
+					return nil, fmt.Errorf("failed to handle tool use: %w", err)
+				}
+
+				// Don't run any more tools, or ask for another response, once the task is aborted
+				if ctx.Err() != nil {
+					return nil, fmt.Errorf("task aborted: %w", context.Cause(ctx))
 				}
 
 				task.Messages = append(task.Messages, *responseMessage)
+				result.ToolCalls = append(result.ToolCalls, summary)
 			default:
-				log.Warn("Received unexpected block type", zap.Any("block", block))
+				log.Warn("Received unexpected block type", zap.String("type", string(block.Type)))
 			}
 		}
 
 		// If we didn't append any new messages, the task is complete. Return the last text block.
 		if len(task.Messages) == initialMessageCount {
-			if len(message.Content) > 0 {
-				if textBlock, ok := message.Content[len(message.Content)-1].AsUnion().(anthropic.TextBlock); ok {
-					return textBlock.Text, nil
-				}
+			if last := resp.Content[len(resp.Content)-1]; last.Type == BlockText {
+				result.Response = last.Text
+				saveTranscript(task)
+				return result, nil
 			}
 
-			log.Warn("expected a text block, but didn't get one", zap.Any("message", message))
-			return "", nil
+			log.Warn("expected a text block, but didn't get one", zap.Any("content", resp.Content))
+			saveTranscript(task)
+			return result, nil
 		}
 	}
 }
 
+// saveTranscript saves the task's conversation if it has a transcript path. Failing to save
+// shouldn't fail the task, so errors are only logged.
+func saveTranscript(task *Task) {
+	if task.TranscriptPath == "" {
+		return
+	}
+
+	if err := newTranscript(task).Save(task.TranscriptPath); err != nil {
+		log.Warn("Failed to save transcript", zap.String("path", task.TranscriptPath), zap.Error(err))
+	}
+}
+
 // handleToolUse handles a tool use block from the assistant's response
-func (m *Manager) handleToolUse(ctx context.Context, toolUse anthropic.ToolUseBlock) (*anthropic.MessageParam, error) {
-	var msg anthropic.MessageParam
+func (m *Manager) handleToolUse(ctx context.Context, toolUse ContentBlock) (*Message, ToolCallSummary, error) {
+	var msg Message
+
+	summary := ToolCallSummary{
+		Name: toolUse.Name,
+		Path: toolInputPath(toolUse.Input),
+	}
 
 	log.Debug("handling tool call",
 		zap.String("tool", toolUse.Name),
@@ -128,7 +276,12 @@ func (m *Manager) handleToolUse(ctx context.Context, toolUse anthropic.ToolUseBl
 			zap.Error(err),
 		)
 
-		msg = anthropic.NewUserMessage(anthropic.NewToolResultBlock(toolUse.ID, fmt.Sprintf("Error: %s", err), true))
+		if result == "" {
+			result = registry.FormatError(err)
+		}
+
+		msg = NewUserMessage(NewToolResultBlock(toolUse.ID, result, true))
+		summary.Error = true
 	} else {
 		log.Debug("tool call result",
 			zap.String("tool", toolUse.Name),
@@ -136,10 +289,10 @@ func (m *Manager) handleToolUse(ctx context.Context, toolUse anthropic.ToolUseBl
 			zap.Any("result", result),
 		)
 
-		msg = anthropic.NewUserMessage(anthropic.NewToolResultBlock(toolUse.ID, result, false))
+		msg = NewUserMessage(NewToolResultBlock(toolUse.ID, result, false))
 	}
 
-	return &msg, nil
+	return &msg, summary, nil
 }
 
 // executeToolCall executes a tool call using either a built-in tool or a tool from the registry
@@ -149,6 +302,10 @@ func (m *Manager) executeToolCall(ctx context.Context, toolCall registry.ToolCal
 		return m.delegateTask(ctx, toolCall)
 
 	default:
+		if m.executeTool != nil {
+			return m.executeTool(ctx, toolCall)
+		}
+
 		return m.ToolRegistry.ExecuteToolCall(ctx, toolCall)
 	}
 }
@@ -158,8 +315,8 @@ func NewTask(description string, systemPrompt string) *Task {
 	return &Task{
 		SystemPrompt: systemPrompt,
 		Description:  description,
-		Messages: []anthropic.MessageParam{
-			anthropic.NewUserMessage(anthropic.NewTextBlock(description)),
+		Messages: []Message{
+			NewUserMessage(NewTextBlock(description)),
 		},
 	}
 }
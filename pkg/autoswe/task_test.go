@@ -0,0 +1,178 @@
+package autoswe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/russellhaering/autoswe/pkg/tools/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteTaskContextFiles(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(rootDir, "pkg"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "pkg", "util.go"), []byte("package pkg"), 0644))
+
+	// Individual files over 128KB are filtered, so use several that add up to more than the cap
+	bigFiles := []string{"big1.go", "big2.go", "big3.go"}
+	for _, name := range bigFiles {
+		require.NoError(t, os.WriteFile(filepath.Join(rootDir, name), []byte(strings.Repeat("x\n", MaxContextFileBytes/5)), 0644))
+	}
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	require.NoError(t, err)
+
+	stub := stubAnthropic(t, `[{"type":"text","text":"Done"}]`)
+	manager := &Manager{
+		Model:        stub.Model,
+		FilteredFS:   filteredFS,
+		ToolRegistry: &registry.ToolRegistry{},
+	}
+
+	_, err = manager.ExecuteTask(context.Background(), "rename main", "main.go", "pkg/util.go")
+	require.NoError(t, err)
+
+	require.Len(t, stub.Requests, 1)
+	require.Len(t, stub.Requests[0].Messages, 1)
+	first := stub.Requests[0].Messages[0]
+	assert.Equal(t, "user", first.Role)
+	require.Len(t, first.Content, 1)
+	assert.Equal(t, "The following files are relevant to the task:\n\n"+
+		"<file path=\"main.go\">\npackage main\n\nfunc main() {}\n</file>\n\n"+
+		"<file path=\"pkg/util.go\">\npackage pkg\n</file>\n\n"+
+		"rename main", first.Content[0].Text)
+
+	_, err = manager.ExecuteTask(context.Background(), "rename main", bigFiles...)
+	assert.ErrorContains(t, err, "exceed the 256KB limit at big3.go")
+
+	_, err = manager.ExecuteTask(context.Background(), "rename main", "missing.go")
+	assert.Error(t, err)
+	assert.Len(t, stub.Requests, 1, "no request should be made when context files can't be read")
+}
+
+func TestExecuteTaskCancelsTools(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	stub := stubAnthropic(t,
+		`[{"type":"tool_use","id":"call-1","name":"exec","input":{"command":["sleep","600"]}},{"type":"tool_use","id":"call-2","name":"fs_fetch","input":{"path":"main.go"}}]`,
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls []string
+	var toolErr error
+	manager := &Manager{
+		Model:        stub.Model,
+		ToolRegistry: &registry.ToolRegistry{},
+		executeTool: func(toolCtx context.Context, call registry.ToolCall) (string, error) {
+			calls = append(calls, call.Name)
+
+			// Abort the task while the long-running tool is in flight
+			cancel()
+
+			select {
+			case <-toolCtx.Done():
+				toolErr = toolCtx.Err()
+				return "", toolErr
+			case <-time.After(10 * time.Second):
+				return "finished", nil
+			}
+		},
+	}
+
+	_, err := manager.ExecuteTask(ctx, "run a long command")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	assert.ErrorIs(t, toolErr, context.Canceled, "the in-flight tool should be cancelled")
+	assert.Equal(t, []string{"exec"}, calls, "no more tools should run once the task is aborted")
+	assert.Len(t, stub.Requests, 1)
+}
+
+func TestExecuteTaskCancelsScopeOnReturn(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	var toolCtx context.Context
+	manager := &Manager{
+		Model: stubAnthropic(t,
+			`[{"type":"tool_use","id":"call-1","name":"exec","input":{"command":["make"]}}]`,
+			`[{"type":"text","text":"Done"}]`,
+		).Model,
+		ToolRegistry: &registry.ToolRegistry{},
+		executeTool: func(ctx context.Context, _ registry.ToolCall) (string, error) {
+			toolCtx = ctx
+			return "ok", nil
+		},
+	}
+
+	_, err := manager.ExecuteTask(context.Background(), "build")
+	require.NoError(t, err)
+
+	// Anything the tool left running with its context is stopped when the task ends
+	require.NotNil(t, toolCtx)
+	select {
+	case <-toolCtx.Done():
+		assert.ErrorIs(t, context.Cause(toolCtx), errTaskFinished)
+	default:
+		t.Fatal("the task's scope should be cancelled when it returns")
+	}
+}
+
+// scriptedModel is an AgentModel that responds with the given turns in order
+type scriptedModel struct {
+	turns    [][]ContentBlock
+	requests []ModelRequest
+}
+
+func (m *scriptedModel) Send(_ context.Context, req ModelRequest) (*ModelResponse, error) {
+	m.requests = append(m.requests, req)
+	if len(m.requests) > len(m.turns) {
+		return nil, errors.New("unexpected request")
+	}
+
+	return &ModelResponse{Content: m.turns[len(m.requests)-1]}, nil
+}
+
+func TestExecuteTaskWithAgentModel(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	model := &scriptedModel{
+		turns: [][]ContentBlock{
+			{NewToolUseBlock("call-1", "exec", json.RawMessage(`{"command":["make"]}`))},
+			{NewTextBlock("Built")},
+		},
+	}
+
+	manager := &Manager{
+		Model:        model,
+		ToolRegistry: &registry.ToolRegistry{},
+		executeTool: func(context.Context, registry.ToolCall) (string, error) {
+			return "ok", nil
+		},
+	}
+
+	result, err := manager.ExecuteTask(context.Background(), "build")
+	require.NoError(t, err)
+	assert.Equal(t, "Built", result.Response)
+
+	require.Len(t, model.requests, 2)
+	assert.Equal(t, "delegate_task", model.requests[0].Tools[0].Name)
+	assert.Equal(t, []Message{
+		NewUserMessage(NewTextBlock("build")),
+		NewAssistantMessage(NewToolUseBlock("call-1", "exec", json.RawMessage(`{"command":["make"]}`))),
+		NewUserMessage(NewToolResultBlock("call-1", "ok", false)),
+	}, model.requests[1].Messages)
+}
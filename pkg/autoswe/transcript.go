@@ -0,0 +1,151 @@
+package autoswe
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Transcript is a saved task conversation, which can be truncated and resumed
+type Transcript struct {
+	SystemPrompt string    `json:"system_prompt"`
+	Description  string    `json:"description"`
+	Messages     []Message `json:"messages"`
+}
+
+// newTranscript captures the conversation of a task
+func newTranscript(task *Task) *Transcript {
+	return &Transcript{
+		SystemPrompt: task.SystemPrompt,
+		Description:  task.Description,
+		Messages:     task.Clone(),
+	}
+}
+
+// LoadTranscript reads a transcript saved by a task
+func LoadTranscript(path string) (*Transcript, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transcript: %w", err)
+	}
+
+	var transcript Transcript
+	if err := json.Unmarshal(data, &transcript); err != nil {
+		return nil, fmt.Errorf("failed to parse transcript %s: %w", path, err)
+	}
+
+	return &transcript, nil
+}
+
+// Save writes the transcript to path, replacing it atomically so that a crash never leaves
+// a partial transcript behind
+func (t *Transcript) Save(path string) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal transcript: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create transcript: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write transcript: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write transcript: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Turns returns the number of assistant turns in the transcript
+func (t *Transcript) Turns() int {
+	turns := 0
+	for _, msg := range t.Messages {
+		if msg.Role == RoleAssistant {
+			turns++
+		}
+	}
+	return turns
+}
+
+// Truncate drops the given assistant turn, numbered from 1, and everything after it, so
+// that resuming asks the model to take that turn again. Every tool call before the turn
+// keeps its result.
+func (t *Transcript) Truncate(turn int) error {
+	if turns := t.Turns(); turn < 1 || turn > turns {
+		return fmt.Errorf("turn %d is out of range, the transcript has %d turns", turn, turns)
+	}
+
+	seen := 0
+	for idx, msg := range t.Messages {
+		if msg.Role != RoleAssistant {
+			continue
+		}
+
+		seen++
+		if seen == turn {
+			t.Messages = t.Messages[:idx]
+			break
+		}
+	}
+
+	return nil
+}
+
+// Resume returns a task that continues the conversation, after adding message, if set, to
+// the user's side of it. The conversation must end with a user message, such as the results
+// of the last turn's tool calls, for the model to respond to.
+func (t *Transcript) Resume(message string) (*Task, error) {
+	if message != "" {
+		t.Messages = appendUserText(t.Messages, message)
+	}
+
+	if len(t.Messages) == 0 {
+		return nil, fmt.Errorf("transcript has no messages")
+	}
+
+	if last := t.Messages[len(t.Messages)-1]; last.Role != RoleUser {
+		return nil, fmt.Errorf("transcript ends with an assistant turn, truncate it or add a message to continue")
+	}
+
+	if err := t.checkToolPairing(); err != nil {
+		return nil, err
+	}
+
+	return &Task{
+		SystemPrompt: t.SystemPrompt,
+		Description:  t.Description,
+		Messages:     t.Messages,
+	}, nil
+}
+
+// checkToolPairing returns an error unless every tool_use has exactly one tool_result, and
+// every tool_result follows its tool_use
+func (t *Transcript) checkToolPairing() error {
+	pending := make(map[string]bool)
+	for _, msg := range t.Messages {
+		for _, block := range msg.Content {
+			switch block.Type {
+			case BlockToolUse:
+				pending[block.ID] = true
+			case BlockToolResult:
+				if !pending[block.ToolUseID] {
+					return fmt.Errorf("tool result %s has no matching tool call", block.ToolUseID)
+				}
+				delete(pending, block.ToolUseID)
+			}
+		}
+	}
+
+	for id := range pending {
+		return fmt.Errorf("tool call %s has no result", id)
+	}
+
+	return nil
+}
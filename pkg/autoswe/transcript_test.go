@@ -0,0 +1,104 @@
+package autoswe
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/tools/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResumeTranscript(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	path := filepath.Join(t.TempDir(), "transcript.json")
+
+	// The first run fails at its second turn, when the build breaks
+	manager := &Manager{
+		Model: stubAnthropic(t,
+			`[{"type":"tool_use","id":"call-1","name":"fs_fetch","input":{"path":"Makefile"}}]`,
+			`[{"type":"tool_use","id":"call-2","name":"exec","input":{"command":["make"]}}]`,
+			`[{"type":"text","text":"The build is broken"}]`,
+		).Model,
+		ToolRegistry: &registry.ToolRegistry{},
+		executeTool: func(_ context.Context, call registry.ToolCall) (string, error) {
+			if call.Name == "exec" {
+				return "", errors.New("make: *** No rule to make target 'all'")
+			}
+			return "all:\n", nil
+		},
+	}
+
+	task, err := manager.PrepareTask("build the project")
+	require.NoError(t, err)
+	task.TranscriptPath = path
+
+	result, err := manager.RunTask(context.Background(), task)
+	require.NoError(t, err)
+	assert.Equal(t, "The build is broken", result.Response)
+
+	transcript, err := LoadTranscript(path)
+	require.NoError(t, err)
+	assert.Equal(t, "build the project", transcript.Description)
+	assert.Equal(t, 3, transcript.Turns())
+	assert.Len(t, transcript.Messages, 6)
+
+	// Take the second turn again, after fixing the Makefile
+	require.NoError(t, transcript.Truncate(2))
+	require.Len(t, transcript.Messages, 3)
+
+	resumed, err := transcript.Resume("I fixed the Makefile, try again")
+	require.NoError(t, err)
+	resumed.TranscriptPath = path
+
+	stub := stubAnthropic(t,
+		`[{"type":"tool_use","id":"call-3","name":"exec","input":{"command":["make"]}}]`,
+		`[{"type":"text","text":"The build passes"}]`,
+	)
+	manager.Model = stub.Model
+	manager.executeTool = func(context.Context, registry.ToolCall) (string, error) {
+		return "ok", nil
+	}
+
+	result, err = manager.RunTask(context.Background(), resumed)
+	require.NoError(t, err)
+	assert.Equal(t, "The build passes", result.Response)
+
+	// The continuation starts from the first turn's tool result, along with the correction
+	require.Len(t, stub.Requests, 2)
+	first := stub.Requests[0]
+	require.Len(t, first.Messages, 3)
+	assert.Equal(t, "user", first.Messages[0].Role)
+	assert.Equal(t, "build the project", first.Messages[0].Content[0].Text)
+	assert.Equal(t, "assistant", first.Messages[1].Role)
+	assert.Equal(t, "tool_use", first.Messages[1].Content[0].Type)
+	assert.Equal(t, "user", first.Messages[2].Role)
+	require.Len(t, first.Messages[2].Content, 2)
+	assert.Equal(t, "tool_result", first.Messages[2].Content[0].Type)
+	assert.Equal(t, "I fixed the Makefile, try again", first.Messages[2].Content[1].Text)
+
+	// Progress is saved back to the transcript
+	transcript, err = LoadTranscript(path)
+	require.NoError(t, err)
+	assert.Equal(t, 3, transcript.Turns())
+	assert.Equal(t, "call-3", transcript.Messages[3].Content[0].ID)
+}
+
+func TestResumeTranscriptToolPairing(t *testing.T) {
+	transcript := &Transcript{
+		Messages: []Message{
+			NewUserMessage(NewTextBlock("build")),
+			NewAssistantMessage(NewToolUseBlock("call-1", "exec", nil)),
+		},
+	}
+
+	// A tool call without its result can't be sent, even with a message after it
+	_, err := transcript.Resume("continue")
+	assert.ErrorContains(t, err, "call-1 has no result")
+
+	assert.Error(t, transcript.Truncate(3))
+}
@@ -0,0 +1,102 @@
+package autoswe
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/russellhaering/autoswe/pkg/pricing"
+)
+
+// TokenUsage accumulates the tokens used by a task, and what they cost
+type TokenUsage struct {
+	Requests     int     `json:"requests"`
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+
+	// UnpricedModels lists models that were used but aren't in the price table, so their
+	// tokens aren't included in CostUSD
+	UnpricedModels []string `json:"unpriced_models,omitempty"`
+}
+
+// add records a request's usage
+func (u *TokenUsage) add(prices pricing.Table, model string, usage Usage) {
+	u.Requests++
+	u.InputTokens += usage.InputTokens
+	u.OutputTokens += usage.OutputTokens
+
+	if cost, ok := prices.Cost(model, usage.InputTokens, usage.OutputTokens); ok {
+		u.CostUSD += cost
+	} else {
+		u.addUnpriced(model)
+	}
+}
+
+// merge adds the usage of a delegated task
+func (u *TokenUsage) merge(other TokenUsage) {
+	u.Requests += other.Requests
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.CostUSD += other.CostUSD
+
+	for _, model := range other.UnpricedModels {
+		u.addUnpriced(model)
+	}
+}
+
+func (u *TokenUsage) addUnpriced(model string) {
+	for _, unpriced := range u.UnpricedModels {
+		if unpriced == model {
+			return
+		}
+	}
+	u.UnpricedModels = append(u.UnpricedModels, model)
+}
+
+// String summarizes the usage, e.g. "12 requests, 35210 input tokens, 2104 output tokens, $0.14"
+func (u TokenUsage) String() string {
+	s := fmt.Sprintf("%d requests, %d input tokens, %d output tokens, $%.2f", u.Requests, u.InputTokens, u.OutputTokens, u.CostUSD)
+	if len(u.UnpricedModels) > 0 {
+		s += fmt.Sprintf(" (excluding unpriced models: %s)", strings.Join(u.UnpricedModels, ", "))
+	}
+	return s
+}
+
+// taskScope tracks the usage of a running task, so that delegated tasks can add their usage
+// to it and count it against the budget
+type taskScope struct {
+	usage *TokenUsage
+
+	// depth is how many tasks delegated down to this one, 0 for a top-level task
+	depth int
+
+	// inheritedCostUSD is what the tasks that delegated to this one had spent when it started
+	inheritedCostUSD float64
+}
+
+// spentUSD returns the cost of the task and the tasks that delegated to it
+func (s *taskScope) spentUSD() float64 {
+	return s.inheritedCostUSD + s.usage.CostUSD
+}
+
+// taskScopeKey is the context key for the scope of the task running a tool
+type taskScopeKey struct{}
+
+// withTaskScope returns a context carrying a new scope for a task, which inherits the
+// spending of the task running in ctx, if any
+func withTaskScope(ctx context.Context, usage *TokenUsage) (context.Context, *taskScope) {
+	scope := &taskScope{usage: usage}
+	if parent := parentTaskScope(ctx); parent != nil {
+		scope.inheritedCostUSD = parent.spentUSD()
+		scope.depth = parent.depth + 1
+	}
+
+	return context.WithValue(ctx, taskScopeKey{}, scope), scope
+}
+
+// parentTaskScope returns the scope of the task running a tool, if any
+func parentTaskScope(ctx context.Context) *taskScope {
+	scope, _ := ctx.Value(taskScopeKey{}).(*taskScope)
+	return scope
+}
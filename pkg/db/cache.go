@@ -0,0 +1,126 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	bolt "go.etcd.io/bbolt"
+	bolterrors "go.etcd.io/bbolt/errors"
+)
+
+var embeddingsBucket = []byte("embeddings")
+
+// EmbeddingCache stores embeddings keyed by a hash of the embedded content, so that the
+// same content is only embedded once
+type EmbeddingCache struct {
+	db *bolt.DB
+}
+
+// NewEmbeddingCache opens the embedding cache at path, creating it if it doesn't exist
+func NewEmbeddingCache(path string) (*EmbeddingCache, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: OpenTimeout})
+	if errors.Is(err, bolterrors.ErrTimeout) {
+		return nil, fmt.Errorf("%w: %s is in use, wait for the other autoswe process to exit or stop it", ErrIndexLocked, path)
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(embeddingsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &EmbeddingCache{db: db}, nil
+}
+
+// Close closes the cache
+func (c *EmbeddingCache) Close() error {
+	return c.db.Close()
+}
+
+// Get returns the cached embedding of content, if there is one
+func (c *EmbeddingCache) Get(content string) ([]float32, bool, error) {
+	var vector []float32
+
+	err := c.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(embeddingsBucket).Get(cacheKey(content))
+		if value == nil {
+			return nil
+		}
+
+		vector = decodeVector(value)
+		return nil
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read embedding cache: %w", err)
+	}
+
+	return vector, vector != nil, nil
+}
+
+// Put stores the embedding of content
+func (c *EmbeddingCache) Put(content string, vector []float32) error {
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(embeddingsBucket).Put(cacheKey(content), encodeVector(vector))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write embedding cache: %w", err)
+	}
+
+	return nil
+}
+
+// Wrap returns an embedding function that returns cached embeddings when possible, and
+// otherwise calls embed and caches the result
+func (c *EmbeddingCache) Wrap(embed EmbeddingFunc) EmbeddingFunc {
+	return func(ctx context.Context, content string) ([]float32, error) {
+		if vector, ok, err := c.Get(content); err != nil {
+			return nil, err
+		} else if ok {
+			return vector, nil
+		}
+
+		vector, err := embed(ctx, content)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := c.Put(content, vector); err != nil {
+			return nil, err
+		}
+
+		return vector, nil
+	}
+}
+
+// cacheKey returns the key that content's embedding is stored under
+func cacheKey(content string) []byte {
+	sum := sha256.Sum256([]byte(content))
+	return sum[:]
+}
+
+// encodeVector encodes a vector as little endian float32s
+func encodeVector(vector []float32) []byte {
+	buf := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(v))
+	}
+	return buf
+}
+
+// decodeVector decodes a vector encoded by encodeVector
+func decodeVector(buf []byte) []float32 {
+	vector := make([]float32, len(buf)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return vector
+}
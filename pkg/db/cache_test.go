@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEmbeddingCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "embeddings")
+
+	cache, err := NewEmbeddingCache(path)
+	if err != nil {
+		t.Fatalf("Failed to open cache: %v", err)
+	}
+
+	calls := 0
+	embed := cache.Wrap(func(ctx context.Context, content string) ([]float32, error) {
+		calls++
+		return mockEmbedding(ctx, content)
+	})
+
+	first, err := embed(context.Background(), "hello world")
+	if err != nil {
+		t.Fatalf("Failed to embed: %v", err)
+	}
+	second, err := embed(context.Background(), "hello world")
+	if err != nil {
+		t.Fatalf("Failed to embed: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 call to the embedder, got %d", calls)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Cached embedding %v differs from original %v", second, first)
+	}
+
+	// Embeddings persist across opens
+	if err := cache.Close(); err != nil {
+		t.Fatalf("Failed to close cache: %v", err)
+	}
+	cache, err = NewEmbeddingCache(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen cache: %v", err)
+	}
+	defer cache.Close()
+
+	vector, ok, err := cache.Get("hello world")
+	if err != nil || !ok || !reflect.DeepEqual(vector, first) {
+		t.Errorf("Get() = (%v, %v, %v), want (%v, true, nil)", vector, ok, err, first)
+	}
+	if _, ok, _ := cache.Get("goodbye"); ok {
+		t.Errorf("Get() found an embedding that was never cached")
+	}
+}
@@ -1,22 +1,49 @@
 package db
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
+	"fmt"
 	"math"
+	"os"
 	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	bolt "go.etcd.io/bbolt"
+	bolterrors "go.etcd.io/bbolt/errors"
 )
 
+// OpenTimeout is how long to wait for another process to release the database lock
+const OpenTimeout = time.Second
+
+// compactTxMaxSize bounds the data copied in each transaction while compacting, to limit the
+// memory used
+const compactTxMaxSize = 64 << 20
+
 var (
 	documentsBucket = []byte("documents")
 	ErrNotFound     = errors.New("document not found")
+
+	// metaBucket holds details of the database as a whole
+	metaBucket = []byte("meta")
+	// dimensionKey is the meta key holding the number of dimensions of the embeddings
+	dimensionKey = []byte("embedding_dimension")
+
+	// ErrIndexLocked is returned when another process holds the database open
+	ErrIndexLocked = errors.New("index is locked by another process")
+
+	// ErrDimensionMismatch is returned when an embedding has a different number of dimensions
+	// to those in the database, usually because the embedding model changed. Similarities
+	// between such vectors are meaningless, so the index has to be rebuilt.
+	ErrDimensionMismatch = errors.New("embedding dimensions don't match the index")
 )
 
-// EmbeddingFunc is a function that converts document contents into a vector
-type EmbeddingFunc func(content string) ([]float32, error)
+// EmbeddingFunc is a function that converts document contents into a vector. It should give up
+// when the context is cancelled.
+type EmbeddingFunc func(ctx context.Context, content string) ([]float32, error)
 
 // Document represents a document with content, metadata, and its vector embedding
 type Document struct {
@@ -30,35 +57,205 @@ type Document struct {
 type SearchResult struct {
 	Document   Document
 	Similarity float64
+
+	// KeywordScore is how well the document matched the query's keywords, from 0 to 1, if
+	// the results were ranked by HybridRank
+	KeywordScore float64
 }
 
 // DocumentDB represents a document-oriented vector database
 type DocumentDB struct {
 	db            *bolt.DB
+	path          string
 	embedDocument EmbeddingFunc
+
+	// dimension is the number of dimensions of the stored embeddings, or 0 if none are stored
+	dimension atomic.Int64
 }
 
 // NewDocumentDB creates a new document database with the specified embedding function
 func NewDocumentDB(path string, embedFn EmbeddingFunc) (*DocumentDB, error) {
-	// Open bolt database
-	db, err := bolt.Open(path, 0600, nil)
+	db, err := openBolt(path)
 	if err != nil {
 		return nil, err
 	}
 
+	ddb := &DocumentDB{
+		db:            db,
+		path:          path,
+		embedDocument: embedFn,
+	}
+
 	err = db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists(documentsBucket)
-		return err
+		if _, err := tx.CreateBucketIfNotExists(documentsBucket); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(metaBucket); err != nil {
+			return err
+		}
+		if err := migrateVectors(tx); err != nil {
+			return fmt.Errorf("failed to migrate %s: %w", path, err)
+		}
+		return ddb.loadDimension(tx)
 	})
 	if err != nil {
 		db.Close()
 		return nil, err
 	}
 
-	return &DocumentDB{
-		db:            db,
-		embedDocument: embedFn,
-	}, nil
+	return ddb, nil
+}
+
+// openBolt opens a bolt database, giving up if another process holds the lock
+func openBolt(path string) (*bolt.DB, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: OpenTimeout})
+	if errors.Is(err, bolterrors.ErrTimeout) {
+		return nil, fmt.Errorf("%w: %s is in use, wait for the other autoswe process to exit or stop it", ErrIndexLocked, path)
+	}
+	return db, err
+}
+
+// loadDimension reads the dimension of the stored embeddings. Older databases don't record
+// it, so it is taken from the first stored embedding and recorded.
+func (ddb *DocumentDB) loadDimension(tx *bolt.Tx) error {
+	if value := tx.Bucket(metaBucket).Get(dimensionKey); value != nil {
+		dimension, err := strconv.Atoi(string(value))
+		if err != nil {
+			return fmt.Errorf("invalid embedding dimension in %s: %w", ddb.path, err)
+		}
+		ddb.dimension.Store(int64(dimension))
+		return nil
+	}
+
+	c := tx.Bucket(documentsBucket).Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		doc, err := decodeDocument(v)
+		if err != nil {
+			return err
+		}
+
+		if len(doc.Vector) > 0 {
+			return ddb.storeDimension(tx, len(doc.Vector))
+		}
+	}
+
+	return nil
+}
+
+// storeDimension records the dimension of the stored embeddings
+func (ddb *DocumentDB) storeDimension(tx *bolt.Tx, dimension int) error {
+	if err := tx.Bucket(metaBucket).Put(dimensionKey, []byte(strconv.Itoa(dimension))); err != nil {
+		return err
+	}
+	ddb.dimension.Store(int64(dimension))
+	return nil
+}
+
+// checkDimension checks that a vector about to be stored has the same dimension as those
+// already stored. An empty database takes on the dimension of the first vector stored in it.
+func (ddb *DocumentDB) checkDimension(tx *bolt.Tx, vector []float32) error {
+	if len(vector) == 0 {
+		return nil
+	}
+
+	dimension := int(ddb.dimension.Load())
+	if dimension == len(vector) {
+		return nil
+	}
+
+	if k, _ := tx.Bucket(documentsBucket).Cursor().First(); dimension == 0 || k == nil {
+		return ddb.storeDimension(tx, len(vector))
+	}
+
+	return ddb.dimensionError(len(vector))
+}
+
+// checkQueryDimension checks that a query vector can be compared with the stored vectors
+func (ddb *DocumentDB) checkQueryDimension(vector []float32) error {
+	dimension := int(ddb.dimension.Load())
+	if dimension == 0 || dimension == len(vector) {
+		return nil
+	}
+
+	return ddb.dimensionError(len(vector))
+}
+
+// dimensionError explains that the embedding model's vectors can't be compared with those
+// in the database, and how to fix it
+func (ddb *DocumentDB) dimensionError(dimension int) error {
+	return fmt.Errorf("%w: the index holds %d-dimension embeddings but the embedding model returned %d, "+
+		"which usually means the embedding model changed; delete %s and run autoswe index to rebuild it",
+		ErrDimensionMismatch, ddb.dimension.Load(), dimension, ddb.path)
+}
+
+// Compact copies the live documents into a new database file and replaces the old file with
+// it. bbolt reuses the pages freed by deleted documents but never shrinks the file, so this
+// reclaims the space left by documents deleted when files are reindexed. It must not be
+// called concurrently with other methods.
+func (ddb *DocumentDB) Compact() (*CompactResult, error) {
+	before, err := fileSize(ddb.path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remove any copy left behind by an interrupted compaction
+	compactPath := ddb.path + ".compact"
+	if err := os.Remove(compactPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	dst, err := bolt.Open(compactPath, 0600, &bolt.Options{Timeout: OpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create compacted database: %w", err)
+	}
+
+	if err := bolt.Compact(dst, ddb.db, compactTxMaxSize); err != nil {
+		dst.Close()
+		os.Remove(compactPath)
+		return nil, fmt.Errorf("failed to compact database: %w", err)
+	}
+
+	if err := dst.Close(); err != nil {
+		os.Remove(compactPath)
+		return nil, fmt.Errorf("failed to write compacted database: %w", err)
+	}
+
+	if err := ddb.db.Close(); err != nil {
+		os.Remove(compactPath)
+		return nil, err
+	}
+
+	// Keep using the old file if the new one can't replace it
+	renameErr := os.Rename(compactPath, ddb.path)
+	if renameErr != nil {
+		os.Remove(compactPath)
+	}
+
+	db, err := openBolt(ddb.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reopen database after compacting it: %w", err)
+	}
+	ddb.db = db
+
+	if renameErr != nil {
+		return nil, fmt.Errorf("failed to replace database with compacted copy: %w", renameErr)
+	}
+
+	after, err := fileSize(ddb.path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CompactResult{SizeBefore: before, SizeAfter: after}, nil
+}
+
+// fileSize returns the size of the file at path in bytes
+func fileSize(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
 }
 
 // Close closes the database
@@ -67,21 +264,25 @@ func (ddb *DocumentDB) Close() error {
 }
 
 // AddDocument adds a new document to the database
-func (ddb *DocumentDB) AddDocument(doc Document) error {
+func (ddb *DocumentDB) AddDocument(ctx context.Context, doc Document) error {
 	if doc.ID == "" {
 		return errors.New("document ID cannot be empty")
 	}
 
 	// Generate embedding for the document
-	vector, err := ddb.embedDocument(doc.Content)
+	vector, err := ddb.embedDocument(ctx, doc.Content)
 	if err != nil {
 		return err
 	}
 	doc.Vector = vector
 
 	return ddb.db.Update(func(tx *bolt.Tx) error {
+		if err := ddb.checkDimension(tx, doc.Vector); err != nil {
+			return err
+		}
+
 		b := tx.Bucket(documentsBucket)
-		data, err := json.Marshal(doc)
+		data, err := encodeDocument(doc)
 		if err != nil {
 			return err
 		}
@@ -98,7 +299,10 @@ func (ddb *DocumentDB) GetDocument(id string) (Document, error) {
 		if data == nil {
 			return ErrNotFound
 		}
-		return json.Unmarshal(data, &doc)
+
+		var err error
+		doc, err = decodeDocument(data)
+		return err
 	})
 	return doc, err
 }
@@ -112,11 +316,14 @@ func (ddb *DocumentDB) DeleteDocument(id string) error {
 }
 
 // SearchSimilar finds k documents most similar to the query content
-func (ddb *DocumentDB) SearchSimilar(queryContent string, k int) ([]Document, error) {
-	queryVector, err := ddb.embedDocument(queryContent)
+func (ddb *DocumentDB) SearchSimilar(ctx context.Context, queryContent string, k int) ([]Document, error) {
+	queryVector, err := ddb.embedDocument(ctx, queryContent)
 	if err != nil {
 		return nil, err
 	}
+	if err := ddb.checkQueryDimension(queryVector); err != nil {
+		return nil, err
+	}
 
 	type docDistance struct {
 		doc      Document
@@ -127,8 +334,8 @@ func (ddb *DocumentDB) SearchSimilar(queryContent string, k int) ([]Document, er
 	err = ddb.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(documentsBucket)
 		return b.ForEach(func(_, value []byte) error {
-			var doc Document
-			if err := json.Unmarshal(value, &doc); err != nil {
+			doc, err := decodeDocument(value)
+			if err != nil {
 				return err
 			}
 			distance := cosineSimilarity(queryVector, doc.Vector)
@@ -163,8 +370,8 @@ func (ddb *DocumentDB) FilterDocuments(filters map[string]string) ([]Document, e
 	err := ddb.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(documentsBucket)
 		return b.ForEach(func(_, value []byte) error {
-			var doc Document
-			if err := json.Unmarshal(value, &doc); err != nil {
+			doc, err := decodeDocument(value)
+			if err != nil {
 				return err
 			}
 
@@ -216,8 +423,8 @@ func (ddb *DocumentDB) ListDocuments() ([]Document, error) {
 	err := ddb.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(documentsBucket)
 		return b.ForEach(func(_, v []byte) error {
-			var doc Document
-			if err := json.Unmarshal(v, &doc); err != nil {
+			doc, err := decodeDocument(v)
+			if err != nil {
 				return err
 			}
 			docs = append(docs, doc)
@@ -229,7 +436,7 @@ func (ddb *DocumentDB) ListDocuments() ([]Document, error) {
 }
 
 // BatchAddDocuments adds multiple documents in a single transaction
-func (ddb *DocumentDB) BatchAddDocuments(docs []Document) error {
+func (ddb *DocumentDB) BatchAddDocuments(ctx context.Context, docs []Document) error {
 	return ddb.db.Batch(func(tx *bolt.Tx) error {
 		b := tx.Bucket(documentsBucket)
 		for _, doc := range docs {
@@ -238,13 +445,17 @@ func (ddb *DocumentDB) BatchAddDocuments(docs []Document) error {
 			}
 
 			// Generate embedding for the document
-			vector, err := ddb.embedDocument(doc.Content)
+			vector, err := ddb.embedDocument(ctx, doc.Content)
 			if err != nil {
 				return err
 			}
 			doc.Vector = vector
 
-			data, err := json.Marshal(doc)
+			if err := ddb.checkDimension(tx, doc.Vector); err != nil {
+				return err
+			}
+
+			data, err := encodeDocument(doc)
 			if err != nil {
 				return err
 			}
@@ -283,8 +494,8 @@ func (ddb *DocumentDB) GetDocumentsWithPrefix(prefix string) ([]Document, error)
 
 		prefixBytes := []byte(prefix)
 		for k, v := c.Seek(prefixBytes); k != nil && strings.HasPrefix(string(k), prefix); k, v = c.Next() {
-			var doc Document
-			if err := json.Unmarshal(v, &doc); err != nil {
+			doc, err := decodeDocument(v)
+			if err != nil {
 				return err
 			}
 			docs = append(docs, doc)
@@ -306,20 +517,49 @@ func (ddb *DocumentDB) Count() (int, error) {
 	return count, err
 }
 
+// CountDocuments returns the number of documents that match the given metadata filters.
+// Only the metadata of each document is decoded.
+func (ddb *DocumentDB) CountDocuments(filters map[string]string) (int, error) {
+	var count int
+
+	err := ddb.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(documentsBucket)
+		return b.ForEach(func(_, value []byte) error {
+			doc, err := decodeDocument(value)
+			if err != nil {
+				return err
+			}
+
+			for k, v := range filters {
+				if doc.Metadata[k] != v {
+					return nil
+				}
+			}
+			count++
+			return nil
+		})
+	})
+
+	return count, err
+}
+
 // Query finds documents matching the metadata filters and ranks them by similarity to the query content
-func (ddb *DocumentDB) Query(queryContent string, limit int, filters map[string]string) ([]SearchResult, error) {
-	queryVector, err := ddb.embedDocument(queryContent)
+func (ddb *DocumentDB) Query(ctx context.Context, queryContent string, limit int, filters map[string]string) ([]SearchResult, error) {
+	queryVector, err := ddb.embedDocument(ctx, queryContent)
 	if err != nil {
 		return nil, err
 	}
+	if err := ddb.checkQueryDimension(queryVector); err != nil {
+		return nil, err
+	}
 
 	var results []SearchResult
 
 	err = ddb.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(documentsBucket)
 		return b.ForEach(func(_, value []byte) error {
-			var doc Document
-			if err := json.Unmarshal(value, &doc); err != nil {
+			doc, err := decodeDocument(value)
+			if err != nil {
 				return err
 			}
 
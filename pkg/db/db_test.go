@@ -1,12 +1,18 @@
 package db
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 // mockEmbedding is a simple embedding function for testing
-func mockEmbedding(content string) ([]float32, error) {
+func mockEmbedding(_ context.Context, content string) ([]float32, error) {
 	switch content {
 	case "hello world":
 		return []float32{1.0, 0.0, 0.0}, nil
@@ -41,7 +47,7 @@ func TestDocumentDB(t *testing.T) {
 	}
 
 	// Test AddDocument
-	err = db.AddDocument(doc1)
+	err = db.AddDocument(context.Background(), doc1)
 	if err != nil {
 		t.Fatalf("Failed to add document: %v", err)
 	}
@@ -66,12 +72,12 @@ func TestDocumentDB(t *testing.T) {
 	}
 
 	for _, doc := range docs {
-		if err := db.AddDocument(doc); err != nil {
+		if err := db.AddDocument(context.Background(), doc); err != nil {
 			t.Fatalf("Failed to add document: %v", err)
 		}
 	}
 
-	similar, err := db.SearchSimilar("hello world", 2)
+	similar, err := db.SearchSimilar(context.Background(), "hello world", 2)
 	if err != nil {
 		t.Fatalf("Failed to search similar documents: %v", err)
 	}
@@ -109,3 +115,141 @@ func TestDocumentDB(t *testing.T) {
 		t.Errorf("Expected ErrNotFound after deletion, got %v", err)
 	}
 }
+
+func TestNewDocumentDBLocked(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := NewDocumentDB(dbPath, mockEmbedding)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	start := time.Now()
+	_, err = NewDocumentDB(dbPath, mockEmbedding)
+	if !errors.Is(err, ErrIndexLocked) {
+		t.Fatalf("Expected ErrIndexLocked, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > OpenTimeout+5*time.Second {
+		t.Errorf("Opening a locked database took %s", elapsed)
+	}
+}
+
+func TestDimensionMismatch(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := NewDocumentDB(dbPath, mockEmbedding)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	if err := db.AddDocument(context.Background(), Document{ID: "doc1", Content: "hello world"}); err != nil {
+		t.Fatalf("Failed to add document: %v", err)
+	}
+	db.Close()
+
+	// Reopen the database with a model that returns embeddings of a different length
+	longEmbedding := func(_ context.Context, content string) ([]float32, error) {
+		return []float32{1.0, 0.0, 0.0, 0.0}, nil
+	}
+	db, err = NewDocumentDB(dbPath, longEmbedding)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Query(context.Background(), "hello world", 10, nil); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Expected ErrDimensionMismatch from Query, got %v", err)
+	}
+	if _, err := db.SearchSimilar(context.Background(), "hello world", 10); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Expected ErrDimensionMismatch from SearchSimilar, got %v", err)
+	}
+	if err := db.AddDocument(context.Background(), Document{ID: "doc2", Content: "hello there"}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Expected ErrDimensionMismatch from AddDocument, got %v", err)
+	}
+
+	// Once the old embeddings are gone, the database takes on the new dimension
+	if err := db.DeleteDocument("doc1"); err != nil {
+		t.Fatalf("Failed to delete document: %v", err)
+	}
+	if err := db.BatchAddDocuments(context.Background(), []Document{{ID: "doc2", Content: "hello there"}}); err != nil {
+		t.Fatalf("Failed to add document: %v", err)
+	}
+	if _, err := db.Query(context.Background(), "hello world", 10, nil); err != nil {
+		t.Errorf("Failed to query: %v", err)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := NewDocumentDB(dbPath, mockEmbedding)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	var docs []Document
+	for i := 0; i < 500; i++ {
+		docs = append(docs, Document{
+			ID:      fmt.Sprintf("old/%d", i),
+			Content: strings.Repeat("hello world ", 100),
+		})
+	}
+	docs = append(docs, Document{ID: "new/1", Content: "hello world"})
+	if err := db.BatchAddDocuments(context.Background(), docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+	if err := db.DeleteDocumentsWithPrefix("old/"); err != nil {
+		t.Fatalf("Failed to delete documents: %v", err)
+	}
+
+	result, err := db.Compact()
+	if err != nil {
+		t.Fatalf("Failed to compact database: %v", err)
+	}
+	if result.SizeAfter >= result.SizeBefore {
+		t.Errorf("Expected compaction to shrink the database, but it went from %d to %d bytes", result.SizeBefore, result.SizeAfter)
+	}
+
+	// The database is still usable, and still holds the live documents
+	if _, err := db.GetDocument("new/1"); err != nil {
+		t.Errorf("Failed to get document after compacting: %v", err)
+	}
+	if err := db.AddDocument(context.Background(), Document{ID: "new/2", Content: "hello there"}); err != nil {
+		t.Errorf("Failed to add document after compacting: %v", err)
+	}
+	if count, err := db.Count(); err != nil || count != 2 {
+		t.Errorf("Expected 2 documents after compacting, got %d (%v)", count, err)
+	}
+	if _, err := os.Stat(dbPath + ".compact"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the compacted copy to replace the database, got %v", err)
+	}
+}
+
+func TestAddDocumentsCancelled(t *testing.T) {
+	db, err := NewDocumentDB(filepath.Join(t.TempDir(), "test.db"), func(ctx context.Context, content string) ([]float32, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return mockEmbedding(ctx, content)
+	})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = db.BatchAddDocuments(ctx, []Document{{ID: "doc1", Content: "hello world"}})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if _, err := db.Query(ctx, "hello world", 10, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from Query, got %v", err)
+	}
+	if count, _ := db.Count(); count != 0 {
+		t.Errorf("Expected no documents to be added, got %d", count)
+	}
+}
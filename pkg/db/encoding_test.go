@@ -0,0 +1,188 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestEncodeDocument(t *testing.T) {
+	doc := Document{
+		ID:       "doc1",
+		Content:  "hello world",
+		Metadata: map[string]string{"type": "greeting"},
+		Vector:   []float32{1.5, -0.25, 0},
+	}
+
+	data, err := encodeDocument(doc)
+	if err != nil {
+		t.Fatalf("Failed to encode document: %v", err)
+	}
+	decoded, err := decodeDocument(data)
+	if err != nil {
+		t.Fatalf("Failed to decode document: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, doc) {
+		t.Errorf("Decoded document %+v differs from original %+v", decoded, doc)
+	}
+
+	// Documents stored as JSON by older versions can still be read
+	legacy, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Failed to marshal document: %v", err)
+	}
+	decoded, err = decodeDocument(legacy)
+	if err != nil {
+		t.Fatalf("Failed to decode legacy document: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, doc) {
+		t.Errorf("Decoded legacy document %+v differs from original %+v", decoded, doc)
+	}
+
+	if _, err := decodeDocument(data[:10]); err == nil {
+		t.Errorf("Expected an error decoding a truncated document")
+	}
+}
+
+func TestMigrateVectors(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	// Write documents the way older versions did, as JSON, without the meta bucket
+	boltDB, err := bolt.Open(dbPath, 0600, nil)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	err = boltDB.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket(documentsBucket)
+		if err != nil {
+			return err
+		}
+		for _, content := range []string{"hello world", "goodbye world"} {
+			vector, _ := mockEmbedding(context.Background(), content)
+			data, err := json.Marshal(Document{ID: content, Content: content, Vector: vector})
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(content), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to write legacy documents: %v", err)
+	}
+	boltDB.Close()
+
+	db, err := NewDocumentDB(dbPath, mockEmbedding)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	err = db.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(documentsBucket).ForEach(func(k, v []byte) error {
+			if v[0] != binaryDocumentFormat {
+				return fmt.Errorf("document %s wasn't migrated", k)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	results, err := db.Query(context.Background(), "hello world", 1, nil)
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(results) != 1 || results[0].Document.ID != "hello world" || results[0].Similarity < 0.99 {
+		t.Errorf("Unexpected query results after migration: %+v", results)
+	}
+}
+
+// benchmarkDocument is a chunk with an embedding the size of those from the default model
+func benchmarkDocument() Document {
+	vector := make([]float32, 768)
+	for i := range vector {
+		vector[i] = float32(i%17) / 17
+	}
+
+	return Document{
+		ID:      "repo:pkg/index/index.go:0",
+		Content: "Indexes files in the repository, summarizing each and embedding the summaries",
+		Metadata: map[string]string{
+			"path":          "pkg/index/index.go",
+			"language":      "Go",
+			"start_line":    "1",
+			"end_line":      "120",
+			"is_file_entry": "false",
+			"namespace":     "repo",
+		},
+		Vector: vector,
+	}
+}
+
+// BenchmarkDecodeDocument compares decoding a stored document with a JSON vector, as older
+// versions stored them, to decoding one with a binary vector
+func BenchmarkDecodeDocument(b *testing.B) {
+	doc := benchmarkDocument()
+
+	legacy, err := json.Marshal(doc)
+	if err != nil {
+		b.Fatal(err)
+	}
+	packed, err := encodeDocument(doc)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, bench := range []struct {
+		name string
+		data []byte
+	}{{"json", legacy}, {"binary", packed}} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportMetric(float64(len(bench.data)), "bytes/doc")
+			for i := 0; i < b.N; i++ {
+				if _, err := decodeDocument(bench.data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkQuery measures a query scanning every document in the database
+func BenchmarkQuery(b *testing.B) {
+	doc := benchmarkDocument()
+	embed := func(context.Context, string) ([]float32, error) {
+		return doc.Vector, nil
+	}
+
+	db, err := NewDocumentDB(filepath.Join(b.TempDir(), "bench.db"), embed)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+
+	docs := make([]Document, 1000)
+	for i := range docs {
+		docs[i] = doc
+		docs[i].ID = fmt.Sprintf("repo:file%d.go:0", i)
+	}
+	if err := db.BatchAddDocuments(context.Background(), docs); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := db.Query(context.Background(), "query", 10, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package db
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// BM25 parameters: bm25K1 limits how much repeating a term raises a document's score, and
+// bm25B how much long documents are penalized
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// tokenize splits text into lower case terms for keyword matching. Identifiers are kept
+// whole, and are also split at underscores and changes of case, so that ComputeID matches
+// both "computeid" and "compute id".
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+
+	var terms []string
+	for _, word := range words {
+		parts := splitIdentifier(word)
+		terms = append(terms, strings.ToLower(strings.ReplaceAll(word, "_", "")))
+		if len(parts) > 1 {
+			for _, part := range parts {
+				terms = append(terms, strings.ToLower(part))
+			}
+		}
+	}
+	return terms
+}
+
+// splitIdentifier splits an identifier at underscores and changes of case, keeping runs of
+// capitals together, eg HTTPServer_init becomes HTTP, Server and init
+func splitIdentifier(word string) []string {
+	var parts []string
+	for _, segment := range strings.Split(word, "_") {
+		runes := []rune(segment)
+		start := 0
+		for i := 1; i < len(runes); i++ {
+			lowerToUpper := unicode.IsLower(runes[i-1]) && unicode.IsUpper(runes[i])
+			acronymEnd := unicode.IsUpper(runes[i-1]) && unicode.IsUpper(runes[i]) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if lowerToUpper || acronymEnd {
+				parts = append(parts, string(runes[start:i]))
+				start = i
+			}
+		}
+		if start < len(runes) {
+			parts = append(parts, string(runes[start:]))
+		}
+	}
+	return parts
+}
+
+// keywordScores scores each document's path and content against the query's terms with
+// BM25, using the documents themselves as the corpus. Scores are scaled so that the best
+// match scores 1, and documents matching no terms score 0.
+func keywordScores(query string, docs []Document) []float64 {
+	terms := make(map[string]bool)
+	for _, term := range tokenize(query) {
+		terms[term] = true
+	}
+
+	// Count the query's terms in each document, and how many documents contain each term
+	counts := make([]map[string]int, len(docs))
+	lengths := make([]int, len(docs))
+	frequency := make(map[string]int)
+	totalLength := 0
+	for idx, doc := range docs {
+		docTerms := tokenize(doc.Metadata["path"] + " " + doc.Content)
+		lengths[idx] = len(docTerms)
+		totalLength += len(docTerms)
+
+		counts[idx] = make(map[string]int)
+		for _, term := range docTerms {
+			if terms[term] {
+				counts[idx][term]++
+			}
+		}
+		for term := range counts[idx] {
+			frequency[term]++
+		}
+	}
+
+	scores := make([]float64, len(docs))
+	if len(docs) == 0 || totalLength == 0 {
+		return scores
+	}
+
+	averageLength := float64(totalLength) / float64(len(docs))
+	best := 0.0
+	for idx := range docs {
+		for term, count := range counts[idx] {
+			idf := math.Log(1 + (float64(len(docs))-float64(frequency[term])+0.5)/(float64(frequency[term])+0.5))
+			tf := float64(count)
+			scores[idx] += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(lengths[idx])/averageLength))
+		}
+		best = max(best, scores[idx])
+	}
+
+	if best > 0 {
+		for idx := range scores {
+			scores[idx] /= best
+		}
+	}
+
+	return scores
+}
+
+// HybridRank re-ranks search results by blending their vector similarity with how well
+// their paths and content match the query's keywords, so that queries naming an
+// identifier find the code that defines it. keywordWeight is the share of the score given
+// to keywords, from 0 for vector similarity alone to 1 for keywords alone. Each result's
+// Similarity becomes the blended score, and the results are sorted by it.
+//
+// Keyword scores are relative to the results given, so they should include every document
+// that could match rather than only the nearest vectors.
+func HybridRank(query string, results []SearchResult, keywordWeight float64) []SearchResult {
+	keywordWeight = min(max(keywordWeight, 0), 1)
+
+	docs := make([]Document, len(results))
+	for idx, result := range results {
+		docs[idx] = result.Document
+	}
+
+	ranked := make([]SearchResult, len(results))
+	for idx, score := range keywordScores(query, docs) {
+		ranked[idx] = SearchResult{
+			Document:     results[idx].Document,
+			Similarity:   (1-keywordWeight)*results[idx].Similarity + keywordWeight*score,
+			KeywordScore: score,
+		}
+	}
+
+	sort.SliceStable(ranked, func(a, b int) bool {
+		return ranked[a].Similarity > ranked[b].Similarity
+	})
+
+	return ranked
+}
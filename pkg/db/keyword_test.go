@@ -0,0 +1,54 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	got := tokenize("func ComputeID(namespace string) // parse HTTPServer_init")
+	want := []string{
+		"func",
+		"computeid", "compute", "id",
+		"namespace",
+		"string",
+		"parse",
+		"httpserverinit", "http", "server", "init",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tokenize() = %q, want %q", got, want)
+	}
+}
+
+func TestHybridRank(t *testing.T) {
+	results := []SearchResult{
+		{Document: Document{ID: "hash", Content: "Computes a hash of the file content for change detection"}, Similarity: 0.62},
+		{Document: Document{ID: "walk", Content: "Walks the repository and computes which files to index"}, Similarity: 0.58},
+		{Document: Document{ID: "id", Content: "func ComputeID(namespace string, path string, idx int) string"}, Similarity: 0.41},
+		{Document: Document{ID: "other", Content: "Renders the progress bar"}, Similarity: 0.2},
+	}
+
+	// The definition of the identifier is ranked first once keywords count
+	ranked := HybridRank("ComputeID", results, 0.5)
+	if ranked[0].Document.ID != "id" {
+		t.Errorf("Expected the definition of ComputeID first, got %+v", ranked)
+	}
+	if ranked[0].KeywordScore != 1 {
+		t.Errorf("Expected the best keyword match to score 1, got %f", ranked[0].KeywordScore)
+	}
+	for idx := 1; idx < len(ranked); idx++ {
+		if ranked[idx].Similarity > ranked[idx-1].Similarity {
+			t.Errorf("Results aren't sorted by blended score: %+v", ranked)
+		}
+	}
+
+	// Without keywords, the vector ranking is kept
+	ranked = HybridRank("ComputeID", results, 0)
+	if ranked[0].Document.ID != "hash" || ranked[0].Similarity != 0.62 {
+		t.Errorf("Expected the vector ranking to be unchanged, got %+v", ranked)
+	}
+
+	if ranked := HybridRank("ComputeID", nil, 0.5); len(ranked) != 0 {
+		t.Errorf("Expected no results, got %+v", ranked)
+	}
+}
@@ -0,0 +1,200 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MemoryDB is a DocumentStore that keeps all documents in memory. Nothing is written to disk,
+// which makes it suitable for tests and ephemeral runs.
+type MemoryDB struct {
+	mu            sync.RWMutex
+	docs          map[string]Document
+	embedDocument EmbeddingFunc
+}
+
+// NewMemoryDB creates an empty in-memory document store with the specified embedding function
+func NewMemoryDB(embedFn EmbeddingFunc) *MemoryDB {
+	return &MemoryDB{
+		docs:          make(map[string]Document),
+		embedDocument: embedFn,
+	}
+}
+
+// Close discards all documents
+func (mdb *MemoryDB) Close() error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	mdb.docs = make(map[string]Document)
+	return nil
+}
+
+// AddDocument adds a new document to the store
+func (mdb *MemoryDB) AddDocument(ctx context.Context, doc Document) error {
+	return mdb.BatchAddDocuments(ctx, []Document{doc})
+}
+
+// BatchAddDocuments adds multiple documents. No documents are added if any fail to embed.
+func (mdb *MemoryDB) BatchAddDocuments(ctx context.Context, docs []Document) error {
+	embedded := make([]Document, 0, len(docs))
+	for _, doc := range docs {
+		if doc.ID == "" {
+			return errors.New("document ID cannot be empty")
+		}
+
+		// Generate embedding for the document
+		vector, err := mdb.embedDocument(ctx, doc.Content)
+		if err != nil {
+			return err
+		}
+		doc.Vector = vector
+
+		embedded = append(embedded, copyDocument(doc))
+	}
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	for _, doc := range embedded {
+		mdb.docs[doc.ID] = doc
+	}
+	return nil
+}
+
+// GetDocument retrieves a document by ID
+func (mdb *MemoryDB) GetDocument(id string) (Document, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	doc, ok := mdb.docs[id]
+	if !ok {
+		return Document{}, ErrNotFound
+	}
+	return copyDocument(doc), nil
+}
+
+// DeleteDocumentsWithPrefix deletes all documents whose IDs start with the given prefix
+func (mdb *MemoryDB) DeleteDocumentsWithPrefix(prefix string) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	for id := range mdb.docs {
+		if strings.HasPrefix(id, prefix) {
+			delete(mdb.docs, id)
+		}
+	}
+	return nil
+}
+
+// FilterDocuments returns documents that match the given metadata filters, ordered by ID
+func (mdb *MemoryDB) FilterDocuments(filters map[string]string) ([]Document, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	var matches []Document
+	for _, id := range mdb.sortedIDs() {
+		doc := mdb.docs[id]
+		if matchesFilters(doc, filters) {
+			matches = append(matches, copyDocument(doc))
+		}
+	}
+	return matches, nil
+}
+
+// Query finds documents matching the metadata filters and ranks them by similarity to the query content
+func (mdb *MemoryDB) Query(ctx context.Context, queryContent string, limit int, filters map[string]string) ([]SearchResult, error) {
+	queryVector, err := mdb.embedDocument(ctx, queryContent)
+	if err != nil {
+		return nil, err
+	}
+
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	var results []SearchResult
+	for _, id := range mdb.sortedIDs() {
+		doc := mdb.docs[id]
+		if !matchesFilters(doc, filters) {
+			continue
+		}
+
+		results = append(results, SearchResult{
+			Document:   copyDocument(doc),
+			Similarity: cosineSimilarity(queryVector, doc.Vector),
+		})
+	}
+
+	// Sort by similarity (higher is better), keeping ID order for ties
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Similarity > results[j].Similarity
+	})
+
+	if limit > len(results) {
+		limit = len(results)
+	}
+
+	return results[:limit], nil
+}
+
+// Count returns the total number of documents in the store
+func (mdb *MemoryDB) Count() (int, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	return len(mdb.docs), nil
+}
+
+// CountDocuments returns the number of documents that match the given metadata filters
+func (mdb *MemoryDB) CountDocuments(filters map[string]string) (int, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	count := 0
+	for _, doc := range mdb.docs {
+		if matchesFilters(doc, filters) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// sortedIDs returns the IDs of all documents in order. The caller must hold the lock.
+func (mdb *MemoryDB) sortedIDs() []string {
+	ids := make([]string, 0, len(mdb.docs))
+	for id := range mdb.docs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// matchesFilters checks if a document's metadata matches all of the filters
+func matchesFilters(doc Document, filters map[string]string) bool {
+	for k, v := range filters {
+		if doc.Metadata[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// copyDocument returns a copy of the document that shares no maps or slices with the original
+func copyDocument(doc Document) Document {
+	if doc.Metadata != nil {
+		metadata := make(map[string]string, len(doc.Metadata))
+		for k, v := range doc.Metadata {
+			metadata[k] = v
+		}
+		doc.Metadata = metadata
+	}
+
+	if doc.Vector != nil {
+		doc.Vector = append([]float32(nil), doc.Vector...)
+	}
+
+	return doc
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMemoryDB(t *testing.T) {
+	db := NewMemoryDB(mockEmbedding)
+	defer db.Close()
+
+	docs := []Document{
+		{ID: "doc1", Content: "hello world", Metadata: map[string]string{"type": "greeting"}},
+		{ID: "doc2", Content: "hello there", Metadata: map[string]string{"type": "greeting"}},
+		{ID: "doc3", Content: "goodbye world", Metadata: map[string]string{"type": "farewell"}},
+		{ID: "other", Content: "something else", Metadata: map[string]string{"type": "other"}},
+	}
+	if err := db.BatchAddDocuments(context.Background(), docs); err != nil {
+		t.Fatalf("Failed to add documents: %v", err)
+	}
+
+	doc, err := db.GetDocument("doc1")
+	if err != nil {
+		t.Fatalf("Failed to get document: %v", err)
+	}
+	if doc.Content != "hello world" || len(doc.Vector) != 3 {
+		t.Errorf("Unexpected document: %+v", doc)
+	}
+
+	// Returned documents must not alias the stored ones
+	doc.Metadata["type"] = "changed"
+	if doc, _ := db.GetDocument("doc1"); doc.Metadata["type"] != "greeting" {
+		t.Errorf("Stored document was modified through a returned copy")
+	}
+
+	results, err := db.Query(context.Background(), "hello world", 10, map[string]string{"type": "greeting"})
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(results) != 2 || results[0].Document.ID != "doc1" || results[1].Document.ID != "doc2" {
+		t.Errorf("Unexpected query results: %+v", results)
+	}
+
+	filtered, err := db.FilterDocuments(map[string]string{"type": "farewell"})
+	if err != nil {
+		t.Fatalf("Failed to filter: %v", err)
+	}
+	if len(filtered) != 1 || filtered[0].ID != "doc3" {
+		t.Errorf("Unexpected filtered documents: %+v", filtered)
+	}
+
+	if count, err := db.CountDocuments(map[string]string{"type": "greeting"}); err != nil || count != 2 {
+		t.Errorf("CountDocuments() = (%d, %v), want 2", count, err)
+	}
+
+	if err := db.DeleteDocumentsWithPrefix("doc"); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if count, _ := db.Count(); count != 1 {
+		t.Errorf("Expected 1 document after delete, got %d", count)
+	}
+	if _, err := db.GetDocument("doc1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
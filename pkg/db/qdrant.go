@@ -0,0 +1,430 @@
+//go:build qdrant
+
+package db
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// qdrantPageSize is the number of points fetched per request when scrolling a collection
+const qdrantPageSize = 256
+
+// errCollectionNotFound is returned by the Qdrant API when the collection doesn't exist yet
+var errCollectionNotFound = errors.New("qdrant collection not found")
+
+// QdrantDB is a DocumentStore backed by a Qdrant collection, which is accessed through Qdrant's
+// REST API. The collection is created with cosine distance the first time a document is
+// added, sized to the dimension of that document's embedding.
+type QdrantDB struct {
+	baseURL       string
+	collection    string
+	client        *http.Client
+	embedDocument EmbeddingFunc
+}
+
+// qdrantPoint is a point as stored in and returned by Qdrant
+type qdrantPoint struct {
+	ID      interface{}   `json:"id"`
+	Vector  []float32     `json:"vector,omitempty"`
+	Payload qdrantPayload `json:"payload"`
+	Score   float64       `json:"score,omitempty"`
+}
+
+// qdrantPayload holds the document fields. Qdrant point IDs must be integers or UUIDs, so
+// the document ID is kept in the payload.
+type qdrantPayload struct {
+	ID       string            `json:"id"`
+	Content  string            `json:"content"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// NewQdrantDB creates a document store using the named collection on the Qdrant server at
+// baseURL, eg http://localhost:6333
+func NewQdrantDB(baseURL, collection string, embedFn EmbeddingFunc) (*QdrantDB, error) {
+	if _, err := url.Parse(baseURL); err != nil {
+		return nil, fmt.Errorf("invalid qdrant URL: %w", err)
+	}
+
+	if collection == "" {
+		return nil, errors.New("qdrant collection name cannot be empty")
+	}
+
+	qdb := &QdrantDB{
+		baseURL:       strings.TrimSuffix(baseURL, "/"),
+		collection:    collection,
+		client:        &http.Client{Timeout: 30 * time.Second},
+		embedDocument: embedFn,
+	}
+
+	// Make sure the server is reachable before indexing starts
+	if err := qdb.do(http.MethodGet, "/collections", nil, nil); err != nil {
+		return nil, fmt.Errorf("failed to connect to qdrant: %w", err)
+	}
+
+	return qdb, nil
+}
+
+// Close releases idle connections to the Qdrant server
+func (qdb *QdrantDB) Close() error {
+	qdb.client.CloseIdleConnections()
+	return nil
+}
+
+// AddDocument adds a new document to the collection
+func (qdb *QdrantDB) AddDocument(ctx context.Context, doc Document) error {
+	return qdb.BatchAddDocuments(ctx, []Document{doc})
+}
+
+// BatchAddDocuments adds multiple documents to the collection in a single request
+func (qdb *QdrantDB) BatchAddDocuments(ctx context.Context, docs []Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	points := make([]qdrantPoint, 0, len(docs))
+	for _, doc := range docs {
+		if doc.ID == "" {
+			return errors.New("document ID cannot be empty")
+		}
+
+		// Generate embedding for the document
+		vector, err := qdb.embedDocument(ctx, doc.Content)
+		if err != nil {
+			return err
+		}
+
+		points = append(points, qdrantPoint{
+			ID:     qdrantPointID(doc.ID),
+			Vector: vector,
+			Payload: qdrantPayload{
+				ID:       doc.ID,
+				Content:  doc.Content,
+				Metadata: doc.Metadata,
+			},
+		})
+	}
+
+	if err := qdb.ensureCollection(len(points[0].Vector)); err != nil {
+		return err
+	}
+
+	body := map[string]interface{}{"points": points}
+	if err := qdb.do(http.MethodPut, qdb.collectionPath("/points?wait=true"), body, nil); err != nil {
+		return fmt.Errorf("failed to upsert points: %w", err)
+	}
+
+	return nil
+}
+
+// GetDocument retrieves a document by ID
+func (qdb *QdrantDB) GetDocument(id string) (Document, error) {
+	body := map[string]interface{}{
+		"ids":          []string{qdrantPointID(id)},
+		"with_payload": true,
+		"with_vector":  true,
+	}
+
+	var points []qdrantPoint
+	err := qdb.do(http.MethodPost, qdb.collectionPath("/points"), body, &points)
+	if errors.Is(err, errCollectionNotFound) {
+		return Document{}, ErrNotFound
+	} else if err != nil {
+		return Document{}, fmt.Errorf("failed to retrieve point: %w", err)
+	}
+
+	if len(points) == 0 {
+		return Document{}, ErrNotFound
+	}
+
+	return points[0].document(), nil
+}
+
+// DeleteDocumentsWithPrefix deletes all documents whose IDs start with the given prefix.
+// Qdrant can't filter on a prefix, so this scrolls through the document IDs in the collection.
+func (qdb *QdrantDB) DeleteDocumentsWithPrefix(prefix string) error {
+	var ids []string
+	err := qdb.scroll(nil, []string{"id"}, false, func(point qdrantPoint) {
+		if strings.HasPrefix(point.Payload.ID, prefix) {
+			ids = append(ids, qdrantPointID(point.Payload.ID))
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	body := map[string]interface{}{"points": ids}
+	if err := qdb.do(http.MethodPost, qdb.collectionPath("/points/delete?wait=true"), body, nil); err != nil {
+		return fmt.Errorf("failed to delete points: %w", err)
+	}
+
+	return nil
+}
+
+// FilterDocuments returns documents that match the given metadata filters
+func (qdb *QdrantDB) FilterDocuments(filters map[string]string) ([]Document, error) {
+	var docs []Document
+	err := qdb.scroll(filters, true, true, func(point qdrantPoint) {
+		docs = append(docs, point.document())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return docs, nil
+}
+
+// Query finds documents matching the metadata filters and ranks them by similarity to the query content
+func (qdb *QdrantDB) Query(ctx context.Context, queryContent string, limit int, filters map[string]string) ([]SearchResult, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	queryVector, err := qdb.embedDocument(ctx, queryContent)
+	if err != nil {
+		return nil, err
+	}
+
+	body := map[string]interface{}{
+		"vector":       queryVector,
+		"limit":        limit,
+		"with_payload": true,
+		"with_vector":  true,
+	}
+	if filter := qdrantFilter(filters); filter != nil {
+		body["filter"] = filter
+	}
+
+	var points []qdrantPoint
+	err = qdb.do(http.MethodPost, qdb.collectionPath("/points/search"), body, &points)
+	if errors.Is(err, errCollectionNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to search points: %w", err)
+	}
+
+	results := make([]SearchResult, 0, len(points))
+	for _, point := range points {
+		results = append(results, SearchResult{
+			Document:   point.document(),
+			Similarity: point.Score,
+		})
+	}
+
+	return results, nil
+}
+
+// Count returns the total number of documents in the collection
+func (qdb *QdrantDB) Count() (int, error) {
+	var result struct {
+		Count int `json:"count"`
+	}
+
+	err := qdb.do(http.MethodPost, qdb.collectionPath("/points/count"), map[string]interface{}{"exact": true}, &result)
+	if errors.Is(err, errCollectionNotFound) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to count points: %w", err)
+	}
+
+	return result.Count, nil
+}
+
+// CountDocuments returns the number of documents that match the given metadata filters
+func (qdb *QdrantDB) CountDocuments(filters map[string]string) (int, error) {
+	var result struct {
+		Count int `json:"count"`
+	}
+
+	body := map[string]interface{}{"exact": true}
+	if filter := qdrantFilter(filters); filter != nil {
+		body["filter"] = filter
+	}
+
+	err := qdb.do(http.MethodPost, qdb.collectionPath("/points/count"), body, &result)
+	if errors.Is(err, errCollectionNotFound) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to count points: %w", err)
+	}
+
+	return result.Count, nil
+}
+
+// ensureCollection creates the collection if it doesn't exist
+func (qdb *QdrantDB) ensureCollection(dimension int) error {
+	err := qdb.do(http.MethodGet, qdb.collectionPath(""), nil, nil)
+	if err == nil {
+		return nil
+	} else if !errors.Is(err, errCollectionNotFound) {
+		return fmt.Errorf("failed to get collection: %w", err)
+	}
+
+	body := map[string]interface{}{
+		"vectors": map[string]interface{}{
+			"size":     dimension,
+			"distance": "Cosine",
+		},
+	}
+	if err := qdb.do(http.MethodPut, qdb.collectionPath(""), body, nil); err != nil {
+		return fmt.Errorf("failed to create collection: %w", err)
+	}
+
+	// Index the metadata fields the indexer filters on
+	for _, field := range []string{"metadata.is_file_entry", "metadata.namespace", "metadata.path"} {
+		index := map[string]interface{}{
+			"field_name":   field,
+			"field_schema": "keyword",
+		}
+		if err := qdb.do(http.MethodPut, qdb.collectionPath("/index?wait=true"), index, nil); err != nil {
+			return fmt.Errorf("failed to index %s: %w", field, err)
+		}
+	}
+
+	return nil
+}
+
+// scroll calls fn for every point matching the filters. withPayload may be a bool or a list
+// of payload fields to return.
+func (qdb *QdrantDB) scroll(filters map[string]string, withPayload interface{}, withVector bool, fn func(qdrantPoint)) error {
+	var offset interface{}
+	for {
+		body := map[string]interface{}{
+			"limit":        qdrantPageSize,
+			"with_payload": withPayload,
+			"with_vector":  withVector,
+		}
+		if filter := qdrantFilter(filters); filter != nil {
+			body["filter"] = filter
+		}
+		if offset != nil {
+			body["offset"] = offset
+		}
+
+		var page struct {
+			Points         []qdrantPoint `json:"points"`
+			NextPageOffset interface{}   `json:"next_page_offset"`
+		}
+		err := qdb.do(http.MethodPost, qdb.collectionPath("/points/scroll"), body, &page)
+		if errors.Is(err, errCollectionNotFound) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to scroll points: %w", err)
+		}
+
+		for _, point := range page.Points {
+			fn(point)
+		}
+
+		if page.NextPageOffset == nil {
+			return nil
+		}
+		offset = page.NextPageOffset
+	}
+}
+
+// collectionPath returns the API path for the collection, followed by suffix
+func (qdb *QdrantDB) collectionPath(suffix string) string {
+	return "/collections/" + url.PathEscape(qdb.collection) + suffix
+}
+
+// do sends a request to the Qdrant API and decodes the "result" field of the response into result
+func (qdb *QdrantDB) do(method, path string, body interface{}, result interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), method, qdb.baseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := qdb.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusNotFound && strings.Contains(string(data), "doesn't exist") {
+		return errCollectionNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("qdrant returned %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	var envelope struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return fmt.Errorf("failed to decode qdrant response: %w", err)
+	}
+
+	return json.Unmarshal(envelope.Result, result)
+}
+
+// document converts the point back into a Document
+func (p qdrantPoint) document() Document {
+	return Document{
+		ID:       p.Payload.ID,
+		Content:  p.Payload.Content,
+		Metadata: p.Payload.Metadata,
+		Vector:   p.Vector,
+	}
+}
+
+// qdrantFilter converts metadata filters into a Qdrant filter requiring every field to match
+func qdrantFilter(filters map[string]string) map[string]interface{} {
+	if len(filters) == 0 {
+		return nil
+	}
+
+	must := make([]map[string]interface{}, 0, len(filters))
+	for k, v := range filters {
+		must = append(must, map[string]interface{}{
+			"key":   "metadata." + k,
+			"match": map[string]interface{}{"value": v},
+		})
+	}
+
+	return map[string]interface{}{"must": must}
+}
+
+// qdrantPointID derives a stable UUID point ID from a document ID
+func qdrantPointID(id string) string {
+	sum := sha1.Sum([]byte(id))
+	sum[6] = (sum[6] & 0x0f) | 0x50 // Version 5
+	sum[8] = (sum[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+var _ DocumentStore = (*QdrantDB)(nil)
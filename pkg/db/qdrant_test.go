@@ -0,0 +1,154 @@
+//go:build qdrant
+
+package db
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// TestQdrantParity indexes the same documents into bbolt and Qdrant and checks that both
+// stores return the same results. It needs a Qdrant server, eg:
+//
+//	docker run -p 6333:6333 qdrant/qdrant
+//	AUTOSWE_QDRANT_URL=http://localhost:6333 go test -tags qdrant ./pkg/db
+func TestQdrantParity(t *testing.T) {
+	baseURL := os.Getenv("AUTOSWE_QDRANT_URL")
+	if baseURL == "" {
+		t.Skip("AUTOSWE_QDRANT_URL is not set")
+	}
+
+	collection := fmt.Sprintf("autoswe-test-%d", time.Now().UnixNano())
+	qdb, err := NewQdrantDB(baseURL, collection, mockEmbedding)
+	if err != nil {
+		t.Fatalf("Failed to connect to qdrant: %v", err)
+	}
+	defer func() {
+		if err := qdb.do(http.MethodDelete, qdb.collectionPath(""), nil, nil); err != nil {
+			t.Errorf("Failed to delete collection: %v", err)
+		}
+		qdb.Close()
+	}()
+
+	bdb, err := NewDocumentDB(filepath.Join(t.TempDir(), "db"), mockEmbedding)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer bdb.Close()
+
+	// An empty collection behaves like an empty database
+	if count, err := qdb.Count(); err != nil || count != 0 {
+		t.Errorf("Count() on empty collection = (%d, %v), want 0", count, err)
+	}
+	if _, err := qdb.GetDocument("repo:missing.go"); err != ErrNotFound {
+		t.Errorf("GetDocument() on empty collection error = %v, want ErrNotFound", err)
+	}
+
+	docs := []Document{
+		{ID: "repo:a.go", Metadata: map[string]string{"path": "a.go", "is_file_entry": "true"}},
+		{ID: "repo:a.go#0", Content: "hello world", Metadata: map[string]string{"path": "a.go", "is_file_entry": "false"}},
+		{ID: "repo:a.go#1", Content: "hello there", Metadata: map[string]string{"path": "a.go", "is_file_entry": "false"}},
+		{ID: "repo:b.go", Metadata: map[string]string{"path": "b.go", "is_file_entry": "true"}},
+		{ID: "repo:b.go#0", Content: "goodbye world", Metadata: map[string]string{"path": "b.go", "is_file_entry": "false"}},
+	}
+
+	for _, store := range []DocumentStore{bdb, qdb} {
+		if err := store.AddDocument(context.Background(), docs[0]); err != nil {
+			t.Fatalf("Failed to add document: %v", err)
+		}
+		if err := store.BatchAddDocuments(context.Background(), docs[1:]); err != nil {
+			t.Fatalf("Failed to add documents: %v", err)
+		}
+	}
+
+	assertParity(t, bdb, qdb)
+
+	for _, store := range []DocumentStore{bdb, qdb} {
+		if err := store.DeleteDocumentsWithPrefix("repo:a.go"); err != nil {
+			t.Fatalf("Failed to delete documents: %v", err)
+		}
+	}
+
+	assertParity(t, bdb, qdb)
+}
+
+// assertParity checks that both stores hold the same documents and rank them the same way
+func assertParity(t *testing.T, want, got DocumentStore) {
+	t.Helper()
+
+	wantCount, _ := want.Count()
+	if gotCount, err := got.Count(); err != nil || gotCount != wantCount {
+		t.Errorf("Count() = (%d, %v), want %d", gotCount, err, wantCount)
+	}
+
+	filters := map[string]string{"is_file_entry": "true"}
+	wantFiltered, _ := want.CountDocuments(filters)
+	if gotFiltered, err := got.CountDocuments(filters); err != nil || gotFiltered != wantFiltered {
+		t.Errorf("CountDocuments() = (%d, %v), want %d", gotFiltered, err, wantFiltered)
+	}
+
+	wantDocs, _ := want.FilterDocuments(filters)
+	gotDocs, err := got.FilterDocuments(filters)
+	if err != nil {
+		t.Fatalf("FilterDocuments() error = %v", err)
+	}
+	if wantIDs, gotIDs := documentIDs(wantDocs), documentIDs(gotDocs); fmt.Sprint(gotIDs) != fmt.Sprint(wantIDs) {
+		t.Errorf("FilterDocuments() = %v, want %v", gotIDs, wantIDs)
+	}
+
+	for _, doc := range wantDocs {
+		gotDoc, err := got.GetDocument(doc.ID)
+		if err != nil {
+			t.Errorf("GetDocument(%s) error = %v", doc.ID, err)
+		} else if fmt.Sprint(gotDoc.Metadata) != fmt.Sprint(doc.Metadata) {
+			t.Errorf("GetDocument(%s) metadata = %v, want %v", doc.ID, gotDoc.Metadata, doc.Metadata)
+		}
+	}
+
+	chunkFilter := map[string]string{"is_file_entry": "false"}
+	wantResults, _ := want.Query(context.Background(), "hello world", 10, chunkFilter)
+	gotResults, err := got.Query(context.Background(), "hello world", 10, chunkFilter)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(gotResults) != len(wantResults) {
+		t.Fatalf("Query() returned %d results, want %d", len(gotResults), len(wantResults))
+	}
+	for i := range wantResults {
+		if gotResults[i].Document.ID != wantResults[i].Document.ID {
+			t.Errorf("Query() result %d = %s, want %s", i, gotResults[i].Document.ID, wantResults[i].Document.ID)
+		}
+		if math.Abs(gotResults[i].Similarity-wantResults[i].Similarity) > 1e-4 {
+			t.Errorf("Query() result %d similarity = %f, want %f", i, gotResults[i].Similarity, wantResults[i].Similarity)
+		}
+	}
+}
+
+func documentIDs(docs []Document) []string {
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestQdrantPointID(t *testing.T) {
+	id := qdrantPointID("repo:a.go#0")
+	if id != qdrantPointID("repo:a.go#0") {
+		t.Error("qdrantPointID() is not stable")
+	}
+	if id == qdrantPointID("repo:a.go#1") {
+		t.Error("qdrantPointID() collided for different IDs")
+	}
+	if len(id) != 36 || id[14] != '5' {
+		t.Errorf("qdrantPointID() = %q, want a version 5 UUID", id)
+	}
+}
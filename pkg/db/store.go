@@ -0,0 +1,52 @@
+package db
+
+import "context"
+
+// DocumentStore is a document-oriented vector store
+type DocumentStore interface {
+	// AddDocument embeds and stores a document, replacing any document with the same ID
+	AddDocument(ctx context.Context, doc Document) error
+
+	// BatchAddDocuments embeds and stores multiple documents
+	BatchAddDocuments(ctx context.Context, docs []Document) error
+
+	// GetDocument retrieves a document by ID, returning ErrNotFound if it doesn't exist
+	GetDocument(id string) (Document, error)
+
+	// DeleteDocumentsWithPrefix deletes all documents whose IDs start with the given prefix
+	DeleteDocumentsWithPrefix(prefix string) error
+
+	// FilterDocuments returns documents that match the given metadata filters
+	FilterDocuments(filters map[string]string) ([]Document, error)
+
+	// Query finds documents matching the metadata filters and ranks them by similarity to
+	// the query content
+	Query(ctx context.Context, queryContent string, limit int, filters map[string]string) ([]SearchResult, error)
+
+	// Count returns the total number of documents in the store
+	Count() (int, error)
+
+	// CountDocuments returns the number of documents that match the given metadata filters
+	CountDocuments(filters map[string]string) (int, error)
+
+	// Close releases resources used by the store
+	Close() error
+}
+
+// Compacter is implemented by document stores that can reclaim the space left by deleted
+// documents
+type Compacter interface {
+	Compact() (*CompactResult, error)
+}
+
+// CompactResult reports the size of a store before and after it was compacted
+type CompactResult struct {
+	SizeBefore int64 `json:"size_before"`
+	SizeAfter  int64 `json:"size_after"`
+}
+
+var (
+	_ Compacter     = (*DocumentDB)(nil)
+	_ DocumentStore = (*DocumentDB)(nil)
+	_ DocumentStore = (*MemoryDB)(nil)
+)
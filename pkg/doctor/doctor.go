@@ -0,0 +1,240 @@
+// Package doctor checks that the external programs the tools depend on are installed and working
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/russellhaering/autoswe/pkg/tools/astgrep"
+	"github.com/russellhaering/autoswe/pkg/tools/registry"
+)
+
+// checkTimeout bounds each check, as docker info can hang when the daemon is unresponsive
+const checkTimeout = 10 * time.Second
+
+// Dependency is an external program that some tools need
+type Dependency struct {
+	// Name identifies the dependency
+	Name string
+
+	// Commands are run to check the dependency works, and the first that succeeds reports
+	// its version. A program may be installed under more than one name. A command without
+	// arguments is only looked up on the PATH, for programs that can't report a version.
+	Commands [][]string
+
+	// Hint explains how to install the dependency
+	Hint string
+
+	// Tools are the tools that can't be used without the dependency
+	Tools []string
+
+	// Alternative is a dependency the tools can use instead, if any
+	Alternative string
+}
+
+// Config selects which dependencies are needed
+type Config struct {
+	// Tools selects the tools whose dependencies are checked
+	Tools registry.ToolsConfig
+
+	// ASTGrepMode is how ast_grep is run, which decides whether it needs ast-grep or Docker
+	ASTGrepMode astgrep.Mode
+}
+
+// Result is the outcome of checking a dependency
+type Result struct {
+	Dependency Dependency
+
+	// Version is the first line the check printed, or the program's path if it was only
+	// looked up, if it succeeded
+	Version string
+
+	// Err is why the dependency is unusable, or nil if it works
+	Err error
+}
+
+// Report is the outcome of checking every dependency needed by the enabled tools
+type Report struct {
+	Results []Result
+
+	// Unavailable are the enabled tools that can't be used, because a dependency is missing
+	Unavailable []string
+}
+
+// OK reports whether every dependency works
+func (r *Report) OK() bool {
+	for _, result := range r.Results {
+		if result.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// runFunc runs a command, returning its combined output
+type runFunc func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+// runCommand runs a command on the local system, or finds the program on the PATH if there
+// are no arguments, returning its path
+func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	if len(args) == 0 {
+		path, err := exec.LookPath(name)
+		return []byte(path), err
+	}
+	return exec.CommandContext(ctx, name, args...).CombinedOutput()
+}
+
+// Dependencies returns the external programs the built-in tools depend on, given how
+// ast_grep is run
+func Dependencies(astGrepMode astgrep.Mode) []Dependency {
+	deps := []Dependency{
+		{
+			Name:     "git",
+			Commands: [][]string{{"git", "--version"}},
+			Hint:     "Install git from https://git-scm.com/downloads",
+			Tools:    []string{"git_blame", "git_branch", "git_command", "git_commit", "git_log"},
+		},
+		{
+			Name:     "go",
+			Commands: [][]string{{"go", "version"}},
+			Hint:     "Install Go from https://go.dev/dl/",
+			Tools:    []string{"build", "test", "dependencies_fetch", "dependencies_list", "go_env", "fs_try_patch"},
+		},
+		{
+			Name:     "goimports",
+			Commands: [][]string{{"goimports"}},
+			Hint:     "Run: go install golang.org/x/tools/cmd/goimports@latest",
+			Tools:    []string{"format"},
+		},
+		{
+			Name:     "golangci-lint",
+			Commands: [][]string{{"golangci-lint", "version"}},
+			Hint:     "Install golangci-lint from https://golangci-lint.run/welcome/install/",
+			Tools:    []string{"lint"},
+		},
+		{
+			Name:     "docker",
+			Commands: [][]string{{"docker", "info", "--format", "Docker {{.ServerVersion}}"}},
+			Hint:     "Install Docker from https://docs.docker.com/get-docker/ and make sure the daemon is running",
+			Tools:    []string{"exec"},
+		},
+	}
+
+	astGrep := Dependency{
+		Name:     "ast-grep",
+		Commands: [][]string{{"ast-grep", "--version"}, {"sg", "--version"}},
+		Hint:     "Install ast-grep from https://ast-grep.github.io/guide/quick-start.html",
+		Tools:    []string{"ast_grep"},
+	}
+
+	switch astGrepMode {
+	case astgrep.ModeDocker:
+		deps[len(deps)-1].Tools = append(deps[len(deps)-1].Tools, "ast_grep")
+	case astgrep.ModeLocal:
+		deps = append(deps, astGrep)
+	default:
+		// ast_grep runs in Docker when ast-grep isn't installed
+		astGrep.Alternative = "docker"
+		astGrep.Hint += ", or it will be run in Docker"
+		deps = append(deps, astGrep)
+	}
+
+	return deps
+}
+
+// Check checks each dependency needed by the enabled tools
+func Check(ctx context.Context, config Config) *Report {
+	return check(ctx, config, runCommand)
+}
+
+func check(ctx context.Context, config Config, run runFunc) *Report {
+	deps := Dependencies(config.ASTGrepMode)
+
+	// A dependency is needed by the tools it serves, and by those it is an alternative for
+	needed := make(map[string]bool)
+	for _, dep := range deps {
+		if slices.ContainsFunc(dep.Tools, config.Tools.Allows) {
+			needed[dep.Name] = true
+			if dep.Alternative != "" {
+				needed[dep.Alternative] = true
+			}
+		}
+	}
+
+	report := &Report{}
+	failed := make(map[string]bool)
+
+	for _, dep := range deps {
+		if !needed[dep.Name] {
+			continue
+		}
+
+		result := checkDependency(ctx, dep, run)
+		report.Results = append(report.Results, result)
+		failed[dep.Name] = result.Err != nil
+	}
+
+	for _, result := range report.Results {
+		dep := result.Dependency
+		if result.Err == nil {
+			continue
+		}
+
+		// The tools still work if the alternative does
+		if dep.Alternative != "" && !failed[dep.Alternative] {
+			continue
+		}
+
+		for _, tool := range dep.Tools {
+			if config.Tools.Allows(tool) && !slices.Contains(report.Unavailable, tool) {
+				report.Unavailable = append(report.Unavailable, tool)
+			}
+		}
+	}
+
+	return report
+}
+
+// checkDependency runs each of the dependency's commands until one succeeds
+func checkDependency(ctx context.Context, dep Dependency, run runFunc) Result {
+	result := Result{Dependency: dep}
+
+	var errs []string
+	for _, command := range dep.Commands {
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		out, err := run(checkCtx, command[0], command[1:]...)
+		cancel()
+
+		if err == nil {
+			result.Version, _, _ = strings.Cut(strings.TrimSpace(string(out)), "\n")
+			return result
+		}
+
+		errs = append(errs, commandError(command, out, err).Error())
+	}
+
+	result.Err = errors.New(strings.Join(errs, "; "))
+	return result
+}
+
+// commandError describes why a check command failed, including the last line of its output
+func commandError(command []string, out []byte, err error) error {
+	if errors.Is(err, exec.ErrNotFound) {
+		return fmt.Errorf("%s not found on the PATH", command[0])
+	}
+
+	output := strings.TrimSpace(string(out))
+	if i := strings.LastIndex(output, "\n"); i >= 0 {
+		output = output[i+1:]
+	}
+	if output != "" {
+		return fmt.Errorf("%s failed: %w: %s", strings.Join(command, " "), err, output)
+	}
+
+	return fmt.Errorf("%s failed: %w", strings.Join(command, " "), err)
+}
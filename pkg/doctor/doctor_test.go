@@ -0,0 +1,85 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/tools/astgrep"
+	"github.com/russellhaering/autoswe/pkg/tools/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRun simulates the installed programs, with a version for each
+func fakeRun(installed map[string]string) runFunc {
+	return func(_ context.Context, name string, _ ...string) ([]byte, error) {
+		version, ok := installed[name]
+		if !ok {
+			return nil, &exec.Error{Name: name, Err: exec.ErrNotFound}
+		}
+		if version == "" {
+			return []byte("Cannot connect to the Docker daemon\n"), fmt.Errorf("exit status 1")
+		}
+		return []byte(version + "\n"), nil
+	}
+}
+
+func TestCheck(t *testing.T) {
+	run := fakeRun(map[string]string{
+		"git":    "git version 2.43.0",
+		"go":     "go version go1.23.0 linux/amd64",
+		"sg":     "ast-grep 0.30.0",
+		"docker": "",
+	})
+
+	report := check(context.Background(), Config{ASTGrepMode: astgrep.ModeAuto}, run)
+	assert.False(t, report.OK())
+
+	results := make(map[string]Result)
+	for _, result := range report.Results {
+		results[result.Dependency.Name] = result
+	}
+	require.Len(t, results, 6)
+
+	assert.Equal(t, "git version 2.43.0", results["git"].Version)
+	assert.Equal(t, "ast-grep 0.30.0", results["ast-grep"].Version, "ast-grep may be installed as sg")
+	assert.ErrorContains(t, results["goimports"].Err, "goimports not found on the PATH")
+	assert.ErrorContains(t, results["docker"].Err, "Cannot connect to the Docker daemon")
+
+	// ast_grep is still available, as it doesn't need Docker when ast-grep is installed
+	assert.Equal(t, []string{"format", "lint", "exec"}, report.Unavailable)
+}
+
+func TestCheckDisabledTools(t *testing.T) {
+	run := fakeRun(map[string]string{"git": "git version 2.43.0"})
+
+	// Dependencies of disabled tools aren't checked, and in Docker mode ast_grep needs Docker
+	report := check(context.Background(), Config{
+		Tools:       registry.ToolsConfig{Enabled: []string{"git_log", "ast_grep"}},
+		ASTGrepMode: astgrep.ModeDocker,
+	}, run)
+
+	require.Len(t, report.Results, 2)
+	assert.Equal(t, "git", report.Results[0].Dependency.Name)
+	assert.Equal(t, "docker", report.Results[1].Dependency.Name)
+	assert.Equal(t, []string{"ast_grep"}, report.Unavailable)
+}
+
+func TestCheckAlternative(t *testing.T) {
+	run := fakeRun(map[string]string{"docker": "Docker 27.3.1"})
+	config := Config{
+		Tools:       registry.ToolsConfig{Enabled: []string{"ast_grep"}},
+		ASTGrepMode: astgrep.ModeAuto,
+	}
+
+	// Docker is checked when ast_grep might fall back to it, even though exec is disabled
+	report := check(context.Background(), config, run)
+	require.Len(t, report.Results, 2)
+	assert.Error(t, report.Results[1].Err)
+	assert.Empty(t, report.Unavailable)
+
+	report = check(context.Background(), config, fakeRun(nil))
+	assert.Equal(t, []string{"ast_grep"}, report.Unavailable)
+}
@@ -0,0 +1,226 @@
+package index
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/russellhaering/autoswe/pkg/db"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"go.uber.org/zap"
+)
+
+// AnalyticsFileName is the name of the query analytics log within StoragePath
+const AnalyticsFileName = "analytics.jsonl"
+
+// maxTopScores is the number of leading similarity scores recorded for each query
+const maxTopScores = 3
+
+// QueryRecord is a single query recorded in the analytics store
+type QueryRecord struct {
+	Time      time.Time `json:"time"`
+	Query     string    `json:"query"`
+	Hits      int       `json:"hits"`
+	TopScores []float64 `json:"top_scores"`
+	Answered  bool      `json:"answered"`
+}
+
+// AnalyticsStore records queries to a local JSON lines file. Nothing leaves the machine.
+type AnalyticsStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewAnalyticsStore creates an analytics store backed by the file at path
+func NewAnalyticsStore(path string) *AnalyticsStore {
+	return &AnalyticsStore{path: path}
+}
+
+// Record appends a query record to the store
+func (s *AnalyticsStore) Record(record QueryRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal query record: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create analytics directory: %w", err)
+	}
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open analytics file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write query record: %w", err)
+	}
+
+	return nil
+}
+
+// Load reads every query record from the store. A missing store has no records.
+func (s *AnalyticsStore) Load() ([]QueryRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open analytics file: %w", err)
+	}
+	defer f.Close()
+
+	var records []QueryRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+
+		var record QueryRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			log.Warn("Skipping malformed query record", zap.Error(err))
+			continue
+		}
+		records = append(records, record)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read analytics file: %w", err)
+	}
+
+	return records, nil
+}
+
+// QueryStats aggregates every run of the same query
+type QueryStats struct {
+	Query       string  `json:"query"`
+	Count       int     `json:"count"`
+	AvgHits     float64 `json:"avg_hits"`
+	AvgTopScore float64 `json:"avg_top_score"`
+	Answered    int     `json:"answered"`
+}
+
+// AnalyticsSummary summarizes the recorded queries
+type AnalyticsSummary struct {
+	TotalQueries int          `json:"total_queries"`
+	Common       []QueryStats `json:"common"`
+	LowRecall    []QueryStats `json:"low_recall"`
+}
+
+// SummarizeQueries aggregates query records by query text, returning up to limit of the
+// most common queries and of the queries with the poorest results
+func SummarizeQueries(records []QueryRecord, limit int) AnalyticsSummary {
+	type totals struct {
+		query    string
+		count    int
+		hits     int
+		topScore float64
+		answered int
+	}
+
+	byQuery := make(map[string]*totals)
+	for _, record := range records {
+		key := strings.ToLower(strings.TrimSpace(record.Query))
+
+		t, ok := byQuery[key]
+		if !ok {
+			t = &totals{query: strings.TrimSpace(record.Query)}
+			byQuery[key] = t
+		}
+
+		t.count++
+		t.hits += record.Hits
+		if len(record.TopScores) > 0 {
+			t.topScore += record.TopScores[0]
+		}
+		if record.Answered {
+			t.answered++
+		}
+	}
+
+	var stats []QueryStats
+	for _, t := range byQuery {
+		stats = append(stats, QueryStats{
+			Query:       t.query,
+			Count:       t.count,
+			AvgHits:     float64(t.hits) / float64(t.count),
+			AvgTopScore: t.topScore / float64(t.count),
+			Answered:    t.answered,
+		})
+	}
+
+	common := make([]QueryStats, len(stats))
+	copy(common, stats)
+	sort.Slice(common, func(a, b int) bool {
+		if common[a].Count != common[b].Count {
+			return common[a].Count > common[b].Count
+		}
+		return common[a].Query < common[b].Query
+	})
+
+	// A query has low recall if it was never answered or its best match was weak
+	var lowRecall []QueryStats
+	for _, s := range stats {
+		if s.Answered == 0 || s.AvgTopScore < DefaultSimilarityThreshold {
+			lowRecall = append(lowRecall, s)
+		}
+	}
+	sort.Slice(lowRecall, func(a, b int) bool {
+		if lowRecall[a].AvgTopScore != lowRecall[b].AvgTopScore {
+			return lowRecall[a].AvgTopScore < lowRecall[b].AvgTopScore
+		}
+		return lowRecall[a].Query < lowRecall[b].Query
+	})
+
+	if limit > 0 {
+		if len(common) > limit {
+			common = common[:limit]
+		}
+		if len(lowRecall) > limit {
+			lowRecall = lowRecall[:limit]
+		}
+	}
+
+	return AnalyticsSummary{
+		TotalQueries: len(records),
+		Common:       common,
+		LowRecall:    lowRecall,
+	}
+}
+
+// recordQuery records a query to the analytics store, if analytics are enabled
+func (i *Indexer) recordQuery(query string, results []db.SearchResult, answered bool) {
+	if i.analytics == nil {
+		return
+	}
+
+	var topScores []float64
+	for idx := 0; idx < len(results) && idx < maxTopScores; idx++ {
+		topScores = append(topScores, results[idx].Similarity)
+	}
+
+	err := i.analytics.Record(QueryRecord{
+		Time:      time.Now().UTC(),
+		Query:     query,
+		Hits:      len(results),
+		TopScores: topScores,
+		Answered:  answered,
+	})
+	if err != nil {
+		log.Warn("Failed to record query analytics", zap.Error(err))
+	}
+}
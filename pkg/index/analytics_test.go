@@ -0,0 +1,99 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/db"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryAnalytics(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "auth.go"), []byte("package auth\n"), 0644))
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	require.NoError(t, err)
+
+	docDB, err := db.NewDocumentDB(filepath.Join(t.TempDir(), "db"), func(_ context.Context, content string) ([]float32, error) {
+		if content == "Validates auth tokens" || content == "auth" {
+			return []float32{1, 0}, nil
+		}
+		return []float32{0, 1}, nil
+	})
+	require.NoError(t, err)
+	defer docDB.Close()
+
+	require.NoError(t, docDB.AddDocument(context.Background(), chunkEntry("auth.go", 0, 1, 1, "Validates auth tokens")))
+
+	store := NewAnalyticsStore(filepath.Join(t.TempDir(), AnalyticsFileName))
+	indexer := &Indexer{
+		fss:       FSContextMap{RepoNamespace: filteredFS},
+		db:        docDB,
+		analytics: store,
+		generate: func(_ context.Context, _ string) (string, error) {
+			return noRelevantCodeAnswer, nil
+		},
+	}
+
+	_, err = indexer.QuerySpans(context.Background(), "auth", QueryOptions{})
+	require.NoError(t, err)
+	_, err = indexer.QuerySpans(context.Background(), " Auth ", QueryOptions{})
+	require.NoError(t, err)
+	_, err = indexer.Query(context.Background(), "billing", QueryOptions{})
+	require.NoError(t, err)
+
+	records, err := store.Load()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+
+	assert.Equal(t, "auth", records[0].Query)
+	assert.Equal(t, 1, records[0].Hits)
+	assert.InDelta(t, 1.0, records[0].TopScores[0], 1e-6)
+	assert.True(t, records[0].Answered)
+
+	assert.Equal(t, "billing", records[2].Query)
+	assert.InDelta(t, 0.0, records[2].TopScores[0], 1e-6)
+	assert.False(t, records[2].Answered, "an answer saying nothing was found should not count as answered")
+}
+
+func TestSummarizeQueries(t *testing.T) {
+	records := []QueryRecord{
+		{Query: "auth", Hits: 4, TopScores: []float64{0.9, 0.8}, Answered: true},
+		{Query: "Auth ", Hits: 2, TopScores: []float64{0.7}, Answered: true},
+		{Query: "billing", Hits: 0, Answered: false},
+		{Query: "logging", Hits: 3, TopScores: []float64{0.3}, Answered: true},
+		{Query: "logging", Hits: 1, TopScores: []float64{0.1}, Answered: false},
+		{Query: "routing", Hits: 5, TopScores: []float64{0.6}, Answered: true},
+	}
+
+	summary := SummarizeQueries(records, 10)
+
+	assert.Equal(t, 6, summary.TotalQueries)
+
+	require.Len(t, summary.Common, 4)
+	assert.Equal(t, "auth", summary.Common[0].Query)
+	assert.Equal(t, 2, summary.Common[0].Count)
+	assert.InDelta(t, 3.0, summary.Common[0].AvgHits, 1e-9)
+	assert.InDelta(t, 0.8, summary.Common[0].AvgTopScore, 1e-9)
+	assert.Equal(t, 2, summary.Common[0].Answered)
+	assert.Equal(t, "logging", summary.Common[1].Query)
+	assert.Equal(t, "billing", summary.Common[2].Query)
+	assert.Equal(t, "routing", summary.Common[3].Query)
+
+	require.Len(t, summary.LowRecall, 2)
+	assert.Equal(t, "billing", summary.LowRecall[0].Query)
+	assert.Equal(t, "logging", summary.LowRecall[1].Query)
+	assert.InDelta(t, 0.2, summary.LowRecall[1].AvgTopScore, 1e-9)
+
+	limited := SummarizeQueries(records, 1)
+	assert.Len(t, limited.Common, 1)
+	assert.Len(t, limited.LowRecall, 1)
+}
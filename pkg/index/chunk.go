@@ -0,0 +1,110 @@
+package index
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/russellhaering/autoswe/pkg/db"
+)
+
+// pathHeaderPrefix starts the header added to chunk content when paths are embedded
+const pathHeaderPrefix = "File: "
+
+// maxChunkCodeBytes limits the code embedded for a chunk, so that large chunks stay within
+// the embedding model's input limit
+const maxChunkCodeBytes = 6000
+
+// IndexMode selects the text embedded for each chunk
+type IndexMode string
+
+const (
+	// IndexModeSummary embeds the English summary of each chunk
+	IndexModeSummary IndexMode = "summary"
+	// IndexModeCode embeds the code of each chunk, so that queries naming symbols or API
+	// calls match it
+	IndexModeCode IndexMode = "code"
+	// IndexModeBoth embeds the summary followed by the code
+	IndexModeBoth IndexMode = "both"
+)
+
+// ParseIndexMode parses an index mode name, returning an error for unknown modes
+func ParseIndexMode(name string) (IndexMode, error) {
+	switch mode := IndexMode(name); mode {
+	case IndexModeSummary, IndexModeCode, IndexModeBoth:
+		return mode, nil
+	case "":
+		return IndexModeSummary, nil
+	default:
+		return "", fmt.Errorf("unknown index mode %q (expected %q, %q or %q)", name, IndexModeSummary, IndexModeCode, IndexModeBoth)
+	}
+}
+
+// chunkContent returns the content to store and embed for a chunk of the file at filePath,
+// given its summary and code. IndexMode selects whether the summary, code or both are
+// embedded. When EmbedPaths is enabled, the path and directory are prepended so they
+// contribute to similarity.
+func (i *Indexer) chunkContent(filePath, summary, code string) string {
+	text := summary
+	switch i.config.IndexMode {
+	case IndexModeCode:
+		text = code
+	case IndexModeBoth:
+		text = summary + "\n\n" + code
+	}
+
+	if !i.config.EmbedPaths {
+		return text
+	}
+
+	return fmt.Sprintf("%s%s\nDirectory: %s\n\n%s", pathHeaderPrefix, filePath, path.Dir(filePath), text)
+}
+
+// chunkMetadata adds the summary to a chunk's metadata when the chunk's content isn't just
+// the summary, so that it can still be shown
+func (i *Indexer) chunkMetadata(metadata map[string]string, summary string) map[string]string {
+	if i.config.IndexMode == IndexModeCode || i.config.IndexMode == IndexModeBoth {
+		metadata["summary"] = summary
+	}
+	return metadata
+}
+
+// chunkCode returns the lines of a chunk's span from the lines of its file, cut short if it
+// is too large to embed
+func chunkCode(lines []string, span ContentSpan) string {
+	start := min(max(span.StartLine, 1), len(lines)+1)
+	end := max(min(span.EndLine, len(lines)), start-1)
+	return truncateLines(strings.Join(lines[start-1:end], "\n"), maxChunkCodeBytes)
+}
+
+// truncateLines cuts text to at most maxBytes at a line break, so that no line or character
+// is split, and marks that it was cut
+func truncateLines(text string, maxBytes int) string {
+	if len(text) <= maxBytes {
+		return text
+	}
+
+	text = text[:maxBytes]
+	if idx := strings.LastIndex(text, "\n"); idx > 0 {
+		text = text[:idx]
+	}
+	return text + "\n..."
+}
+
+// chunkSummary returns the summary of a stored chunk, without any path header or code
+func chunkSummary(doc db.Document) string {
+	if summary, ok := doc.Metadata["summary"]; ok {
+		return summary
+	}
+
+	content := doc.Content
+	if !strings.HasPrefix(content, pathHeaderPrefix) {
+		return content
+	}
+
+	if _, summary, ok := strings.Cut(content, "\n\n"); ok {
+		return summary
+	}
+
+	return content
+}
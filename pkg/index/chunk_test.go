@@ -0,0 +1,113 @@
+package index
+
+import (
+	"context"
+	"hash/fnv"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/russellhaering/autoswe/pkg/db"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkContent(t *testing.T) {
+	indexer := &Indexer{}
+	assert.Equal(t, "Loads config", indexer.chunkContent("pkg/autoswe/manager.go", "Loads config", "func Load() {}"))
+
+	indexer.config.EmbedPaths = true
+	content := indexer.chunkContent("pkg/autoswe/manager.go", "Loads config", "func Load() {}")
+	assert.Equal(t, "File: pkg/autoswe/manager.go\nDirectory: pkg/autoswe\n\nLoads config", content)
+	assert.Equal(t, "Loads config", chunkSummary(db.Document{Content: content}))
+	assert.Equal(t, "Loads config", chunkSummary(db.Document{Content: "Loads config"}))
+
+	// The code can be embedded instead of, or as well as, the summary, which is then kept
+	// in the metadata
+	indexer.config = Config{IndexMode: IndexModeCode}
+	assert.Equal(t, "func Load() {}", indexer.chunkContent("pkg/autoswe/manager.go", "Loads config", "func Load() {}"))
+
+	indexer.config.IndexMode = IndexModeBoth
+	doc := db.Document{
+		Content:  indexer.chunkContent("pkg/autoswe/manager.go", "Loads config", "func Load() {}"),
+		Metadata: indexer.chunkMetadata(map[string]string{"path": "pkg/autoswe/manager.go"}, "Loads config"),
+	}
+	assert.Equal(t, "Loads config\n\nfunc Load() {}", doc.Content)
+	assert.Equal(t, "Loads config", chunkSummary(doc))
+}
+
+func TestChunkCode(t *testing.T) {
+	lines := strings.Split("package main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n", "\n")
+
+	assert.Equal(t, "func main() {\n\tprintln(\"hi\")\n}", chunkCode(lines, ContentSpan{StartLine: 3, EndLine: 5}))
+	assert.Equal(t, "package main", chunkCode(lines, ContentSpan{StartLine: 1, EndLine: 1}))
+	assert.Equal(t, "", chunkCode(lines, ContentSpan{StartLine: 10, EndLine: 12}))
+
+	long := strings.Repeat("// a comment line\n", 1000)
+	code := chunkCode(strings.Split(long, "\n"), ContentSpan{StartLine: 1, EndLine: 1000})
+	assert.LessOrEqual(t, len(code), maxChunkCodeBytes+4)
+	assert.True(t, strings.HasSuffix(code, "// a comment line\n..."))
+}
+
+func TestEmbedPathsRanking(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	query := "the config in manager.go"
+
+	rank := func(embedPaths bool) (int, float64) {
+		docDB, err := db.NewDocumentDB(filepath.Join(t.TempDir(), "db"), bagOfWords)
+		require.NoError(t, err)
+		defer docDB.Close()
+
+		indexer := &Indexer{db: docDB, config: Config{EmbedPaths: embedPaths}}
+
+		files := []struct {
+			path    string
+			summary string
+		}{
+			{"pkg/server/settings.go", "Loads the config"},
+			{"pkg/autoswe/manager.go", "Loads the config, then constructs the Anthropic and Gemini API clients"},
+			{"pkg/cli/flags.go", "Parses the config flags"},
+		}
+		for idx, f := range files {
+			doc := chunkEntry(f.path, 0, 1, 10, indexer.chunkContent(f.path, f.summary, ""))
+			doc.ID = ComputeID(RepoNamespace, f.path, idx)
+			require.NoError(t, docDB.AddDocument(context.Background(), doc))
+		}
+
+		results, err := indexer.Search(context.Background(), query, 10, "")
+		require.NoError(t, err)
+
+		for idx, result := range results {
+			if result.Document.Metadata["path"] == "pkg/autoswe/manager.go" {
+				return idx, result.Similarity
+			}
+		}
+
+		t.Fatal("manager.go not found in results")
+		return -1, 0
+	}
+
+	disabledRank, disabledSimilarity := rank(false)
+	enabledRank, enabledSimilarity := rank(true)
+
+	assert.Equal(t, 0, enabledRank, "manager.go should rank first with path embedding")
+	assert.Less(t, enabledRank, disabledRank)
+	assert.Greater(t, enabledSimilarity, disabledSimilarity)
+}
+
+// bagOfWords is a deterministic embedding that hashes each word into a fixed-size vector
+func bagOfWords(_ context.Context, content string) ([]float32, error) {
+	vector := make([]float32, 64)
+	words := strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		h := fnv.New32a()
+		h.Write([]byte(word))
+		vector[h.Sum32()%uint32(len(vector))]++
+	}
+	return vector, nil
+}
@@ -0,0 +1,106 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// Chunker splits a file into chunks with exact line ranges, without asking the summary model
+type Chunker interface {
+	// Chunk returns a chunk for each element of the file, with the text to embed as its
+	// summary. Files that can't be chunked return an error, and are summarized by the model
+	// instead.
+	Chunk(ctx context.Context, path string, content []byte) ([]ContentSummary, error)
+}
+
+// DefaultChunkers returns the chunkers for each language that has one, keyed by language as
+// stored in the index, eg Go
+func DefaultChunkers() map[string]Chunker {
+	return map[string]Chunker{
+		"Go": GoChunker{},
+	}
+}
+
+// goChunkMaxBytes limits the source included in a chunk for a type, var or const
+// declaration, so that large declarations stay within the embedding model's input limit
+const goChunkMaxBytes = 2000
+
+// GoChunker parses Go files, and returns a chunk for each top level declaration. A function
+// is described by its doc comment and signature, and other declarations by their doc comment
+// and source.
+type GoChunker struct{}
+
+// Chunk implements Chunker
+func (GoChunker) Chunk(_ context.Context, path string, content []byte) ([]ContentSummary, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, content, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	// span returns the lines from the start of a declaration's doc comment to its end
+	span := func(doc *ast.CommentGroup, node ast.Node) ContentSpan {
+		start := node.Pos()
+		if doc != nil {
+			start = doc.Pos()
+		}
+		return ContentSpan{
+			StartLine: fset.Position(start).Line,
+			EndLine:   fset.Position(node.End()).Line,
+		}
+	}
+
+	// source returns the source between two positions
+	source := func(start, end token.Pos) string {
+		return string(content[fset.Position(start).Offset:fset.Position(end).Offset])
+	}
+
+	var chunks []ContentSummary
+
+	// Package documentation describes the whole package
+	if file.Doc != nil {
+		chunks = append(chunks, ContentSummary{
+			Summary:     goChunkText(file.Doc, "package "+file.Name.Name),
+			ContentSpan: span(file.Doc, file.Name),
+		})
+	}
+
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			// The body is left out, as it is often too large to embed
+			end := decl.End()
+			if decl.Body != nil {
+				end = decl.Body.Lbrace
+			}
+
+			chunks = append(chunks, ContentSummary{
+				Summary:     goChunkText(decl.Doc, strings.TrimSpace(source(decl.Pos(), end))),
+				ContentSpan: span(decl.Doc, decl),
+			})
+		case *ast.GenDecl:
+			if decl.Tok == token.IMPORT {
+				continue
+			}
+
+			chunks = append(chunks, ContentSummary{
+				Summary:     goChunkText(decl.Doc, truncateLines(source(decl.Pos(), decl.End()), goChunkMaxBytes)),
+				ContentSpan: span(decl.Doc, decl),
+			})
+		}
+	}
+
+	return chunks, nil
+}
+
+// goChunkText joins a declaration's doc comment, if it has one, to its source
+func goChunkText(doc *ast.CommentGroup, source string) string {
+	if doc == nil {
+		return source
+	}
+	return strings.TrimSpace(doc.Text()) + "\n\n" + source
+}
@@ -0,0 +1,82 @@
+package index
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const chunkerSource = `// Package server serves requests
+package server
+
+import "net/http"
+
+const defaultAddr = ":8080"
+
+// Server handles requests
+type Server struct {
+	addr string
+}
+
+// Serve listens on the server's address
+func (s *Server) Serve() error {
+	return http.ListenAndServe(s.addr, nil)
+}
+
+func helper() {}
+`
+
+func TestGoChunker(t *testing.T) {
+	chunks, err := GoChunker{}.Chunk(context.Background(), "server.go", []byte(chunkerSource))
+	require.NoError(t, err)
+
+	assert.Equal(t, []ContentSummary{
+		{Summary: "Package server serves requests\n\npackage server", ContentSpan: ContentSpan{StartLine: 1, EndLine: 2}},
+		{Summary: `const defaultAddr = ":8080"`, ContentSpan: ContentSpan{StartLine: 6, EndLine: 6}},
+		{Summary: "Server handles requests\n\ntype Server struct {\n\taddr string\n}", ContentSpan: ContentSpan{StartLine: 8, EndLine: 11}},
+		{Summary: "Serve listens on the server's address\n\nfunc (s *Server) Serve() error", ContentSpan: ContentSpan{StartLine: 13, EndLine: 16}},
+		{Summary: "func helper()", ContentSpan: ContentSpan{StartLine: 18, EndLine: 18}},
+	}, chunks)
+
+	// Large declarations are cut short
+	large := "package big\n\nvar table = []string{\n" + strings.Repeat("\t\"entry\",\n", 500) + "}\n"
+	chunks, err = GoChunker{}.Chunk(context.Background(), "big.go", []byte(large))
+	require.NoError(t, err)
+	require.Len(t, chunks, 1)
+	assert.LessOrEqual(t, len(chunks[0].Summary), goChunkMaxBytes+4)
+	assert.True(t, strings.HasSuffix(chunks[0].Summary, "\"entry\",\n..."))
+	assert.Equal(t, ContentSpan{StartLine: 3, EndLine: 504}, chunks[0].ContentSpan)
+
+	_, err = GoChunker{}.Chunk(context.Background(), "broken.go", []byte("package broken\n\nfunc {"))
+	assert.Error(t, err)
+}
+
+func TestExtractSummariesChunkers(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	indexer := &Indexer{config: Config{Chunkers: DefaultChunkers()}}
+
+	var summarized []string
+	indexer.summarize = func(_ context.Context, content []byte) ([]ContentSummary, error) {
+		summarized = append(summarized, string(content))
+		return []ContentSummary{{Summary: "summarized", ContentSpan: ContentSpan{StartLine: 1, EndLine: 1}}}, nil
+	}
+
+	// Go files are chunked without the summary model
+	summaries, err := indexer.extractSummaries(context.Background(), "server.go", []byte(chunkerSource))
+	require.NoError(t, err)
+	assert.Len(t, summaries, 5)
+	assert.Empty(t, summarized)
+
+	// Other languages, and Go files that don't parse, fall back to the summary model
+	for _, path := range []string{"README.md", "broken.go"} {
+		summaries, err = indexer.extractSummaries(context.Background(), path, []byte("package broken\n\nfunc {"))
+		require.NoError(t, err)
+		assert.Equal(t, "summarized", summaries[0].Summary)
+	}
+	assert.Len(t, summarized, 2)
+}
@@ -0,0 +1,135 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	iofs "io/fs"
+	"slices"
+
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/pricing"
+	"go.uber.org/zap"
+)
+
+// The estimate assumes roughly how files are summarized, since the real numbers are only
+// known once the summary model has responded
+const (
+	// estimateBytesPerToken is the typical number of bytes of source code per token
+	estimateBytesPerToken = 4
+
+	// estimatePromptTokens is the size of the summary prompt, excluding the file
+	estimatePromptTokens = 250
+
+	// estimateLineNumberBytes is the line number prefix added to each line of a summarized
+	// file, e.g. "  12 | "
+	estimateLineNumberBytes = 7
+
+	// estimateLinesPerSummary is the typical number of lines covered by each summary
+	estimateLinesPerSummary = 25
+
+	// estimateSummaryTokens is the typical size of a summary, which is both generated by the
+	// summary model and then embedded
+	estimateSummaryTokens = 40
+)
+
+// prices returns the prices used to estimate costs
+func (c Config) prices() pricing.Table {
+	if c.Prices == nil {
+		return pricing.Defaults
+	}
+	return c.Prices
+}
+
+// UpdateEstimate is the projected scale and cost of an index update. Token counts are rough,
+// as they are estimated from file sizes rather than counted by the models.
+type UpdateEstimate struct {
+	Files int   `json:"files"`
+	Bytes int64 `json:"bytes"`
+
+	SummaryCalls   int `json:"summary_calls"`
+	EmbeddingCalls int `json:"embedding_calls"`
+
+	SummaryInputTokens  int64 `json:"summary_input_tokens"`
+	SummaryOutputTokens int64 `json:"summary_output_tokens"`
+	EmbeddingTokens     int64 `json:"embedding_tokens"`
+
+	CostUSD float64 `json:"cost_usd"`
+
+	// UnpricedModels are models with no known price, whose cost isn't included in CostUSD
+	UnpricedModels []string `json:"unpriced_models,omitempty"`
+}
+
+// EstimateUpdate projects the model calls and cost of the files UpdateIndex would add or
+// update, without calling any model
+func (i *Indexer) EstimateUpdate(ctx context.Context) (*UpdateEstimate, error) {
+	plan, err := i.PlanUpdate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	estimate := &UpdateEstimate{}
+	for _, file := range slices.Concat(plan.Add, plan.Update) {
+		fsys, ok := i.fss[file.Namespace]
+		if !ok {
+			continue
+		}
+
+		content, err := iofs.ReadFile(fsys, file.Path)
+		if err != nil {
+			log.Warn("Failed to read file for estimate", zap.String("path", file.Path), zap.Error(err))
+			continue
+		}
+
+		lines := bytes.Count(content, []byte("\n")) + 1
+		summaries := max(1, lines/estimateLinesPerSummary)
+
+		estimate.Files++
+		estimate.Bytes += int64(len(content))
+
+		// Large files are summarized a window at a time, each with its own prompt, while
+		// files with a chunker don't need the summary model at all
+		if _, ok := i.config.Chunkers[detectLanguage(file.Path)]; !ok {
+			for _, window := range i.config.summaryWindows(content) {
+				estimate.SummaryCalls++
+				estimate.SummaryInputTokens += int64((len(window.content)+window.lines*estimateLineNumberBytes)/estimateBytesPerToken + estimatePromptTokens)
+			}
+			estimate.SummaryOutputTokens += int64(summaries * estimateSummaryTokens)
+		}
+
+		// The file entry is embedded along with each summary
+		estimate.EmbeddingCalls += 1 + summaries
+		estimate.EmbeddingTokens += int64(summaries * estimateSummaryTokens)
+	}
+
+	prices := i.config.prices()
+	estimate.addCost(prices, i.config.summaryModel(), estimate.SummaryInputTokens, estimate.SummaryOutputTokens)
+	estimate.addCost(prices, i.config.embeddingModel(), estimate.EmbeddingTokens, 0)
+
+	return estimate, nil
+}
+
+// addCost adds the cost of a model's tokens, or records that its price is unknown
+func (e *UpdateEstimate) addCost(prices pricing.Table, model string, inputTokens, outputTokens int64) {
+	cost, ok := prices.Cost(model, inputTokens, outputTokens)
+	if !ok {
+		if inputTokens > 0 || outputTokens > 0 {
+			e.UnpricedModels = append(e.UnpricedModels, model)
+		}
+		return
+	}
+
+	e.CostUSD += cost
+}
+
+// String summarizes the estimate for display
+func (e *UpdateEstimate) String() string {
+	s := fmt.Sprintf("%d files (%d bytes) to index\n", e.Files, e.Bytes)
+	s += fmt.Sprintf("Summary calls: %d (~%d input tokens, ~%d output tokens)\n", e.SummaryCalls, e.SummaryInputTokens, e.SummaryOutputTokens)
+	s += fmt.Sprintf("Embedding calls: ~%d (~%d tokens)\n", e.EmbeddingCalls, e.EmbeddingTokens)
+	s += fmt.Sprintf("Estimated cost: ~$%.2f", e.CostUSD)
+	for _, model := range e.UnpricedModels {
+		s += fmt.Sprintf("\nNo price is known for %s, so its cost isn't included", model)
+	}
+	return s
+}
@@ -0,0 +1,73 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/pricing"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateUpdate(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+
+	// 50 lines of 19 bytes, plus the empty line after the last newline
+	content := strings.Repeat("// a line of code\n", 50)
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "main.go"), []byte(content), 0644))
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	require.NoError(t, err)
+
+	store, err := OpenStore(Config{Backend: BackendMemory}, func(context.Context, string) ([]float32, error) {
+		t.Fatal("estimating should not embed anything")
+		return nil, nil
+	})
+	require.NoError(t, err)
+
+	indexer := NewIndexer(nil, store, FSContextMap{RepoNamespace: filteredFS}, Config{})
+	defer indexer.Close()
+	indexer.summarize = func(context.Context, []byte) ([]ContentSummary, error) {
+		t.Fatal("estimating should not summarize anything")
+		return nil, nil
+	}
+
+	estimate, err := indexer.EstimateUpdate(context.Background())
+	require.NoError(t, err)
+
+	inputTokens := int64((len(content)+51*estimateLineNumberBytes)/estimateBytesPerToken + estimatePromptTokens)
+	assert.Equal(t, &UpdateEstimate{
+		Files:               1,
+		Bytes:               int64(len(content)),
+		SummaryCalls:        1,
+		EmbeddingCalls:      3,
+		SummaryInputTokens:  inputTokens,
+		SummaryOutputTokens: 2 * estimateSummaryTokens,
+		EmbeddingTokens:     2 * estimateSummaryTokens,
+		CostUSD:             (float64(inputTokens)*0.075 + float64(2*estimateSummaryTokens)*0.30) / 1e6,
+	}, estimate)
+
+	// Unknown models are reported rather than silently costed at nothing
+	indexer.config.SummaryModel = "gemini-9-ultra"
+	estimate, err = indexer.EstimateUpdate(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gemini-9-ultra"}, estimate.UnpricedModels)
+	assert.Zero(t, estimate.CostUSD)
+
+	// Prices from the configured table are used, so such models can be priced
+	indexer.config.Prices = pricing.Table{
+		"gemini-9-ultra":     {InputPerMTok: 1, OutputPerMTok: 2},
+		"text-embedding-004": {},
+	}
+	estimate, err = indexer.EstimateUpdate(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, estimate.UnpricedModels)
+	assert.InDelta(t, float64(inputTokens)/1e6*1+float64(2*estimateSummaryTokens)/1e6*2, estimate.CostUSD, 1e-12)
+}
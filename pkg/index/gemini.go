@@ -0,0 +1,96 @@
+package index
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// GeminiEmbedder embeds text with a Gemini embedding model
+type GeminiEmbedder struct {
+	Client *genai.Client
+	Model  string
+}
+
+// Embed implements Embedder
+func (e *GeminiEmbedder) Embed(ctx context.Context, content string) ([]float32, error) {
+	resp, err := e.Client.EmbeddingModel(e.Model).EmbedContent(ctx, genai.Text(content))
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Embedding.Values, nil
+}
+
+// GeminiGenerator generates text with Gemini models
+type GeminiGenerator struct {
+	Client *genai.Client
+}
+
+// Generate implements Generator
+func (g *GeminiGenerator) Generate(ctx context.Context, req GenerateRequest) (string, error) {
+	model := g.Client.GenerativeModel(req.Model)
+	model.SetTemperature(req.Temperature)
+	if req.MaxOutputTokens > 0 {
+		model.SetMaxOutputTokens(req.MaxOutputTokens)
+	}
+
+	if req.Schema != nil {
+		model.ResponseMIMEType = "application/json"
+		model.ResponseSchema = req.Schema.toGenai()
+	}
+
+	resp, err := model.GenerateContent(ctx, genai.Text(req.Prompt))
+	if err != nil {
+		return "", err
+	}
+
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
+		return "", fmt.Errorf("no content generated")
+	}
+
+	text, ok := resp.Candidates[0].Content.Parts[0].(genai.Text)
+	if !ok {
+		return "", fmt.Errorf("expected text response, got %T", resp.Candidates[0].Content.Parts[0])
+	}
+
+	return string(text), nil
+}
+
+// toGenai converts the schema to Gemini's schema type
+func (s *ResponseSchema) toGenai() *genai.Schema {
+	if s == nil {
+		return nil
+	}
+
+	schema := &genai.Schema{
+		Description: s.Description,
+		Items:       s.Items.toGenai(),
+		Required:    s.Required,
+	}
+
+	switch s.Type {
+	case "object":
+		schema.Type = genai.TypeObject
+	case "array":
+		schema.Type = genai.TypeArray
+	case "string":
+		schema.Type = genai.TypeString
+	case "integer":
+		schema.Type = genai.TypeInteger
+	case "number":
+		schema.Type = genai.TypeNumber
+	case "boolean":
+		schema.Type = genai.TypeBoolean
+	}
+
+	if len(s.Properties) > 0 {
+		schema.Properties = make(map[string]*genai.Schema, len(s.Properties))
+		for name, property := range s.Properties {
+			schema.Properties[name] = property.toGenai()
+		}
+	}
+
+	return schema
+}
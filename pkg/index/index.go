@@ -6,18 +6,22 @@ import (
 	"fmt"
 	"io/fs"
 	iofs "io/fs"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"crypto/sha256"
 	"io"
 
-	"github.com/google/generative-ai-go/genai"
 	"github.com/russellhaering/autoswe/pkg/db"
 	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/pricing"
 	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/russellhaering/autoswe/pkg/retry"
 	"go.uber.org/zap"
 )
 
@@ -25,8 +29,39 @@ const (
 	StoragePath = ".autoswe"
 	DBFileName  = "db"
 
+	// EmbeddingCacheFileName is the name of the embedding cache within StoragePath
+	EmbeddingCacheFileName = "embeddings"
+
 	RepoNamespace         = "repo"
 	ExtraContextNamespace = "extra"
+
+	DefaultQdrantURL        = "http://localhost:6333"
+	DefaultQdrantCollection = "autoswe"
+
+	// DefaultMinSimilarity is the similarity the best search result must reach for a query
+	// to be answered
+	DefaultMinSimilarity = 0.2
+
+	// DefaultSimilarityThreshold is the similarity a search result needs to be kept in
+	// threshold mode
+	DefaultSimilarityThreshold = 0.4
+	// DefaultMinResults is the number of results threshold mode keeps, even if they are below
+	// the threshold
+	DefaultMinResults = 3
+	// DefaultMaxResults is the most search results used to answer a query
+	DefaultMaxResults = 20
+
+	// DefaultSummaryWindowLines is the most lines of a file summarized in one request
+	DefaultSummaryWindowLines = 1000
+	// DefaultSummaryWindowBytes is the most bytes of a file summarized in one request
+	DefaultSummaryWindowBytes = 128 << 10
+
+	// DefaultEmbeddingModel is the Gemini model used to embed chunks and queries
+	DefaultEmbeddingModel = "text-embedding-004"
+	// DefaultSummaryModel is the Gemini model used to summarize files while indexing
+	DefaultSummaryModel = "gemini-2.0-flash-lite"
+	// DefaultQueryModel is the Gemini model used to answer queries from search results
+	DefaultQueryModel = "gemini-2.0-flash-lite"
 )
 
 // Metadata represents additional information about a document
@@ -48,48 +83,183 @@ type Document struct {
 
 type FSContextMap map[string]repo.FilteredFS
 
-// Indexer manages the vector-based code index
-type Indexer struct {
-	fss    FSContextMap
-	db     *db.DocumentDB
-	gemini *genai.Client
+// Config represents configuration for the indexer
+type Config struct {
+	// FilterMode selects how search results are cut off before answering a query
+	// Default: FilterModeThreshold
+	FilterMode FilterMode
+
+	// MinSimilarity is the similarity the best search result must reach for a query to be
+	// answered. Below it, queries report that no relevant code was found rather than
+	// answering from weak matches. A negative value disables the floor.
+	// Default: DefaultMinSimilarity
+	MinSimilarity float64
+
+	// SimilarityThreshold is the similarity a search result needs to be kept in threshold
+	// mode. Embedding models score similarity differently, so it may need tuning along with
+	// the model. A negative value keeps every result.
+	// Default: DefaultSimilarityThreshold
+	SimilarityThreshold float64
+
+	// MinResults is the number of results threshold mode keeps, topping up with the best
+	// results below the threshold. A negative value disables the top-up.
+	// Default: DefaultMinResults
+	MinResults int
+
+	// MaxResults is the most search results used to answer a query, in either filter mode. A
+	// negative value is treated as zero.
+	// Default: DefaultMaxResults
+	MaxResults int
+
+	// IncludeScores adds the similarity of every search result considered by a query to its
+	// result, along with whether it was kept, to help tune SimilarityThreshold
+	IncludeScores bool
+
+	// EmbedPaths prepends each file's path and directory to its chunks before embedding, so
+	// that queries mentioning a file name match it. Changing this requires rebuilding the
+	// index, since it changes the stored vectors.
+	EmbedPaths bool
+
+	// Backend selects where the index is stored
+	// Default: BackendBolt
+	Backend Backend
+
+	// QdrantURL is the address of the Qdrant server used by BackendQdrant
+	// Default: DefaultQdrantURL
+	QdrantURL string
+
+	// QdrantCollection is the Qdrant collection used by BackendQdrant
+	// Default: DefaultQdrantCollection
+	QdrantCollection string
+
+	// RecordAnalytics logs each query to a local analytics store under StoragePath
+	RecordAnalytics bool
+
+	// EmbeddingCache caches embeddings under StoragePath, so that repeated queries and
+	// unchanged content aren't embedded again. Use Warm to prefetch likely queries.
+	EmbeddingCache bool
+
+	// Retry controls how Gemini calls made while indexing and querying are retried
+	Retry retry.Config
+
+	// Provider selects the service used for embeddings, summaries and answers
+	// Default: ProviderGemini
+	Provider Provider
+
+	// OpenAIBaseURL is the address of the OpenAI-compatible API used by ProviderOpenAI, such
+	// as http://localhost:11434/v1 for Ollama
+	// Default: DefaultOpenAIBaseURL
+	OpenAIBaseURL string
+
+	// OpenAIAPIKey is the key for the OpenAI-compatible API, if it needs one
+	OpenAIAPIKey string
+
+	// EmbeddingModel is the model used for embeddings. Changing it requires
+	// rebuilding the index, since vectors from different models can't be compared.
+	// Default: DefaultEmbeddingModel, or DefaultOpenAIEmbeddingModel with ProviderOpenAI
+	EmbeddingModel string
+
+	// SummaryModel is the model used to summarize files while indexing
+	// Default: DefaultSummaryModel, or DefaultOpenAIChatModel with ProviderOpenAI
+	SummaryModel string
+
+	// Chunkers split files into chunks by language, eg Go, instead of asking the summary
+	// model, which is faster and cheaper. Files in other languages, and files a chunker
+	// can't parse, are summarized by the model. DefaultChunkers returns the chunkers
+	// available.
+	Chunkers map[string]Chunker
+
+	// KeywordWeight blends keyword matching into search rankings, so that queries naming an
+	// identifier find the code that defines it. It is the share of each result's score given
+	// to keywords, from 0 for vector similarity alone to 1 for keywords alone.
+	KeywordWeight float64
+
+	// Prices are used to estimate what updating the index will cost.
+	// Default: pricing.Defaults
+	Prices pricing.Table
+
+	// IndexMode selects whether each chunk's summary, code or both are embedded. Changing
+	// it requires rebuilding the index, since it changes the stored vectors.
+	// Default: IndexModeSummary
+	IndexMode IndexMode
+
+	// SummaryWindowLines and SummaryWindowBytes limit how much of a file is sent to the
+	// summary model at once. Larger files are summarized in overlapping windows, so that
+	// they fit within the model's input and output limits. A negative value removes the
+	// limit.
+	// Default: DefaultSummaryWindowLines and DefaultSummaryWindowBytes
+	SummaryWindowLines int
+	SummaryWindowBytes int
+
+	// QueryModel is the model used to answer queries
+	// Default: DefaultQueryModel, or DefaultOpenAIChatModel with ProviderOpenAI
+	QueryModel string
+
+	// Progress, if set, is called as UpdateIndex indexes each file and once it is done
+	Progress ProgressFunc
 }
 
-// NewIndexer creates a new code indexer with the given configuration
-func NewIndexer(ctx context.Context, gemini *genai.Client, fss FSContextMap) (*Indexer, error) {
-	// Create storage directory if it doesn't exist
-	if err := os.MkdirAll(StoragePath, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create storage directory: %w", err)
-	}
+// UpdateProgress reports how far an index update has got
+type UpdateProgress struct {
+	// Total is the number of files that need indexing
+	Total int
 
-	embeddingModel := gemini.EmbeddingModel("text-embedding-004")
+	// Indexed and Failed count the files indexed so far, and those that couldn't be
+	Indexed int
+	Failed  int
 
-	// Initialize document database
-	docDB, err := db.NewDocumentDB(filepath.Join(StoragePath, "db"), func(content string) ([]float32, error) {
-		embedding, err := embeddingModel.EmbedContent(ctx, genai.Text(content))
-		if err != nil {
-			return nil, fmt.Errorf("failed to embed text: %w", err)
-		}
+	// Unchanged is the number of files that didn't need indexing
+	Unchanged int
 
-		return embedding.Embedding.Values, nil
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to create document database: %w", err)
-	}
+	// Deleted is the number of deleted files removed from the index, once Done
+	Deleted int
+
+	// Namespace and Path identify the file that was just indexed, and Err is set if it
+	// failed. They are empty once Done.
+	Namespace string
+	Path      string
+	Err       error
+
+	// Done is set for the final report, after every file has been indexed
+	Done bool
+}
+
+// ProgressFunc receives the progress of an index update
+type ProgressFunc func(UpdateProgress)
+
+// Indexer manages the vector-based code index
+type Indexer struct {
+	fss       FSContextMap
+	db        db.DocumentStore
+	generator Generator
+	config    Config
+
+	// analytics records queries, if enabled
+	analytics *AnalyticsStore
+
+	// generate overrides answer generation, for testing
+	generate func(ctx context.Context, prompt string) (string, error)
+
+	// summarize overrides summary extraction, for testing
+	summarize func(ctx context.Context, content []byte) ([]ContentSummary, error)
+}
 
+// NewIndexer creates a new code indexer backed by the given document store, which uses the
+// generator to summarize files and answer queries. The index is not updated until
+// UpdateIndex is called.
+func NewIndexer(generator Generator, store db.DocumentStore, fss FSContextMap, config Config) *Indexer {
 	indexer := &Indexer{
-		fss:    fss,
-		db:     docDB,
-		gemini: gemini,
+		fss:       fss,
+		db:        store,
+		generator: generator,
+		config:    config,
 	}
 
-	err = indexer.UpdateIndex(ctx)
-	if err != nil {
-		indexer.Close()
-		return nil, fmt.Errorf("failed to update index: %w", err)
+	if config.RecordAnalytics {
+		indexer.analytics = NewAnalyticsStore(filepath.Join(StoragePath, AnalyticsFileName))
 	}
 
-	return indexer, nil
+	return indexer
 }
 
 // Close releases resources used by the indexer
@@ -207,18 +377,26 @@ func (i *Indexer) indexFile(ctx context.Context, namespace, path string) error {
 		return fmt.Errorf("failed to compute hash for %s: %w", path, err)
 	}
 
-	log.Info("Indexing file",
+	log.Debug("Indexing file",
 		zap.String("path", path),
 		zap.String("namespace", namespace),
 		zap.String("hash", fileHash))
 
+	// Extract semantic summaries from the content before touching the existing entries, so
+	// that a failure leaves them intact
+	summaries, err := i.extractSummaries(ctx, path, content)
+	if err != nil {
+		return fmt.Errorf("failed to extract summaries from file: %w", err)
+	}
+
 	// Delete any existing entries for this file
 	prefix := ComputeID(namespace, path, -1)
 	if err := i.db.DeleteDocumentsWithPrefix(prefix); err != nil {
 		return fmt.Errorf("failed to delete existing entries: %w", err)
 	}
 
-	// Create a file-level entry to track indexing state
+	// Create a file-level entry to track indexing state. The chunk count lets Verify detect
+	// an update that was interrupted before all chunks were written.
 	fileDoc := db.Document{
 		ID:      prefix,
 		Content: "", // Empty content for file-level entries
@@ -230,41 +408,22 @@ func (i *Indexer) indexFile(ctx context.Context, namespace, path string) error {
 			"hash":          fileHash,
 			"is_file_entry": "true",
 			"namespace":     namespace,
+			"chunk_count":   strconv.Itoa(len(summaries)),
 		},
 	}
 
-	if err := i.db.AddDocument(fileDoc); err != nil {
+	if err := i.db.AddDocument(ctx, fileDoc); err != nil {
 		return fmt.Errorf("failed to add file-level entry: %w", err)
 	}
 
-	// Create a temporary file with the content for extracting summaries
-	tempFile, err := os.CreateTemp("", "autoswe-index-*")
-	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
-	}
-	tempFilePath := tempFile.Name()
-	defer os.Remove(tempFilePath) // Clean up temp file when done
-
-	// Write content to temp file
-	if _, err := tempFile.Write(content); err != nil {
-		tempFile.Close()
-		return fmt.Errorf("failed to write to temp file: %w", err)
-	}
-	tempFile.Close()
-
-	// Extract semantic summaries from the temp file
-	summaries, err := i.ExtractFileSummaries(ctx, tempFilePath)
-	if err != nil {
-		return fmt.Errorf("failed to extract summaries from file: %w", err)
-	}
-
 	// Create documents for each summary
+	lines := strings.Split(string(content), "\n")
 	var docs []db.Document
 	for idx, summary := range summaries {
 		doc := db.Document{
 			ID:      ComputeID(namespace, path, idx),
-			Content: summary.Summary,
-			Metadata: map[string]string{
+			Content: i.chunkContent(path, summary.Summary, chunkCode(lines, summary.ContentSpan)),
+			Metadata: i.chunkMetadata(map[string]string{
 				"path":          path,
 				"language":      language,
 				"mod_time":      info.ModTime().Format(time.RFC3339),
@@ -274,14 +433,14 @@ func (i *Indexer) indexFile(ctx context.Context, namespace, path string) error {
 				"end_line":      fmt.Sprintf("%d", summary.ContentSpan.EndLine),
 				"is_file_entry": "false",
 				"namespace":     namespace,
-			},
+			}, summary.Summary),
 		}
 		docs = append(docs, doc)
 	}
 
 	// Batch add all summary documents
 	if len(docs) > 0 {
-		if err := i.db.BatchAddDocuments(docs); err != nil {
+		if err := i.db.BatchAddDocuments(ctx, docs); err != nil {
 			return fmt.Errorf("failed to add summary documents: %w", err)
 		}
 	}
@@ -308,9 +467,23 @@ func ComputeContentHash(content []byte) (string, error) {
 	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
 
-// needsReindexing checks if a file needs to be re-indexed by comparing both its mod time
-// and hash with the values stored in the metadata
-func (i *Indexer) needsReindexing(ctx context.Context, namespace, path string, info fs.FileInfo) (bool, error) {
+// ReindexReason describes why a file needs to be (re)indexed
+type ReindexReason string
+
+const (
+	// ReindexReasonNone indicates the file is up to date
+	ReindexReasonNone ReindexReason = ""
+	// ReindexReasonNew indicates the file has never been indexed
+	ReindexReasonNew ReindexReason = "new"
+	// ReindexReasonModTime indicates the file's mod time changed and its content could not be compared
+	ReindexReasonModTime ReindexReason = "modtime"
+	// ReindexReasonHash indicates the file's content hash changed
+	ReindexReasonHash ReindexReason = "hash"
+)
+
+// reindexReason determines why a file needs to be re-indexed, returning ReindexReasonNone if
+// the stored entry is up to date
+func (i *Indexer) reindexReason(_ context.Context, namespace, path string, info fs.FileInfo) (ReindexReason, error) {
 	// Get the file-level entry
 	fileID := ComputeID(namespace, path, -1)
 	doc, err := i.db.GetDocument(fileID)
@@ -318,7 +491,7 @@ func (i *Indexer) needsReindexing(ctx context.Context, namespace, path string, i
 		log.Debug("File needs indexing - no existing file-level entry found",
 			zap.String("path", path),
 			zap.String("namespace", namespace))
-		return true, nil // If no file-level entry exists, needs indexing
+		return ReindexReasonNew, nil // If no file-level entry exists, needs indexing
 	}
 
 	// Get the mod time from metadata
@@ -328,23 +501,31 @@ func (i *Indexer) needsReindexing(ctx context.Context, namespace, path string, i
 			zap.String("path", path),
 			zap.String("namespace", namespace),
 			zap.Error(err))
-		return true, fmt.Errorf("failed to parse last mod time: %w", err)
+		return ReindexReasonModTime, fmt.Errorf("failed to parse last mod time: %w", err)
 	}
 
 	fileModTime := info.ModTime()
 
-	// Compare modification times using Unix timestamps to avoid precision issues
-	modTimeChanged := fileModTime.Unix() > lastModTime.Unix()
+	// Compare modification times using Unix timestamps to avoid precision issues. A mod time
+	// that moved backwards, such as a file restored from an archive, may hide a change too.
+	modTimeChanged := fileModTime.Unix() != lastModTime.Unix()
+
+	// A different size means the content changed even if the mod time was preserved. Entries
+	// from older indexes may have no size.
+	sizeChanged := false
+	if storedSize, err := strconv.ParseInt(doc.Metadata["size"], 10, 64); err == nil {
+		sizeChanged = storedSize != info.Size()
+	}
 
-	// If mod time hasn't changed, we can skip the hash calculation
-	if !modTimeChanged {
-		return false, nil
+	// If neither has changed, we can skip the hash calculation
+	if !modTimeChanged && !sizeChanged {
+		return ReindexReasonNone, nil
 	}
 
 	// Get file content for hash calculation
 	fsys, ok := i.fss[namespace]
 	if !ok {
-		return true, fmt.Errorf("unknown namespace: %s", namespace)
+		return ReindexReasonModTime, fmt.Errorf("unknown namespace: %s", namespace)
 	}
 
 	file, err := fsys.Open(path)
@@ -353,7 +534,7 @@ func (i *Indexer) needsReindexing(ctx context.Context, namespace, path string, i
 			zap.String("path", path),
 			zap.String("namespace", namespace),
 			zap.Error(err))
-		return true, nil
+		return ReindexReasonModTime, nil
 	}
 	defer file.Close()
 
@@ -363,7 +544,7 @@ func (i *Indexer) needsReindexing(ctx context.Context, namespace, path string, i
 			zap.String("path", path),
 			zap.String("namespace", namespace),
 			zap.Error(err))
-		return true, nil
+		return ReindexReasonModTime, nil
 	}
 
 	// Calculate current file hash
@@ -373,18 +554,23 @@ func (i *Indexer) needsReindexing(ctx context.Context, namespace, path string, i
 			zap.String("path", path),
 			zap.String("namespace", namespace),
 			zap.Error(err))
-		return true, nil
+		return ReindexReasonModTime, nil
 	}
 
 	// Get the stored hash from metadata
 	storedHash, hashExists := doc.Metadata["hash"]
 
-	// If no hash exists in metadata or hash has changed, reindex
-	hashChanged := !hashExists || currentHash != storedHash
-
-	needsUpdate := modTimeChanged && hashChanged
+	// If no hash exists in metadata, as in older indexes, we can only go by the mod time
+	if !hashExists || storedHash == "" {
+		log.Debug("File needs update - mod time or size changed and no stored hash",
+			zap.String("path", path),
+			zap.String("namespace", namespace),
+			zap.Time("file_mod_time", fileModTime),
+			zap.Time("last_indexed", lastModTime))
+		return ReindexReasonModTime, nil
+	}
 
-	if needsUpdate {
+	if currentHash != storedHash {
 		log.Debug("File needs update - changed content detected",
 			zap.String("path", path),
 			zap.String("namespace", namespace),
@@ -392,16 +578,18 @@ func (i *Indexer) needsReindexing(ctx context.Context, namespace, path string, i
 			zap.Time("last_indexed", lastModTime),
 			zap.String("current_hash", currentHash),
 			zap.String("stored_hash", storedHash))
-	} else if modTimeChanged {
-		log.Debug("File modified time changed but content is the same (hash unchanged)",
-			zap.String("path", path),
-			zap.String("namespace", namespace),
-			zap.Time("file_mod_time", fileModTime),
-			zap.Time("last_indexed", lastModTime),
-			zap.String("hash", currentHash))
+		return ReindexReasonHash, nil
 	}
 
-	return needsUpdate, nil
+	// Only the mod time changed, eg by a git checkout or touch, so the index is still valid
+	log.Debug("File modified time changed but content is the same (hash unchanged)",
+		zap.String("path", path),
+		zap.String("namespace", namespace),
+		zap.Time("file_mod_time", fileModTime),
+		zap.Time("last_indexed", lastModTime),
+		zap.String("hash", currentHash))
+
+	return ReindexReasonNone, nil
 }
 
 // CleanupDeletedFiles removes index entries for files that no longer exist
@@ -420,7 +608,7 @@ func (i *Indexer) CleanupDeletedFiles(ctx context.Context) error {
 
 		// Check if file still exists
 		if _, err := iofs.Stat(fsys, ref.Path); os.IsNotExist(err) {
-			log.Info("Removing index entries for deleted file", zap.String("path", ref.Path))
+			log.Debug("Removing index entries for deleted file", zap.String("path", ref.Path))
 			prefix := ComputeID(ref.Namespace, ref.Path, -1)
 			if err := i.deleteFileEntries(ctx, prefix); err != nil {
 				log.Warn("Failed to delete entries for deleted file", zap.String("path", prefix), zap.Error(err))
@@ -431,8 +619,8 @@ func (i *Indexer) CleanupDeletedFiles(ctx context.Context) error {
 	return nil
 }
 
-// UpdateIndex updates the index with changes since the last indexing
-func (i *Indexer) UpdateIndex(ctx context.Context) error {
+// walkFiles calls fn for every regular file in every namespace of the index
+func (i *Indexer) walkFiles(fn func(namespace, path string, info fs.FileInfo) error) error {
 	for namespace, fsys := range i.fss {
 		err := iofs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
 			if err != nil {
@@ -453,26 +641,7 @@ func (i *Indexer) UpdateIndex(ctx context.Context) error {
 				return nil
 			}
 
-			// Check if file needs re-indexing
-			needsUpdate, err := i.needsReindexing(ctx, namespace, path, info)
-			if err != nil {
-				log.Warn("Failed to check if file needs re-indexing",
-					zap.String("path", path),
-					zap.Error(err))
-				return nil
-			}
-
-			if !needsUpdate {
-				return nil
-			}
-
-			if err := i.indexFile(ctx, namespace, path); err != nil {
-				log.Warn("Failed to index file",
-					zap.String("path", path),
-					zap.Error(err))
-			}
-
-			return nil
+			return fn(namespace, path, info)
 		})
 
 		if err != nil {
@@ -480,17 +649,91 @@ func (i *Indexer) UpdateIndex(ctx context.Context) error {
 		}
 	}
 
+	return nil
+}
+
+// UpdateIndex updates the index with changes since the last indexing. The files that need
+// indexing are counted first, so that progress can be reported to Config.Progress as each
+// one is indexed.
+func (i *Indexer) UpdateIndex(ctx context.Context) error {
+	plan, err := i.PlanUpdate(ctx)
+	if err != nil {
+		return err
+	}
+
+	files := slices.Concat(plan.Add, plan.Update)
+	progress := UpdateProgress{
+		Total:     len(files),
+		Unchanged: plan.Unchanged,
+	}
+
+	if len(files) > 0 {
+		log.Info("Indexing files", zap.Int("files", len(files)))
+	}
+
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		progress.Namespace = file.Namespace
+		progress.Path = file.Path
+		progress.Err = i.indexFile(ctx, file.Namespace, file.Path)
+		if progress.Err != nil {
+			log.Warn("Failed to index file",
+				zap.String("path", file.Path),
+				zap.Error(progress.Err))
+			progress.Failed++
+		} else {
+			progress.Indexed++
+		}
+
+		i.reportProgress(progress)
+	}
+
 	// Clean up entries for deleted files
-	if err := i.CleanupDeletedFiles(ctx); err != nil {
-		log.Error("Failed to cleanup deleted files", zap.Error(err))
-		// Continue anyway as this is not a fatal error
+	for _, ref := range plan.Delete {
+		log.Debug("Removing index entries for deleted file", zap.String("path", ref.Path))
+		if err := i.deleteFileEntries(ctx, ComputeID(ref.Namespace, ref.Path, -1)); err != nil {
+			// Continue anyway as this is not a fatal error
+			log.Warn("Failed to delete entries for deleted file", zap.String("path", ref.Path), zap.Error(err))
+			continue
+		}
+		progress.Deleted++
 	}
 
+	log.Info("Index updated",
+		zap.Int("indexed", progress.Indexed),
+		zap.Int("unchanged", progress.Unchanged),
+		zap.Int("failed", progress.Failed),
+		zap.Int("deleted", progress.Deleted))
+
+	progress.Namespace, progress.Path, progress.Err = "", "", nil
+	progress.Done = true
+	i.reportProgress(progress)
+
 	return nil
 }
 
-// Search performs a semantic search over the indexed codebase
-func (i *Indexer) Search(_ context.Context, query string, queryLimit int) ([]db.SearchResult, error) {
+// reportProgress passes the progress of an update to Config.Progress, if set
+func (i *Indexer) reportProgress(progress UpdateProgress) {
+	if i.config.Progress != nil {
+		i.config.Progress(progress)
+	}
+}
+
+// Search performs a semantic search over the indexed codebase, restricted to a namespace if
+// one is given
+func (i *Indexer) Search(ctx context.Context, query string, queryLimit int, namespace string) ([]db.SearchResult, error) {
+	filters := map[string]string{
+		"is_file_entry": "false",
+	}
+	if namespace != "" {
+		if _, ok := i.fss[namespace]; !ok {
+			return nil, fmt.Errorf("%w %q (expected one of %s)", ErrUnknownNamespace, namespace, strings.Join(slices.Sorted(maps.Keys(i.fss)), ", "))
+		}
+		filters["namespace"] = namespace
+	}
 
 	// Get total document count
 	count, err := i.db.Count()
@@ -502,14 +745,26 @@ func (i *Indexer) Search(_ context.Context, query string, queryLimit int) ([]db.
 		queryLimit = count
 	}
 
+	// Keyword matches may not be among the nearest vectors, so hybrid search ranks every
+	// document
+	limit := queryLimit
+	if i.config.KeywordWeight > 0 {
+		limit = count
+	}
+
 	// Search for similar documents with metadata filter
-	searchResults, err := i.db.Query(query, queryLimit, map[string]string{
-		"is_file_entry": "false",
-	})
+	searchResults, err := i.db.Query(ctx, query, limit, filters)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search documents: %w", err)
 	}
 
+	if i.config.KeywordWeight > 0 {
+		searchResults = db.HybridRank(query, searchResults, i.config.KeywordWeight)
+		if len(searchResults) > queryLimit {
+			searchResults = searchResults[:queryLimit]
+		}
+	}
+
 	return searchResults, nil
 }
 
@@ -533,6 +788,57 @@ func (i *Indexer) ExtractFileSummaries(ctx context.Context, path string) ([]Cont
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
+	return i.extractSummaries(ctx, path, content)
+}
+
+// extractSummaries splits a file into chunks with the chunker for its language, if there is
+// one. Otherwise it uses the generator to create semantic summaries of the content, a window
+// at a time if it is too large to summarize at once.
+func (i *Indexer) extractSummaries(ctx context.Context, path string, content []byte) ([]ContentSummary, error) {
+	if chunker, ok := i.config.Chunkers[detectLanguage(path)]; ok {
+		summaries, err := chunker.Chunk(ctx, path, content)
+		if err == nil {
+			return summaries, nil
+		}
+
+		log.Debug("Falling back to the summary model", zap.String("path", path), zap.Error(err))
+	}
+
+	windows := i.config.summaryWindows(content)
+	if len(windows) == 1 {
+		return i.summarizeWindow(ctx, content)
+	}
+
+	var summaries []ContentSummary
+	for idx, window := range windows {
+		windowSummaries, err := i.summarizeWindow(ctx, window.content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to summarize lines %d-%d: %w", window.startLine, window.endLine(), err)
+		}
+
+		for _, summary := range windowSummaries {
+			summary.ContentSpan.StartLine += window.startLine - 1
+			summary.ContentSpan.EndLine += window.startLine - 1
+
+			// The previous window saw the whole of anything that ends in the overlap
+			if idx > 0 && summary.ContentSpan.EndLine < window.startLine+window.overlap {
+				continue
+			}
+
+			summaries = append(summaries, summary)
+		}
+	}
+
+	return summaries, nil
+}
+
+// summarizeWindow uses the generator to create semantic summaries of a file, or of a window
+// of one, with line numbers relative to the start of the content
+func (i *Indexer) summarizeWindow(ctx context.Context, content []byte) ([]ContentSummary, error) {
+	if i.summarize != nil {
+		return i.summarize(ctx, content)
+	}
+
 	// Add line numbers to the content
 	lines := strings.Split(string(content), "\n")
 	numberedContent := strings.Builder{}
@@ -540,30 +846,25 @@ func (i *Indexer) ExtractFileSummaries(ctx context.Context, path string) ([]Cont
 		numberedContent.WriteString(fmt.Sprintf("%4d | %s\n", idx+1, line))
 	}
 
-	model := i.gemini.GenerativeModel("gemini-2.0-flash-lite")
-	model.SetTemperature(0.1)       // Lower temperature for more consistent output
-	model.SetMaxOutputTokens(32768) // Set maximum token limit to 32k
-
-	// Configure structured output
-	model.ResponseMIMEType = "application/json"
-	model.ResponseSchema = &genai.Schema{
-		Type: genai.TypeObject,
-		Properties: map[string]*genai.Schema{
+	// Request structured output, so the summaries can be parsed reliably
+	schema := &ResponseSchema{
+		Type: "object",
+		Properties: map[string]*ResponseSchema{
 			"summaries": {
-				Type: genai.TypeArray,
-				Items: &genai.Schema{
-					Type: genai.TypeObject,
-					Properties: map[string]*genai.Schema{
+				Type: "array",
+				Items: &ResponseSchema{
+					Type: "object",
+					Properties: map[string]*ResponseSchema{
 						"summary": {
-							Type:        genai.TypeString,
+							Type:        "string",
 							Description: "A clear, concise description in plain English of what this code element or section does",
 						},
 						"start_line": {
-							Type:        genai.TypeInteger,
+							Type:        "integer",
 							Description: "The starting line number of this element",
 						},
 						"end_line": {
-							Type:        genai.TypeInteger,
+							Type:        "integer",
 							Description: "The ending line number of this element",
 						},
 					},
@@ -594,26 +895,19 @@ File contents:
 %s`, numberedContent.String())
 
 	// Generate the content
-	resp, err := model.GenerateContent(ctx, genai.Text(prompt))
+	text, err := retry.Do(ctx, i.config.Retry, func(ctx context.Context) (string, error) {
+		return i.generator.Generate(ctx, GenerateRequest{
+			Model:           i.config.summaryModel(),
+			Prompt:          prompt,
+			Temperature:     0.1,   // Lower temperature for more consistent output
+			MaxOutputTokens: 32768, // Set maximum token limit to 32k
+			Schema:          schema,
+		})
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate content: %w", err)
 	}
 
-	if len(resp.Candidates) == 0 {
-		return nil, fmt.Errorf("no content generated")
-	}
-
-	candidate := resp.Candidates[0]
-	if len(candidate.Content.Parts) == 0 {
-		return nil, fmt.Errorf("empty response")
-	}
-
-	// Extract the text response
-	text, ok := candidate.Content.Parts[0].(genai.Text)
-	if !ok {
-		return nil, fmt.Errorf("expected text response, got %T", candidate.Content.Parts[0])
-	}
-
 	// Parse the JSON response
 	var result struct {
 		Summaries []struct {
@@ -623,7 +917,7 @@ File contents:
 		} `json:"summaries"`
 	}
 
-	if err := json.Unmarshal([]byte(string(text)), &result); err != nil {
+	if err := json.Unmarshal([]byte(text), &result); err != nil {
 		return nil, fmt.Errorf("failed to parse response as JSON: %w", err)
 	}
 
@@ -631,18 +925,8 @@ File contents:
 		return nil, fmt.Errorf("no summaries found in response")
 	}
 
-	// Convert to ContentSummary objects and validate
-	var summaries []ContentSummary
-	for i, s := range result.Summaries {
-		if s.StartLine > s.EndLine {
-			s.StartLine, s.EndLine = s.EndLine, s.StartLine
-		}
-
-		// Validate line numbers
-		if s.StartLine < 1 || s.EndLine < s.StartLine || s.EndLine > len(lines) {
-			return nil, fmt.Errorf("invalid line range %d-%d at index %d", s.StartLine, s.EndLine, i)
-		}
-
+	summaries := make([]ContentSummary, 0, len(result.Summaries))
+	for _, s := range result.Summaries {
 		summaries = append(summaries, ContentSummary{
 			Summary: s.Summary,
 			ContentSpan: ContentSpan{
@@ -652,5 +936,48 @@ File contents:
 		})
 	}
 
-	return summaries, nil
+	return repairSpans(summaries, len(lines))
+}
+
+// repairSpans fixes the line ranges of summaries from the summary model, which occasionally
+// gets one slightly wrong. Inverted ranges are swapped and ranges that overrun the content
+// are clamped to it, while summaries entirely outside the content are dropped. It is only an
+// error if no summaries remain.
+func repairSpans(summaries []ContentSummary, lineCount int) ([]ContentSummary, error) {
+	var repaired []ContentSummary
+	for idx, summary := range summaries {
+		span := summary.ContentSpan
+		if span.StartLine > span.EndLine {
+			span.StartLine, span.EndLine = span.EndLine, span.StartLine
+		}
+
+		if span.EndLine < 1 || span.StartLine > lineCount {
+			log.Warn("Dropping summary with a line range outside the content",
+				zap.Int("index", idx),
+				zap.Int("start_line", summary.ContentSpan.StartLine),
+				zap.Int("end_line", summary.ContentSpan.EndLine),
+				zap.Int("lines", lineCount))
+			continue
+		}
+
+		span.StartLine = max(span.StartLine, 1)
+		span.EndLine = min(span.EndLine, lineCount)
+		if span != summary.ContentSpan {
+			log.Debug("Repaired summary line range",
+				zap.Int("index", idx),
+				zap.Int("start_line", summary.ContentSpan.StartLine),
+				zap.Int("end_line", summary.ContentSpan.EndLine),
+				zap.Int("repaired_start_line", span.StartLine),
+				zap.Int("repaired_end_line", span.EndLine))
+		}
+
+		summary.ContentSpan = span
+		repaired = append(repaired, summary)
+	}
+
+	if len(repaired) == 0 {
+		return nil, fmt.Errorf("no summary had a line range within the content's %d lines", lineCount)
+	}
+
+	return repaired, nil
 }
@@ -0,0 +1,157 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IndexedFile describes a file held in the index
+type IndexedFile struct {
+	Namespace string `json:"namespace"`
+	Path      string `json:"path"`
+	Language  string `json:"language"`
+	Size      int64  `json:"size"`
+	Chunks    int    `json:"chunks"`
+}
+
+// FileFilter narrows the files listed by ListIndexedFiles. Empty fields match every file.
+type FileFilter struct {
+	// Namespace only matches files in this namespace
+	Namespace string
+
+	// Language only matches files in this language, eg Go, ignoring case
+	Language string
+
+	// PathPrefix only matches files whose path starts with this prefix, eg a directory
+	PathPrefix string
+}
+
+// LanguageStats counts the files and chunks in the index written in a language
+type LanguageStats struct {
+	Language string `json:"language"`
+	Files    int    `json:"files"`
+	Chunks   int    `json:"chunks"`
+}
+
+// IndexStats summarizes the contents of the index
+type IndexStats struct {
+	// Documents is the total number of documents, both file entries and chunks
+	Documents int `json:"documents"`
+	Files     int `json:"files"`
+	Chunks    int `json:"chunks"`
+
+	// Languages breaks the files and chunks down by language, most files first
+	Languages []LanguageStats `json:"languages"`
+
+	// EmbeddingDimensions is the length of the chunks' embedding vectors, or 0 if there are
+	// no chunks
+	EmbeddingDimensions int `json:"embedding_dimensions"`
+}
+
+// ListIndexedFiles returns the files held in the index that match the filter, sorted by
+// namespace and path
+func (i *Indexer) ListIndexedFiles(_ context.Context, filter FileFilter) ([]IndexedFile, error) {
+	filters := map[string]string{"is_file_entry": "true"}
+	if filter.Namespace != "" {
+		filters["namespace"] = filter.Namespace
+	}
+
+	docs, err := i.db.FilterDocuments(filters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query file entries: %w", err)
+	}
+
+	files := make([]IndexedFile, 0, len(docs))
+	for _, doc := range docs {
+		metadata := doc.Metadata
+		if filter.Language != "" && !strings.EqualFold(metadata["language"], filter.Language) {
+			continue
+		}
+		if !strings.HasPrefix(metadata["path"], filter.PathPrefix) {
+			continue
+		}
+
+		// Entries from older indexes may have no size or chunk count
+		size, _ := strconv.ParseInt(metadata["size"], 10, 64)
+		chunks, _ := strconv.Atoi(metadata["chunk_count"])
+
+		files = append(files, IndexedFile{
+			Namespace: metadata["namespace"],
+			Path:      metadata["path"],
+			Language:  metadata["language"],
+			Size:      size,
+			Chunks:    chunks,
+		})
+	}
+
+	sort.Slice(files, func(a, b int) bool {
+		if files[a].Namespace != files[b].Namespace {
+			return files[a].Namespace < files[b].Namespace
+		}
+		return files[a].Path < files[b].Path
+	})
+
+	return files, nil
+}
+
+// Stats counts the documents in the index, by kind and language, and reports the dimensions
+// of the embeddings
+func (i *Indexer) Stats(_ context.Context) (*IndexStats, error) {
+	documents, err := i.db.Count()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count documents: %w", err)
+	}
+
+	files, err := i.db.FilterDocuments(map[string]string{"is_file_entry": "true"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query file entries: %w", err)
+	}
+
+	chunks, err := i.db.FilterDocuments(map[string]string{"is_file_entry": "false"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chunks: %w", err)
+	}
+
+	stats := &IndexStats{
+		Documents: documents,
+		Files:     len(files),
+		Chunks:    len(chunks),
+	}
+
+	byLanguage := make(map[string]*LanguageStats)
+	languageStats := func(language string) *LanguageStats {
+		s, ok := byLanguage[language]
+		if !ok {
+			s = &LanguageStats{Language: language}
+			byLanguage[language] = s
+		}
+		return s
+	}
+
+	for _, doc := range files {
+		languageStats(doc.Metadata["language"]).Files++
+	}
+
+	for _, doc := range chunks {
+		languageStats(doc.Metadata["language"]).Chunks++
+		if stats.EmbeddingDimensions == 0 {
+			stats.EmbeddingDimensions = len(doc.Vector)
+		}
+	}
+
+	stats.Languages = make([]LanguageStats, 0, len(byLanguage))
+	for _, s := range byLanguage {
+		stats.Languages = append(stats.Languages, *s)
+	}
+	sort.Slice(stats.Languages, func(a, b int) bool {
+		if stats.Languages[a].Files != stats.Languages[b].Files {
+			return stats.Languages[a].Files > stats.Languages[b].Files
+		}
+		return stats.Languages[a].Language < stats.Languages[b].Language
+	})
+
+	return stats, nil
+}
@@ -0,0 +1,80 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInventory(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(rootDir, "cmd"), 0755))
+	for path, content := range map[string]string{
+		"main.go":      "package main\n\nfunc main() {}\n",
+		"cmd/serve.go": "package cmd\n",
+		"tools.py":     "print('hi')\n",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(rootDir, path), []byte(content), 0644))
+	}
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	require.NoError(t, err)
+
+	store, err := OpenStore(Config{Backend: BackendMemory}, bagOfWords)
+	require.NoError(t, err)
+
+	indexer := NewIndexer(nil, store, FSContextMap{RepoNamespace: filteredFS}, Config{Backend: BackendMemory})
+	defer indexer.Close()
+
+	// Each line of a file becomes a chunk
+	indexer.summarize = func(_ context.Context, content []byte) ([]ContentSummary, error) {
+		var summaries []ContentSummary
+		for n, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+			if line != "" {
+				summaries = append(summaries, ContentSummary{Summary: line, ContentSpan: ContentSpan{StartLine: n + 1, EndLine: n + 1}})
+			}
+		}
+		return summaries, nil
+	}
+
+	require.NoError(t, indexer.UpdateIndex(context.Background()))
+
+	files, err := indexer.ListIndexedFiles(context.Background(), FileFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []IndexedFile{
+		{Namespace: RepoNamespace, Path: "cmd/serve.go", Language: "Go", Size: 12, Chunks: 1},
+		{Namespace: RepoNamespace, Path: "main.go", Language: "Go", Size: 29, Chunks: 2},
+		{Namespace: RepoNamespace, Path: "tools.py", Language: "Python", Size: 12, Chunks: 1},
+	}, files)
+
+	files, err = indexer.ListIndexedFiles(context.Background(), FileFilter{Language: "go", PathPrefix: "cmd/"})
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "cmd/serve.go", files[0].Path)
+
+	files, err = indexer.ListIndexedFiles(context.Background(), FileFilter{Namespace: ExtraContextNamespace})
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	stats, err := indexer.Stats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &IndexStats{
+		Documents: 7,
+		Files:     3,
+		Chunks:    4,
+		Languages: []LanguageStats{
+			{Language: "Go", Files: 2, Chunks: 3},
+			{Language: "Python", Files: 1, Chunks: 1},
+		},
+		EmbeddingDimensions: 64,
+	}, stats)
+}
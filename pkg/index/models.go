@@ -0,0 +1,126 @@
+package index
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// Provider selects the service that embeds and generates text for the index
+type Provider string
+
+const (
+	// ProviderGemini uses the Gemini API
+	ProviderGemini Provider = "gemini"
+	// ProviderOpenAI uses an OpenAI-compatible API, such as OpenAI itself, Ollama or LM Studio
+	ProviderOpenAI Provider = "openai"
+)
+
+const (
+	// DefaultOpenAIBaseURL is the address of the OpenAI API
+	DefaultOpenAIBaseURL = "https://api.openai.com/v1"
+	// DefaultOpenAIEmbeddingModel is the embedding model used with ProviderOpenAI
+	DefaultOpenAIEmbeddingModel = "text-embedding-3-small"
+	// DefaultOpenAIChatModel is the model used to summarize and answer with ProviderOpenAI
+	DefaultOpenAIChatModel = "gpt-4o-mini"
+)
+
+// ParseProvider parses a provider name, returning an error for unknown providers
+func ParseProvider(name string) (Provider, error) {
+	switch provider := Provider(name); provider {
+	case ProviderGemini, ProviderOpenAI:
+		return provider, nil
+	case "":
+		return ProviderGemini, nil
+	default:
+		return "", fmt.Errorf("unknown model provider %q (expected %q or %q)", name, ProviderGemini, ProviderOpenAI)
+	}
+}
+
+// Embedder turns text into a vector for similarity search
+type Embedder interface {
+	Embed(ctx context.Context, content string) ([]float32, error)
+}
+
+// Generator generates text from a prompt
+type Generator interface {
+	Generate(ctx context.Context, req GenerateRequest) (string, error)
+}
+
+// GenerateRequest is a prompt for a Generator, along with how to respond to it
+type GenerateRequest struct {
+	Model  string
+	Prompt string
+
+	Temperature float32
+
+	// MaxOutputTokens limits the length of the response, if set
+	MaxOutputTokens int32
+
+	// Schema, if set, requires the response to be JSON matching it
+	Schema *ResponseSchema
+}
+
+// ResponseSchema is the subset of JSON Schema supported by every provider's structured output
+type ResponseSchema struct {
+	Type        string                     `json:"type"`
+	Description string                     `json:"description,omitempty"`
+	Properties  map[string]*ResponseSchema `json:"properties,omitempty"`
+	Items       *ResponseSchema            `json:"items,omitempty"`
+	Required    []string                   `json:"required,omitempty"`
+}
+
+// NewModels returns the embedder and generator for the configured provider. The Gemini
+// client is only used by ProviderGemini.
+func NewModels(gemini *genai.Client, config Config) (Embedder, Generator, error) {
+	switch config.Provider {
+	case ProviderGemini, "":
+		embedder := &GeminiEmbedder{
+			Client: gemini,
+			Model:  config.embeddingModel(),
+		}
+		return embedder, &GeminiGenerator{Client: gemini}, nil
+
+	case ProviderOpenAI:
+		client := &OpenAIClient{
+			BaseURL:    cmp.Or(config.OpenAIBaseURL, DefaultOpenAIBaseURL),
+			APIKey:     config.OpenAIAPIKey,
+			HTTPClient: http.DefaultClient,
+		}
+		embedder := &OpenAIEmbedder{
+			Client: client,
+			Model:  config.embeddingModel(),
+		}
+		return embedder, &OpenAIGenerator{Client: client}, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unknown model provider %q", config.Provider)
+	}
+}
+
+// embeddingModel returns the model used for embeddings
+func (c Config) embeddingModel() string {
+	if c.Provider == ProviderOpenAI {
+		return cmp.Or(c.EmbeddingModel, DefaultOpenAIEmbeddingModel)
+	}
+	return cmp.Or(c.EmbeddingModel, DefaultEmbeddingModel)
+}
+
+// summaryModel returns the model used to summarize files
+func (c Config) summaryModel() string {
+	if c.Provider == ProviderOpenAI {
+		return cmp.Or(c.SummaryModel, DefaultOpenAIChatModel)
+	}
+	return cmp.Or(c.SummaryModel, DefaultSummaryModel)
+}
+
+// queryModel returns the model used to answer queries
+func (c Config) queryModel() string {
+	if c.Provider == ProviderOpenAI {
+		return cmp.Or(c.QueryModel, DefaultOpenAIChatModel)
+	}
+	return cmp.Or(c.QueryModel, DefaultQueryModel)
+}
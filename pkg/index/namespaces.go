@@ -0,0 +1,49 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrUnknownNamespace is returned when searching a namespace the indexer doesn't read from
+var ErrUnknownNamespace = errors.New("unknown namespace")
+
+// NamespaceInfo describes a source of context held in the index
+type NamespaceInfo struct {
+	Name      string `json:"name"`
+	Files     int    `json:"files"`
+	Documents int    `json:"documents"`
+}
+
+// Namespaces returns each namespace the indexer reads from, along with how many files it
+// has indexed and the total number of documents (file entries and chunks) it holds
+func (i *Indexer) Namespaces(_ context.Context) ([]NamespaceInfo, error) {
+	names := make([]string, 0, len(i.fss))
+	for name := range i.fss {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	infos := make([]NamespaceInfo, 0, len(names))
+	for _, name := range names {
+		documents, err := i.db.CountDocuments(map[string]string{"namespace": name})
+		if err != nil {
+			return nil, fmt.Errorf("failed to count documents in namespace %s: %w", name, err)
+		}
+
+		files, err := i.db.CountDocuments(map[string]string{"namespace": name, "is_file_entry": "true"})
+		if err != nil {
+			return nil, fmt.Errorf("failed to count files in namespace %s: %w", name, err)
+		}
+
+		infos = append(infos, NamespaceInfo{
+			Name:      name,
+			Files:     files,
+			Documents: documents,
+		})
+	}
+
+	return infos, nil
+}
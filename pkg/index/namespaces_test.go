@@ -0,0 +1,122 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/db"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamespaces(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	docDB, err := db.NewDocumentDB(filepath.Join(t.TempDir(), "db"), func(context.Context, string) ([]float32, error) {
+		return []float32{1, 0, 0}, nil
+	})
+	require.NoError(t, err)
+	defer docDB.Close()
+
+	// Move a document into the extra namespace
+	extra := func(doc db.Document) db.Document {
+		doc.ID = ExtraContextNamespace + doc.ID[len(RepoNamespace):]
+		doc.Metadata["namespace"] = ExtraContextNamespace
+		return doc
+	}
+
+	for _, doc := range []db.Document{
+		fileEntry("main.go", "", ""),
+		chunkEntry("main.go", 0, 1, 10, "main function"),
+		chunkEntry("main.go", 1, 11, 20, "helpers"),
+		fileEntry("util.go", "", ""),
+		extra(fileEntry("docs/guide.md", "", "")),
+		extra(chunkEntry("docs/guide.md", 0, 1, 5, "setup guide")),
+	} {
+		require.NoError(t, docDB.AddDocument(context.Background(), doc))
+	}
+
+	repoFS, err := repo.NewRepoFS(t.TempDir()).Filter()
+	require.NoError(t, err)
+	extraFS, err := repo.NewRepoFS(t.TempDir()).Filter()
+	require.NoError(t, err)
+
+	indexer := &Indexer{
+		fss: FSContextMap{RepoNamespace: repoFS, ExtraContextNamespace: extraFS},
+		db:  docDB,
+	}
+
+	namespaces, err := indexer.Namespaces(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, []NamespaceInfo{
+		{Name: ExtraContextNamespace, Files: 1, Documents: 2},
+		{Name: RepoNamespace, Files: 2, Documents: 4},
+	}, namespaces)
+
+	// Searches can be restricted to a namespace
+	for namespace, want := range map[string]int{"": 3, RepoNamespace: 2, ExtraContextNamespace: 1} {
+		results, err := indexer.Search(context.Background(), "guide", 10, namespace)
+		require.NoError(t, err)
+		require.Len(t, results, want, "namespace %q", namespace)
+		if namespace != "" {
+			for _, result := range results {
+				assert.Equal(t, namespace, result.Document.Metadata["namespace"])
+			}
+		}
+	}
+
+	_, err = indexer.Search(context.Background(), "guide", 10, "docs")
+	assert.ErrorIs(t, err, ErrUnknownNamespace)
+}
+
+func TestQueryExtraContext(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "server.go"), []byte("package server\n\nfunc Serve() {}\n"), 0644))
+
+	// The design doc lives outside the repository, as with --extra-context docs/design.md
+	designPath := filepath.Join(t.TempDir(), "design.md")
+	require.NoError(t, os.WriteFile(designPath, []byte("# Retries\n\nFailed uploads are retried three times with backoff.\n"), 0644))
+
+	repoFS, err := repo.NewRepoFS(rootDir).Filter()
+	require.NoError(t, err)
+
+	virtualFS := repo.NewVirtualFS()
+	require.NoError(t, virtualFS.AddFile(designPath))
+	extraFS, err := virtualFS.Filter()
+	require.NoError(t, err)
+
+	store, err := OpenStore(Config{Backend: BackendMemory}, bagOfWords)
+	require.NoError(t, err)
+
+	indexer := NewIndexer(nil, store, FSContextMap{RepoNamespace: repoFS, ExtraContextNamespace: extraFS}, Config{Backend: BackendMemory})
+	defer indexer.Close()
+
+	indexer.summarize = func(_ context.Context, content []byte) ([]ContentSummary, error) {
+		summary := "Starts the HTTP server"
+		if strings.Contains(string(content), "Retries") {
+			summary = "How failed uploads are retried"
+		}
+		return []ContentSummary{{Summary: summary, ContentSpan: ContentSpan{StartLine: 1, EndLine: 3}}}, nil
+	}
+
+	var prompt string
+	indexer.generate = func(_ context.Context, p string) (string, error) {
+		prompt = p
+		return "Uploads are retried three times", nil
+	}
+
+	require.NoError(t, indexer.UpdateIndex(context.Background()))
+
+	result, err := indexer.Query(context.Background(), "how are failed uploads retried", QueryOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "Uploads are retried three times", result.Answer)
+	assert.Contains(t, prompt, "Failed uploads are retried three times with backoff.")
+}
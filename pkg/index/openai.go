@@ -0,0 +1,142 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/russellhaering/autoswe/pkg/retry"
+)
+
+// OpenAIClient calls an OpenAI-compatible API, such as OpenAI itself, Ollama or LM Studio
+type OpenAIClient struct {
+	// BaseURL is the address of the API, including any version prefix such as /v1
+	BaseURL string
+
+	// APIKey is sent as a bearer token, if set. Local servers usually don't need one.
+	APIKey string
+
+	HTTPClient *http.Client
+}
+
+// post sends a JSON request to the API and decodes the JSON response into out. Error
+// responses are returned as a retry.HTTPError, so that rate limits and server errors are
+// retried.
+func (c *OpenAIClient) post(ctx context.Context, path string, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(c.BaseURL, "/")+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &retry.HTTPError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return nil
+}
+
+// OpenAIEmbedder embeds text with the embeddings endpoint of an OpenAI-compatible API
+type OpenAIEmbedder struct {
+	Client *OpenAIClient
+	Model  string
+}
+
+// Embed implements Embedder
+func (e *OpenAIEmbedder) Embed(ctx context.Context, content string) ([]float32, error) {
+	var resp struct {
+		Data []struct {
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+
+	err := e.Client.post(ctx, "/embeddings", map[string]any{
+		"model": e.Model,
+		"input": content,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Data) == 0 {
+		return nil, fmt.Errorf("no embedding returned")
+	}
+
+	return resp.Data[0].Embedding, nil
+}
+
+// OpenAIGenerator generates text with the chat completions endpoint of an OpenAI-compatible
+// API
+type OpenAIGenerator struct {
+	Client *OpenAIClient
+}
+
+// Generate implements Generator
+func (g *OpenAIGenerator) Generate(ctx context.Context, req GenerateRequest) (string, error) {
+	body := map[string]any{
+		"model": req.Model,
+		"messages": []map[string]string{
+			{"role": "user", "content": req.Prompt},
+		},
+		"temperature": req.Temperature,
+	}
+
+	if req.MaxOutputTokens > 0 {
+		body["max_tokens"] = req.MaxOutputTokens
+	}
+
+	if req.Schema != nil {
+		body["response_format"] = map[string]any{
+			"type": "json_schema",
+			"json_schema": map[string]any{
+				"name":   "response",
+				"schema": req.Schema,
+			},
+		}
+	}
+
+	var resp struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+
+	if err := g.Client.post(ctx, "/chat/completions", body, &resp); err != nil {
+		return "", err
+	}
+
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no content generated")
+	}
+
+	return resp.Choices[0].Message.Content, nil
+}
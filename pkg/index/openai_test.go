@@ -0,0 +1,71 @@
+package index
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAIModels(t *testing.T) {
+	var requests []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer test-key", r.Header.Get("Authorization"))
+
+		var req map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		requests = append(requests, req)
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/embeddings":
+			_, _ = w.Write([]byte(`{"data":[{"embedding":[0.5,0.25]}]}`))
+		case "/v1/chat/completions":
+			_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"{\"answer\":\"42\"}"}}]}`))
+		default:
+			http.Error(w, "rate limited", http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	embedder, generator, err := NewModels(nil, Config{
+		Provider:      ProviderOpenAI,
+		OpenAIBaseURL: server.URL + "/v1/",
+		OpenAIAPIKey:  "test-key",
+	})
+	require.NoError(t, err)
+
+	embedding, err := embedder.Embed(context.Background(), "func main() {}")
+	require.NoError(t, err)
+	assert.Equal(t, []float32{0.5, 0.25}, embedding)
+	assert.Equal(t, DefaultOpenAIEmbeddingModel, requests[0]["model"])
+	assert.Equal(t, "func main() {}", requests[0]["input"])
+
+	text, err := generator.Generate(context.Background(), GenerateRequest{
+		Model:  "local-model",
+		Prompt: "What is the answer?",
+		Schema: &ResponseSchema{
+			Type:       "object",
+			Properties: map[string]*ResponseSchema{"answer": {Type: "string"}},
+			Required:   []string{"answer"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `{"answer":"42"}`, text)
+	assert.Equal(t, "local-model", requests[1]["model"])
+	assert.Equal(t, "json_schema", requests[1]["response_format"].(map[string]any)["type"])
+
+	// Error responses can be retried when they are transient
+	client := &OpenAIClient{BaseURL: server.URL, APIKey: "test-key", HTTPClient: server.Client()}
+	err = client.post(context.Background(), "/unknown", map[string]any{}, &struct{}{})
+	var httpErr *retry.HTTPError
+	require.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, http.StatusTooManyRequests, httpErr.StatusCode)
+	assert.True(t, retry.IsTransient(err))
+}
@@ -0,0 +1,82 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	iofs "io/fs"
+	"os"
+
+	"github.com/russellhaering/autoswe/pkg/log"
+	"go.uber.org/zap"
+)
+
+// PlannedFile is a file that would be indexed by an update, along with the reason why
+type PlannedFile struct {
+	Namespace string        `json:"namespace"`
+	Path      string        `json:"path"`
+	Reason    ReindexReason `json:"reason"`
+}
+
+// UpdatePlan describes the changes that UpdateIndex would make without making them
+type UpdatePlan struct {
+	Add    []PlannedFile `json:"add"`
+	Update []PlannedFile `json:"update"`
+	Delete []FileRef     `json:"delete"`
+
+	// Unchanged is the number of files that are already up to date
+	Unchanged int `json:"unchanged"`
+}
+
+// PlanUpdate walks the indexed filesystems and reports which files would be added, updated
+// or deleted by UpdateIndex. No summaries or embeddings are generated.
+func (i *Indexer) PlanUpdate(ctx context.Context) (*UpdatePlan, error) {
+	plan := &UpdatePlan{}
+
+	err := i.walkFiles(func(namespace, path string, info iofs.FileInfo) error {
+		reason, err := i.reindexReason(ctx, namespace, path, info)
+		if err != nil {
+			log.Warn("Failed to check if file needs re-indexing",
+				zap.String("path", path),
+				zap.Error(err))
+			return nil
+		}
+
+		planned := PlannedFile{
+			Namespace: namespace,
+			Path:      path,
+			Reason:    reason,
+		}
+
+		switch reason {
+		case ReindexReasonNone:
+			plan.Unchanged++
+		case ReindexReasonNew:
+			plan.Add = append(plan.Add, planned)
+		default:
+			plan.Update = append(plan.Update, planned)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	indexedFiles, err := i.GetIndexedFiles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get indexed files: %w", err)
+	}
+
+	for _, ref := range indexedFiles {
+		fsys, ok := i.fss[ref.Namespace]
+		if !ok {
+			continue
+		}
+
+		if _, err := iofs.Stat(fsys, ref.Path); os.IsNotExist(err) {
+			plan.Delete = append(plan.Delete, ref)
+		}
+	}
+
+	return plan, nil
+}
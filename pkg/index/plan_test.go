@@ -0,0 +1,209 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/russellhaering/autoswe/pkg/db"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanUpdate(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "new.go"), []byte("package new"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "changed.go"), []byte("package changed"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "same.go"), []byte("package same"), 0644))
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	require.NoError(t, err)
+
+	embedCalls := 0
+	docDB, err := db.NewDocumentDB(filepath.Join(t.TempDir(), "db"), func(context.Context, string) ([]float32, error) {
+		embedCalls++
+		return []float32{1, 0, 0}, nil
+	})
+	require.NoError(t, err)
+	defer docDB.Close()
+
+	sameInfo, err := os.Stat(filepath.Join(rootDir, "same.go"))
+	require.NoError(t, err)
+	sameHash, err := ComputeContentHash([]byte("package same"))
+	require.NoError(t, err)
+
+	longAgo := time.Now().Add(-24 * time.Hour).Format(time.RFC3339)
+	for _, doc := range []db.Document{
+		fileEntry("changed.go", longAgo, "stale-hash"),
+		fileEntry("deleted.go", longAgo, "deleted-hash"),
+		fileEntry("same.go", sameInfo.ModTime().Format(time.RFC3339), sameHash),
+	} {
+		require.NoError(t, docDB.AddDocument(context.Background(), doc))
+	}
+	embedCalls = 0
+
+	indexer := &Indexer{
+		fss: FSContextMap{RepoNamespace: filteredFS},
+		db:  docDB,
+	}
+
+	plan, err := indexer.PlanUpdate(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, []PlannedFile{{Namespace: RepoNamespace, Path: "new.go", Reason: ReindexReasonNew}}, plan.Add)
+	assert.Equal(t, []PlannedFile{{Namespace: RepoNamespace, Path: "changed.go", Reason: ReindexReasonHash}}, plan.Update)
+	assert.Equal(t, []FileRef{{Namespace: RepoNamespace, Path: "deleted.go"}}, plan.Delete)
+	assert.Equal(t, 1, plan.Unchanged)
+	assert.Zero(t, embedCalls, "planning should not call the embedder")
+}
+
+func TestUpdateIndexProgress(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go", "broken.go"} {
+		require.NoError(t, os.WriteFile(filepath.Join(rootDir, name), []byte("package "+strings.TrimSuffix(name, ".go")), 0644))
+	}
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	require.NoError(t, err)
+
+	store, err := OpenStore(Config{Backend: BackendMemory}, bagOfWords)
+	require.NoError(t, err)
+
+	var reports []UpdateProgress
+	indexer := NewIndexer(nil, store, FSContextMap{RepoNamespace: filteredFS}, Config{
+		Backend:  BackendMemory,
+		Progress: func(progress UpdateProgress) { reports = append(reports, progress) },
+	})
+	defer indexer.Close()
+
+	indexer.summarize = func(_ context.Context, content []byte) ([]ContentSummary, error) {
+		if strings.Contains(string(content), "broken") {
+			return nil, errors.New("summary failed")
+		}
+		return []ContentSummary{{Summary: string(content), ContentSpan: ContentSpan{StartLine: 1, EndLine: 1}}}, nil
+	}
+
+	require.NoError(t, indexer.UpdateIndex(context.Background()))
+
+	// Each file is reported as it is indexed, followed by a final summary
+	require.Len(t, reports, 4)
+	for n, progress := range reports[:3] {
+		assert.Equal(t, 3, progress.Total)
+		assert.Equal(t, n+1, progress.Indexed+progress.Failed)
+		assert.False(t, progress.Done)
+	}
+	assert.Equal(t, "broken.go", reports[2].Path)
+	assert.Error(t, reports[2].Err)
+	assert.Equal(t, UpdateProgress{Total: 3, Indexed: 2, Failed: 1, Done: true}, reports[3])
+
+	// A second update finds nothing to do, apart from retrying the file that failed
+	reports = nil
+	require.NoError(t, indexer.UpdateIndex(context.Background()))
+	assert.Equal(t, UpdateProgress{Total: 1, Failed: 1, Unchanged: 2, Done: true}, reports[len(reports)-1])
+}
+
+func fileEntry(path, modTime, hash string) db.Document {
+	return db.Document{
+		ID: ComputeID(RepoNamespace, path, -1),
+		Metadata: map[string]string{
+			"path":          path,
+			"mod_time":      modTime,
+			"hash":          hash,
+			"is_file_entry": "true",
+			"namespace":     RepoNamespace,
+		},
+	}
+}
+
+func TestPlanUpdateIncludePaths(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "main.go"), []byte("package main"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "go.sum"), []byte("example.com/lib v1.0.0 h1:abc="), 0644))
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter("go.sum")
+	require.NoError(t, err)
+
+	docDB, err := db.NewDocumentDB(filepath.Join(t.TempDir(), "db"), func(context.Context, string) ([]float32, error) {
+		return []float32{1, 0, 0}, nil
+	})
+	require.NoError(t, err)
+	defer docDB.Close()
+
+	indexer := &Indexer{
+		fss: FSContextMap{RepoNamespace: filteredFS},
+		db:  docDB,
+	}
+
+	plan, err := indexer.PlanUpdate(context.Background())
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []PlannedFile{
+		{Namespace: RepoNamespace, Path: "go.sum", Reason: ReindexReasonNew},
+		{Namespace: RepoNamespace, Path: "main.go", Reason: ReindexReasonNew},
+	}, plan.Add)
+}
+
+func TestReindexReason(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+	content := []byte("package main")
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "main.go"), content, 0644))
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	require.NoError(t, err)
+
+	info, err := os.Stat(filepath.Join(rootDir, "main.go"))
+	require.NoError(t, err)
+	hash, err := ComputeContentHash(content)
+	require.NoError(t, err)
+
+	modTime := info.ModTime().Format(time.RFC3339)
+	later := info.ModTime().Add(time.Hour).Format(time.RFC3339)
+	earlier := info.ModTime().Add(-time.Hour).Format(time.RFC3339)
+	size := strconv.Itoa(len(content))
+
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		want     ReindexReason
+	}{
+		{"unchanged", map[string]string{"mod_time": modTime, "size": size, "hash": hash}, ReindexReasonNone},
+		{"touched", map[string]string{"mod_time": earlier, "size": size, "hash": hash}, ReindexReasonNone},
+		{"edited", map[string]string{"mod_time": earlier, "size": size, "hash": "stale"}, ReindexReasonHash},
+		{"restored with an older mod time", map[string]string{"mod_time": later, "size": size, "hash": "stale"}, ReindexReasonHash},
+		{"edited preserving the mod time", map[string]string{"mod_time": modTime, "size": "3", "hash": "stale"}, ReindexReasonHash},
+		{"older index without a hash or size", map[string]string{"mod_time": earlier}, ReindexReasonModTime},
+		{"older index without a size", map[string]string{"mod_time": earlier, "hash": hash}, ReindexReasonNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := OpenStore(Config{Backend: BackendMemory}, bagOfWords)
+			require.NoError(t, err)
+			defer store.Close()
+
+			doc := fileEntry("main.go", "", "")
+			doc.Metadata = tt.metadata
+			require.NoError(t, store.AddDocument(context.Background(), doc))
+
+			indexer := &Indexer{fss: FSContextMap{RepoNamespace: filteredFS}, db: store}
+			reason, err := indexer.reindexReason(context.Background(), RepoNamespace, "main.go", info)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, reason)
+		})
+	}
+}
@@ -1,22 +1,49 @@
 package index
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	iofs "io/fs"
+	"maps"
+	"math"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 
-	"github.com/google/generative-ai-go/genai"
 	"github.com/russellhaering/autoswe/pkg/db"
 	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/retry"
 	"go.uber.org/zap"
 )
 
 // QueryResult represents the result of a semantic query with AI analysis
 type QueryResult struct {
-	Answer string `json:"answer"` // The AI-generated answer
+	Answer string        `json:"answer"`           // The AI-generated answer
+	Spans  []CitedSpan   `json:"spans,omitempty"`  // Relevant file spans, when no answer is generated
+	Scores []ResultScore `json:"scores,omitempty"` // Every search result considered, with Config.IncludeScores
+}
+
+// ResultScore is the similarity of a search result to a query, and whether it was kept
+type ResultScore struct {
+	ID           string  `json:"id"`                      // ID of the indexed document
+	Path         string  `json:"path"`                    // Path to the file
+	StartLine    int     `json:"start_line"`              // Starting line number
+	EndLine      int     `json:"end_line"`                // Ending line number
+	Namespace    string  `json:"namespace"`               // The namespace of the file
+	Similarity   float64 `json:"similarity"`              // Similarity to the query
+	KeywordScore float64 `json:"keyword_score,omitempty"` // Keyword match, with Config.KeywordWeight
+	Kept         bool    `json:"kept"`                    // Whether the result survived filtering
+}
+
+// CitedSpan is a range of lines in a file relevant to a query, without the code itself
+type CitedSpan struct {
+	Path      string `json:"path"`       // Path to the file
+	StartLine int    `json:"start_line"` // Starting line number
+	EndLine   int    `json:"end_line"`   // Ending line number
+	Namespace string `json:"namespace"`  // The namespace of the file
+	Reason    string `json:"reason"`     // One-line summary of why the span is relevant
 }
 
 // CodeExample represents a specific code example from the codebase
@@ -28,9 +55,26 @@ type CodeExample struct {
 	Namespace string `json:"namespace"`  // The namespace of the code example
 }
 
+// QueryOptions narrows the results considered by a query
+type QueryOptions struct {
+	// Paths restricts the query to these repository paths, if set. Results from other
+	// namespaces are excluded.
+	Paths []string
+
+	// Namespace restricts the query to one namespace, eg RepoNamespace for code or
+	// ExtraContextNamespace for documentation, if set
+	Namespace string
+}
+
+// noRelevantCodeAnswer is the answer given when a query matches nothing in the codebase
+const noRelevantCodeAnswer = "No relevant code found in the codebase for this query."
+
 const (
 	contextLines   = 5  // Number of context lines to add before and after snippets
 	mergeThreshold = 10 // Maximum number of lines between snippets to trigger merging
+
+	// maxSnippetTokens limits the estimated tokens of the snippets quoted in a prompt
+	maxSnippetTokens = 32000
 )
 
 // snippetRange represents a range of lines in a file
@@ -40,40 +84,148 @@ type snippetRange struct {
 	filePath  string
 	path      string
 	namespace string
+
+	// similarity is that of the best search result the range came from
+	similarity float64
+}
+
+// rankedSnippet is a snippet extracted for a prompt, with the similarity it is ranked by
+type rankedSnippet struct {
+	example    CodeExample
+	tokens     int
+	similarity float64
+}
+
+// FilterMode selects how search results are filtered before building an answer
+type FilterMode string
+
+const (
+	// FilterModeThreshold keeps results above a fixed similarity threshold
+	FilterModeThreshold FilterMode = "threshold"
+	// FilterModeAdaptive cuts results at the largest relative drop in similarity
+	FilterModeAdaptive FilterMode = "adaptive"
+)
+
+// ParseFilterMode parses a filter mode name, returning an error for unknown modes
+func ParseFilterMode(name string) (FilterMode, error) {
+	switch mode := FilterMode(name); mode {
+	case FilterModeThreshold, FilterModeAdaptive:
+		return mode, nil
+	case "":
+		return FilterModeThreshold, nil
+	default:
+		return "", fmt.Errorf("unknown filter mode %q (expected %q or %q)", name, FilterModeThreshold, FilterModeAdaptive)
+	}
+}
+
+// similarityThreshold returns the similarity a result needs to be kept in threshold mode
+func (c Config) similarityThreshold() float64 {
+	if c.SimilarityThreshold < 0 {
+		return math.Inf(-1)
+	}
+	return cmp.Or(c.SimilarityThreshold, DefaultSimilarityThreshold)
+}
+
+// minResults returns the number of results threshold mode tops up to
+func (c Config) minResults() int {
+	return max(cmp.Or(c.MinResults, DefaultMinResults), 0)
+}
+
+// maxResults returns the most results kept
+func (c Config) maxResults() int {
+	return max(cmp.Or(c.MaxResults, DefaultMaxResults), 0)
 }
 
-// filterResults filters search results by similarity and returns up to 10 results
-func filterResults(results []db.SearchResult) []db.SearchResult {
+// filterResults filters search results, which must be sorted by descending similarity,
+// using the configured mode
+func filterResults(results []db.SearchResult, config Config) []db.SearchResult {
+	for _, result := range results {
+		log.Debug("potential query result",
+			zap.String("path", result.Document.ID),
+			zap.Float64("similarity", result.Similarity))
+	}
+
+	var filtered []db.SearchResult
+	if config.FilterMode == FilterModeAdaptive {
+		filtered = filterAdaptive(results)
+	} else {
+		filtered = filterByThreshold(results, config.similarityThreshold(), config.minResults())
+	}
+
+	if len(filtered) > config.maxResults() {
+		filtered = filtered[:config.maxResults()]
+	}
+
+	return filtered
+}
+
+// filterByThreshold keeps results above a fixed similarity threshold, topping up with
+// lower similarity results if there are fewer than minResults
+func filterByThreshold(results []db.SearchResult, threshold float64, minResults int) []db.SearchResult {
 	var goodResults, lowSimilarityResults []db.SearchResult
 	for _, result := range results {
-		if result.Similarity >= 0.4 {
+		if result.Similarity >= threshold {
 			goodResults = append(goodResults, result)
 		} else {
 			lowSimilarityResults = append(lowSimilarityResults, result)
 		}
-
-		log.Debug("potential query result",
-			zap.String("path", result.Document.ID),
-			zap.Float64("similarity", result.Similarity))
 	}
 
 	filtered := goodResults
 
-	// If we have less than 3 results, add lower similarity results
-	if len(filtered) < 3 && len(lowSimilarityResults) > 0 {
-		needed := 3 - len(filtered)
+	// If there are too few results, add lower similarity results
+	if len(filtered) < minResults && len(lowSimilarityResults) > 0 {
+		needed := minResults - len(filtered)
 		if needed > len(lowSimilarityResults) {
 			needed = len(lowSimilarityResults)
 		}
 		filtered = append(filtered, lowSimilarityResults[:needed]...)
 	}
 
-	// Limit to 20 total results
-	if len(filtered) > 20 {
-		filtered = filtered[:20]
+	return filtered
+}
+
+// filterAdaptive keeps the leading cluster of results by cutting at the largest relative
+// drop in similarity between consecutive results
+func filterAdaptive(results []db.SearchResult) []db.SearchResult {
+	if len(results) <= 1 {
+		return results
 	}
 
-	return filtered
+	cut := len(results)
+	largestDrop := 0.0
+	for idx := 1; idx < len(results); idx++ {
+		prev := results[idx-1].Similarity
+		drop := prev - results[idx].Similarity
+		if prev > 0 {
+			drop /= prev
+		}
+
+		if drop > largestDrop {
+			largestDrop = drop
+			cut = idx
+		}
+	}
+
+	return results[:cut]
+}
+
+// belowFloor reports whether the best of the search results, which must be sorted by
+// descending similarity, is too weak to answer a query from
+func (i *Indexer) belowFloor(results []db.SearchResult) bool {
+	floor := i.config.MinSimilarity
+	if floor == 0 {
+		floor = DefaultMinSimilarity
+	}
+
+	if len(results) == 0 || results[0].Similarity >= floor {
+		return false
+	}
+
+	log.Info("best search result is below the similarity floor",
+		zap.Float64("similarity", results[0].Similarity),
+		zap.Float64("floor", floor))
+	return true
 }
 
 // mergeRanges merges overlapping or nearby snippet ranges
@@ -95,6 +247,7 @@ func mergeRanges(ranges []snippetRange) []snippetRange {
 		// If this range is close to the current one, merge them
 		if r.startLine <= current.endLine+mergeThreshold {
 			current.endLine = r.endLine
+			current.similarity = max(current.similarity, r.similarity)
 		} else {
 			mergedRanges = append(mergedRanges, current)
 			current = r
@@ -173,32 +326,30 @@ func buildPrompt(query string, examples []CodeExample) string {
 2. Reproduce relevant snippets verbatim, wrapped in triple-backtick quotes
 3. Do not include any additional text or commentary
 
-If you cannot find any relevant snippets, return "No relevant code found in the codebase for this query."
+If you cannot find any relevant snippets, return "` + noRelevantCodeAnswer + `"
 DO NOT MAKE UP CODE, ONLY RETURN EXACTLY WHAT IS PROVIDED.`)
 
 	return promptBuilder.String()
 }
 
-// generateAnswer uses the Gemini API to generate an answer from the prompt
+// generateAnswer uses the generator to produce an answer from the prompt
 func (i *Indexer) generateAnswer(ctx context.Context, prompt string) (string, error) {
-	model := i.gemini.GenerativeModel("gemini-2.0-flash-lite")
-	model.SetTemperature(0.1) // Lower temperature for more consistent output
+	if i.generate != nil {
+		return i.generate(ctx, prompt)
+	}
 
-	resp, err := model.GenerateContent(ctx, genai.Text(prompt))
+	text, err := retry.Do(ctx, i.config.Retry, func(ctx context.Context) (string, error) {
+		return i.generator.Generate(ctx, GenerateRequest{
+			Model:       i.config.queryModel(),
+			Prompt:      prompt,
+			Temperature: 0.1, // Lower temperature for more consistent output
+		})
+	})
 	if err != nil {
 		return "", fmt.Errorf("failed to generate content: %w", err)
 	}
 
-	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
-		return "", fmt.Errorf("no content generated")
-	}
-
-	text, ok := resp.Candidates[0].Content.Parts[0].(genai.Text)
-	if !ok {
-		return "", fmt.Errorf("unexpected response type")
-	}
-
-	return string(text), nil
+	return text, nil
 }
 
 // collectSnippets processes search results and collects code snippets
@@ -233,25 +384,28 @@ func (i *Indexer) collectSnippets(results []db.SearchResult) ([]CodeExample, err
 		}
 
 		fileRanges[namespace][path] = append(fileRanges[namespace][path], snippetRange{
-			startLine: startLine,
-			endLine:   endLine,
-			filePath:  path,
-			path:      path,
-			namespace: namespace,
+			startLine:  startLine,
+			endLine:    endLine,
+			filePath:   path,
+			path:       path,
+			namespace:  namespace,
+			similarity: result.Similarity,
 		})
 	}
 
-	var examples []CodeExample
-	var totalTokens int
+	var snippets []rankedSnippet
 
-	// Process each file's ranges
-	for namespace, files := range fileRanges {
+	// Extract the snippets from each file's ranges, in a stable order so that snippets with
+	// equal similarity are always ranked the same way
+	for _, namespace := range slices.Sorted(maps.Keys(fileRanges)) {
+		files := fileRanges[namespace]
 		if i.fss[namespace] == nil {
 			log.Warn("namespace not found in fss", zap.String("namespace", namespace))
 			continue
 		}
 
-		for filePath, ranges := range files {
+		for _, filePath := range slices.Sorted(maps.Keys(files)) {
+			ranges := files[filePath]
 			content, err := iofs.ReadFile(i.fss[namespace], filePath)
 			if err != nil {
 				log.Error("failed to read file", zap.Error(err), zap.String("path", filePath))
@@ -262,56 +416,126 @@ func (i *Indexer) collectSnippets(results []db.SearchResult) ([]CodeExample, err
 			// Get merged ranges first
 			mergedRanges := mergeRanges(ranges)
 
-			// Check if we should include the whole file
+			// Include the whole file if the ranges cover most of it, ranked by its best range
 			if shouldIncludeWholeFile(mergedRanges, len(lines)) {
-				// Include the whole file
-				example, tokenEstimate, err := extractSnippet(lines, snippetRange{
-					startLine: 1,
-					endLine:   len(lines),
-					filePath:  filePath,
-					path:      ranges[0].path,      // Use the path from the first range
-					namespace: ranges[0].namespace, // And its namespace
-				})
-				if err == nil && totalTokens+tokenEstimate <= 32000 {
-					examples = append(examples, example)
-					totalTokens += tokenEstimate
+				similarity := mergedRanges[0].similarity
+				for _, r := range mergedRanges[1:] {
+					similarity = max(similarity, r.similarity)
 				}
-			} else {
-				// Process individual ranges
-				for _, r := range mergedRanges {
-					example, tokenEstimate, err := extractSnippet(lines, r)
-					if err != nil {
-						log.Error("failed to extract snippet", zap.Error(err))
-						continue
-					}
-
-					if totalTokens+tokenEstimate > 32000 {
-						log.Info("exceeded max tokens", zap.Int("totalTokens", totalTokens))
-						break
-					}
-					totalTokens += tokenEstimate
-
-					examples = append(examples, example)
+
+				mergedRanges = []snippetRange{{
+					startLine:  1,
+					endLine:    len(lines),
+					filePath:   filePath,
+					path:       ranges[0].path,      // Use the path from the first range
+					namespace:  ranges[0].namespace, // And its namespace
+					similarity: similarity,
+				}}
+			}
+
+			for _, r := range mergedRanges {
+				example, tokenEstimate, err := extractSnippet(lines, r)
+				if err != nil {
+					log.Error("failed to extract snippet", zap.Error(err))
+					continue
 				}
+
+				snippets = append(snippets, rankedSnippet{
+					example:    example,
+					tokens:     tokenEstimate,
+					similarity: r.similarity,
+				})
 			}
 		}
 	}
 
-	return examples, nil
+	return rerankSnippets(snippets, maxSnippetTokens), nil
+}
+
+// rerankSnippets orders snippets by descending similarity, and keeps those that fit in the
+// token budget, so that the most relevant snippets are never crowded out by less relevant
+// ones
+func rerankSnippets(snippets []rankedSnippet, maxTokens int) []CodeExample {
+	sort.SliceStable(snippets, func(a, b int) bool {
+		return snippets[a].similarity > snippets[b].similarity
+	})
+
+	var examples []CodeExample
+	var totalTokens int
+	for _, snippet := range snippets {
+		if totalTokens+snippet.tokens > maxTokens {
+			log.Info("snippet exceeds max tokens",
+				zap.String("path", snippet.example.Path),
+				zap.Int("tokens", snippet.tokens),
+				zap.Int("totalTokens", totalTokens))
+			continue
+		}
+
+		totalTokens += snippet.tokens
+		examples = append(examples, snippet.example)
+	}
+
+	return examples
+}
+
+// queryResultLimit is the number of search results considered by a query
+const queryResultLimit = 30
+
+// searchScoped returns the best search results for a query, restricted to opts.Namespace and
+// opts.Paths if set
+func (i *Indexer) searchScoped(ctx context.Context, query string, opts QueryOptions) ([]db.SearchResult, error) {
+	if len(opts.Paths) == 0 {
+		return i.Search(ctx, query, queryResultLimit, opts.Namespace)
+	}
+
+	// The best results may all be outside the scope, so rank every document and keep the
+	// best ones inside it
+	count, err := i.db.Count()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document count: %w", err)
+	}
+
+	results, err := i.Search(ctx, query, count, opts.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make(map[string]bool, len(opts.Paths))
+	for _, path := range opts.Paths {
+		paths[path] = true
+	}
+
+	var scoped []db.SearchResult
+	for _, result := range results {
+		metadata := result.Document.Metadata
+		if metadata["namespace"] != RepoNamespace || !paths[metadata["path"]] {
+			continue
+		}
+
+		scoped = append(scoped, result)
+		if len(scoped) == queryResultLimit {
+			break
+		}
+	}
+
+	return scoped, nil
 }
 
 // Query performs a semantic search and uses Gemini to analyze the results
-func (i *Indexer) Query(ctx context.Context, query string) (*QueryResult, error) {
+func (i *Indexer) Query(ctx context.Context, query string, opts QueryOptions) (*QueryResult, error) {
 	// Get and filter search results
-	results, err := i.Search(ctx, query, 30)
+	results, err := i.searchScoped(ctx, query, opts)
 	if err != nil {
 		return nil, fmt.Errorf("search failed: %w", err)
 	}
 
-	filteredResults := filterResults(results)
-	if len(filteredResults) == 0 {
+	filteredResults := filterResults(results, i.config)
+	scores := i.resultScores(results, filteredResults)
+	if len(filteredResults) == 0 || i.belowFloor(filteredResults) {
+		i.recordQuery(query, filteredResults, false)
 		return &QueryResult{
-			Answer: "No relevant code found in the codebase for this query.",
+			Answer: noRelevantCodeAnswer,
+			Scores: scores,
 		}, nil
 	}
 
@@ -326,10 +550,159 @@ func (i *Indexer) Query(ctx context.Context, query string) (*QueryResult, error)
 
 	answer, err := i.generateAnswer(ctx, prompt)
 	if err != nil {
+		i.recordQuery(query, filteredResults, false)
 		return nil, fmt.Errorf("failed to generate answer: %w", err)
 	}
 
+	i.recordQuery(query, filteredResults, strings.TrimSpace(answer) != noRelevantCodeAnswer)
+
 	return &QueryResult{
 		Answer: answer,
+		Scores: scores,
 	}, nil
 }
+
+// QuerySpans performs a semantic search and returns the relevant file spans ranked by
+// similarity. Unlike Query, no code is quoted and no answer is generated, so the caller
+// reads the authoritative source itself.
+func (i *Indexer) QuerySpans(ctx context.Context, query string, opts QueryOptions) (*QueryResult, error) {
+	results, err := i.searchScoped(ctx, query, opts)
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+
+	filteredResults := filterResults(results, i.config)
+	scores := i.resultScores(results, filteredResults)
+	if len(filteredResults) == 0 || i.belowFloor(filteredResults) {
+		i.recordQuery(query, filteredResults, false)
+		return &QueryResult{
+			Answer: noRelevantCodeAnswer,
+			Scores: scores,
+		}, nil
+	}
+
+	spans := citeSpans(filteredResults)
+	i.recordQuery(query, filteredResults, len(spans) > 0)
+
+	return &QueryResult{
+		Spans:  spans,
+		Scores: scores,
+	}, nil
+}
+
+// SearchSnippets performs a semantic search and returns the relevant code snippets, ranked by
+// similarity, with their paths and line ranges. Unlike Query, no answer is generated, so the
+// snippets are exactly the indexed code.
+func (i *Indexer) SearchSnippets(ctx context.Context, query string, opts QueryOptions) ([]CodeExample, error) {
+	results, err := i.searchScoped(ctx, query, opts)
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+
+	filteredResults := filterResults(results, i.config)
+	if len(filteredResults) == 0 || i.belowFloor(filteredResults) {
+		i.recordQuery(query, filteredResults, false)
+		return nil, nil
+	}
+
+	examples, err := i.collectSnippets(filteredResults)
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect snippets: %w", err)
+	}
+
+	i.recordQuery(query, filteredResults, len(examples) > 0)
+
+	return examples, nil
+}
+
+// resultScores lists the similarity of each search result, and whether filtering kept it,
+// if the config asks for scores
+func (i *Indexer) resultScores(results, kept []db.SearchResult) []ResultScore {
+	if !i.config.IncludeScores {
+		return nil
+	}
+
+	keptIDs := make(map[string]bool, len(kept))
+	for _, result := range kept {
+		keptIDs[result.Document.ID] = true
+	}
+
+	scores := make([]ResultScore, 0, len(results))
+	for _, result := range results {
+		metadata := result.Document.Metadata
+		startLine, _ := strconv.Atoi(metadata["start_line"])
+		endLine, _ := strconv.Atoi(metadata["end_line"])
+		scores = append(scores, ResultScore{
+			ID:           result.Document.ID,
+			Path:         metadata["path"],
+			StartLine:    startLine,
+			EndLine:      endLine,
+			Namespace:    metadata["namespace"],
+			Similarity:   result.Similarity,
+			KeywordScore: result.KeywordScore,
+			Kept:         keptIDs[result.Document.ID],
+		})
+	}
+
+	return scores
+}
+
+// citeSpans converts search results, sorted by descending similarity, into spans. Results
+// overlapping a higher ranked span in the same file are merged into it.
+func citeSpans(results []db.SearchResult) []CitedSpan {
+	var spans []CitedSpan
+
+	for _, result := range results {
+		startLine, err := strconv.Atoi(result.Document.Metadata["start_line"])
+		if err != nil {
+			log.Error("failed to convert start line to int", zap.Error(err))
+			continue
+		}
+
+		endLine, err := strconv.Atoi(result.Document.Metadata["end_line"])
+		if err != nil {
+			log.Error("failed to convert end line to int", zap.Error(err))
+			continue
+		}
+
+		span := CitedSpan{
+			Path:      result.Document.Metadata["path"],
+			StartLine: startLine,
+			EndLine:   endLine,
+			Namespace: result.Document.Metadata["namespace"],
+			Reason:    firstLine(chunkSummary(result.Document)),
+		}
+
+		merged := false
+		for idx := range spans {
+			existing := &spans[idx]
+			if existing.Namespace != span.Namespace || existing.Path != span.Path {
+				continue
+			}
+
+			if span.StartLine <= existing.EndLine && span.EndLine >= existing.StartLine {
+				existing.StartLine = min(existing.StartLine, span.StartLine)
+				existing.EndLine = max(existing.EndLine, span.EndLine)
+				merged = true
+				break
+			}
+		}
+
+		if !merged {
+			spans = append(spans, span)
+		}
+	}
+
+	return spans
+}
+
+// firstLine returns the first non-empty line of s
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+
+	return ""
+}
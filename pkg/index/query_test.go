@@ -1,8 +1,18 @@
 package index
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/db"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
 )
 
 func TestMergeRanges(t *testing.T) {
@@ -102,3 +112,526 @@ func TestMergeRanges(t *testing.T) {
 		})
 	}
 }
+
+func TestMergeRangesSimilarity(t *testing.T) {
+	got := mergeRanges([]snippetRange{
+		{startLine: 10, endLine: 20, similarity: 0.4},
+		{startLine: 25, endLine: 35, similarity: 0.9},
+		{startLine: 60, endLine: 70, similarity: 0.5},
+	})
+
+	expected := []snippetRange{
+		{startLine: 10, endLine: 35, similarity: 0.9},
+		{startLine: 60, endLine: 70, similarity: 0.5},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("mergeRanges() = %v, want %v", got, expected)
+	}
+}
+
+func TestRerankSnippets(t *testing.T) {
+	if err := log.Init(true); err != nil {
+		t.Fatalf("failed to initialize logger: %v", err)
+	}
+
+	snippets := []rankedSnippet{
+		{example: CodeExample{Path: "weak.go"}, tokens: 100, similarity: 0.3},
+		{example: CodeExample{Path: "large.go"}, tokens: 900, similarity: 0.6},
+		{example: CodeExample{Path: "best.go"}, tokens: 500, similarity: 0.9},
+		{example: CodeExample{Path: "good.go"}, tokens: 300, similarity: 0.7},
+	}
+
+	// The best snippets are kept first, and a snippet too large for the remaining budget
+	// doesn't stop smaller ones after it
+	var paths []string
+	for _, example := range rerankSnippets(snippets, 1000) {
+		paths = append(paths, example.Path)
+	}
+
+	expected := []string{"best.go", "good.go", "weak.go"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("rerankSnippets() = %v, want %v", paths, expected)
+	}
+}
+
+func TestCollectSnippetsDeterministic(t *testing.T) {
+	if err := log.Init(true); err != nil {
+		t.Fatalf("failed to initialize logger: %v", err)
+	}
+
+	rootDir := t.TempDir()
+	paths := []string{"e.go", "c.go", "a.go", "d.go", "b.go"}
+	for _, path := range paths {
+		if err := os.WriteFile(filepath.Join(rootDir, path), []byte(strings.Repeat("// code\n", 100)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every chunk is equally similar to the query, so only their order breaks ties
+	docDB, err := db.NewDocumentDB(filepath.Join(t.TempDir(), "db"), func(_ context.Context, _ string) ([]float32, error) {
+		return []float32{1, 0}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer docDB.Close()
+
+	for _, path := range paths {
+		for idx, start := range []int{60, 10} {
+			if err := docDB.AddDocument(context.Background(), chunkEntry(path, idx, start, start+5, "Code")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	indexer := &Indexer{
+		fss: FSContextMap{RepoNamespace: filteredFS},
+		db:  docDB,
+	}
+
+	query := func() []CodeExample {
+		results, err := indexer.Search(context.Background(), "code", queryResultLimit, "")
+		if err != nil {
+			t.Fatalf("Search() error = %v", err)
+		}
+		examples, err := indexer.collectSnippets(results)
+		if err != nil {
+			t.Fatalf("collectSnippets() error = %v", err)
+		}
+		return examples
+	}
+
+	first := query()
+	if len(first) != 2*len(paths) {
+		t.Fatalf("collectSnippets() returned %d snippets, want %d", len(first), 2*len(paths))
+	}
+	for attempt := 0; attempt < 10; attempt++ {
+		if got := query(); !reflect.DeepEqual(got, first) {
+			t.Fatalf("collectSnippets() differed between runs: %+v, want %+v", got, first)
+		}
+	}
+
+	// Ties are ordered by path, then line
+	var locations []string
+	for _, example := range first[:4] {
+		locations = append(locations, fmt.Sprintf("%s:%d", example.Path, example.StartLine))
+	}
+	expected := []string{"a.go:5", "a.go:55", "b.go:5", "b.go:55"}
+	if !reflect.DeepEqual(locations, expected) {
+		t.Errorf("collectSnippets() order = %v, want %v", locations, expected)
+	}
+}
+
+func TestFilterResultsAdaptive(t *testing.T) {
+	if err := log.Init(true); err != nil {
+		t.Fatalf("Failed to initialize logger: %v", err)
+	}
+
+	results := searchResults(0.82, 0.80, 0.78, 0.45, 0.43, 0.40)
+
+	got := filterResults(results, Config{FilterMode: FilterModeAdaptive})
+	if !reflect.DeepEqual(got, results[:3]) {
+		t.Errorf("filterResults() kept %v, want the high cluster %v", similarities(got), similarities(results[:3]))
+	}
+
+	// The fixed threshold keeps everything since all scores are above 0.4
+	got = filterResults(results, Config{FilterMode: FilterModeThreshold})
+	if len(got) != len(results) {
+		t.Errorf("filterResults() in threshold mode kept %d results, want %d", len(got), len(results))
+	}
+}
+
+func TestFilterResultsThreshold(t *testing.T) {
+	if err := log.Init(true); err != nil {
+		t.Fatalf("Failed to initialize logger: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		similarities []float64
+		config       Config
+		want         []float64
+	}{
+		{
+			name:         "keeps results above the default threshold",
+			similarities: []float64{0.9, 0.7, 0.5, 0.41, 0.3},
+			want:         []float64{0.9, 0.7, 0.5, 0.41},
+		},
+		{
+			name:         "tops up to the minimum with weaker results",
+			similarities: []float64{0.6, 0.3, 0.2, 0.1},
+			want:         []float64{0.6, 0.3, 0.2},
+		},
+		{
+			name:         "tops up with whatever there is",
+			similarities: []float64{0.2, 0.1},
+			want:         []float64{0.2, 0.1},
+		},
+		{
+			name:         "configured threshold and minimum",
+			similarities: []float64{0.9, 0.7, 0.5, 0.3},
+			config:       Config{SimilarityThreshold: 0.8, MinResults: 2},
+			want:         []float64{0.9, 0.7},
+		},
+		{
+			name:         "top-up disabled",
+			similarities: []float64{0.6, 0.3, 0.2},
+			config:       Config{MinResults: -1},
+			want:         []float64{0.6},
+		},
+		{
+			name:         "negative threshold keeps everything",
+			similarities: []float64{0.3, 0.1, -0.2, -0.6},
+			config:       Config{SimilarityThreshold: -1},
+			want:         []float64{0.3, 0.1, -0.2, -0.6},
+		},
+		{
+			name:         "capped at the default maximum",
+			similarities: repeatSimilarity(0.9, 25),
+			want:         repeatSimilarity(0.9, DefaultMaxResults),
+		},
+		{
+			name:         "capped at the configured maximum, in either mode",
+			similarities: []float64{0.9, 0.89, 0.88, 0.87},
+			config:       Config{FilterMode: FilterModeAdaptive, MaxResults: 2},
+			want:         []float64{0.9, 0.89},
+		},
+		{
+			name:         "negative maximum keeps nothing",
+			similarities: []float64{0.9, 0.7},
+			config:       Config{MaxResults: -1},
+			want:         []float64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := similarities(filterResults(searchResults(tt.similarities...), tt.config))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filterResults() kept %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// repeatSimilarity returns n copies of the similarity
+func repeatSimilarity(similarity float64, n int) []float64 {
+	similarities := make([]float64, n)
+	for idx := range similarities {
+		similarities[idx] = similarity
+	}
+	return similarities
+}
+
+// searchResults builds search results with the given similarities
+func searchResults(similarities ...float64) []db.SearchResult {
+	results := make([]db.SearchResult, 0, len(similarities))
+	for idx, similarity := range similarities {
+		results = append(results, db.SearchResult{
+			Document:   db.Document{ID: ComputeID(RepoNamespace, "file.go", idx)},
+			Similarity: similarity,
+		})
+	}
+	return results
+}
+
+// similarities extracts the similarity scores from search results
+func similarities(results []db.SearchResult) []float64 {
+	scores := make([]float64, 0, len(results))
+	for _, result := range results {
+		scores = append(scores, result.Similarity)
+	}
+	return scores
+}
+
+func TestQuerySpans(t *testing.T) {
+	if err := log.Init(true); err != nil {
+		t.Fatalf("failed to initialize logger: %v", err)
+	}
+
+	rootDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(rootDir, "auth.go"), []byte(strings.Repeat("// auth\n", 25)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(rootDir, "db.go"), []byte(strings.Repeat("// db\n", 25)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	docDB, err := db.NewDocumentDB(filepath.Join(t.TempDir(), "db"), func(_ context.Context, content string) ([]float32, error) {
+		switch {
+		case strings.Contains(content, "sessions"):
+			return []float32{0.9, 0.1}, nil
+		case strings.Contains(content, "auth"):
+			return []float32{1, 0}, nil
+		default:
+			return []float32{0, 1}, nil
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer docDB.Close()
+
+	for _, doc := range []db.Document{
+		chunkEntry("auth.go", 0, 1, 10, "Validates auth tokens\nChecks the signature and expiry"),
+		chunkEntry("auth.go", 1, 8, 20, "Refreshes auth sessions"),
+		chunkEntry("db.go", 0, 1, 5, "Opens the database"),
+	} {
+		if err := docDB.AddDocument(context.Background(), doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	generateCalls := 0
+	indexer := &Indexer{
+		fss: FSContextMap{RepoNamespace: filteredFS},
+		db:  docDB,
+		generate: func(_ context.Context, _ string) (string, error) {
+			generateCalls++
+			return "answer", nil
+		},
+	}
+
+	result, err := indexer.QuerySpans(context.Background(), "auth", QueryOptions{})
+	if err != nil {
+		t.Fatalf("QuerySpans() error = %v", err)
+	}
+
+	expected := []CitedSpan{
+		{Path: "auth.go", StartLine: 1, EndLine: 20, Namespace: RepoNamespace, Reason: "Validates auth tokens"},
+		{Path: "db.go", StartLine: 1, EndLine: 5, Namespace: RepoNamespace, Reason: "Opens the database"},
+	}
+	if !reflect.DeepEqual(result.Spans, expected) {
+		t.Errorf("QuerySpans() spans = %+v, want %+v", result.Spans, expected)
+	}
+	if result.Answer != "" {
+		t.Errorf("QuerySpans() answer = %q, want empty", result.Answer)
+	}
+	if generateCalls != 0 {
+		t.Errorf("QuerySpans() made %d generate calls, want 0", generateCalls)
+	}
+
+	// For comparison, Query generates an answer quoting the snippets
+	if _, err := indexer.Query(context.Background(), "auth", QueryOptions{}); err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if generateCalls != 1 {
+		t.Errorf("Query() made %d generate calls, want 1", generateCalls)
+	}
+
+	// SearchSnippets quotes the same snippets without generating an answer
+	examples, err := indexer.SearchSnippets(context.Background(), "auth", QueryOptions{})
+	if err != nil {
+		t.Fatalf("SearchSnippets() error = %v", err)
+	}
+	if len(examples) != 2 || examples[0].Path != "auth.go" || examples[0].StartLine != 1 || examples[0].EndLine != 26 {
+		t.Errorf("SearchSnippets() = %+v, want the whole of auth.go first", examples)
+	}
+	if !strings.HasPrefix(examples[0].Content, "// auth\n") {
+		t.Errorf("SearchSnippets() content = %q, want the code of auth.go", examples[0].Content)
+	}
+	if generateCalls != 1 {
+		t.Errorf("SearchSnippets() made %d generate calls, want 0", generateCalls-1)
+	}
+
+	// Scores show every result considered, including those filtered out
+	indexer.config = Config{IncludeScores: true, SimilarityThreshold: 0.5, MinResults: -1}
+	result, err = indexer.QuerySpans(context.Background(), "auth", QueryOptions{})
+	if err != nil {
+		t.Fatalf("QuerySpans() error = %v", err)
+	}
+
+	var kept []string
+	for _, score := range result.Scores {
+		kept = append(kept, fmt.Sprintf("%s:%d %.1f %t", score.Path, score.StartLine, score.Similarity, score.Kept))
+	}
+	expectedScores := []string{"auth.go:1 1.0 true", "auth.go:8 1.0 true", "db.go:1 0.0 false"}
+	if !reflect.DeepEqual(kept, expectedScores) {
+		t.Errorf("QuerySpans() scores = %v, want %v", kept, expectedScores)
+	}
+	if id := result.Scores[0].ID; id != ComputeID(RepoNamespace, "auth.go", 0) {
+		t.Errorf("QuerySpans() score ID = %q, want the document's ID", id)
+	}
+}
+
+func chunkEntry(path string, idx, startLine, endLine int, summary string) db.Document {
+	return db.Document{
+		ID:      ComputeID(RepoNamespace, path, idx),
+		Content: summary,
+		Metadata: map[string]string{
+			"path":          path,
+			"start_line":    strconv.Itoa(startLine),
+			"end_line":      strconv.Itoa(endLine),
+			"is_file_entry": "false",
+			"namespace":     RepoNamespace,
+		},
+	}
+}
+
+func TestQueryBelowSimilarityFloor(t *testing.T) {
+	if err := log.Init(true); err != nil {
+		t.Fatalf("failed to initialize logger: %v", err)
+	}
+
+	rootDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(rootDir, "db.go"), []byte(strings.Repeat("// db\n", 25)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The query is nearly orthogonal to every chunk
+	docDB, err := db.NewDocumentDB(filepath.Join(t.TempDir(), "db"), func(_ context.Context, content string) ([]float32, error) {
+		if strings.Contains(content, "weather") {
+			return []float32{1, 0.1}, nil
+		}
+		return []float32{0, 1}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer docDB.Close()
+
+	for _, doc := range []db.Document{
+		chunkEntry("db.go", 0, 1, 5, "Opens the database"),
+		chunkEntry("db.go", 1, 10, 15, "Closes the database"),
+	} {
+		if err := docDB.AddDocument(context.Background(), doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	generateCalls := 0
+	indexer := &Indexer{
+		fss: FSContextMap{RepoNamespace: filteredFS},
+		db:  docDB,
+		generate: func(_ context.Context, _ string) (string, error) {
+			generateCalls++
+			return "a baseless answer", nil
+		},
+	}
+
+	result, err := indexer.Query(context.Background(), "weather", QueryOptions{})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if result.Answer != noRelevantCodeAnswer {
+		t.Errorf("Query() answer = %q, want %q", result.Answer, noRelevantCodeAnswer)
+	}
+	if generateCalls != 0 {
+		t.Errorf("Query() made %d generate calls, want 0", generateCalls)
+	}
+
+	// Disabling the floor answers from the weak matches
+	indexer.config.MinSimilarity = -1
+	if _, err := indexer.Query(context.Background(), "weather", QueryOptions{}); err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if generateCalls != 1 {
+		t.Errorf("Query() made %d generate calls with the floor disabled, want 1", generateCalls)
+	}
+}
+
+func TestQuerySpansScopedToPaths(t *testing.T) {
+	if err := log.Init(true); err != nil {
+		t.Fatalf("failed to initialize logger: %v", err)
+	}
+
+	docDB, err := db.NewDocumentDB(filepath.Join(t.TempDir(), "db"), func(_ context.Context, content string) ([]float32, error) {
+		if strings.Contains(content, "auth") {
+			return []float32{1, 0}, nil
+		}
+		return []float32{0.8, 0.2}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer docDB.Close()
+
+	for _, doc := range []db.Document{
+		chunkEntry("auth.go", 0, 1, 10, "Validates auth tokens"),
+		chunkEntry("session.go", 0, 1, 5, "Stores sessions"),
+	} {
+		if err := docDB.AddDocument(context.Background(), doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	indexer := &Indexer{db: docDB}
+
+	result, err := indexer.QuerySpans(context.Background(), "auth", QueryOptions{Paths: []string{"session.go"}})
+	if err != nil {
+		t.Fatalf("QuerySpans() error = %v", err)
+	}
+
+	expected := []CitedSpan{
+		{Path: "session.go", StartLine: 1, EndLine: 5, Namespace: RepoNamespace, Reason: "Stores sessions"},
+	}
+	if !reflect.DeepEqual(result.Spans, expected) {
+		t.Errorf("QuerySpans() spans = %+v, want %+v", result.Spans, expected)
+	}
+}
+
+func TestSearchKeywordWeight(t *testing.T) {
+	// The embedding model favors prose about IDs over the code defining ComputeID
+	docDB, err := db.NewDocumentDB(filepath.Join(t.TempDir(), "db"), func(_ context.Context, content string) ([]float32, error) {
+		switch {
+		case content == "ComputeID":
+			return []float32{1, 0}, nil
+		case strings.Contains(content, "func ComputeID"):
+			return []float32{0.6, 0.8}, nil
+		case strings.Contains(content, "IDs"):
+			return []float32{0.9, 0.44}, nil
+		default:
+			return []float32{0.8, 0.6}, nil
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer docDB.Close()
+
+	for _, doc := range []db.Document{
+		chunkEntry("ids.go", 0, 1, 10, "Explains how document IDs are structured"),
+		chunkEntry("hash.go", 0, 1, 10, "Computes the hash of file content"),
+		chunkEntry("index.go", 0, 1, 10, "func ComputeID(namespace string, path string, idx int) string"),
+	} {
+		if err := docDB.AddDocument(context.Background(), doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		keywordWeight float64
+		want          string
+	}{
+		{0, "ids.go"},
+		{0.5, "index.go"},
+	} {
+		indexer := &Indexer{db: docDB, config: Config{KeywordWeight: tt.keywordWeight}}
+
+		results, err := indexer.Search(context.Background(), "ComputeID", 2, "")
+		if err != nil {
+			t.Fatalf("Search() error = %v", err)
+		}
+		if len(results) != 2 {
+			t.Fatalf("Search() returned %d results, want 2", len(results))
+		}
+		if got := results[0].Document.Metadata["path"]; got != tt.want {
+			t.Errorf("Search() with keyword weight %v ranked %s first, want %s", tt.keywordWeight, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package index
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/russellhaering/autoswe/pkg/db"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/retry"
+	"go.uber.org/zap"
+)
+
+// Backend selects where the index is stored
+type Backend string
+
+const (
+	// BackendBolt stores the index in a bbolt database under StoragePath
+	BackendBolt Backend = "bolt"
+	// BackendMemory keeps the index in memory, so it is rebuilt on every run
+	BackendMemory Backend = "memory"
+	// BackendQdrant stores the index in a Qdrant collection. It is only available in builds
+	// with the qdrant build tag.
+	BackendQdrant Backend = "qdrant"
+)
+
+// openQdrant opens a Qdrant document store, and is only set in builds with the qdrant build tag
+var openQdrant func(config Config, embed db.EmbeddingFunc) (db.DocumentStore, error)
+
+// ParseBackend parses an index backend name, returning an error for unknown backends
+func ParseBackend(name string) (Backend, error) {
+	switch backend := Backend(name); backend {
+	case BackendBolt, BackendMemory, BackendQdrant:
+		return backend, nil
+	case "":
+		return BackendBolt, nil
+	default:
+		return "", fmt.Errorf("unknown index backend %q (expected %q, %q or %q)", name, BackendBolt, BackendMemory, BackendQdrant)
+	}
+}
+
+// NewEmbeddingFunc adapts an embedder to the store's embedding function, retrying
+// transient errors until the context is cancelled
+func NewEmbeddingFunc(embedder Embedder, retryConfig retry.Config) db.EmbeddingFunc {
+	return func(ctx context.Context, content string) ([]float32, error) {
+		embedding, err := retry.Do(ctx, retryConfig, func(ctx context.Context) ([]float32, error) {
+			return embedder.Embed(ctx, content)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed text: %w", err)
+		}
+
+		return embedding, nil
+	}
+}
+
+// OpenStore opens the document store selected by the config. If the embedding cache is
+// enabled, it is opened along with the store and closed when the store is closed.
+func OpenStore(config Config, embed db.EmbeddingFunc) (db.DocumentStore, error) {
+	if !config.EmbeddingCache {
+		return openStore(config, embed)
+	}
+
+	if err := os.MkdirAll(StoragePath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	cache, err := db.NewEmbeddingCache(filepath.Join(StoragePath, EmbeddingCacheFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open embedding cache: %w", err)
+	}
+
+	embed = cache.Wrap(embed)
+	store, err := openStore(config, embed)
+	if err != nil {
+		cache.Close()
+		return nil, err
+	}
+
+	return &cachingStore{
+		DocumentStore: store,
+		cache:         cache,
+		embed:         embed,
+	}, nil
+}
+
+// cachingStore is a document store whose embeddings are cached
+type cachingStore struct {
+	db.DocumentStore
+	cache *db.EmbeddingCache
+
+	// embed is the store's embedding function, which reads through the cache
+	embed db.EmbeddingFunc
+}
+
+// Close closes both the store and the cache
+func (s *cachingStore) Close() error {
+	return errors.Join(s.DocumentStore.Close(), s.cache.Close())
+}
+
+// openStore opens the document store selected by the config
+func openStore(config Config, embed db.EmbeddingFunc) (db.DocumentStore, error) {
+	switch config.Backend {
+	case BackendMemory:
+		return db.NewMemoryDB(embed), nil
+	case BackendQdrant:
+		if openQdrant == nil {
+			return nil, fmt.Errorf("index backend %q is not available, rebuild autoswe with -tags qdrant", BackendQdrant)
+		}
+
+		return openQdrant(config, embed)
+	case BackendBolt, "":
+		// Create storage directory if it doesn't exist
+		if err := os.MkdirAll(StoragePath, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create storage directory: %w", err)
+		}
+
+		docDB, err := db.NewDocumentDB(filepath.Join(StoragePath, DBFileName), embed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create document database: %w", err)
+		}
+
+		return docDB, nil
+	default:
+		return nil, fmt.Errorf("unknown index backend %q", config.Backend)
+	}
+}
+
+// Compact compacts the underlying store, if it can be compacted
+func (s *cachingStore) Compact() (*db.CompactResult, error) {
+	compacter, ok := s.DocumentStore.(db.Compacter)
+	if !ok {
+		return nil, errCompactUnsupported
+	}
+
+	return compacter.Compact()
+}
+
+// errCompactUnsupported is returned when compacting a store that can't be compacted
+var errCompactUnsupported = errors.New("index backend can't be compacted")
+
+// Compact reclaims the space left in the index by documents deleted as files were reindexed
+// or removed. Only the bolt backend can be compacted.
+func (i *Indexer) Compact(_ context.Context) (*db.CompactResult, error) {
+	compacter, ok := i.db.(db.Compacter)
+	if !ok {
+		return nil, fmt.Errorf("the %s %w", cmp.Or(i.config.Backend, BackendBolt), errCompactUnsupported)
+	}
+
+	result, err := compacter.Compact()
+	if errors.Is(err, errCompactUnsupported) {
+		return nil, fmt.Errorf("the %s %w", cmp.Or(i.config.Backend, BackendBolt), err)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to compact index: %w", err)
+	}
+
+	log.Info("Compacted index", zap.Int64("size_before", result.SizeBefore), zap.Int64("size_after", result.SizeAfter))
+	return result, nil
+}
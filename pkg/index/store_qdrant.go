@@ -0,0 +1,30 @@
+//go:build qdrant
+
+package index
+
+import (
+	"fmt"
+
+	"github.com/russellhaering/autoswe/pkg/db"
+)
+
+func init() {
+	openQdrant = func(config Config, embed db.EmbeddingFunc) (db.DocumentStore, error) {
+		url := config.QdrantURL
+		if url == "" {
+			url = DefaultQdrantURL
+		}
+
+		collection := config.QdrantCollection
+		if collection == "" {
+			collection = DefaultQdrantCollection
+		}
+
+		store, err := db.NewQdrantDB(url, collection, embed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open qdrant index: %w", err)
+		}
+
+		return store, nil
+	}
+}
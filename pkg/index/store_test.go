@@ -0,0 +1,292 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/db"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBackend(t *testing.T) {
+	backend, err := ParseBackend("")
+	require.NoError(t, err)
+	assert.Equal(t, BackendBolt, backend)
+
+	backend, err = ParseBackend("memory")
+	require.NoError(t, err)
+	assert.Equal(t, BackendMemory, backend)
+
+	_, err = ParseBackend("postgres")
+	assert.Error(t, err)
+}
+
+func TestCompactUnsupported(t *testing.T) {
+	store, err := OpenStore(Config{Backend: BackendMemory}, bagOfWords)
+	require.NoError(t, err)
+
+	indexer := NewIndexer(nil, store, FSContextMap{}, Config{Backend: BackendMemory})
+	defer indexer.Close()
+
+	_, err = indexer.Compact(context.Background())
+	assert.ErrorContains(t, err, "the memory index backend can't be compacted")
+}
+
+func TestMemoryStoreIndexAndQuery(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "config.go"), []byte("package config\n\nfunc Load() {}\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "server.go"), []byte("package server\n\nfunc Serve() {}\n"), 0644))
+
+	// Run from an empty working directory with an empty temp directory, so that anything
+	// written to StoragePath or os.TempDir would be noticed
+	workDir := t.TempDir()
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	oldWd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(workDir))
+	defer func() {
+		require.NoError(t, os.Chdir(oldWd))
+	}()
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	require.NoError(t, err)
+
+	store, err := OpenStore(Config{Backend: BackendMemory}, bagOfWords)
+	require.NoError(t, err)
+
+	indexer := NewIndexer(nil, store, FSContextMap{RepoNamespace: filteredFS}, Config{Backend: BackendMemory})
+	defer indexer.Close()
+
+	indexer.summarize = func(_ context.Context, content []byte) ([]ContentSummary, error) {
+		summary := "Starts the HTTP server"
+		if strings.Contains(string(content), "package config") {
+			summary = "Loads the configuration"
+		}
+		return []ContentSummary{{Summary: summary, ContentSpan: ContentSpan{StartLine: 1, EndLine: 3}}}, nil
+	}
+
+	var prompts []string
+	indexer.generate = func(_ context.Context, prompt string) (string, error) {
+		prompts = append(prompts, prompt)
+		return "Configuration is loaded by Load in config.go", nil
+	}
+
+	require.NoError(t, indexer.UpdateIndex(context.Background()))
+
+	files, err := indexer.GetIndexedFiles(context.Background())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []FileRef{
+		{Namespace: RepoNamespace, Path: "config.go"},
+		{Namespace: RepoNamespace, Path: "server.go"},
+	}, files)
+
+	spans, err := indexer.QuerySpans(context.Background(), "load the configuration", QueryOptions{})
+	require.NoError(t, err)
+	require.NotEmpty(t, spans.Spans)
+	assert.Equal(t, "config.go", spans.Spans[0].Path)
+
+	result, err := indexer.Query(context.Background(), "load the configuration", QueryOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "Configuration is loaded by Load in config.go", result.Answer)
+	require.Len(t, prompts, 1)
+	assert.Contains(t, prompts[0], "func Load()")
+
+	for _, dir := range []string{workDir, tempDir} {
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, entries, "no files should be written to %s", dir)
+	}
+
+	entries, err := os.ReadDir(rootDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2, "no files should be written to the repository")
+}
+
+// fakeStore is a DocumentStore that records every call made to it. Query ranks documents by ID
+// rather than similarity.
+type fakeStore struct {
+	docs  map[string]db.Document
+	calls []string
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{docs: make(map[string]db.Document)}
+}
+
+func (s *fakeStore) record(format string, args ...interface{}) {
+	s.calls = append(s.calls, fmt.Sprintf(format, args...))
+}
+
+func (s *fakeStore) AddDocument(_ context.Context, doc db.Document) error {
+	s.record("AddDocument(%s)", doc.ID)
+	s.docs[doc.ID] = doc
+	return nil
+}
+
+func (s *fakeStore) BatchAddDocuments(_ context.Context, docs []db.Document) error {
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.ID)
+		s.docs[doc.ID] = doc
+	}
+	s.record("BatchAddDocuments(%s)", strings.Join(ids, ", "))
+	return nil
+}
+
+func (s *fakeStore) GetDocument(id string) (db.Document, error) {
+	s.record("GetDocument(%s)", id)
+	doc, ok := s.docs[id]
+	if !ok {
+		return db.Document{}, db.ErrNotFound
+	}
+	return doc, nil
+}
+
+func (s *fakeStore) DeleteDocumentsWithPrefix(prefix string) error {
+	s.record("DeleteDocumentsWithPrefix(%s)", prefix)
+	for id := range s.docs {
+		if strings.HasPrefix(id, prefix) {
+			delete(s.docs, id)
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) FilterDocuments(filters map[string]string) ([]db.Document, error) {
+	s.record("FilterDocuments(%v)", filters)
+	return s.filter(filters), nil
+}
+
+func (s *fakeStore) Query(_ context.Context, queryContent string, limit int, filters map[string]string) ([]db.SearchResult, error) {
+	s.record("Query(%s, %d, %v)", queryContent, limit, filters)
+
+	var results []db.SearchResult
+	for idx, doc := range s.filter(filters) {
+		if idx == limit {
+			break
+		}
+		results = append(results, db.SearchResult{Document: doc, Similarity: 0.9})
+	}
+	return results, nil
+}
+
+func (s *fakeStore) Count() (int, error) {
+	s.record("Count()")
+	return len(s.docs), nil
+}
+
+func (s *fakeStore) CountDocuments(filters map[string]string) (int, error) {
+	s.record("CountDocuments(%v)", filters)
+	return len(s.filter(filters)), nil
+}
+
+func (s *fakeStore) Close() error {
+	s.record("Close()")
+	return nil
+}
+
+func (s *fakeStore) filter(filters map[string]string) []db.Document {
+	var docs []db.Document
+	for _, doc := range s.docs {
+		matches := true
+		for k, v := range filters {
+			if doc.Metadata[k] != v {
+				matches = false
+			}
+		}
+		if matches {
+			docs = append(docs, doc)
+		}
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		return docs[i].ID < docs[j].ID
+	})
+	return docs
+}
+
+func (s *fakeStore) takeCalls() []string {
+	calls := s.calls
+	s.calls = nil
+	return calls
+}
+
+func TestIndexerDrivesStore(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644))
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	require.NoError(t, err)
+
+	store := newFakeStore()
+	indexer := NewIndexer(nil, store, FSContextMap{RepoNamespace: filteredFS}, Config{})
+	indexer.summarize = func(_ context.Context, _ []byte) ([]ContentSummary, error) {
+		return []ContentSummary{
+			{Summary: "Declares the main package", ContentSpan: ContentSpan{StartLine: 1, EndLine: 1}},
+			{Summary: "Defines the entry point", ContentSpan: ContentSpan{StartLine: 3, EndLine: 3}},
+		}, nil
+	}
+	indexer.generate = func(_ context.Context, _ string) (string, error) {
+		return "main is the entry point", nil
+	}
+
+	fileFilter := map[string]string{"is_file_entry": "true"}
+
+	// Indexing a new file replaces its entries with a file entry followed by its chunks, once
+	// the update has been planned
+	require.NoError(t, indexer.UpdateIndex(context.Background()))
+	assert.Equal(t, []string{
+		"GetDocument(repo:main.go)",
+		fmt.Sprintf("FilterDocuments(%v)", fileFilter),
+		"DeleteDocumentsWithPrefix(repo:main.go)",
+		"AddDocument(repo:main.go)",
+		"BatchAddDocuments(repo:main.go#0, repo:main.go#1)",
+	}, store.takeCalls())
+
+	chunk := store.docs[ComputeID(RepoNamespace, "main.go", 1)]
+	assert.Equal(t, "Defines the entry point", chunk.Content)
+	assert.Equal(t, "3", chunk.Metadata["start_line"])
+	assert.Equal(t, "false", chunk.Metadata["is_file_entry"])
+	assert.Equal(t, RepoNamespace, chunk.Metadata["namespace"])
+
+	// An unchanged file is not touched
+	require.NoError(t, indexer.UpdateIndex(context.Background()))
+	assert.Equal(t, []string{
+		"GetDocument(repo:main.go)",
+		fmt.Sprintf("FilterDocuments(%v)", fileFilter),
+	}, store.takeCalls())
+
+	// Queries search the chunks, capped at the number of documents
+	result, err := indexer.Query(context.Background(), "where is the entry point", QueryOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "main is the entry point", result.Answer)
+	assert.Equal(t, []string{
+		"Count()",
+		fmt.Sprintf("Query(where is the entry point, 3, %v)", map[string]string{"is_file_entry": "false"}),
+	}, store.takeCalls())
+
+	// Deleted files have their entries removed
+	require.NoError(t, os.Remove(filepath.Join(rootDir, "main.go")))
+	require.NoError(t, indexer.UpdateIndex(context.Background()))
+	assert.Equal(t, []string{
+		fmt.Sprintf("FilterDocuments(%v)", fileFilter),
+		"DeleteDocumentsWithPrefix(repo:main.go)",
+	}, store.takeCalls())
+	assert.Empty(t, store.docs)
+
+	require.NoError(t, indexer.Close())
+	assert.Equal(t, []string{"Close()"}, store.takeCalls())
+}
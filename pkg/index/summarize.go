@@ -0,0 +1,97 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	iofs "io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SectionSummary is a summary of a range of lines in a file
+type SectionSummary struct {
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	Summary   string `json:"summary"`
+}
+
+// FileSummary is a natural language summary of a file, made up of summaries of its sections
+type FileSummary struct {
+	Path     string           `json:"path"`
+	Indexed  bool             `json:"indexed"`
+	Sections []SectionSummary `json:"sections"`
+}
+
+// String concatenates the section summaries, one per line
+func (s *FileSummary) String() string {
+	var sb strings.Builder
+	for _, section := range s.Sections {
+		fmt.Fprintf(&sb, "Lines %d-%d: %s\n", section.StartLine, section.EndLine, section.Summary)
+	}
+	return sb.String()
+}
+
+// SummarizeFile returns the stored summaries for a file in the repository. If the file hasn't
+// been indexed, fresh summaries are generated but not stored.
+func (i *Indexer) SummarizeFile(ctx context.Context, path string) (*FileSummary, error) {
+	fsys, ok := i.fss[RepoNamespace]
+	if !ok {
+		return nil, fmt.Errorf("unknown namespace: %s", RepoNamespace)
+	}
+
+	docs, err := i.db.FilterDocuments(map[string]string{
+		"namespace":     RepoNamespace,
+		"path":          path,
+		"is_file_entry": "false",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stored summaries: %w", err)
+	}
+
+	if len(docs) > 0 {
+		summary := &FileSummary{
+			Path:    path,
+			Indexed: true,
+		}
+
+		for _, doc := range docs {
+			startLine, _ := strconv.Atoi(doc.Metadata["start_line"])
+			endLine, _ := strconv.Atoi(doc.Metadata["end_line"])
+			summary.Sections = append(summary.Sections, SectionSummary{
+				StartLine: startLine,
+				EndLine:   endLine,
+				Summary:   chunkSummary(doc),
+			})
+		}
+
+		sort.SliceStable(summary.Sections, func(a, b int) bool {
+			return summary.Sections[a].StartLine < summary.Sections[b].StartLine
+		})
+
+		return summary, nil
+	}
+
+	content, err := iofs.ReadFile(fsys, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	summaries, err := i.extractSummaries(ctx, path, content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize %s: %w", path, err)
+	}
+
+	summary := &FileSummary{
+		Path: path,
+	}
+	for _, s := range summaries {
+		summary.Sections = append(summary.Sections, SectionSummary{
+			StartLine: s.ContentSpan.StartLine,
+			EndLine:   s.ContentSpan.EndLine,
+			Summary:   s.Summary,
+		})
+	}
+
+	return summary, nil
+}
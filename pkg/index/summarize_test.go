@@ -0,0 +1,107 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/db"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarizeFile(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "indexed.go"), []byte("package indexed\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "new.go"), []byte("package new\n\nfunc New() {}\n"), 0644))
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	require.NoError(t, err)
+
+	store := db.NewMemoryDB(bagOfWords)
+	indexer := NewIndexer(nil, store, FSContextMap{RepoNamespace: filteredFS}, Config{EmbedPaths: true})
+
+	summarizeCalls := 0
+	indexer.summarize = func(_ context.Context, content []byte) ([]ContentSummary, error) {
+		summarizeCalls++
+		assert.Equal(t, "package new\n\nfunc New() {}\n", string(content))
+		return []ContentSummary{{Summary: "Constructs a new thing", ContentSpan: ContentSpan{StartLine: 3, EndLine: 3}}}, nil
+	}
+
+	// Stored summaries are returned in line order, without the embedded path header
+	require.NoError(t, store.BatchAddDocuments(context.Background(), []db.Document{
+		fileEntry("indexed.go", "2024-01-01T00:00:00Z", "hash"),
+		chunkEntry("indexed.go", 1, 10, 20, indexer.chunkContent("indexed.go", "Defines the handlers", "")),
+		chunkEntry("indexed.go", 0, 1, 8, indexer.chunkContent("indexed.go", "Declares the package", "")),
+	}))
+
+	summary, err := indexer.SummarizeFile(context.Background(), "indexed.go")
+	require.NoError(t, err)
+	assert.Zero(t, summarizeCalls, "stored summaries should not call the model")
+	assert.Equal(t, &FileSummary{
+		Path:    "indexed.go",
+		Indexed: true,
+		Sections: []SectionSummary{
+			{StartLine: 1, EndLine: 8, Summary: "Declares the package"},
+			{StartLine: 10, EndLine: 20, Summary: "Defines the handlers"},
+		},
+	}, summary)
+	assert.Equal(t, "Lines 1-8: Declares the package\nLines 10-20: Defines the handlers\n", summary.String())
+
+	// Unindexed files are summarized on demand, without being added to the index
+	summary, err = indexer.SummarizeFile(context.Background(), "new.go")
+	require.NoError(t, err)
+	assert.Equal(t, 1, summarizeCalls)
+	assert.Equal(t, &FileSummary{
+		Path:     "new.go",
+		Sections: []SectionSummary{{StartLine: 3, EndLine: 3, Summary: "Constructs a new thing"}},
+	}, summary)
+
+	count, err := store.Count()
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	_, err = indexer.SummarizeFile(context.Background(), "missing.go")
+	assert.Error(t, err)
+}
+
+// cannedGenerator responds to every request with the same text
+type cannedGenerator string
+
+func (g cannedGenerator) Generate(context.Context, GenerateRequest) (string, error) {
+	return string(g), nil
+}
+
+func TestSummarizeRepairsLineRanges(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	content := []byte("package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hi\")\n}")
+
+	// The model numbers from 0, inverts a range, overruns the file and invents a section
+	indexer := &Indexer{generator: cannedGenerator(`{"summaries": [
+		{"summary": "Package declaration", "start_line": 0, "end_line": 1},
+		{"summary": "Imports fmt", "start_line": 3, "end_line": 3},
+		{"summary": "Prints a greeting", "start_line": 7, "end_line": 5},
+		{"summary": "Closing brace", "start_line": 7, "end_line": 8},
+		{"summary": "Tests", "start_line": 9, "end_line": 20}
+	]}`)}
+
+	summaries, err := indexer.summarizeWindow(context.Background(), content)
+	require.NoError(t, err)
+	assert.Equal(t, []ContentSummary{
+		{Summary: "Package declaration", ContentSpan: ContentSpan{StartLine: 1, EndLine: 1}},
+		{Summary: "Imports fmt", ContentSpan: ContentSpan{StartLine: 3, EndLine: 3}},
+		{Summary: "Prints a greeting", ContentSpan: ContentSpan{StartLine: 5, EndLine: 7}},
+		{Summary: "Closing brace", ContentSpan: ContentSpan{StartLine: 7, EndLine: 7}},
+	}, summaries)
+
+	// The file is only rejected when no summary is usable
+	indexer.generator = cannedGenerator(`{"summaries": [{"summary": "Tests", "start_line": 9, "end_line": 20}]}`)
+	_, err = indexer.summarizeWindow(context.Background(), content)
+	assert.ErrorContains(t, err, "no summary had a line range within the content's 7 lines")
+}
@@ -0,0 +1,128 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	iofs "io/fs"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/russellhaering/autoswe/pkg/log"
+	"go.uber.org/zap"
+)
+
+// Problem describes how a file's index entries are inconsistent
+type Problem string
+
+const (
+	// ProblemMissingChunks means a file entry has fewer chunks than were written with it,
+	// usually because an update was interrupted
+	ProblemMissingChunks Problem = "missing_chunks"
+	// ProblemOrphanedChunks means there are chunks for a file that has no file entry
+	ProblemOrphanedChunks Problem = "orphaned_chunks"
+)
+
+// Inconsistency is a file whose index entries are inconsistent
+type Inconsistency struct {
+	FileRef
+	Problem Problem `json:"problem"`
+}
+
+// VerifyReport lists the inconsistencies found by Verify
+type VerifyReport struct {
+	Files           int             `json:"files"`
+	Chunks          int             `json:"chunks"`
+	Inconsistencies []Inconsistency `json:"inconsistencies"`
+}
+
+// Verify checks that every file entry in the index has all of its chunks, and that every
+// chunk belongs to a file entry. Nothing is changed; use Repair to fix the problems found.
+func (i *Indexer) Verify(_ context.Context) (*VerifyReport, error) {
+	fileDocs, err := i.db.FilterDocuments(map[string]string{"is_file_entry": "true"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file entries: %w", err)
+	}
+
+	chunkDocs, err := i.db.FilterDocuments(map[string]string{"is_file_entry": "false"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chunks: %w", err)
+	}
+
+	chunkCounts := make(map[FileRef]int)
+	for _, doc := range chunkDocs {
+		ref := FileRef{Namespace: doc.Metadata["namespace"], Path: doc.Metadata["path"]}
+		chunkCounts[ref]++
+	}
+
+	report := &VerifyReport{
+		Files:  len(fileDocs),
+		Chunks: len(chunkDocs),
+	}
+
+	files := make(map[FileRef]bool)
+	for _, doc := range fileDocs {
+		ref := FileRef{Namespace: doc.Metadata["namespace"], Path: doc.Metadata["path"]}
+		files[ref] = true
+
+		// Entries written before chunk counts were recorded are only known to be
+		// incomplete if they have no chunks at all
+		want := 1
+		if count, ok := doc.Metadata["chunk_count"]; ok {
+			if want, err = strconv.Atoi(count); err != nil {
+				log.Warn("Invalid chunk count", zap.String("id", doc.ID), zap.String("chunk_count", count))
+				want = 1
+			}
+		}
+
+		if chunkCounts[ref] < want {
+			report.Inconsistencies = append(report.Inconsistencies, Inconsistency{FileRef: ref, Problem: ProblemMissingChunks})
+		}
+	}
+
+	for ref := range chunkCounts {
+		if !files[ref] {
+			report.Inconsistencies = append(report.Inconsistencies, Inconsistency{FileRef: ref, Problem: ProblemOrphanedChunks})
+		}
+	}
+
+	sort.Slice(report.Inconsistencies, func(a, b int) bool {
+		x, y := report.Inconsistencies[a], report.Inconsistencies[b]
+		if x.Namespace != y.Namespace {
+			return x.Namespace < y.Namespace
+		}
+		return x.Path < y.Path
+	})
+
+	return report, nil
+}
+
+// Repair fixes the inconsistencies in a report by removing the affected files' entries and
+// re-indexing those that still exist
+func (i *Indexer) Repair(ctx context.Context, report *VerifyReport) error {
+	for _, inconsistency := range report.Inconsistencies {
+		ref := inconsistency.FileRef
+
+		if err := i.deleteFileEntries(ctx, ComputeID(ref.Namespace, ref.Path, -1)); err != nil {
+			return fmt.Errorf("failed to delete entries for %s: %w", ref.Path, err)
+		}
+
+		fsys, ok := i.fss[ref.Namespace]
+		if !ok {
+			continue
+		}
+
+		if _, err := iofs.Stat(fsys, ref.Path); os.IsNotExist(err) {
+			log.Info("Removed index entries for deleted file", zap.String("path", ref.Path))
+			continue
+		}
+
+		if err := i.indexFile(ctx, ref.Namespace, ref.Path); err != nil {
+			return fmt.Errorf("failed to re-index %s: %w", ref.Path, err)
+		}
+
+		log.Info("Re-indexed file", zap.String("path", ref.Path), zap.String("problem", string(inconsistency.Problem)))
+	}
+
+	return nil
+}
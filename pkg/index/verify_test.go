@@ -0,0 +1,82 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/db"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyAndRepair(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+	for _, name := range []string{"interrupted.go", "ok.go", "legacy.go"} {
+		require.NoError(t, os.WriteFile(filepath.Join(rootDir, name), []byte("package main\n"), 0644))
+	}
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	require.NoError(t, err)
+
+	store := db.NewMemoryDB(bagOfWords)
+	indexer := NewIndexer(nil, store, FSContextMap{RepoNamespace: filteredFS}, Config{})
+
+	summarizeCalls := 0
+	indexer.summarize = func(_ context.Context, _ []byte) ([]ContentSummary, error) {
+		summarizeCalls++
+		return []ContentSummary{
+			{Summary: "Declares the package", ContentSpan: ContentSpan{StartLine: 1, EndLine: 1}},
+			{Summary: "Nothing else", ContentSpan: ContentSpan{StartLine: 1, EndLine: 1}},
+		}, nil
+	}
+
+	withChunkCount := func(doc db.Document, count string) db.Document {
+		doc.Metadata["chunk_count"] = count
+		return doc
+	}
+
+	modTime := "2024-01-01T00:00:00Z"
+	require.NoError(t, store.BatchAddDocuments(context.Background(), []db.Document{
+		// Interrupted after writing one of its two chunks
+		withChunkCount(fileEntry("interrupted.go", modTime, "hash"), "2"),
+		chunkEntry("interrupted.go", 0, 1, 1, "Declares the package"),
+		// Consistent
+		withChunkCount(fileEntry("ok.go", modTime, "hash"), "1"),
+		chunkEntry("ok.go", 0, 1, 1, "Declares the package"),
+		// Written before chunk counts were recorded, with no chunks
+		fileEntry("legacy.go", modTime, "hash"),
+		// Chunks left behind for a deleted file
+		chunkEntry("deleted.go", 0, 1, 1, "Declares the package"),
+		chunkEntry("deleted.go", 1, 2, 2, "Does something"),
+	}))
+
+	report, err := indexer.Verify(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Files)
+	assert.Equal(t, 4, report.Chunks)
+	assert.Equal(t, []Inconsistency{
+		{FileRef: FileRef{Namespace: RepoNamespace, Path: "deleted.go"}, Problem: ProblemOrphanedChunks},
+		{FileRef: FileRef{Namespace: RepoNamespace, Path: "interrupted.go"}, Problem: ProblemMissingChunks},
+		{FileRef: FileRef{Namespace: RepoNamespace, Path: "legacy.go"}, Problem: ProblemMissingChunks},
+	}, report.Inconsistencies)
+	assert.Zero(t, summarizeCalls, "verify should not change the index")
+
+	require.NoError(t, indexer.Repair(context.Background(), report))
+	assert.Equal(t, 2, summarizeCalls, "only the existing inconsistent files should be re-indexed")
+
+	report, err = indexer.Verify(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, report.Inconsistencies)
+	assert.Equal(t, 3, report.Files)
+	assert.Equal(t, 5, report.Chunks)
+
+	doc, err := store.GetDocument(ComputeID(RepoNamespace, "interrupted.go", -1))
+	require.NoError(t, err)
+	assert.Equal(t, "2", doc.Metadata["chunk_count"])
+}
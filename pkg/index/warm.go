@@ -0,0 +1,62 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/russellhaering/autoswe/pkg/log"
+	"go.uber.org/zap"
+)
+
+// ErrNoEmbeddingCache is returned by Warm when the embedding cache isn't enabled
+var ErrNoEmbeddingCache = errors.New("the embedding cache is not enabled")
+
+// WarmResult reports what Warm did
+type WarmResult struct {
+	// Embedded is the number of queries that were embedded and added to the cache
+	Embedded int `json:"embedded"`
+	// Cached is the number of queries that were already in the cache
+	Cached int `json:"cached"`
+}
+
+// Warm embeds each of the queries into the embedding cache, so that running them later
+// doesn't wait on the embedding model. Blank and duplicate queries are skipped.
+func (i *Indexer) Warm(ctx context.Context, queries []string) (*WarmResult, error) {
+	store, ok := i.db.(*cachingStore)
+	if !ok {
+		return nil, ErrNoEmbeddingCache
+	}
+
+	result := &WarmResult{}
+	seen := make(map[string]bool)
+
+	for _, query := range queries {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		query = strings.TrimSpace(query)
+		if query == "" || seen[query] {
+			continue
+		}
+		seen[query] = true
+
+		if _, ok, err := store.cache.Get(query); err != nil {
+			return result, err
+		} else if ok {
+			result.Cached++
+			continue
+		}
+
+		if _, err := store.embed(ctx, query); err != nil {
+			return result, fmt.Errorf("failed to embed query %q: %w", query, err)
+		}
+		result.Embedded++
+
+		log.Debug("Warmed query", zap.String("query", query))
+	}
+
+	return result, nil
+}
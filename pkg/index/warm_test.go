@@ -0,0 +1,58 @@
+package index
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/db"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarm(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	cache, err := db.NewEmbeddingCache(filepath.Join(t.TempDir(), EmbeddingCacheFileName))
+	require.NoError(t, err)
+
+	var embedded []string
+	embed := cache.Wrap(func(ctx context.Context, content string) ([]float32, error) {
+		embedded = append(embedded, content)
+		return bagOfWords(ctx, content)
+	})
+
+	store := &cachingStore{
+		DocumentStore: db.NewMemoryDB(embed),
+		cache:         cache,
+		embed:         embed,
+	}
+	require.NoError(t, store.AddDocument(context.Background(), chunkEntry("index.go", 0, 1, 10, "walks the repository and indexes files")))
+
+	indexer := &Indexer{db: store}
+	defer indexer.Close()
+
+	result, err := indexer.Warm(context.Background(), []string{"how are files indexed", "", "how are files indexed", "query syntax"})
+	require.NoError(t, err)
+	assert.Equal(t, &WarmResult{Embedded: 2}, result)
+
+	// Searching for a warmed query uses the cached embedding
+	embedded = nil
+	results, err := indexer.Search(context.Background(), "how are files indexed", 5, "")
+	require.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Empty(t, embedded, "the embedder should not be called for a warmed query")
+
+	result, err = indexer.Warm(context.Background(), []string{"how are files indexed", "new query"})
+	require.NoError(t, err)
+	assert.Equal(t, &WarmResult{Embedded: 1, Cached: 1}, result)
+	assert.Equal(t, []string{"new query"}, embedded)
+}
+
+func TestWarmWithoutCache(t *testing.T) {
+	indexer := &Indexer{db: db.NewMemoryDB(bagOfWords)}
+
+	_, err := indexer.Warm(context.Background(), []string{"query"})
+	assert.ErrorIs(t, err, ErrNoEmbeddingCache)
+}
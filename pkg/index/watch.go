@@ -0,0 +1,109 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/russellhaering/autoswe/pkg/log"
+	"go.uber.org/zap"
+)
+
+// WatchMode selects how Watch notices changes to the indexed files
+type WatchMode string
+
+const (
+	// WatchModeAuto picks the best mode available on this system. Filesystem notifications
+	// aren't supported yet, so it currently falls back to polling.
+	WatchModeAuto WatchMode = "auto"
+	// WatchModePoll periodically walks the indexed files and compares their mod times with
+	// the index, which works on every filesystem, including network mounts
+	WatchModePoll WatchMode = "poll"
+)
+
+// DefaultWatchInterval is how often the indexed files are checked for changes when polling
+const DefaultWatchInterval = 5 * time.Second
+
+// WatchConfig configures Watch
+type WatchConfig struct {
+	Mode WatchMode
+	// Interval is how often to poll for changes, DefaultWatchInterval if zero
+	Interval time.Duration
+}
+
+// ParseWatchMode parses a watch mode name, as accepted on the command line
+func ParseWatchMode(name string) (WatchMode, error) {
+	switch mode := WatchMode(name); mode {
+	case WatchModeAuto, WatchModePoll:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown watch mode %q, expected %q or %q", name, WatchModeAuto, WatchModePoll)
+	}
+}
+
+// Watch keeps the index up to date as files change, until ctx is done. Changes are found
+// with the same checks UpdateIndex uses, so only modified, added or deleted files cause an
+// update.
+func (i *Indexer) Watch(ctx context.Context, config WatchConfig) error {
+	interval := config.Interval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	switch config.Mode {
+	case WatchModePoll:
+	case WatchModeAuto, "":
+		log.Warn("Filesystem notifications are not available, falling back to polling")
+	default:
+		return fmt.Errorf("unknown watch mode %q", config.Mode)
+	}
+
+	log.Info("Watching for changes", zap.Duration("interval", interval))
+
+	return pollChanges(ctx, interval, i.hasChanges, i.UpdateIndex)
+}
+
+// hasChanges reports whether UpdateIndex would add, update or delete any files
+func (i *Indexer) hasChanges(ctx context.Context) (bool, error) {
+	plan, err := i.PlanUpdate(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return len(plan.Add) > 0 || len(plan.Update) > 0 || len(plan.Delete) > 0, nil
+}
+
+// pollChanges calls check every interval, and update whenever it reports changes, until ctx
+// is done. Errors are logged rather than returned, so that a failed update is retried at
+// the next interval.
+func pollChanges(ctx context.Context, interval time.Duration, check func(context.Context) (bool, error), update func(context.Context) error) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return nil
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		changed, err := check(ctx)
+		if err != nil {
+			log.Warn("Failed to check for changes", zap.Error(err))
+			continue
+		}
+
+		if !changed {
+			continue
+		}
+
+		log.Info("Files changed, updating index")
+		if err := update(ctx); err != nil {
+			log.Warn("Failed to update index", zap.Error(err))
+		}
+	}
+}
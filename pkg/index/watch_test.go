@@ -0,0 +1,78 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/russellhaering/autoswe/pkg/db"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPollChangesReindexesModifiedFile(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+	path := filepath.Join(rootDir, "main.go")
+	require.NoError(t, os.WriteFile(path, []byte("package main"), 0644))
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	require.NoError(t, err)
+
+	docDB, err := db.NewDocumentDB(filepath.Join(t.TempDir(), "db"), func(context.Context, string) ([]float32, error) {
+		return []float32{1, 0, 0}, nil
+	})
+	require.NoError(t, err)
+	defer docDB.Close()
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	hash, err := ComputeContentHash([]byte("package main"))
+	require.NoError(t, err)
+	require.NoError(t, docDB.AddDocument(context.Background(), fileEntry("main.go", info.ModTime().Format(time.RFC3339), hash)))
+
+	indexer := &Indexer{
+		fss: FSContextMap{RepoNamespace: filteredFS},
+		db:  docDB,
+	}
+
+	updated := make(chan struct{}, 1)
+	update := func(context.Context) error {
+		select {
+		case updated <- struct{}{}:
+		default:
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	interval := 20 * time.Millisecond
+	go func() {
+		done <- pollChanges(ctx, interval, indexer.hasChanges, update)
+	}()
+
+	// Nothing has changed yet, so no update should happen
+	select {
+	case <-updated:
+		t.Fatal("index updated before any file changed")
+	case <-time.After(5 * interval):
+	}
+
+	require.NoError(t, os.WriteFile(path, []byte("package main\n\nfunc main() {}"), 0644))
+	later := info.ModTime().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, later, later))
+
+	select {
+	case <-updated:
+	case <-time.After(50 * interval):
+		t.Fatal("modified file did not trigger an update")
+	}
+
+	cancel()
+	require.NoError(t, <-done)
+}
@@ -0,0 +1,94 @@
+package index
+
+import (
+	"cmp"
+	"math"
+	"strings"
+)
+
+// summaryWindowOverlap is the number of lines shared by consecutive summary windows, so that
+// an element split across a window boundary is seen whole by one of them
+const summaryWindowOverlap = 50
+
+// summaryWindow is part of a file that is summarized in one request
+type summaryWindow struct {
+	// startLine is the line number of the window's first line in the file
+	startLine int
+
+	// lines is the number of lines in the window
+	lines int
+
+	// overlap is the number of lines at the start of the window that the previous window
+	// also covered
+	overlap int
+
+	content []byte
+}
+
+// endLine returns the line number of the window's last line in the file
+func (w summaryWindow) endLine() int {
+	return w.startLine + w.lines - 1
+}
+
+// summaryWindowLines returns the most lines summarized at once
+func (c Config) summaryWindowLines() int {
+	if c.SummaryWindowLines < 0 {
+		return math.MaxInt
+	}
+	return cmp.Or(c.SummaryWindowLines, DefaultSummaryWindowLines)
+}
+
+// summaryWindowBytes returns the most bytes summarized at once
+func (c Config) summaryWindowBytes() int {
+	if c.SummaryWindowBytes < 0 {
+		return math.MaxInt
+	}
+	return cmp.Or(c.SummaryWindowBytes, DefaultSummaryWindowBytes)
+}
+
+// summaryWindows splits content into overlapping windows that are small enough to summarize
+// at once. Content within the limits is returned as a single window. A window always holds
+// at least one line, however long it is.
+func (c Config) summaryWindows(content []byte) []summaryWindow {
+	maxLines, maxBytes := c.summaryWindowLines(), c.summaryWindowBytes()
+
+	// Lines are counted the same way as when they are numbered for the summary model
+	lines := strings.SplitAfter(string(content), "\n")
+	if len(lines) <= maxLines && len(content) <= maxBytes {
+		return []summaryWindow{{startLine: 1, lines: len(lines), content: content}}
+	}
+
+	var windows []summaryWindow
+	for start, overlap := 0, 0; ; {
+		end, size := start, 0
+
+		// The empty last line after a final newline takes no space, so it never needs a
+		// window of its own
+		for end < len(lines) && end-start < maxLines && (end == start || size+len(lines[end]) <= maxBytes || lines[end] == "") {
+			size += len(lines[end])
+			end++
+		}
+
+		// Leave off the final newline, so that the model isn't shown an empty last line
+		// that belongs to the next window
+		text := strings.Join(lines[start:end], "")
+		if end < len(lines) {
+			text = strings.TrimSuffix(text, "\n")
+		}
+
+		windows = append(windows, summaryWindow{
+			startLine: start + 1,
+			lines:     end - start,
+			overlap:   overlap,
+			content:   []byte(text),
+		})
+
+		if end == len(lines) {
+			return windows
+		}
+
+		// Always move forward, even when a window is shorter than the overlap
+		next := max(end-summaryWindowOverlap, start+1)
+		start, overlap = next, end-next
+	}
+}
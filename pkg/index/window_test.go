@@ -0,0 +1,79 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractSummariesLargeFile(t *testing.T) {
+	var lines []string
+	for n := 1; n <= 2500; n++ {
+		lines = append(lines, fmt.Sprintf("line %d", n))
+	}
+	content := []byte(strings.Join(lines, "\n"))
+
+	indexer := &Indexer{config: Config{SummaryWindowLines: 1000}}
+
+	// Summarize every 10 lines of each window, quoting the first line so that the line
+	// numbers can be checked against the file
+	var windows []string
+	indexer.summarize = func(_ context.Context, window []byte) ([]ContentSummary, error) {
+		windowLines := strings.Split(string(window), "\n")
+		windows = append(windows, windowLines[0]+" to "+windowLines[len(windowLines)-1])
+
+		var summaries []ContentSummary
+		for start := 1; start <= len(windowLines); start += 10 {
+			end := min(start+9, len(windowLines))
+			summaries = append(summaries, ContentSummary{
+				Summary:     windowLines[start-1],
+				ContentSpan: ContentSpan{StartLine: start, EndLine: end},
+			})
+		}
+		return summaries, nil
+	}
+
+	summaries, err := indexer.extractSummaries(context.Background(), "large.txt", content)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"line 1 to line 1000",
+		"line 951 to line 1950",
+		"line 1901 to line 2500",
+	}, windows)
+
+	// The summaries cover the whole file once, with line numbers relative to the file
+	require.NotEmpty(t, summaries)
+	assert.Equal(t, 1, summaries[0].ContentSpan.StartLine)
+	assert.Equal(t, 2500, summaries[len(summaries)-1].ContentSpan.EndLine)
+	for idx, summary := range summaries {
+		assert.Equal(t, fmt.Sprintf("line %d", summary.ContentSpan.StartLine), summary.Summary)
+		if idx > 0 {
+			assert.Equal(t, summaries[idx-1].ContentSpan.EndLine+1, summary.ContentSpan.StartLine)
+		}
+	}
+}
+
+func TestSummaryWindows(t *testing.T) {
+	content := []byte("package main\n\nfunc main() {}\n")
+
+	windows := Config{}.summaryWindows(content)
+	require.Len(t, windows, 1)
+	assert.Equal(t, content, windows[0].content)
+
+	// A window holds at least one line, even if it is over the byte limit
+	windows = Config{SummaryWindowBytes: 13}.summaryWindows(content)
+	require.Len(t, windows, 3)
+	assert.Equal(t, "package main", string(windows[0].content))
+	assert.Equal(t, 2, windows[1].startLine)
+	assert.Equal(t, 0, windows[1].overlap, "windows shorter than the overlap move on a line at a time")
+	assert.Equal(t, "func main() {}\n", string(windows[2].content))
+	assert.Equal(t, 4, windows[2].endLine())
+
+	windows = Config{SummaryWindowLines: -1, SummaryWindowBytes: -1}.summaryWindows([]byte(strings.Repeat("x\n", 5000)))
+	assert.Len(t, windows, 1)
+}
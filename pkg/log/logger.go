@@ -1,28 +1,85 @@
 package log
 
 import (
+	"fmt"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
 var (
-	// Log is the global logger instance
-	Log *zap.Logger
+	// Log is the global logger instance. It discards everything until Init is called.
+	Log = zap.NewNop()
+
+	// Level is the least severe level the global logger writes, which can be changed at any
+	// time after Init
+	Level = zap.NewAtomicLevel()
 )
 
-// Init initializes the global logger with the given config
+// Format is how log entries are encoded
+type Format string
+
+const (
+	// FormatConsole writes human readable entries, for terminals
+	FormatConsole Format = "console"
+
+	// FormatJSON writes an entry per line as a JSON object, for CI and log collectors
+	FormatJSON Format = "json"
+)
+
+// ParseFormat parses a log format name, returning an error for unknown formats
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(name); format {
+	case FormatConsole, FormatJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown log format %q, expected %q or %q", name, FormatConsole, FormatJSON)
+	}
+}
+
+// Config configures the global logger
+type Config struct {
+	// Format is how entries are encoded
+	// Default: FormatConsole
+	Format Format
+
+	// Level is the least severe level that is logged
+	Level zapcore.Level
+}
+
+// Init initializes the global logger, with the console format at debug level for development
+// or the JSON format at info level otherwise
 func Init(development bool) error {
+	if development {
+		return InitWithConfig(Config{Format: FormatConsole, Level: zapcore.DebugLevel})
+	}
+	return InitWithConfig(Config{Format: FormatJSON, Level: zapcore.InfoLevel})
+}
+
+// InitWithConfig initializes the global logger with the given config. Both formats include
+// every field of each entry, with any secrets redacted.
+func InitWithConfig(config Config) error {
 	var cfg zap.Config
 
-	if development {
+	switch config.Format {
+	case FormatConsole, "":
 		cfg = zap.NewDevelopmentConfig()
 		cfg.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
-	} else {
+	case FormatJSON:
 		cfg = zap.NewProductionConfig()
+		// Collectors should see every entry, rather than a sample of repeated ones
+		cfg.Sampling = nil
+	default:
+		return fmt.Errorf("unknown log format %q", config.Format)
 	}
 
+	Level.SetLevel(config.Level)
+	cfg.Level = Level
+	cfg.Encoding = redactedEncoding(cfg.Encoding)
+
+	// Report the caller of this package's functions, rather than the functions themselves
 	var err error
-	Log, err = cfg.Build()
+	Log, err = cfg.Build(zap.AddCallerSkip(1))
 	if err != nil {
 		return err
 	}
@@ -32,7 +89,8 @@ func Init(development bool) error {
 
 // With creates a child logger and adds structured context to it
 func With(fields ...zap.Field) *zap.Logger {
-	return Log.With(fields...)
+	// The child logger is called directly, not through this package
+	return Log.WithOptions(zap.AddCallerSkip(-1)).With(fields...)
 }
 
 // Debug uses fmt.Sprint to construct and log a message
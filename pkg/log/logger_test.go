@@ -0,0 +1,76 @@
+package log
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// captureLogs initializes the global logger with config, writing to a file rather than
+// stderr, and returns a function that reads back what was logged
+func captureLogs(t *testing.T, config Config) func() []byte {
+	path := filepath.Join(t.TempDir(), "log")
+	file, err := os.Create(path)
+	require.NoError(t, err)
+
+	// zap opens stderr when the logger is built
+	stderr := os.Stderr
+	os.Stderr = file
+	err = InitWithConfig(config)
+	os.Stderr = stderr
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		file.Close()
+		require.NoError(t, Init(true))
+	})
+
+	return func() []byte {
+		require.NoError(t, Log.Sync())
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		return data
+	}
+}
+
+func TestInitWithConfigJSON(t *testing.T) {
+	logged := captureLogs(t, Config{Format: FormatJSON, Level: zapcore.InfoLevel})
+
+	Debug("Hidden")
+	Info("Indexed file", zap.String("path", "main.go"), zap.Int("chunks", 3))
+	data := logged()
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(data, &entry), "only the info entry should be logged, as one JSON object")
+	assert.Equal(t, "info", entry["level"])
+	assert.Equal(t, "Indexed file", entry["msg"])
+	assert.Equal(t, "main.go", entry["path"])
+	assert.Equal(t, 3.0, entry["chunks"])
+	assert.Contains(t, entry["caller"], "log/logger_test.go")
+}
+
+func TestLevel(t *testing.T) {
+	require.NoError(t, Init(true))
+	assert.True(t, Log.Core().Enabled(zapcore.DebugLevel))
+
+	// The level can be raised after Init, without replacing the logger
+	Level.SetLevel(zapcore.WarnLevel)
+	defer Level.SetLevel(zapcore.DebugLevel)
+	assert.False(t, Log.Core().Enabled(zapcore.InfoLevel))
+	assert.True(t, Log.Core().Enabled(zapcore.WarnLevel))
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("json")
+	require.NoError(t, err)
+	assert.Equal(t, FormatJSON, format)
+
+	_, err = ParseFormat("xml")
+	assert.ErrorContains(t, err, `unknown log format "xml"`)
+}
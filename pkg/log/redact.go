@@ -0,0 +1,119 @@
+package log
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// redacted replaces each secret that is scrubbed from the logs
+const redacted = "[REDACTED]"
+
+// minSecretLength is the shortest value AddSecret accepts, so that short placeholder values
+// don't redact unrelated text
+const minSecretLength = 8
+
+// secretPatterns match well known secrets. Where a pattern has a group, only the text after
+// the group is redacted, so that it is still clear what was removed.
+var secretPatterns = []*regexp.Regexp{
+	// Anthropic and OpenAI API keys
+	regexp.MustCompile(`sk-[A-Za-z0-9_-]{20,}`),
+	// Google API keys
+	regexp.MustCompile(`AIza[0-9A-Za-z_-]{35}`),
+	// Authorization headers
+	regexp.MustCompile(`(?i)(authorization["']?\s*[:=]\s*["']?(?:bearer|basic)\s+)[^\s"',]+`),
+	// API key headers
+	regexp.MustCompile(`(?i)((?:x-api-key|x-goog-api-key)["']?\s*[:=]\s*["']?)[^\s"',]+`),
+	// API keys passed in URLs
+	regexp.MustCompile(`([?&]key=)[^&\s"']+`),
+}
+
+var (
+	secretsMu sync.RWMutex
+	secrets   []string
+)
+
+func init() {
+	// The logger is built from a zap.Config, which finds encoders by name
+	encoders := map[string]func(zapcore.EncoderConfig) zapcore.Encoder{
+		"console": zapcore.NewConsoleEncoder,
+		"json":    zapcore.NewJSONEncoder,
+	}
+	for name, newEncoder := range encoders {
+		if err := zap.RegisterEncoder(redactedEncoding(name), func(config zapcore.EncoderConfig) (zapcore.Encoder, error) {
+			return newRedactingEncoder(newEncoder(config)), nil
+		}); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// redactedEncoding is the name of the encoding that redacts the output of another
+func redactedEncoding(encoding string) string {
+	return "redacted-" + encoding
+}
+
+// AddSecret adds a value, such as an API key from the command line, to be redacted wherever
+// it appears in the logs. Values shorter than 8 characters are ignored.
+func AddSecret(secret string) {
+	if len(secret) < minSecretLength {
+		return
+	}
+
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	secrets = append(secrets, secret)
+}
+
+// Redact replaces secrets in s, both those added with AddSecret and those matching well known
+// patterns such as API keys and Authorization headers
+func Redact(s string) string {
+	secretsMu.RLock()
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	secretsMu.RUnlock()
+
+	for _, pattern := range secretPatterns {
+		if pattern.NumSubexp() > 0 {
+			s = pattern.ReplaceAllString(s, "${1}"+redacted)
+		} else {
+			s = pattern.ReplaceAllString(s, redacted)
+		}
+	}
+
+	return s
+}
+
+// redactingEncoder redacts secrets from each encoded entry, so that they are caught wherever
+// they appear: in the message, in any field, or in a struct or error logged as a field
+type redactingEncoder struct {
+	zapcore.Encoder
+}
+
+func newRedactingEncoder(encoder zapcore.Encoder) zapcore.Encoder {
+	return &redactingEncoder{Encoder: encoder}
+}
+
+func (e *redactingEncoder) Clone() zapcore.Encoder {
+	return &redactingEncoder{Encoder: e.Encoder.Clone()}
+}
+
+func (e *redactingEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	encoded, err := e.Encoder.EncodeEntry(entry, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	scrubbed := Redact(encoded.String())
+	if scrubbed != encoded.String() {
+		encoded.Reset()
+		encoded.AppendString(scrubbed)
+	}
+
+	return encoded, nil
+}
@@ -0,0 +1,88 @@
+// Package pricing holds what models charge for tokens, used to report the cost of tasks and
+// to estimate the cost of indexing
+package pricing
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+)
+
+// Price is what a model charges for tokens, in US dollars per million tokens
+type Price struct {
+	InputPerMTok  float64 `json:"input_per_mtok"`
+	OutputPerMTok float64 `json:"output_per_mtok"`
+}
+
+// Table maps model names to their prices. A model matches its exact name, or else the
+// longest name that is a prefix of it, so "claude-3-7-sonnet" prices every version of
+// Claude 3.7 Sonnet.
+type Table map[string]Price
+
+// Defaults are the published prices of the Claude models that run tasks, and of the default
+// models used to index
+var Defaults = Table{
+	"claude-3-7-sonnet": {InputPerMTok: 3, OutputPerMTok: 15},
+	"claude-3-5-sonnet": {InputPerMTok: 3, OutputPerMTok: 15},
+	"claude-3-5-haiku":  {InputPerMTok: 0.8, OutputPerMTok: 4},
+	"claude-3-opus":     {InputPerMTok: 15, OutputPerMTok: 75},
+	"claude-3-haiku":    {InputPerMTok: 0.25, OutputPerMTok: 1.25},
+
+	"gemini-2.0-flash-lite":  {InputPerMTok: 0.075, OutputPerMTok: 0.30},
+	"gemini-2.0-flash":       {InputPerMTok: 0.10, OutputPerMTok: 0.40},
+	"text-embedding-004":     {},
+	"gpt-4o-mini":            {InputPerMTok: 0.15, OutputPerMTok: 0.60},
+	"text-embedding-3-small": {InputPerMTok: 0.02},
+}
+
+// Load reads prices from a JSON file mapping model names to prices, such as
+// {"claude-3-7-sonnet": {"input_per_mtok": 3, "output_per_mtok": 15}}, and returns them
+// merged over Defaults
+func Load(path string) (Table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prices: %w", err)
+	}
+
+	var prices Table
+	if err := json.Unmarshal(data, &prices); err != nil {
+		return nil, fmt.Errorf("failed to parse prices %s: %w", path, err)
+	}
+
+	table := maps.Clone(Defaults)
+	maps.Copy(table, prices)
+
+	return table, nil
+}
+
+// Lookup returns the price of the named model
+func (t Table) Lookup(model string) (Price, bool) {
+	if price, ok := t[model]; ok {
+		return price, true
+	}
+
+	var best string
+	for name := range t {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+
+	if best == "" {
+		return Price{}, false
+	}
+	return t[best], true
+}
+
+// Cost returns what the tokens cost with the named model, and false if the model's price
+// isn't known
+func (t Table) Cost(model string, inputTokens, outputTokens int64) (float64, bool) {
+	price, ok := t.Lookup(model)
+	if !ok {
+		return 0, false
+	}
+
+	return (float64(inputTokens)*price.InputPerMTok + float64(outputTokens)*price.OutputPerMTok) / 1e6, true
+}
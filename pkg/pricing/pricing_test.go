@@ -0,0 +1,42 @@
+package pricing
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup(t *testing.T) {
+	price, ok := Defaults.Lookup("claude-3-5-haiku-20241022")
+	require.True(t, ok)
+	assert.Equal(t, Price{InputPerMTok: 0.8, OutputPerMTok: 4}, price)
+
+	// The longest matching name wins
+	price, ok = Defaults.Lookup("gemini-2.0-flash-lite-001")
+	require.True(t, ok)
+	assert.Equal(t, 0.075, price.InputPerMTok)
+
+	_, ok = Defaults.Lookup("gpt-4o")
+	assert.False(t, ok)
+
+	cost, ok := Defaults.Cost("claude-3-7-sonnet", 1_000_000, 100_000)
+	require.True(t, ok)
+	assert.InDelta(t, 4.5, cost, 1e-12)
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"gpt-4o": {"input_per_mtok": 2.5, "output_per_mtok": 10}}`), 0644))
+
+	table, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, Price{InputPerMTok: 2.5, OutputPerMTok: 10}, table["gpt-4o"])
+	assert.Equal(t, Defaults["claude-3-opus"], table["claude-3-opus"])
+	assert.NotContains(t, Defaults, "gpt-4o", "loading prices must not change the defaults")
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
@@ -1,6 +1,7 @@
 package repo
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -59,25 +60,64 @@ func (r *RepositoryFS) Path() string {
 	return r.basePath
 }
 
-func (r *RepositoryFS) Filter() (FilteredFS, error) {
-	bytes, err := fs.ReadFile(r, ".autosweignore")
+// Filter returns a view of the repository that hides ignored files. Paths matching any of
+// includePaths, which use the same glob syntax as .autosweignore, are always visible even
+// if the ignore rules would exclude them.
+func (r *RepositoryFS) Filter(includePaths ...string) (FilteredFS, error) {
+	lines, userRules := r.ignoreLines()
+	gitignore := ignore.CompileIgnoreLines(lines...)
+
+	var include *ignore.GitIgnore
+	if len(includePaths) > 0 {
+		include = ignore.CompileIgnoreLines(includePaths...)
+	}
+
+	return &filteredFS{
+		ReadDirFS:    r.ReadDirFS,
+		gitignore:    gitignore,
+		include:      include,
+		includePaths: includePaths,
+		userRules:    userRules,
+		basePath:     r.basePath, // Use the stored base path directly
+		locks:        newPathLocks(),
+	}, nil
+}
+
+// IgnoreFileName is the name of the file holding a repository's ignore rules
+const IgnoreFileName = ".autosweignore"
+
+// ignoreLines returns the repository's ignore rules followed by the default rules, along
+// with the number of lines that came from the ignore file
+func (r *RepositoryFS) ignoreLines() ([]string, int) {
+	bytes, err := fs.ReadFile(r, IgnoreFileName)
 	if err != nil {
 		log.Debug("No .autosweignore file found, using default ignore rules")
 	}
 
-	lines := strings.Split(string(bytes), "\n")
-	lines = append(lines, SkipDirs...)
-	lines = append(lines, SkipExts...)
+	var lines []string
+	if len(bytes) > 0 {
+		lines = strings.Split(string(bytes), "\n")
+	}
+	userLines := len(lines)
 
-	gitignore := ignore.CompileIgnoreLines(lines...)
+	return append(lines, defaultIgnoreLines()...), userLines
+}
 
-	return &filteredFS{
-		ReadDirFS: r.ReadDirFS,
-		gitignore: gitignore,
-		basePath:  r.basePath, // Use the stored base path directly
-	}, nil
+// defaultIgnoreLines returns the ignore rules that apply to every filesystem
+func defaultIgnoreLines() []string {
+	lines := make([]string, 0, len(SkipDirs)+len(SkipExts))
+	lines = append(lines, SkipDirs...)
+	return append(lines, SkipExts...)
 }
 
+var (
+	// ErrFiltered is returned when modifying a path that is excluded by the ignore rules
+	ErrFiltered = errors.New("path is filtered")
+
+	// ErrOutsideRoot is returned when a path refers to a location outside the mounted directory
+	ErrOutsideRoot = errors.New("path attempts to access parent directory")
+)
+
 type FilteredFS interface {
 	fs.ReadDirFS
 	isFilteredFS()
@@ -93,53 +133,96 @@ type FilteredFS interface {
 	// RemoveAll removes the named file or directory and all its contents if it's a directory
 	// It will return an error if the path is filtered or outside the mounted directory
 	RemoveAll(name string) error
+
+	// Rename moves a file or directory, creating the destination's parent directories and
+	// replacing any existing file at the destination
+	// It will return an error if either path is filtered or outside the mounted directory
+	Rename(oldName, newName string) error
+
+	// Mkdir creates the named directory, which must not already exist
+	// It will return an error if the path is filtered or outside the mounted directory
+	Mkdir(name string, perm os.FileMode) error
+
+	// MkdirAll creates the named directory along with any missing parents
+	// It will return an error if the path is filtered or outside the mounted directory
+	MkdirAll(name string, perm os.FileMode) error
+
+	// Lock blocks until no other caller holds the named path and returns a function that
+	// releases it. Read-modify-write sequences should hold the lock so that concurrent
+	// changes to the same file are not lost.
+	Lock(name string) (unlock func())
 }
 
 // filteredFS implements FilteredFS and fs.ReadDirFS interfaces to provide file filtering
 type filteredFS struct {
 	fs.ReadDirFS
-	gitignore *ignore.GitIgnore
-	basePath  string // Store the base path for validation
+	gitignore    *ignore.GitIgnore
+	include      *ignore.GitIgnore // Paths that override the ignore rules, if any
+	userRules    int               // Number of gitignore lines that came from the ignore file
+	includePaths []string
+	basePath     string // Store the base path for validation
+	locks        *pathLocks
 }
 
 func (f *filteredFS) isFilteredFS() {}
 
+// Lock implements FilteredFS.Lock
+func (f *filteredFS) Lock(name string) func() {
+	return f.locks.lock(name)
+}
+
 // isLargeOrBinaryFile checks if the file is large (>128KB) or binary.
 func (f *filteredFS) isLargeOrBinaryFile(path string) bool {
-	file, err := f.ReadDirFS.Open(path)
+	return f.contentExclusion(path) != ""
+}
+
+// contentExclusion returns why a file is hidden because of its content, or an empty string
+// if it isn't
+func (f *filteredFS) contentExclusion(path string) string {
+	return contentExclusion(f.ReadDirFS, path)
+}
+
+// contentExclusion returns why a file in fsys is hidden because of its content: it is too
+// large, empty or binary. An empty string is returned if it isn't hidden.
+func contentExclusion(fsys fs.FS, path string) string {
+	file, err := fsys.Open(path)
 	if err != nil {
-		return false
+		return ""
 	}
 
 	defer file.Close()
 
 	info, err := file.Stat()
 	if err != nil {
-		return false
+		return ""
 	}
 
 	if info.IsDir() {
-		return false
+		return ""
 	}
 
 	if info.Size() > 128*1024 {
-		return true
+		return "larger than 128KB"
 	}
 
 	// Read up to 512 bytes
 	buf := make([]byte, 512)
 	n, err := file.Read(buf)
 	if err != nil && err != io.EOF {
-		return false
+		return ""
 	}
 
-	// An empty file is valid UTF-8
+	// An empty file is valid UTF-8, but there's nothing in it worth reading
 	if n == 0 {
-		return true
+		return "empty file"
 	}
 
 	// Check if the bytes are valid UTF-8
-	return !utf8.Valid(buf[:n])
+	if !utf8.Valid(buf[:n]) {
+		return "binary file"
+	}
+
+	return ""
 }
 
 func (f *filteredFS) Open(name string) (fs.File, error) {
@@ -178,6 +261,10 @@ func (f *filteredFS) ReadDir(name string) ([]fs.DirEntry, error) {
 
 // shouldIgnore checks if the given path should be ignored
 func (f *filteredFS) shouldIgnore(path string) bool {
+	if f.isIncluded(path) {
+		return false
+	}
+
 	if f.gitignore.MatchesPath(path) {
 		return true
 	}
@@ -189,6 +276,38 @@ func (f *filteredFS) shouldIgnore(path string) bool {
 	return false
 }
 
+// isIncluded checks if the given path matches the include paths, or is a directory that
+// must be traversed to reach them
+func (f *filteredFS) isIncluded(path string) bool {
+	if f.include == nil {
+		return false
+	}
+
+	if f.include.MatchesPath(path) {
+		return true
+	}
+
+	dir := filepath.ToSlash(filepath.Clean(path)) + "/"
+	for _, pattern := range f.includePaths {
+		pattern = strings.TrimPrefix(filepath.ToSlash(strings.TrimSpace(pattern)), "/")
+
+		// The literal part of the pattern before any glob characters
+		prefix := pattern
+		if idx := strings.IndexAny(pattern, "*?["); idx >= 0 {
+			prefix = pattern[:idx]
+		}
+
+		// The directory is on the way to the pattern's literal prefix, or within a
+		// prefix that is followed by a glob
+		if strings.HasPrefix(prefix, dir) || (prefix != pattern && strings.Contains(prefix, "/") && strings.HasPrefix(dir, prefix)) {
+			info, err := fs.Stat(f.ReadDirFS, path)
+			return err == nil && info.IsDir()
+		}
+	}
+
+	return false
+}
+
 // WalkDir walks the file tree rooted at root, calling fn for each file or
 // directory in the tree, including root, but filtering out ignored files and directories
 // as well as files with invalid UTF-8 content
@@ -239,13 +358,13 @@ func (f *filteredFS) Glob(pattern string) ([]string, error) {
 func (f *filteredFS) validatePath(name string) error {
 	// Check if path is filtered
 	if f.shouldIgnore(name) {
-		return fmt.Errorf("path is filtered by ignore rules: %s", name)
+		return fmt.Errorf("%w by ignore rules: %s", ErrFiltered, name)
 	}
 
 	// Ensure the path is within the mounted directory
 	cleanPath := filepath.Clean(name)
 	if strings.HasPrefix(cleanPath, "..") || strings.Contains(cleanPath, "../") {
-		return fmt.Errorf("path attempts to access parent directory: %s", name)
+		return fmt.Errorf("%w: %s", ErrOutsideRoot, name)
 	}
 
 	return nil
@@ -296,36 +415,100 @@ func (f *filteredFS) RemoveAll(name string) error {
 
 	// If it's a directory, we need to check if any child would be filtered
 	// This prevents removing a directory that contains filtered files
-	info, err := os.Stat(absPath)
-	if err == nil && info.IsDir() {
-		var hasFiltered bool
-		err := filepath.WalkDir(absPath, func(path string, _ fs.DirEntry, err error) error {
-			if err != nil {
-				return err
-			}
+	if err := f.checkNoFilteredChildren(name); err != nil {
+		return err
+	}
 
-			// Convert absolute path back to relative for filter check
-			relPath, err := filepath.Rel(f.basePath, path)
-			if err != nil {
-				return err
-			}
+	return os.RemoveAll(absPath)
+}
 
-			if f.shouldIgnore(relPath) {
-				hasFiltered = true
-				return filepath.SkipDir
-			}
+// Rename moves a file or directory
+func (f *filteredFS) Rename(oldName, newName string) error {
+	for _, name := range []string{oldName, newName} {
+		if err := f.validatePath(name); err != nil {
+			log.Warn("Rejected rename attempt", zap.String("from", oldName), zap.String("to", newName), zap.Error(err))
+			return err
+		}
+	}
 
-			return nil
-		})
+	// Moving a directory would also move any filtered files within it
+	if err := f.checkNoFilteredChildren(oldName); err != nil {
+		return err
+	}
+
+	absOld := filepath.Join(f.basePath, oldName)
+	absNew := filepath.Join(f.basePath, newName)
 
+	// Ensure directory exists
+	if err := os.MkdirAll(filepath.Dir(absNew), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	return os.Rename(absOld, absNew)
+}
+
+// Mkdir creates the named directory, which must not already exist
+func (f *filteredFS) Mkdir(name string, perm os.FileMode) error {
+	if err := f.validatePath(name); err != nil {
+		log.Warn("Rejected mkdir attempt", zap.String("path", name), zap.Error(err))
+		return err
+	}
+
+	// Create absolute path by joining with base path
+	absPath := filepath.Join(f.basePath, name)
+
+	return os.Mkdir(absPath, perm)
+}
+
+// MkdirAll creates the named directory along with any missing parents
+func (f *filteredFS) MkdirAll(name string, perm os.FileMode) error {
+	if err := f.validatePath(name); err != nil {
+		log.Warn("Rejected mkdir attempt", zap.String("path", name), zap.Error(err))
+		return err
+	}
+
+	// Create absolute path by joining with base path
+	absPath := filepath.Join(f.basePath, name)
+
+	return os.MkdirAll(absPath, perm)
+}
+
+// checkNoFilteredChildren returns an error if name is a directory containing filtered files
+func (f *filteredFS) checkNoFilteredChildren(name string) error {
+	absPath := filepath.Join(f.basePath, name)
+
+	info, err := os.Stat(absPath)
+	if err != nil || !info.IsDir() {
+		return nil
+	}
+
+	var hasFiltered bool
+	err = filepath.WalkDir(absPath, func(path string, _ fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
-		if hasFiltered {
-			return fmt.Errorf("directory contains filtered files: %s", name)
+		// Convert absolute path back to relative for filter check
+		relPath, err := filepath.Rel(f.basePath, path)
+		if err != nil {
+			return err
 		}
+
+		if f.shouldIgnore(relPath) {
+			hasFiltered = true
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
 	}
 
-	return os.RemoveAll(absPath)
+	if hasFiltered {
+		return fmt.Errorf("%w: directory contains filtered files: %s", ErrFiltered, name)
+	}
+
+	return nil
 }
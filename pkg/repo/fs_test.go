@@ -5,6 +5,7 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -84,7 +85,8 @@ func TestFilteredFS_UTF8Validation(t *testing.T) {
 
 	// Create a file with valid UTF-8 at the beginning but invalid later (should pass)
 	validPrefixPath := filepath.Join(tmpDir, "valid-prefix.bin")
-	validPrefix := "Valid UTF-8 prefix: "
+	// Only the first 512 bytes are sniffed, so the invalid bytes must come after them
+	validPrefix := "Valid UTF-8 prefix: " + strings.Repeat("x", 512)
 	content := make([]byte, len(validPrefix)+10)
 	copy(content, validPrefix)
 	// Add some invalid UTF-8 bytes at the end
@@ -369,3 +371,62 @@ func mustCreateDir(t *testing.T, path string) {
 		t.Fatalf("Failed to create directory %s: %v", path, err)
 	}
 }
+
+// TestFilteredFS_IncludePaths tests that include paths override the ignore rules
+func TestFilteredFS_IncludePaths(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	mustCreateFile(t, filepath.Join(tmpDir, "main.go"), "package main")
+	mustCreateFile(t, filepath.Join(tmpDir, "go.sum"), "example.com/lib v1.0.0 h1:abc=")
+	mustCreateFile(t, filepath.Join(tmpDir, "vendor", "example.com", "lib", "lib.pb.go"), "package lib")
+	mustCreateFile(t, filepath.Join(tmpDir, "vendor", "example.com", "lib", "lib.go"), "package lib")
+	mustCreateFile(t, filepath.Join(tmpDir, "vendor", "example.com", "other", "other.go"), "package other")
+
+	repoFS := NewRepoFS(tmpDir)
+
+	// Without include paths, go.sum (a SkipExts entry) and vendor (a SkipDirs entry) are hidden
+	filteredFS, err := repoFS.Filter()
+	assert.NoError(t, err)
+	_, err = filteredFS.Open("go.sum")
+	assert.Error(t, err)
+	_, err = filteredFS.Open("vendor/example.com/lib/lib.pb.go")
+	assert.Error(t, err)
+
+	filteredFS, err = repoFS.Filter("go.sum", "vendor/example.com/lib/*.pb.go")
+	assert.NoError(t, err)
+
+	file, err := filteredFS.Open("go.sum")
+	assert.NoError(t, err)
+	if err == nil {
+		file.Close()
+	}
+
+	file, err = filteredFS.Open("vendor/example.com/lib/lib.pb.go")
+	assert.NoError(t, err)
+	if err == nil {
+		file.Close()
+	}
+
+	visitedPaths := make(map[string]bool)
+	err = fs.WalkDir(filteredFS, ".", func(path string, _ fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		visitedPaths[path] = true
+		return nil
+	})
+	assert.NoError(t, err)
+
+	for _, path := range []string{"main.go", "go.sum", "vendor", "vendor/example.com/lib/lib.pb.go"} {
+		assert.True(t, visitedPaths[path], "Did not visit: "+path)
+	}
+
+	// Other files in ignored directories stay hidden
+	for _, path := range []string{"vendor/example.com/lib/lib.go", "vendor/example.com/other", "vendor/example.com/other/other.go"} {
+		assert.False(t, visitedPaths[path], "Should not have visited: "+path)
+	}
+
+	// Included paths can be written
+	assert.NoError(t, filteredFS.WriteFile("vendor/example.com/lib/lib.pb.go", []byte("package lib\n"), 0644))
+	assert.Error(t, filteredFS.WriteFile("vendor/example.com/lib/lib.go", []byte("package lib\n"), 0644))
+}
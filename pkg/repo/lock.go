@@ -0,0 +1,51 @@
+package repo
+
+import (
+	"path/filepath"
+	"sync"
+)
+
+// pathLocks is a keyed mutex that serializes operations on the same path while letting
+// operations on different paths proceed in parallel
+type pathLocks struct {
+	mu    sync.Mutex
+	locks map[string]*pathLock
+}
+
+type pathLock struct {
+	sync.Mutex
+	waiters int // Number of holders and waiters, the entry is dropped when it reaches zero
+}
+
+func newPathLocks() *pathLocks {
+	return &pathLocks{
+		locks: make(map[string]*pathLock),
+	}
+}
+
+// lock blocks until the named path is available and returns a function that releases it
+func (p *pathLocks) lock(name string) func() {
+	key := filepath.Clean(name)
+
+	p.mu.Lock()
+	l, ok := p.locks[key]
+	if !ok {
+		l = &pathLock{}
+		p.locks[key] = l
+	}
+	l.waiters++
+	p.mu.Unlock()
+
+	l.Lock()
+
+	return func() {
+		l.Unlock()
+
+		p.mu.Lock()
+		l.waiters--
+		if l.waiters == 0 {
+			delete(p.locks, key)
+		}
+		p.mu.Unlock()
+	}
+}
@@ -0,0 +1,108 @@
+package repo
+
+import (
+	"fmt"
+	"io/fs"
+)
+
+// Exclusion is a file or directory hidden by the ignore rules, and the reason it is hidden
+type Exclusion struct {
+	Path  string `json:"path"`
+	IsDir bool   `json:"is_dir"`
+
+	// Rule is the ignore rule that matched the path, or a description of why its content
+	// was excluded
+	Rule string `json:"rule"`
+
+	// Source is where the rule came from: a line of the ignore file, the default rules, or
+	// the file's content
+	Source string `json:"source"`
+}
+
+// IgnorePreview reports what the ignore rules exclude from a repository
+type IgnorePreview struct {
+	// Excluded lists the excluded paths in walk order. The contents of an excluded directory
+	// are not listed.
+	Excluded []Exclusion `json:"excluded"`
+
+	// IncludedFiles is the number of files that are visible, and so would be indexed
+	IncludedFiles int `json:"included_files"`
+}
+
+const (
+	// SourceDefault is the Source of exclusions made by the built-in ignore rules
+	SourceDefault = "default"
+	// SourceContent is the Source of files excluded because they are empty, large or binary
+	SourceContent = "content"
+)
+
+// PreviewIgnore walks the repository and reports which paths the ignore rules exclude, and
+// which rule excludes each one, using the same rules as Filter
+func (r *RepositoryFS) PreviewIgnore(includePaths ...string) (*IgnorePreview, error) {
+	filtered, err := r.Filter(includePaths...)
+	if err != nil {
+		return nil, err
+	}
+	f := filtered.(*filteredFS)
+
+	preview := &IgnorePreview{}
+
+	err = fs.WalkDir(r.ReadDirFS, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if path == "." || f.isIncluded(path) {
+			if !d.IsDir() {
+				preview.IncludedFiles++
+			}
+			return nil
+		}
+
+		matched, pattern := f.gitignore.MatchesPathHow(path)
+		if !matched && d.IsDir() {
+			// Rules like "testdata/" match the directory's contents rather than the directory
+			matched, pattern = f.gitignore.MatchesPathHow(path + "/")
+		}
+
+		if matched && pattern != nil {
+			source := SourceDefault
+			if pattern.LineNo <= f.userRules {
+				source = fmt.Sprintf("%s:%d", IgnoreFileName, pattern.LineNo)
+			}
+
+			preview.Excluded = append(preview.Excluded, Exclusion{
+				Path:   path,
+				IsDir:  d.IsDir(),
+				Rule:   pattern.Line,
+				Source: source,
+			})
+
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		if reason := f.contentExclusion(path); reason != "" {
+			preview.Excluded = append(preview.Excluded, Exclusion{
+				Path:   path,
+				Rule:   reason,
+				Source: SourceContent,
+			})
+			return nil
+		}
+
+		preview.IncludedFiles++
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk repository: %w", err)
+	}
+
+	return preview, nil
+}
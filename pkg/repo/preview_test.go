@@ -0,0 +1,33 @@
+package repo
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreviewIgnore(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	mustCreateFile(t, filepath.Join(tmpDir, IgnoreFileName), "# generated code\n*.pb.go\n\ntestdata/\n")
+	mustCreateFile(t, filepath.Join(tmpDir, "main.go"), "package main")
+	mustCreateFile(t, filepath.Join(tmpDir, "api.pb.go"), "package main")
+	mustCreateFile(t, filepath.Join(tmpDir, "testdata", "input.txt"), "input")
+	mustCreateFile(t, filepath.Join(tmpDir, "vendor", "lib", "lib.go"), "package lib")
+	mustCreateFile(t, filepath.Join(tmpDir, "empty.go"), "")
+
+	preview, err := NewRepoFS(tmpDir).PreviewIgnore()
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []Exclusion{
+		{Path: "api.pb.go", Rule: "*.pb.go", Source: ".autosweignore:2"},
+		{Path: "testdata", IsDir: true, Rule: "testdata/", Source: ".autosweignore:4"},
+		{Path: "vendor", IsDir: true, Rule: "vendor", Source: SourceDefault},
+		{Path: "empty.go", Rule: "empty file", Source: SourceContent},
+	}, preview.Excluded)
+
+	// main.go and the ignore file itself
+	assert.Equal(t, 2, preview.IncludedFiles)
+}
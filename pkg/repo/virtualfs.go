@@ -5,8 +5,13 @@ import (
 	"io"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"time"
+	"unicode/utf8"
+
+	ignore "github.com/sabhiram/go-gitignore"
 )
 
 // VirtualFile represents a file in the VirtualFS
@@ -106,10 +111,15 @@ func (d *VirtualDirEntry) Info() (fs.FileInfo, error) {
 	return d.fileInfo, nil
 }
 
-// VirtualFS implements fs.ReadDirFS and provides a virtual filesystem where all files
-// appear at the root level, regardless of their original location
+// VirtualFS implements fs.ReadDirFS and provides a virtual filesystem of files gathered from
+// anywhere on the real filesystem. Files added by a relative path keep that path, and others
+// are placed at the root under their base name.
 type VirtualFS struct {
 	files map[string]*VirtualFile
+	dirs  map[string]bool
+
+	// sources maps each virtual path to the real path it was read from
+	sources map[string]string
 }
 
 // Ensure VirtualFS implements fs.ReadDirFS and FilteredFS
@@ -118,44 +128,111 @@ var _ fs.ReadDirFS = (*VirtualFS)(nil)
 // NewVirtualFS creates a new virtual filesystem
 func NewVirtualFS() *VirtualFS {
 	return &VirtualFS{
-		files: make(map[string]*VirtualFile),
+		files:   make(map[string]*VirtualFile),
+		dirs:    map[string]bool{".": true},
+		sources: make(map[string]string),
 	}
 }
 
-// AddFile adds a file to the virtual filesystem by reading it from the real filesystem
+// AddFile adds a file to the virtual filesystem by reading it from the real filesystem. If
+// the path is a directory, every text file beneath it is added, keeping its path relative to
+// the directory.
 func (vfs *VirtualFS) AddFile(sourcePath string) error {
-	// Read file content
+	info, err := os.Stat(sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	name := virtualName(sourcePath)
+	if !info.IsDir() {
+		return vfs.addFile(sourcePath, name, info, false)
+	}
+
+	return filepath.WalkDir(sourcePath, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(sourcePath, filePath)
+		if err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("failed to stat file: %w", err)
+		}
+
+		return vfs.addFile(filePath, path.Join(name, filepath.ToSlash(rel)), info, true)
+	})
+}
+
+// addFile reads a file from sourcePath into the virtual filesystem at name. If textOnly is
+// set, the file is skipped unless it is text.
+func (vfs *VirtualFS) addFile(sourcePath, name string, info fs.FileInfo, textOnly bool) error {
+	if existing, ok := vfs.sources[name]; ok && existing != sourcePath {
+		return fmt.Errorf("%s and %s would both be added as %s", existing, sourcePath, name)
+	}
+
 	content, err := os.ReadFile(sourcePath)
 	if err != nil {
 		return fmt.Errorf("failed to read file: %w", err)
 	}
 
-	// Get file info
-	info, err := os.Stat(sourcePath)
-	if err != nil {
-		return fmt.Errorf("failed to stat file: %w", err)
+	if textOnly && !isText(content) {
+		return nil
 	}
 
-	// Add file to virtual filesystem with just the base name
-	baseName := filepath.Base(sourcePath)
-	vfs.files[baseName] = &VirtualFile{
-		name:    baseName,
+	vfs.files[name] = &VirtualFile{
+		name:    path.Base(name),
 		content: content,
 		modTime: info.ModTime(),
 		size:    info.Size(),
 		isDir:   false,
 		offset:  0,
 	}
+	vfs.sources[name] = sourcePath
+
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		vfs.dirs[dir] = true
+	}
 
 	return nil
 }
 
+// virtualName returns where a file or directory added from sourcePath appears in the
+// virtual filesystem. Relative paths within the working directory are kept, so that files
+// with the same name in different directories don't collide.
+func virtualName(sourcePath string) string {
+	cleaned := filepath.Clean(sourcePath)
+	if filepath.IsLocal(cleaned) || cleaned == "." {
+		return filepath.ToSlash(cleaned)
+	}
+	return filepath.Base(cleaned)
+}
+
+// isText reports whether content looks like text, by checking that its first 512 bytes are
+// valid UTF-8
+func isText(content []byte) bool {
+	if len(content) > 512 {
+		content = content[:512]
+	}
+	return utf8.Valid(content)
+}
+
 // Open implements fs.FS
 func (vfs *VirtualFS) Open(name string) (fs.File, error) {
-	// Handle special case of root directory
-	if name == "." {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if vfs.dirs[name] {
 		return &VirtualFile{
-			name:    ".",
+			name:    path.Base(name),
 			content: nil,
 			modTime: time.Now(),
 			size:    0,
@@ -163,9 +240,6 @@ func (vfs *VirtualFS) Open(name string) (fs.File, error) {
 		}, nil
 	}
 
-	// Clean the path to handle "./" prefixes, etc.
-	name = filepath.Clean(name)
-
 	// Check if file exists
 	file, ok := vfs.files[name]
 	if !ok {
@@ -178,33 +252,93 @@ func (vfs *VirtualFS) Open(name string) (fs.File, error) {
 	return &fileCopy, nil
 }
 
-// ReadDir implements fs.ReadDirFS
+// ReadDir implements fs.ReadDirFS, returning the entries of a directory sorted by name
 func (vfs *VirtualFS) ReadDir(name string) ([]fs.DirEntry, error) {
-	// Only support reading the root directory
-	if name != "." {
+	if !vfs.dirs[name] {
 		return nil, fs.ErrNotExist
 	}
 
-	entries := make([]fs.DirEntry, 0, len(vfs.files))
-	for _, file := range vfs.files {
-		entries = append(entries, &VirtualDirEntry{fileInfo: file})
+	var entries []fs.DirEntry
+	for filePath, file := range vfs.files {
+		if path.Dir(filePath) == name {
+			entries = append(entries, &VirtualDirEntry{fileInfo: file})
+		}
 	}
 
+	for dir := range vfs.dirs {
+		if dir != "." && path.Dir(dir) == name {
+			entries = append(entries, &VirtualDirEntry{fileInfo: &VirtualFile{
+				name:    path.Base(dir),
+				modTime: time.Now(),
+				isDir:   true,
+			}})
+		}
+	}
+
+	sort.Slice(entries, func(a, b int) bool {
+		return entries[a].Name() < entries[b].Name()
+	})
+
 	return entries, nil
 }
 
-// Filter returns a FilteredFS implementation for the virtual filesystem
+// Filter returns a FilteredFS implementation for the virtual filesystem, which hides files
+// the same way as the repository's: those matching the default ignore rules, and those that
+// are large, empty or binary
 func (vfs *VirtualFS) Filter() (FilteredFS, error) {
-	return &virtualFilteredFS{VirtualFS: vfs}, nil
+	return &virtualFilteredFS{
+		VirtualFS: vfs,
+		gitignore: ignore.CompileIgnoreLines(defaultIgnoreLines()...),
+	}, nil
 }
 
 // virtualFilteredFS implements FilteredFS for VirtualFS
 type virtualFilteredFS struct {
 	*VirtualFS
+	gitignore *ignore.GitIgnore
 }
 
 func (f *virtualFilteredFS) isFilteredFS() {}
 
+// Open implements fs.FS, hiding ignored files
+func (f *virtualFilteredFS) Open(name string) (fs.File, error) {
+	if f.shouldIgnore(name) {
+		return nil, fs.ErrNotExist
+	}
+
+	return f.VirtualFS.Open(name)
+}
+
+// ReadDir implements fs.ReadDirFS, leaving out ignored entries
+func (f *virtualFilteredFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if f.shouldIgnore(name) {
+		return nil, fs.ErrNotExist
+	}
+
+	entries, err := f.VirtualFS.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var filteredEntries []fs.DirEntry
+	for _, entry := range entries {
+		if !f.shouldIgnore(path.Join(name, entry.Name())) {
+			filteredEntries = append(filteredEntries, entry)
+		}
+	}
+
+	return filteredEntries, nil
+}
+
+// shouldIgnore checks if the given path should be hidden
+func (f *virtualFilteredFS) shouldIgnore(name string) bool {
+	if name == "." {
+		return false
+	}
+
+	return f.gitignore.MatchesPath(name) || contentExclusion(f.VirtualFS, name) != ""
+}
+
 // WriteFile implements FilteredFS.WriteFile
 func (f *virtualFilteredFS) WriteFile(name string, data []byte, perm os.FileMode) error {
 	return fmt.Errorf("write operations not supported on virtual filesystem")
@@ -219,3 +353,24 @@ func (f *virtualFilteredFS) Remove(name string) error {
 func (f *virtualFilteredFS) RemoveAll(name string) error {
 	return fmt.Errorf("remove operations not supported on virtual filesystem")
 }
+
+// Rename implements FilteredFS.Rename
+func (f *virtualFilteredFS) Rename(oldName, newName string) error {
+	return fmt.Errorf("rename operations not supported on virtual filesystem")
+}
+
+// Mkdir implements FilteredFS.Mkdir
+func (f *virtualFilteredFS) Mkdir(name string, perm os.FileMode) error {
+	return fmt.Errorf("mkdir operations not supported on virtual filesystem")
+}
+
+// Lock implements FilteredFS.Lock. The virtual filesystem is read-only, so there is nothing
+// to serialize.
+func (f *virtualFilteredFS) Lock(name string) func() {
+	return func() {}
+}
+
+// MkdirAll implements FilteredFS.MkdirAll
+func (f *virtualFilteredFS) MkdirAll(name string, perm os.FileMode) error {
+	return fmt.Errorf("mkdir operations not supported on virtual filesystem")
+}
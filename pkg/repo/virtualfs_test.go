@@ -0,0 +1,96 @@
+package repo
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVirtualFSDirectories(t *testing.T) {
+	workDir := t.TempDir()
+	mustCreateFile(t, filepath.Join(workDir, "docs", "README.md"), "# Docs")
+	mustCreateFile(t, filepath.Join(workDir, "docs", "design", "README.md"), "# Design")
+	mustCreateFile(t, filepath.Join(workDir, "docs", "logo.png"), "\x89PNG\r\n\x1a\n\xff\xfe")
+	mustCreateFile(t, filepath.Join(workDir, "CONTRIBUTING.md"), "# Contributing")
+
+	outside := filepath.Join(t.TempDir(), "notes.txt")
+	mustCreateFile(t, outside, "notes")
+
+	oldWd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(workDir))
+	defer func() {
+		require.NoError(t, os.Chdir(oldWd))
+	}()
+
+	vfs := NewVirtualFS()
+	require.NoError(t, vfs.AddFile("./docs"))
+	require.NoError(t, vfs.AddFile("CONTRIBUTING.md"))
+	require.NoError(t, vfs.AddFile(outside))
+
+	// Relative paths are kept, so the two READMEs don't collide, and binary files in a
+	// directory are skipped
+	var paths []string
+	require.NoError(t, fs.WalkDir(vfs, ".", func(path string, d fs.DirEntry, err error) error {
+		require.NoError(t, err)
+		if !d.IsDir() {
+			paths = append(paths, path)
+		}
+		return nil
+	}))
+	assert.Equal(t, []string{"CONTRIBUTING.md", "docs/README.md", "docs/design/README.md", "notes.txt"}, paths)
+
+	content, err := fs.ReadFile(vfs, "docs/design/README.md")
+	require.NoError(t, err)
+	assert.Equal(t, "# Design", string(content))
+
+	info, err := fs.Stat(vfs, "docs/design")
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+
+	_, err = vfs.Open("docs/missing.md")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	// Files outside the working directory are added under their base name, and must not clash
+	other := filepath.Join(t.TempDir(), "notes.txt")
+	mustCreateFile(t, other, "other notes")
+	assert.Error(t, vfs.AddFile(other))
+}
+
+func TestVirtualFSFilter(t *testing.T) {
+	dir := t.TempDir()
+	mustCreateFile(t, filepath.Join(dir, "design.md"), "# Design")
+	mustCreateFile(t, filepath.Join(dir, "spec.pdf"), "%PDF-1.7\n\xe2\xe3\xcf\xd3")
+	mustCreateFile(t, filepath.Join(dir, "empty.txt"), "")
+	mustCreateFile(t, filepath.Join(dir, "node_modules", "lib.js"), "module.exports = {}")
+
+	vfs := NewVirtualFS()
+	for _, name := range []string{"design.md", "spec.pdf", "empty.txt", "node_modules"} {
+		require.NoError(t, vfs.AddFile(filepath.Join(dir, name)))
+	}
+
+	filtered, err := vfs.Filter()
+	require.NoError(t, err)
+
+	// The unfiltered filesystem exposes everything that was added
+	_, err = fs.Stat(vfs, "spec.pdf")
+	require.NoError(t, err)
+
+	entries, err := filtered.ReadDir(".")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "design.md", entries[0].Name())
+
+	for _, name := range []string{"spec.pdf", "empty.txt", "node_modules", "node_modules/lib.js"} {
+		_, err := filtered.Open(name)
+		assert.ErrorIs(t, err, fs.ErrNotExist, name)
+	}
+
+	content, err := fs.ReadFile(filtered, "design.md")
+	require.NoError(t, err)
+	assert.Equal(t, "# Design", string(content))
+}
@@ -0,0 +1,124 @@
+// Package retry retries calls to rate limited APIs, such as Gemini, with exponential backoff
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/russellhaering/autoswe/pkg/log"
+	"go.uber.org/zap"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DefaultMaxAttempts is the number of attempts made when Config.MaxAttempts is unset
+	DefaultMaxAttempts = 5
+
+	// DefaultBaseDelay is the longest wait before the first retry when Config.BaseDelay is
+	// unset. The limit doubles with each retry.
+	DefaultBaseDelay = time.Second
+
+	// maxDelay caps the wait between attempts
+	maxDelay = 30 * time.Second
+)
+
+// Config controls how calls are retried
+type Config struct {
+	// MaxAttempts is the most times a call is made, including the first. One disables
+	// retries.
+	// Default: DefaultMaxAttempts
+	MaxAttempts int
+
+	// BaseDelay is the longest wait before the first retry
+	// Default: DefaultBaseDelay
+	BaseDelay time.Duration
+}
+
+// Do calls fn until it succeeds, fails with an error that isn't transient, or has been
+// attempted config.MaxAttempts times. Between attempts it waits a random time up to an
+// exponentially growing limit, returning early if ctx is done.
+func Do[T any](ctx context.Context, config Config, fn func(ctx context.Context) (T, error)) (T, error) {
+	maxAttempts := config.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+
+	limit := config.BaseDelay
+	if limit <= 0 {
+		limit = DefaultBaseDelay
+	}
+
+	for attempt := 1; ; attempt++ {
+		result, err := fn(ctx)
+		if err == nil || attempt == maxAttempts || !IsTransient(err) {
+			return result, err
+		}
+
+		// Full jitter spreads out retries from concurrent callers hitting the same limit
+		delay := rand.N(limit) + 1
+		log.Warn("transient error, retrying",
+			zap.Int("attempt", attempt),
+			zap.Duration("delay", delay),
+			zap.Error(err))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			var zero T
+			return zero, errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+
+		limit = min(limit*2, maxDelay)
+	}
+}
+
+// HTTPError is an error response from an HTTP API without its own error type
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Body)
+}
+
+// IsTransient reports whether err is a rate limit or server error that may succeed if the
+// call is retried
+func IsTransient(err error) bool {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return isTransientStatus(apiErr.Code)
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return isTransientStatus(httpErr.StatusCode)
+	}
+
+	if s, ok := status.FromError(err); ok {
+		switch s.Code() {
+		case codes.ResourceExhausted, codes.Unavailable, codes.Internal, codes.DeadlineExceeded:
+			return true
+		}
+	}
+
+	return false
+}
+
+// isTransientStatus reports whether an HTTP status code is a rate limit or server error
+func isTransientStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
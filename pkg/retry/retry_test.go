@@ -0,0 +1,91 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestDo(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	config := Config{MaxAttempts: 3, BaseDelay: time.Millisecond}
+	rateLimited := &googleapi.Error{Code: http.StatusTooManyRequests}
+
+	t.Run("retries transient errors", func(t *testing.T) {
+		calls := 0
+		result, err := Do(context.Background(), config, func(_ context.Context) (string, error) {
+			calls++
+			if calls < 3 {
+				return "", rateLimited
+			}
+			return "ok", nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "ok", result)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		calls := 0
+		_, err := Do(context.Background(), config, func(_ context.Context) (string, error) {
+			calls++
+			return "", status.Error(codes.Unavailable, "overloaded")
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("doesn't retry other errors", func(t *testing.T) {
+		calls := 0
+		_, err := Do(context.Background(), config, func(_ context.Context) (string, error) {
+			calls++
+			return "", status.Error(codes.InvalidArgument, "bad request")
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("stops waiting when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		calls := 0
+		_, err := Do(ctx, Config{MaxAttempts: 3, BaseDelay: time.Hour}, func(_ context.Context) (string, error) {
+			calls++
+			return "", rateLimited
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&googleapi.Error{Code: http.StatusTooManyRequests}, true},
+		{fmt.Errorf("failed to embed: %w", &googleapi.Error{Code: http.StatusServiceUnavailable}), true},
+		{&googleapi.Error{Code: http.StatusBadRequest}, false},
+		{&HTTPError{StatusCode: http.StatusBadGateway}, true},
+		{&HTTPError{StatusCode: http.StatusUnauthorized}, false},
+		{status.Error(codes.ResourceExhausted, "quota"), true},
+		{status.Error(codes.PermissionDenied, "bad key"), false},
+		{errors.New("no content generated"), false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, IsTransient(tt.err), "IsTransient(%v)", tt.err)
+	}
+}
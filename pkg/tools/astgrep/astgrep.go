@@ -1,7 +1,9 @@
 package astgrep
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
@@ -10,6 +12,7 @@ import (
 	"github.com/google/wire"
 	"github.com/invopop/jsonschema"
 	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/tools/toolerr"
 	"go.uber.org/zap"
 
 	_ "embed"
@@ -20,21 +23,79 @@ var toolDescription string
 
 const astGrepImage = "ghcr.io/russellhaering/ast-grep-container:latest"
 
+// Mode selects how ast-grep is run
+type Mode string
+
+const (
+	// ModeAuto uses a local ast-grep binary if one is on the PATH, and Docker otherwise
+	ModeAuto Mode = "auto"
+	// ModeDocker always runs ast-grep in a Docker container
+	ModeDocker Mode = "docker"
+	// ModeLocal always runs a local ast-grep binary
+	ModeLocal Mode = "local"
+)
+
+// localBinaries are the names a local ast-grep binary may be installed under, in order of preference
+var localBinaries = []string{"ast-grep", "sg"}
+
+// ParseMode parses an ast-grep mode name, returning an error for unknown modes
+func ParseMode(name string) (Mode, error) {
+	switch mode := Mode(name); mode {
+	case ModeAuto, ModeDocker, ModeLocal:
+		return mode, nil
+	case "":
+		return ModeAuto, nil
+	default:
+		return "", fmt.Errorf("unknown ast-grep mode %q (expected %q, %q or %q)", name, ModeAuto, ModeDocker, ModeLocal)
+	}
+}
+
 // Input represents the input parameters for the ASTGrep tool
 type Input struct {
 	Pattern string   `json:"pattern" jsonschema_description:"ast-grep pattern to search for in the codebase, eg 'func $FUNC($$$ARGS) { $$$ }'"`
 	Lang    string   `json:"lang,omitempty" jsonschema_description:"Language to search in (e.g., 'go', 'rust', 'typescript'). If not specified, will search all supported languages."`
 	Paths   []string `json:"paths,omitempty" jsonschema_description:"Paths to search in (e.g., 'src', 'test'). If not specified, will default to ."`
 	Rewrite string   `json:"rewrite,omitempty" jsonschema_description:"Pattern with which to rewrite matched AST nodes, eg '$PROP?.()'. If unspecified ast-grep will only search for the pattern."`
+	Apply   bool     `json:"apply,omitempty" jsonschema_description:"If true, write the rewrites to disk. Requires rewrite. If false, rewrites are only previewed."`
+	Pretty  bool     `json:"pretty,omitempty" jsonschema_description:"If true, return ast-grep's human readable output instead of structured matches."`
+}
+
+// Position is a location in a file. Lines are 1-based and columns are 0-based.
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Range is the span of a match, from the start of the first character to the end of the last
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Match is a single ast-grep match
+type Match struct {
+	File        string `json:"file"`
+	Range       Range  `json:"range"`
+	MatchedText string `json:"matched_text"`
+	Replacement string `json:"replacement,omitempty"`
 }
 
 // Output represents the output of the ASTGrep tool
 type Output struct {
-	Output string `json:"output"`
+	Output       string   `json:"output,omitempty"`
+	Matches      []Match  `json:"matches,omitempty"`
+	ChangedFiles []string `json:"changed_files,omitempty"`
 }
 
 // Tool implements the ASTGrep tool
-type Tool struct{}
+type Tool struct {
+	// Mode selects how ast-grep is run
+	// Default: ModeAuto
+	Mode Mode
+
+	// lookPath finds executables, and is only replaced in tests
+	lookPath func(file string) (string, error) `wire:"-"`
+}
 
 var ProvideASTGrepTool = wire.Struct(new(Tool), "*")
 
@@ -54,12 +115,12 @@ func (t *Tool) Schema() *jsonschema.Schema {
 }
 
 // Execute implements the astgrep operation
-func (t *Tool) Execute(_ context.Context, input Input) (Output, error) {
+func (t *Tool) Execute(ctx context.Context, input Input) (Output, error) {
 	log.Info("Starting ast-grep operation", zap.String("pattern", input.Pattern))
 
 	if input.Pattern == "" {
 		log.Error("No pattern provided")
-		return Output{}, fmt.Errorf("pattern is required")
+		return Output{}, toolerr.New(toolerr.InvalidInput, "pattern is required")
 	}
 
 	// Get current working directory for mounting
@@ -69,39 +130,219 @@ func (t *Tool) Execute(_ context.Context, input Input) (Output, error) {
 		return Output{}, fmt.Errorf("failed to get working directory: %w", err)
 	}
 
-	// Construct docker run command
-	dockerArgs := []string{
-		"run",
-		"--rm",                                  // Remove container after execution
-		"-t",                                    // We get less verbose output with a TTY
-		"-v", fmt.Sprintf("%s:/workspace", pwd), // Mount current directory
-		"-w", "/workspace", // Set working directory
-		astGrepImage,
-		"ast-grep",
+	mode, binary, err := t.resolveMode()
+	if err != nil {
+		log.Error("Failed to find ast-grep", zap.Error(err))
+		return Output{}, err
+	}
+
+	if input.Apply && input.Rewrite == "" {
+		return Output{}, toolerr.New(toolerr.InvalidInput, "rewrite is required when apply is set")
+	}
+
+	var matches []Match
+	if !input.Pretty || input.Apply {
+		// ast-grep doesn't report which files it rewrites, so find the matches first
+		name, args := buildCommand(mode, binary, pwd, matchArgs(input), false)
+		out, err := t.run(ctx, pwd, name, args)
+		if err != nil {
+			return Output{}, err
+		}
+
+		matches, err = parseMatches(out)
+		if err != nil {
+			log.Error("Failed to parse ast-grep matches", zap.Error(err), zap.String("output", string(out)))
+			return Output{}, fmt.Errorf("failed to parse ast-grep matches: %w", err)
+		}
+	}
+
+	var changedFiles []string
+	if input.Apply {
+		changedFiles = matchedFiles(matches)
+	}
+
+	output := Output{ChangedFiles: changedFiles}
+	if !input.Pretty {
+		output.Matches = matches
+	}
+
+	if input.Pretty || input.Apply {
+		name, args := buildCommand(mode, binary, pwd, runArgs(input), true)
+		out, err := t.run(ctx, pwd, name, args)
+		if err != nil {
+			return Output{}, err
+		}
+
+		if input.Pretty {
+			output.Output = strings.TrimSpace(string(out))
+		}
+	}
+
+	log.Info("AST grep completed successfully",
+		zap.Int("matches", len(matches)),
+		zap.Int("changed_files", len(changedFiles)))
+
+	return output, nil
+}
+
+// run executes an ast-grep command and returns its output
+func (t *Tool) run(ctx context.Context, dir, name string, args []string) ([]byte, error) {
+	log.Debug("Running ast-grep", zap.String("command", name), zap.Strings("args", args))
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		log.Error("AST grep command failed", zap.Error(err), zap.String("output", string(out)))
+		return nil, fmt.Errorf("ast-grep command failed: %w", err)
+	}
+
+	return out, nil
+}
+
+// resolveMode determines whether to run ast-grep locally or in Docker, returning the path to
+// the local binary when running locally
+func (t *Tool) resolveMode() (Mode, string, error) {
+	lookPath := t.lookPath
+	if lookPath == nil {
+		lookPath = exec.LookPath
+	}
+
+	mode := t.Mode
+	if mode == "" {
+		mode = ModeAuto
+	}
+
+	if mode == ModeDocker {
+		return ModeDocker, "", nil
+	}
+
+	for _, name := range localBinaries {
+		if path, err := lookPath(name); err == nil {
+			return ModeLocal, path, nil
+		}
+	}
+
+	if mode == ModeLocal {
+		return "", "", fmt.Errorf("ast-grep mode is %q but no %s binary was found on the PATH", ModeLocal, strings.Join(localBinaries, " or "))
+	}
+
+	return ModeDocker, "", nil
+}
+
+// runArgs builds the ast-grep arguments to search for the pattern, previewing or applying any rewrite
+func runArgs(input Input) []string {
+	args := []string{
 		"run",
 		"--pattern", input.Pattern,
 	}
 
+	if input.Rewrite != "" {
+		args = append(args, "--rewrite", input.Rewrite)
+		if input.Apply {
+			args = append(args, "--update-all")
+		}
+	}
+
 	// Add language filter if specified
 	if input.Lang != "" {
-		dockerArgs = append(dockerArgs, "--lang", input.Lang)
+		args = append(args, "--lang", input.Lang)
 	}
 
-	if len(input.Paths) > 0 {
-		dockerArgs = append(dockerArgs, input.Paths...)
+	return append(args, input.Paths...)
+}
+
+// matchArgs builds the ast-grep arguments to list the matches for the pattern as JSON,
+// without applying any rewrite
+func matchArgs(input Input) []string {
+	preview := input
+	preview.Apply = false
+
+	return append([]string{"run", "--json=stream"}, runArgs(preview)[1:]...)
+}
+
+// astGrepPosition is a zero-based position in ast-grep's JSON output
+type astGrepPosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// position converts the position to a Position with a 1-based line
+func (p astGrepPosition) position() Position {
+	return Position{Line: p.Line + 1, Column: p.Column}
+}
+
+// parseMatches parses ast-grep's streamed JSON output
+func parseMatches(out []byte) ([]Match, error) {
+	var matches []Match
+
+	decoder := json.NewDecoder(bytes.NewReader(out))
+	for decoder.More() {
+		var match struct {
+			File        string `json:"file"`
+			Text        string `json:"text"`
+			Replacement string `json:"replacement"`
+			Range       struct {
+				Start astGrepPosition `json:"start"`
+				End   astGrepPosition `json:"end"`
+			} `json:"range"`
+		}
+		if err := decoder.Decode(&match); err != nil {
+			return nil, err
+		}
+
+		matches = append(matches, Match{
+			File: match.File,
+			Range: Range{
+				Start: match.Range.Start.position(),
+				End:   match.Range.End.position(),
+			},
+			MatchedText: match.Text,
+			Replacement: match.Replacement,
+		})
 	}
 
-	// Execute docker command
-	cmd := exec.Command("docker", dockerArgs...)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		log.Error("AST grep command failed", zap.Error(err), zap.String("output", string(out)))
-		return Output{}, fmt.Errorf("ast-grep command failed: %w", err)
+	return matches, nil
+}
+
+// matchedFiles returns the distinct files containing matches, in order
+func matchedFiles(matches []Match) []string {
+	var files []string
+	seen := make(map[string]bool)
+
+	for _, match := range matches {
+		if match.File != "" && !seen[match.File] {
+			seen[match.File] = true
+			files = append(files, match.File)
+		}
+	}
+
+	return files
+}
+
+// buildCommand builds the command to run ast-grep with the given arguments. For ModeLocal,
+// binary is the path to the ast-grep executable. For ModeDocker, workDir is mounted into the
+// container, and tty allocates a TTY for less verbose human readable output. The TTY must not
+// be used for JSON output, which it would corrupt.
+func buildCommand(mode Mode, binary, workDir string, astGrepArgs []string, tty bool) (string, []string) {
+	if mode == ModeLocal {
+		return binary, astGrepArgs
 	}
 
-	log.Info("AST grep completed successfully")
+	// Construct docker run command
+	dockerArgs := []string{
+		"run",
+		"--rm", // Remove container after execution
+	}
+	if tty {
+		dockerArgs = append(dockerArgs, "-t")
+	}
+	dockerArgs = append(dockerArgs,
+		"-v", fmt.Sprintf("%s:/workspace", workDir), // Mount working directory
+		"-w", "/workspace", // Set working directory
+		astGrepImage,
+		"ast-grep",
+	)
 
-	return Output{
-		Output: strings.TrimSpace(string(out)),
-	}, nil
+	return "docker", append(dockerArgs, astGrepArgs...)
 }
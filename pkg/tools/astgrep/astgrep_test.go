@@ -0,0 +1,169 @@
+package astgrep
+
+import (
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+func TestBuildCommand(t *testing.T) {
+	args := []string{"run", "--pattern", "foo()"}
+
+	name, got := buildCommand(ModeLocal, "/usr/local/bin/sg", "/repo", args, true)
+	if name != "/usr/local/bin/sg" {
+		t.Errorf("local command = %q, want %q", name, "/usr/local/bin/sg")
+	}
+	if !reflect.DeepEqual(got, args) {
+		t.Errorf("local args = %v, want %v", got, args)
+	}
+
+	name, got = buildCommand(ModeDocker, "", "/repo", args, true)
+	if name != "docker" {
+		t.Errorf("docker command = %q, want %q", name, "docker")
+	}
+	want := []string{
+		"run", "--rm", "-t", "-v", "/repo:/workspace", "-w", "/workspace", astGrepImage, "ast-grep",
+		"run", "--pattern", "foo()",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("docker args = %v, want %v", got, want)
+	}
+
+	_, got = buildCommand(ModeDocker, "", "/repo", args, false)
+	want = []string{
+		"run", "--rm", "-v", "/repo:/workspace", "-w", "/workspace", astGrepImage, "ast-grep",
+		"run", "--pattern", "foo()",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("docker args without TTY = %v, want %v", got, want)
+	}
+}
+
+func TestRunArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     Input
+		wantRun   []string
+		wantMatch []string
+	}{
+		{
+			name:      "search",
+			input:     Input{Pattern: "func $FUNC() { $$$ }", Lang: "go", Paths: []string{"pkg", "cmd"}},
+			wantRun:   []string{"run", "--pattern", "func $FUNC() { $$$ }", "--lang", "go", "pkg", "cmd"},
+			wantMatch: []string{"run", "--json=stream", "--pattern", "func $FUNC() { $$$ }", "--lang", "go", "pkg", "cmd"},
+		},
+		{
+			name:      "preview rewrite",
+			input:     Input{Pattern: "$A == nil", Rewrite: "$A.IsNil()"},
+			wantRun:   []string{"run", "--pattern", "$A == nil", "--rewrite", "$A.IsNil()"},
+			wantMatch: []string{"run", "--json=stream", "--pattern", "$A == nil", "--rewrite", "$A.IsNil()"},
+		},
+		{
+			name:      "apply rewrite",
+			input:     Input{Pattern: "$A == nil", Rewrite: "$A.IsNil()", Lang: "go", Apply: true},
+			wantRun:   []string{"run", "--pattern", "$A == nil", "--rewrite", "$A.IsNil()", "--update-all", "--lang", "go"},
+			wantMatch: []string{"run", "--json=stream", "--pattern", "$A == nil", "--rewrite", "$A.IsNil()", "--lang", "go"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runArgs(tt.input); !reflect.DeepEqual(got, tt.wantRun) {
+				t.Errorf("runArgs() = %v, want %v", got, tt.wantRun)
+			}
+			if got := matchArgs(tt.input); !reflect.DeepEqual(got, tt.wantMatch) {
+				t.Errorf("matchArgs() = %v, want %v", got, tt.wantMatch)
+			}
+
+			for _, mode := range []Mode{ModeLocal, ModeDocker} {
+				_, args := buildCommand(mode, "ast-grep", "/repo", runArgs(tt.input), true)
+				if got := args[len(args)-len(tt.wantRun):]; !reflect.DeepEqual(got, tt.wantRun) {
+					t.Errorf("buildCommand(%s) ends with %v, want %v", mode, got, tt.wantRun)
+				}
+			}
+		})
+	}
+}
+
+func TestParseMatches(t *testing.T) {
+	out := []byte(`{"text":"x == nil","range":{"byteOffset":{"start":10,"end":18},"start":{"line":2,"column":4},"end":{"line":2,"column":12}},"file":"a.go","lines":"\tif x == nil {","replacement":"x.IsNil()","language":"Go"}` + "\r\n" +
+		`{"text":"y == nil","range":{"start":{"line":0,"column":0},"end":{"line":1,"column":3}},"file":"b.go"}` + "\n" +
+		`{"text":"z == nil","range":{"start":{"line":7,"column":1},"end":{"line":7,"column":9}},"file":"a.go"}` + "\n")
+
+	matches, err := parseMatches(out)
+	if err != nil {
+		t.Fatalf("parseMatches() error = %v", err)
+	}
+
+	want := []Match{
+		{File: "a.go", Range: Range{Start: Position{Line: 3, Column: 4}, End: Position{Line: 3, Column: 12}}, MatchedText: "x == nil", Replacement: "x.IsNil()"},
+		{File: "b.go", Range: Range{Start: Position{Line: 1, Column: 0}, End: Position{Line: 2, Column: 3}}, MatchedText: "y == nil"},
+		{File: "a.go", Range: Range{Start: Position{Line: 8, Column: 1}, End: Position{Line: 8, Column: 9}}, MatchedText: "z == nil"},
+	}
+	if !reflect.DeepEqual(matches, want) {
+		t.Errorf("parseMatches() = %+v, want %+v", matches, want)
+	}
+
+	if files := matchedFiles(matches); !reflect.DeepEqual(files, []string{"a.go", "b.go"}) {
+		t.Errorf("matchedFiles() = %v, want [a.go b.go]", files)
+	}
+
+	if matches, err := parseMatches(nil); err != nil || len(matches) != 0 {
+		t.Errorf("parseMatches(nil) = (%v, %v), want no matches", matches, err)
+	}
+
+	if _, err := parseMatches([]byte("Error: not json")); err == nil {
+		t.Error("parseMatches() expected an error for text output")
+	}
+}
+
+func TestResolveMode(t *testing.T) {
+	found := func(paths map[string]string) func(string) (string, error) {
+		return func(name string) (string, error) {
+			if path, ok := paths[name]; ok {
+				return path, nil
+			}
+			return "", exec.ErrNotFound
+		}
+	}
+
+	tests := []struct {
+		name       string
+		mode       Mode
+		binaries   map[string]string
+		wantMode   Mode
+		wantBinary string
+		wantErr    bool
+	}{
+		{name: "auto prefers ast-grep", mode: ModeAuto, binaries: map[string]string{"ast-grep": "/bin/ast-grep", "sg": "/bin/sg"}, wantMode: ModeLocal, wantBinary: "/bin/ast-grep"},
+		{name: "auto falls back to sg", mode: ModeAuto, binaries: map[string]string{"sg": "/bin/sg"}, wantMode: ModeLocal, wantBinary: "/bin/sg"},
+		{name: "auto falls back to docker", mode: ModeAuto, wantMode: ModeDocker},
+		{name: "empty mode is auto", binaries: map[string]string{"sg": "/bin/sg"}, wantMode: ModeLocal, wantBinary: "/bin/sg"},
+		{name: "docker ignores local binary", mode: ModeDocker, binaries: map[string]string{"ast-grep": "/bin/ast-grep"}, wantMode: ModeDocker},
+		{name: "local requires binary", mode: ModeLocal, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tool := &Tool{Mode: tt.mode, lookPath: found(tt.binaries)}
+
+			mode, binary, err := tool.resolveMode()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveMode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if mode != tt.wantMode || binary != tt.wantBinary {
+				t.Errorf("resolveMode() = (%q, %q), want (%q, %q)", mode, binary, tt.wantMode, tt.wantBinary)
+			}
+		})
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	if mode, err := ParseMode(""); err != nil || mode != ModeAuto {
+		t.Errorf("ParseMode(\"\") = (%q, %v), want (%q, nil)", mode, err, ModeAuto)
+	}
+
+	if _, err := ParseMode("podman"); err == nil {
+		t.Errorf("ParseMode(\"podman\") should fail")
+	}
+}
@@ -42,10 +42,10 @@ func (t *Tool) Schema() *jsonschema.Schema {
 }
 
 // Execute implements the build operation
-func (t *Tool) Execute(_ context.Context, _ Input) (Output, error) {
+func (t *Tool) Execute(ctx context.Context, _ Input) (Output, error) {
 	log.Info("Starting build operation")
 
-	cmd := exec.Command("go", "build", "./...")
+	cmd := exec.CommandContext(ctx, "go", "build", "./...")
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		log.Error("Build failed", zap.Error(err), zap.String("output", string(out)))
@@ -2,6 +2,8 @@ package exec
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"os/exec"
@@ -10,6 +12,7 @@ import (
 	"github.com/google/wire"
 	"github.com/invopop/jsonschema"
 	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/tools/toolerr"
 	"go.uber.org/zap"
 )
 
@@ -20,6 +23,7 @@ const (
 // Input represents the input for the Exec tool
 type Input struct {
 	Command []string `json:"command" jsonschema_description:"The command to execute."`
+	Shell   bool     `json:"shell,omitempty" jsonschema_description:"Run the command as a bash script, allowing pipes (|), chaining (&&, ;) and redirection. The command elements are joined with spaces to form the script."`
 }
 
 // Output represents the output of the Exec tool
@@ -39,7 +43,7 @@ func (t *Tool) Name() string {
 
 // Description returns a description of the exec tool
 func (t *Tool) Description() string {
-	return fmt.Sprintf("Executes a shell command with the project as the working directory. Commands are executed in a container running the '%s' Docker image with a bash shell.", DockerImage)
+	return fmt.Sprintf("Executes a shell command with the project as the working directory. Commands are executed in a container running the '%s' Docker image with a bash shell. By default the command is run directly as an argument list; set shell=true to run it with `bash -c` so that pipelines and chained commands work in a single call.", DockerImage)
 }
 
 // Schema returns the JSON schema for the exec tool
@@ -48,12 +52,15 @@ func (t *Tool) Schema() *jsonschema.Schema {
 }
 
 // Execute implements the exec operation
-func (t *Tool) Execute(_ context.Context, input Input) (Output, error) {
-	log.Info("Starting exec operation", zap.Strings("command", input.Command))
+func (t *Tool) Execute(ctx context.Context, input Input) (Output, error) {
+	log.Info("Starting exec operation",
+		zap.Strings("command", input.Command),
+		zap.Bool("shell", input.Shell))
 
-	if len(input.Command) == 0 {
-		log.Error("No command provided")
-		return Output{}, fmt.Errorf("no command provided")
+	command, err := containerCommand(input)
+	if err != nil {
+		log.Error("Invalid command", zap.Error(err))
+		return Output{}, err
 	}
 
 	// Get current working directory for mounting
@@ -63,19 +70,39 @@ func (t *Tool) Execute(_ context.Context, input Input) (Output, error) {
 		return Output{}, fmt.Errorf("failed to get working directory: %w", err)
 	}
 
+	// Name the container so that it can be stopped if the context is cancelled
+	containerName, err := newContainerName()
+	if err != nil {
+		return Output{}, err
+	}
+
 	// Construct docker run command
 	dockerArgs := []string{
 		"run",
-		"--rm",                                  // Remove container after execution
+		"--rm",                  // Remove container after execution
+		"--name", containerName, // Name the container so it can be killed
 		"-v", fmt.Sprintf("%s:/workspace", pwd), // Mount current directory
 		"-w", "/workspace", // Set working directory
 		DockerImage, // Use the configured image
 	}
-	dockerArgs = append(dockerArgs, input.Command...)
+	dockerArgs = append(dockerArgs, command...)
+
+	// Execute docker command. Killing the docker client doesn't stop the container, so on
+	// cancellation the container is killed too.
+	cmd := exec.CommandContext(ctx, "docker", dockerArgs...)
+	cmd.Cancel = func() error {
+		if err := exec.Command("docker", "kill", containerName).Run(); err != nil {
+			log.Warn("Failed to kill container", zap.String("container", containerName), zap.Error(err))
+		}
+		return cmd.Process.Kill()
+	}
 
-	// Execute docker command
-	cmd := exec.Command("docker", dockerArgs...)
 	out, err := cmd.CombinedOutput()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		log.Warn("Command cancelled", zap.Error(ctxErr), zap.String("output", string(out)))
+		return Output{}, fmt.Errorf("command cancelled: %w", ctxErr)
+	}
+
 	if err != nil {
 		log.Error("Command failed", zap.Error(err), zap.String("output", string(out)))
 
@@ -99,3 +126,32 @@ func (t *Tool) Execute(_ context.Context, input Input) (Output, error) {
 		Output: strings.TrimSpace(string(out)),
 	}, nil
 }
+
+// newContainerName returns a unique name for an exec container
+func newContainerName() (string, error) {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate container name: %w", err)
+	}
+
+	return "autoswe-exec-" + hex.EncodeToString(suffix), nil
+}
+
+// containerCommand returns the command to run inside the container. In shell mode the
+// command is joined into a single script and run with bash, otherwise it is run as is.
+func containerCommand(input Input) ([]string, error) {
+	if len(input.Command) == 0 {
+		return nil, toolerr.New(toolerr.InvalidInput, "no command provided")
+	}
+
+	if !input.Shell {
+		return input.Command, nil
+	}
+
+	script := strings.TrimSpace(strings.Join(input.Command, " "))
+	if script == "" {
+		return nil, toolerr.New(toolerr.InvalidInput, "no command provided")
+	}
+
+	return []string{"bash", "-c", script}, nil
+}
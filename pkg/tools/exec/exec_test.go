@@ -0,0 +1,42 @@
+package exec
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerCommand(t *testing.T) {
+	command, err := containerCommand(Input{Command: []string{"go", "list", "./..."}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"go", "list", "./..."}, command)
+
+	command, err = containerCommand(Input{Command: []string{"go list ./...", "|", "grep tools"}, Shell: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bash", "-c", "go list ./... | grep tools"}, command)
+
+	_, err = containerCommand(Input{})
+	assert.Error(t, err)
+
+	_, err = containerCommand(Input{Command: []string{" "}, Shell: true})
+	assert.Error(t, err)
+}
+
+func TestContainerCommandShellPipeline(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+
+	command, err := containerCommand(Input{
+		Command: []string{"printf 'alpha\\nbeta\\ngamma\\n' | grep -v beta && echo done"},
+		Shell:   true,
+	})
+	require.NoError(t, err)
+
+	// Run the same command the container would, but locally
+	out, err := exec.Command(command[0], command[1:]...).CombinedOutput()
+	require.NoError(t, err)
+	assert.Equal(t, "alpha\ngamma\ndone\n", string(out))
+}
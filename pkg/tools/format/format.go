@@ -42,10 +42,10 @@ func (t *Tool) Schema() *jsonschema.Schema {
 }
 
 // Execute implements the format operation
-func (t *Tool) Execute(_ context.Context, _ Input) (Output, error) {
+func (t *Tool) Execute(ctx context.Context, _ Input) (Output, error) {
 	log.Info("Starting format operation")
 
-	cmd := exec.Command("goimports", "-w", ".")
+	cmd := exec.CommandContext(ctx, "goimports", "-w", ".")
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		log.Error("Formatting failed", zap.Error(err), zap.String("output", string(out)))
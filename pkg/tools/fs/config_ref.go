@@ -0,0 +1,185 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/wire"
+	"github.com/invopop/jsonschema"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/russellhaering/autoswe/pkg/tools/toolerr"
+	"go.uber.org/zap"
+
+	_ "embed"
+)
+
+//go:embed config_ref.md
+var configRefToolDescription string
+
+// ConfigRefInput represents the parameters for the config reference search
+type ConfigRefInput struct {
+	Key  string `json:"key" jsonschema_description:"Environment variable or config key to search for"`
+	Path string `json:"path,omitempty" jsonschema_description:"Optional path to limit the search scope (defaults to .)"`
+}
+
+// ConfigRefMatch is a single line referencing the key
+type ConfigRefMatch struct {
+	Line    int    `json:"line"`
+	Kind    string `json:"kind"`
+	Content string `json:"content"`
+}
+
+// ConfigRefFile groups the references found in a single file
+type ConfigRefFile struct {
+	Path    string           `json:"path"`
+	Matches []ConfigRefMatch `json:"matches"`
+}
+
+// ConfigRefOutput represents the results of the config reference search
+type ConfigRefOutput struct {
+	Key   string          `json:"key"`
+	Files []ConfigRefFile `json:"files"`
+}
+
+// ConfigRefTool finds where an environment variable or config key is read and set
+type ConfigRefTool struct {
+	FilteredFS repo.FilteredFS
+}
+
+var ProvideConfigRefTool = wire.Struct(new(ConfigRefTool), "*")
+
+// configRefPattern is a language-aware pattern for a reference to a config key. The format
+// string receives the quoted key.
+type configRefPattern struct {
+	kind   string
+	format string
+}
+
+var configRefPatterns = []configRefPattern{
+	// Go, Python, Node, Ruby and Rust environment lookups
+	{kind: "env", format: `(Getenv|LookupEnv|Setenv|Unsetenv|getenv|environ\.get|environ\[|env::var|ENV\[|ENV\.fetch)\(?\s*["'\x60]%s["'\x60]`},
+	{kind: "env", format: `process\.env(\.%[1]s\b|\[["'\x60]%[1]s["'\x60]\])`},
+	// Shell and Makefile expansion
+	{kind: "env", format: `\$\{?%s\b`},
+	// .env files and shell exports
+	{kind: "dotenv", format: `^\s*(export\s+)?%s=`},
+	// Struct tags such as json, yaml, toml, env and mapstructure
+	{kind: "struct_tag", format: `\w+:"%s[",]`},
+	// YAML, TOML and INI keys
+	{kind: "config", format: `^\s*(-\s*)?["']?%s["']?\s*[:=]`},
+	// JSON keys
+	{kind: "config", format: `"%s"\s*:`},
+}
+
+// Name returns the name of the tool
+func (t *ConfigRefTool) Name() string {
+	return "find_config_ref"
+}
+
+// Description returns a description of the config reference tool
+func (t *ConfigRefTool) Description() string {
+	return configRefToolDescription
+}
+
+// Schema returns the JSON schema for the config reference tool
+func (t *ConfigRefTool) Schema() *jsonschema.Schema {
+	return jsonschema.Reflect(&ConfigRefInput{})
+}
+
+// Execute implements the config reference search
+func (t *ConfigRefTool) Execute(_ context.Context, input ConfigRefInput) (ConfigRefOutput, error) {
+	log.Info("Starting config reference search", zap.String("key", input.Key))
+
+	if strings.TrimSpace(input.Key) == "" {
+		log.Error("Key is required")
+		return ConfigRefOutput{}, toolerr.New(toolerr.InvalidInput, "key is required")
+	}
+
+	searchPath := "."
+	if input.Path != "" {
+		searchPath = input.Path
+	}
+
+	kinds, combined, err := compileConfigRefPatterns(input.Key)
+	if err != nil {
+		return ConfigRefOutput{}, err
+	}
+
+	matches, _, err := grepFS(t.FilteredFS, searchPath, combined, grepOptions{})
+	if err != nil {
+		return ConfigRefOutput{}, err
+	}
+
+	output := ConfigRefOutput{
+		Key: input.Key,
+	}
+
+	fileIndex := make(map[string]int)
+	for _, match := range matches {
+		idx, ok := fileIndex[match.File]
+		if !ok {
+			idx = len(output.Files)
+			fileIndex[match.File] = idx
+			output.Files = append(output.Files, ConfigRefFile{Path: match.File})
+		}
+
+		output.Files[idx].Matches = append(output.Files[idx].Matches, ConfigRefMatch{
+			Line:    match.Line,
+			Kind:    classifyConfigRef(kinds, match.Content),
+			Content: match.Content,
+		})
+	}
+
+	log.Info("Config reference search completed",
+		zap.String("key", input.Key),
+		zap.Int("files", len(output.Files)),
+		zap.Int("matches", len(matches)))
+
+	return output, nil
+}
+
+type compiledConfigRefPattern struct {
+	kind string
+	re   *regexp.Regexp
+}
+
+// compileConfigRefPatterns compiles each pattern for the key, along with a single combined
+// pattern used to search files
+func compileConfigRefPatterns(key string) ([]compiledConfigRefPattern, *regexp.Regexp, error) {
+	quoted := regexp.QuoteMeta(key)
+
+	compiled := make([]compiledConfigRefPattern, 0, len(configRefPatterns))
+	alternatives := make([]string, 0, len(configRefPatterns))
+	for _, pattern := range configRefPatterns {
+		expr := fmt.Sprintf(pattern.format, quoted)
+
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to compile pattern for key %q: %w", key, err)
+		}
+
+		compiled = append(compiled, compiledConfigRefPattern{kind: pattern.kind, re: re})
+		alternatives = append(alternatives, "(?:"+expr+")")
+	}
+
+	combined, err := regexp.Compile(strings.Join(alternatives, "|"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to compile patterns for key %q: %w", key, err)
+	}
+
+	return compiled, combined, nil
+}
+
+// classifyConfigRef returns the kind of the first pattern matching the line
+func classifyConfigRef(patterns []compiledConfigRefPattern, line string) string {
+	for _, pattern := range patterns {
+		if pattern.re.MatchString(line) {
+			return pattern.kind
+		}
+	}
+
+	return "unknown"
+}
@@ -0,0 +1,65 @@
+package fs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigRefTool(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "main.go"), []byte(`package main
+
+import "os"
+
+func main() {
+	addr := os.Getenv("LISTEN_ADDR")
+	other := os.Getenv("LISTEN_ADDR_V6")
+	_, _ = addr, other
+}
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "config.yaml"), []byte(`server:
+  LISTEN_ADDR: ":8080"
+  timeout: 30s
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "README.md"), []byte("Nothing to see here\n"), 0644))
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	require.NoError(t, err)
+
+	tool := &ConfigRefTool{FilteredFS: filteredFS}
+	output, err := tool.Execute(context.Background(), ConfigRefInput{Key: "LISTEN_ADDR"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "LISTEN_ADDR", output.Key)
+	assert.ElementsMatch(t, []ConfigRefFile{
+		{
+			Path: "config.yaml",
+			Matches: []ConfigRefMatch{
+				{Line: 2, Kind: "config", Content: `  LISTEN_ADDR: ":8080"`},
+			},
+		},
+		{
+			Path: "main.go",
+			Matches: []ConfigRefMatch{
+				{Line: 6, Kind: "env", Content: `	addr := os.Getenv("LISTEN_ADDR")`},
+			},
+		},
+	}, output.Files)
+}
+
+func TestConfigRefToolRequiresKey(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	tool := &ConfigRefTool{}
+	_, err := tool.Execute(context.Background(), ConfigRefInput{Key: " "})
+	assert.Error(t, err)
+}
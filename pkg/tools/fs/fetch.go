@@ -4,11 +4,13 @@ import (
 	"context"
 	"fmt"
 	iofs "io/fs"
+	"strings"
 
 	"github.com/google/wire"
 	"github.com/invopop/jsonschema"
 	"github.com/russellhaering/autoswe/pkg/log"
 	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/russellhaering/autoswe/pkg/tools/toolerr"
 	"go.uber.org/zap"
 
 	_ "embed"
@@ -17,18 +19,42 @@ import (
 //go:embed fetch.md
 var fetchToolDescription string
 
+// DefaultFetchMaxBytes is the default limit on the content returned by a single fetch
+const DefaultFetchMaxBytes = 100 * 1024
+
+// FetchConfig configures limits on the fetch tool that the LLM can't override
+type FetchConfig struct {
+	// MaxBytes is the most content returned by a single fetch. Longer content is truncated
+	// at a line boundary, and the rest can be read by fetching again from an offset. Zero
+	// disables the limit.
+	MaxBytes int
+}
+
 // FetchInput represents the input parameters for the Fetch tool
 type FetchInput struct {
-	Path string `json:"path" jsonschema_description:"Path to the file to fetch"`
+	Path      string `json:"path" jsonschema_description:"Path to the file to fetch"`
+	StartLine int    `json:"start_line,omitempty" jsonschema_description:"Optional first line (1-based, inclusive) to return"`
+	EndLine   int    `json:"end_line,omitempty" jsonschema_description:"Optional last line (1-based, inclusive) to return"`
+
+	WithLineNumbers bool `json:"with_line_numbers,omitempty" jsonschema_description:"If true, prefix each line with its line number"`
+
+	Offset int `json:"offset,omitempty" jsonschema_description:"Optional byte offset to start reading from, used to read the next page of a truncated file. Can't be combined with start_line or end_line."`
 }
 
 // FetchOutput represents the output of the Fetch tool
 type FetchOutput struct {
-	Content string `json:"content"`
+	Content    string `json:"content"`
+	TotalLines int    `json:"total_lines,omitempty"`
+
+	// TotalBytes is the size of the file, set when only part of it was returned
+	TotalBytes int `json:"total_bytes,omitempty"`
+	// NextOffset is the offset to fetch the rest of a truncated file from
+	NextOffset int `json:"next_offset,omitempty"`
 }
 
 type FetchTool struct {
 	FilteredFS repo.FilteredFS
+	Config     FetchConfig
 }
 
 var ProvideFetchTool = wire.Struct(new(FetchTool), "*")
@@ -50,7 +76,28 @@ func (t *FetchTool) Schema() *jsonschema.Schema {
 
 // Execute implements the fetch operation
 func (t *FetchTool) Execute(_ context.Context, input FetchInput) (FetchOutput, error) {
-	log.Debug("Starting fetch operation", zap.String("path", input.Path))
+	log.Debug("Starting fetch operation",
+		zap.String("path", input.Path),
+		zap.Int("start_line", input.StartLine),
+		zap.Int("end_line", input.EndLine),
+		zap.Int("offset", input.Offset))
+
+	if input.Offset < 0 {
+		return FetchOutput{}, toolerr.New(toolerr.InvalidInput, "offset must not be negative")
+	}
+
+	lineRange := input.StartLine != 0 || input.EndLine != 0
+	if lineRange && input.Offset > 0 {
+		return FetchOutput{}, toolerr.New(toolerr.InvalidInput, "offset can't be combined with start_line or end_line")
+	}
+
+	if input.StartLine < 0 || input.EndLine < 0 {
+		return FetchOutput{}, toolerr.New(toolerr.InvalidInput, "line numbers must be positive")
+	}
+
+	if input.EndLine > 0 && input.StartLine > input.EndLine {
+		return FetchOutput{}, toolerr.New(toolerr.InvalidInput, "start_line %d is after end_line %d", input.StartLine, input.EndLine)
+	}
 
 	// Read the file
 	content, err := iofs.ReadFile(t.FilteredFS, input.Path)
@@ -61,7 +108,106 @@ func (t *FetchTool) Execute(_ context.Context, input FetchInput) (FetchOutput, e
 
 	log.Debug("Successfully read file", zap.String("path", input.Path), zap.Int("bytes", len(content)))
 
-	return FetchOutput{
+	output := FetchOutput{
 		Content: string(content),
-	}, nil
+	}
+
+	firstLine := 1
+	if lineRange {
+		output.Content, output.TotalLines, err = selectLines(output.Content, input.StartLine, input.EndLine)
+		if err != nil {
+			return FetchOutput{}, err
+		}
+
+		firstLine = max(input.StartLine, 1)
+	} else if input.Offset > 0 {
+		if input.Offset >= len(content) {
+			return FetchOutput{}, toolerr.New(toolerr.InvalidInput, "offset %d is past the end of the file, which is %d bytes", input.Offset, len(content))
+		}
+
+		output.Content = output.Content[input.Offset:]
+		output.TotalBytes = len(content)
+		firstLine = 1 + strings.Count(string(content[:input.Offset]), "\n")
+	}
+
+	truncated := t.Config.MaxBytes > 0 && len(output.Content) > t.Config.MaxBytes
+	if truncated {
+		output.Content = truncateAtLine(output.Content, t.Config.MaxBytes)
+		output.TotalBytes = len(content)
+		if !lineRange {
+			output.NextOffset = input.Offset + len(output.Content)
+		}
+
+		log.Debug("Truncated fetched content",
+			zap.String("path", input.Path),
+			zap.Int("bytes", len(output.Content)),
+			zap.Int("total_bytes", len(content)))
+	}
+
+	if input.WithLineNumbers {
+		output.Content = numberLines(output.Content, firstLine)
+	}
+
+	if truncated {
+		if !strings.HasSuffix(output.Content, "\n") {
+			output.Content += "\n"
+		}
+
+		if lineRange {
+			output.Content += fmt.Sprintf("[truncated at %d bytes, request a smaller line range to read the rest]\n", t.Config.MaxBytes)
+		} else {
+			output.Content += fmt.Sprintf("[truncated: returned bytes %d-%d of %d, fetch again with offset=%d to read more]\n",
+				input.Offset, output.NextOffset, len(content), output.NextOffset)
+		}
+	}
+
+	return output, nil
+}
+
+// truncateAtLine returns at most maxBytes of content, ending after the last complete line
+// if there is one
+func truncateAtLine(content string, maxBytes int) string {
+	if idx := strings.LastIndexByte(content[:maxBytes], '\n'); idx >= 0 {
+		return content[:idx+1]
+	}
+
+	return content[:maxBytes]
+}
+
+// numberLines prefixes each line of content with its line number, counting from firstLine
+func numberLines(content string, firstLine int) string {
+	if content == "" {
+		return ""
+	}
+
+	var sb strings.Builder
+	for idx, line := range strings.Split(strings.TrimSuffix(content, "\n"), "\n") {
+		fmt.Fprintf(&sb, "%4d | %s\n", firstLine+idx, line)
+	}
+
+	return sb.String()
+}
+
+// selectLines returns lines start through end (1-based, inclusive) of content along with
+// the number of lines in content. A zero start reads from the first line and a zero end,
+// or one past the last line, reads to the end of the file.
+func selectLines(content string, start, end int) (string, int, error) {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	totalLines := len(lines)
+
+	if start == 0 {
+		start = 1
+	}
+	if end == 0 || end > totalLines {
+		end = totalLines
+	}
+
+	if start > totalLines {
+		return "", totalLines, toolerr.New(toolerr.InvalidInput, "start_line %d is past the end of the file, which has %d lines", start, totalLines)
+	}
+
+	return strings.Join(lines[start-1:end], ""), totalLines, nil
 }
@@ -0,0 +1,122 @@
+package fs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchToolLineRange(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+	content := "line 1\nline 2\nline 3\nline 4\nline 5\n"
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "file.txt"), []byte(content), 0644))
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	require.NoError(t, err)
+
+	tool := &FetchTool{FilteredFS: filteredFS}
+
+	tests := []struct {
+		name       string
+		input      FetchInput
+		want       string
+		totalLines int
+		wantErr    bool
+	}{
+		{name: "whole file", input: FetchInput{}, want: content},
+		{name: "range", input: FetchInput{StartLine: 2, EndLine: 3}, want: "line 2\nline 3\n", totalLines: 5},
+		{name: "single line", input: FetchInput{StartLine: 4, EndLine: 4}, want: "line 4\n", totalLines: 5},
+		{name: "start only", input: FetchInput{StartLine: 4}, want: "line 4\nline 5\n", totalLines: 5},
+		{name: "end only", input: FetchInput{EndLine: 2}, want: "line 1\nline 2\n", totalLines: 5},
+		{name: "end past eof", input: FetchInput{StartLine: 5, EndLine: 100}, want: "line 5\n", totalLines: 5},
+		{name: "start past eof", input: FetchInput{StartLine: 6}, wantErr: true},
+		{name: "reversed", input: FetchInput{StartLine: 3, EndLine: 2}, wantErr: true},
+		{name: "negative", input: FetchInput{StartLine: -1}, wantErr: true},
+		{
+			name:  "line numbers",
+			input: FetchInput{WithLineNumbers: true},
+			want:  "   1 | line 1\n   2 | line 2\n   3 | line 3\n   4 | line 4\n   5 | line 5\n",
+		},
+		{
+			name:       "line numbers with range",
+			input:      FetchInput{StartLine: 3, EndLine: 4, WithLineNumbers: true},
+			want:       "   3 | line 3\n   4 | line 4\n",
+			totalLines: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.input.Path = "file.txt"
+			output, err := tool.Execute(context.Background(), tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, output.Content)
+			assert.Equal(t, tt.totalLines, output.TotalLines)
+		})
+	}
+}
+
+func TestFetchToolPagination(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+	content := "line 1\nline 2\nline 3\nline 4\nline 5\n"
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "file.txt"), []byte(content), 0644))
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	require.NoError(t, err)
+
+	tool := &FetchTool{FilteredFS: filteredFS, Config: FetchConfig{MaxBytes: 20}}
+	fetch := func(input FetchInput) (FetchOutput, error) {
+		input.Path = "file.txt"
+		return tool.Execute(context.Background(), input)
+	}
+
+	// Each page ends on a line boundary and says where the next one starts
+	output, err := fetch(FetchInput{})
+	require.NoError(t, err)
+	assert.Equal(t, "line 1\nline 2\n[truncated: returned bytes 0-14 of 35, fetch again with offset=14 to read more]\n", output.Content)
+	assert.Equal(t, 35, output.TotalBytes)
+	assert.Equal(t, 14, output.NextOffset)
+
+	output, err = fetch(FetchInput{Offset: 14, WithLineNumbers: true})
+	require.NoError(t, err)
+	assert.Equal(t, "   3 | line 3\n   4 | line 4\n[truncated: returned bytes 14-28 of 35, fetch again with offset=28 to read more]\n", output.Content)
+	assert.Equal(t, 28, output.NextOffset)
+
+	output, err = fetch(FetchInput{Offset: 28})
+	require.NoError(t, err)
+	assert.Equal(t, FetchOutput{Content: "line 5\n", TotalBytes: 35}, output)
+
+	// Line ranges are capped too, but can't be paged by offset
+	output, err = fetch(FetchInput{StartLine: 2})
+	require.NoError(t, err)
+	assert.Equal(t, "line 2\nline 3\n[truncated at 20 bytes, request a smaller line range to read the rest]\n", output.Content)
+	assert.Zero(t, output.NextOffset)
+
+	_, err = fetch(FetchInput{Offset: 7, StartLine: 2})
+	assert.Error(t, err)
+	_, err = fetch(FetchInput{Offset: 35})
+	assert.Error(t, err)
+
+	// A line longer than the limit is cut mid-line
+	tool.Config.MaxBytes = 4
+	output, err = fetch(FetchInput{})
+	require.NoError(t, err)
+	assert.Equal(t, 4, output.NextOffset)
+	assert.True(t, strings.HasPrefix(output.Content, "line\n[truncated"), output.Content)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io/fs"
+	"path"
 	"regexp"
 	"strings"
 
@@ -11,6 +12,8 @@ import (
 	"github.com/invopop/jsonschema"
 	"github.com/russellhaering/autoswe/pkg/log"
 	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/russellhaering/autoswe/pkg/tools/git"
+	"github.com/russellhaering/autoswe/pkg/tools/toolerr"
 	"go.uber.org/zap"
 
 	_ "embed"
@@ -19,10 +22,32 @@ import (
 //go:embed grep.md
 var grepToolDescription string
 
+const (
+	// DefaultGrepMaxMatches is the number of matches returned when GrepInput.MaxMatches is unset
+	DefaultGrepMaxMatches = 200
+
+	// DefaultGrepMaxFileSize is the size above which files are skipped by default
+	DefaultGrepMaxFileSize = 1 << 20
+)
+
+// GrepConfig configures limits on the grep tool that the LLM can't override
+type GrepConfig struct {
+	// MaxFileSize is the size in bytes above which files are skipped, to avoid searching
+	// large generated files. Zero disables the limit.
+	MaxFileSize int64
+}
+
 // GrepInput represents the parameters for the grep operation
 type GrepInput struct {
-	Pattern string `json:"pattern" jsonschema_description:"Regular expression pattern to search for"`
-	Path    string `json:"path,omitempty" jsonschema_description:"Optional path to limit the search scope (defaults to .)"`
+	Pattern     string   `json:"pattern" jsonschema_description:"Regular expression pattern to search for"`
+	Path        string   `json:"path,omitempty" jsonschema_description:"Optional path to limit the search scope (defaults to .)"`
+	IgnoreCase  bool     `json:"ignore_case,omitempty" jsonschema_description:"If true, match without regard to case"`
+	Literal     bool     `json:"literal,omitempty" jsonschema_description:"If true, search for the pattern as a literal string rather than a regular expression. Characters like ^, $ and ( have no special meaning."`
+	Include     []string `json:"include,omitempty" jsonschema_description:"Optional globs selecting the files to search, eg '*.go'. Globs containing a slash match the full path, others match the file name."`
+	Exclude     []string `json:"exclude,omitempty" jsonschema_description:"Optional globs of files or directories to skip, eg '*_test.go' or 'vendor'"`
+	MaxMatches  int      `json:"max_matches,omitempty" jsonschema_description:"Maximum number of matches to return (defaults to 200)"`
+	Structured  bool     `json:"structured,omitempty" jsonschema_description:"If true, return the matches as a list of objects with file, line, content, before and after fields instead of a formatted result string"`
+	ChangedOnly bool     `json:"changed_only,omitempty" jsonschema_description:"If true, only search files changed on the current branch, including uncommitted and untracked files"`
 }
 
 // GrepMatch represents a single match found by grep
@@ -34,13 +59,18 @@ type GrepMatch struct {
 	After   []string `json:"after"`
 }
 
-// GrepOutput represents the results of the grep operation
+// GrepOutput represents the results of the grep operation. Result is set by default, and
+// Matches is set instead in structured mode.
 type GrepOutput struct {
-	Result string `json:"result"`
+	Result    string      `json:"result,omitempty"`
+	Matches   []GrepMatch `json:"matches,omitempty"`
+	Truncated bool        `json:"truncated,omitempty"`
 }
 
 type GrepTool struct {
 	FilteredFS repo.FilteredFS
+	RepoFS     *repo.RepositoryFS
+	Config     GrepConfig
 }
 
 var ProvideGrepTool = wire.Struct(new(GrepTool), "*")
@@ -57,51 +87,237 @@ func (t *GrepTool) Description() string {
 
 // Schema returns the JSON schema for the grep tool
 func (t *GrepTool) Schema() *jsonschema.Schema {
-	// Only reflect the input schema - the output is still a JSON object but with a simplified string result
+	// Only reflect the input schema - the output is a JSON object with either a result string or structured matches
 	return jsonschema.Reflect(&GrepInput{})
 }
 
 // Execute implements the grep operation
-func (t *GrepTool) Execute(_ context.Context, input GrepInput) (GrepOutput, error) {
+func (t *GrepTool) Execute(ctx context.Context, input GrepInput) (GrepOutput, error) {
 	log.Info("Starting grep operation", zap.String("pattern", input.Pattern))
 
 	if input.Pattern == "" {
 		log.Error("Pattern is required")
-		return GrepOutput{}, fmt.Errorf("pattern is required")
+		return GrepOutput{}, toolerr.New(toolerr.InvalidInput, "pattern is required")
 	}
 
-	re, err := regexp.Compile(input.Pattern)
+	re, err := compileGrepPattern(input)
 	if err != nil {
 		log.Error("Invalid regex pattern", zap.Error(err))
-		return GrepOutput{}, fmt.Errorf("invalid regex pattern: %w", err)
+		return GrepOutput{}, toolerr.New(toolerr.InvalidInput, "invalid regex pattern: %w", err)
 	}
 
-	var matches []GrepMatch
 	searchPath := "."
 	if input.Path != "" {
 		searchPath = input.Path
 	}
 
+	if input.MaxMatches < 0 {
+		return GrepOutput{}, toolerr.New(toolerr.InvalidInput, "max_matches must not be negative")
+	}
+
+	for _, globs := range [][]string{input.Include, input.Exclude} {
+		if err := validateGlobs(globs); err != nil {
+			return GrepOutput{}, err
+		}
+	}
+
+	maxMatches := input.MaxMatches
+	if maxMatches == 0 {
+		maxMatches = DefaultGrepMaxMatches
+	}
+
+	opts := grepOptions{
+		maxMatches:  maxMatches,
+		maxFileSize: t.Config.MaxFileSize,
+		include:     input.Include,
+		exclude:     input.Exclude,
+	}
+
+	if input.ChangedOnly {
+		changed, err := git.ChangedFiles(ctx, &git.Config{WorkDir: t.RepoFS.Path()}, "")
+		if err != nil {
+			log.Error("Failed to list changed files", zap.Error(err))
+			return GrepOutput{}, err
+		}
+
+		opts.only = make(map[string]bool, len(changed))
+		for _, path := range changed {
+			opts.only[path] = true
+		}
+	}
+
+	matches, truncated, err := grepFS(t.FilteredFS, searchPath, re, opts)
+	if err != nil {
+		return GrepOutput{}, err
+	}
+
+	log.Info("Grep operation completed",
+		zap.Int("matches", len(matches)),
+		zap.Bool("truncated", truncated))
+
+	if input.Structured {
+		return GrepOutput{
+			Matches:   matches,
+			Truncated: truncated,
+		}, nil
+	}
+
+	return GrepOutput{
+		Result:    formatGrepMatches(input.Pattern, matches, truncated),
+		Truncated: truncated,
+	}, nil
+}
+
+// compileGrepPattern compiles the input pattern, quoting it first in literal mode. Anchors
+// in a literal pattern are quoted along with everything else, so they match literally.
+func compileGrepPattern(input GrepInput) (*regexp.Regexp, error) {
+	pattern := input.Pattern
+	if input.Literal {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+
+	if input.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+
+	return regexp.Compile(pattern)
+}
+
+// formatGrepMatches formats matches as a string, with line numbered context around each match
+func formatGrepMatches(pattern string, matches []GrepMatch, truncated bool) string {
+	var sb strings.Builder
+
+	if len(matches) == 0 {
+		sb.WriteString("No matches found for pattern: " + pattern)
+	} else {
+		sb.WriteString(fmt.Sprintf("Found %d matches for pattern: %s\n\n", len(matches), pattern))
+
+		for _, match := range matches {
+			sb.WriteString(fmt.Sprintf("%s:%d\n", match.File, match.Line))
+
+			// Add before context with line numbers
+			for i, line := range match.Before {
+				lineNum := match.Line - len(match.Before) + i
+				sb.WriteString(fmt.Sprintf("  %d: %s\n", lineNum, line))
+			}
+
+			// Add the matched line (highlighted)
+			sb.WriteString(fmt.Sprintf("> %d: %s\n", match.Line, match.Content))
+
+			// Add after context with line numbers
+			for i, line := range match.After {
+				lineNum := match.Line + i + 1
+				sb.WriteString(fmt.Sprintf("  %d: %s\n", lineNum, line))
+			}
+
+			sb.WriteString("\n")
+		}
+	}
+
+	if truncated {
+		sb.WriteString(fmt.Sprintf("Results truncated after %d matches. Narrow the pattern or path to see the rest.\n", len(matches)))
+	}
+
+	return sb.String()
+}
+
+// grepOptions controls which files grepFS searches and how many matches it returns. Zero
+// values disable a limit.
+type grepOptions struct {
+	maxMatches  int
+	maxFileSize int64
+
+	// include and exclude are globs selecting which files are searched
+	include []string
+	exclude []string
+
+	// only restricts the search to these paths, if set
+	only map[string]bool
+}
+
+// matchesGlob reports whether the file path matches any of the globs. Globs containing a
+// slash are matched against the full path, and other globs against the base name.
+func matchesGlob(globs []string, filePath string) bool {
+	for _, glob := range globs {
+		name := path.Base(filePath)
+		if strings.Contains(glob, "/") {
+			name = filePath
+		}
+
+		if matched, _ := path.Match(glob, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateGlobs returns an error if any of the globs is malformed
+func validateGlobs(globs []string) error {
+	for _, glob := range globs {
+		if _, err := path.Match(glob, ""); err != nil {
+			return toolerr.New(toolerr.InvalidInput, "invalid glob %q: %w", glob, err)
+		}
+	}
+
+	return nil
+}
+
+// grepFS searches every file under searchPath for lines matching re, returning each match
+// with surrounding context. The search stops early once opts.maxMatches is reached, in which
+// case truncated is true.
+func grepFS(fsys fs.FS, searchPath string, re *regexp.Regexp, opts grepOptions) (matches []GrepMatch, truncated bool, err error) {
 	// Check if path exists in the filtered FS
-	_, err = fs.Stat(t.FilteredFS, searchPath)
+	_, err = fs.Stat(fsys, searchPath)
 	if err != nil {
 		log.Error("Failed to access path", zap.String("path", searchPath), zap.Error(err))
-		return GrepOutput{}, fmt.Errorf("failed to access path: %w", err)
+		return nil, false, fmt.Errorf("failed to access path: %w", err)
 	}
 
-	err = fs.WalkDir(t.FilteredFS, searchPath, func(path string, d fs.DirEntry, err error) error {
+	err = fs.WalkDir(fsys, searchPath, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			log.Warn("Error accessing path during walk", zap.String("path", path), zap.Error(err))
 			return nil // Continue walking despite errors
 		}
 
+		// Skip excluded files and directories, but never the search root itself
+		if path != searchPath && matchesGlob(opts.exclude, path) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
 		// Skip directories
 		if d.IsDir() {
 			return nil
 		}
 
+		// Only read files matching the include globs
+		if len(opts.include) > 0 && !matchesGlob(opts.include, path) {
+			return nil
+		}
+
+		if opts.only != nil && !opts.only[path] {
+			return nil
+		}
+
+		// Skip files that are too large to search usefully
+		if opts.maxFileSize > 0 {
+			info, err := d.Info()
+			if err != nil {
+				log.Warn("Failed to get info for file", zap.String("path", path), zap.Error(err))
+				return nil
+			}
+
+			if info.Size() > opts.maxFileSize {
+				log.Debug("Skipping large file", zap.String("path", path), zap.Int64("size", info.Size()))
+				return nil
+			}
+		}
+
 		// Read file content
-		content, err := fs.ReadFile(t.FilteredFS, path)
+		content, err := fs.ReadFile(fsys, path)
 		if err != nil {
 			log.Warn("Failed to read file", zap.String("path", path), zap.Error(err))
 			return nil // Skip files we can't read
@@ -111,6 +327,11 @@ func (t *GrepTool) Execute(_ context.Context, input GrepInput) (GrepOutput, erro
 		lines := strings.Split(string(content), "\n")
 		for lineNum, line := range lines {
 			if re.MatchString(line) {
+				if opts.maxMatches > 0 && len(matches) >= opts.maxMatches {
+					truncated = true
+					return fs.SkipAll
+				}
+
 				// Calculate context line ranges
 				const contextLines = 3
 
@@ -150,42 +371,8 @@ func (t *GrepTool) Execute(_ context.Context, input GrepInput) (GrepOutput, erro
 
 	if err != nil {
 		log.Error("Failed to search files", zap.Error(err))
-		return GrepOutput{}, fmt.Errorf("failed to search files: %w", err)
-	}
-
-	log.Info("Grep operation completed", zap.Int("matches", len(matches)))
-
-	// Format the matches as a string
-	var sb strings.Builder
-
-	if len(matches) == 0 {
-		sb.WriteString("No matches found for pattern: " + input.Pattern)
-	} else {
-		sb.WriteString(fmt.Sprintf("Found %d matches for pattern: %s\n\n", len(matches), input.Pattern))
-
-		for _, match := range matches {
-			sb.WriteString(fmt.Sprintf("%s:%d\n", match.File, match.Line))
-
-			// Add before context with line numbers
-			for i, line := range match.Before {
-				lineNum := match.Line - len(match.Before) + i
-				sb.WriteString(fmt.Sprintf("  %d: %s\n", lineNum, line))
-			}
-
-			// Add the matched line (highlighted)
-			sb.WriteString(fmt.Sprintf("> %d: %s\n", match.Line, match.Content))
-
-			// Add after context with line numbers
-			for i, line := range match.After {
-				lineNum := match.Line + i + 1
-				sb.WriteString(fmt.Sprintf("  %d: %s\n", lineNum, line))
-			}
-
-			sb.WriteString("\n")
-		}
+		return nil, false, fmt.Errorf("failed to search files: %w", err)
 	}
 
-	return GrepOutput{
-		Result: sb.String(),
-	}, nil
+	return matches, truncated, nil
 }
@@ -0,0 +1,239 @@
+package fs
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGrepTool(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "main.go"), []byte(`package main
+
+// TODO: handle errors
+func main() {
+	run()
+}
+`), 0644))
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	require.NoError(t, err)
+
+	tool := &GrepTool{FilteredFS: filteredFS}
+
+	output, err := tool.Execute(context.Background(), GrepInput{Pattern: "TODO"})
+	require.NoError(t, err)
+	assert.Nil(t, output.Matches)
+	assert.Contains(t, output.Result, "Found 1 matches for pattern: TODO")
+	assert.Contains(t, output.Result, "> 3: // TODO: handle errors")
+
+	output, err = tool.Execute(context.Background(), GrepInput{Pattern: "TODO", Structured: true})
+	require.NoError(t, err)
+	assert.Empty(t, output.Result)
+	assert.Equal(t, []GrepMatch{{
+		File:    "main.go",
+		Line:    3,
+		Content: "// TODO: handle errors",
+		Before:  []string{"package main", ""},
+		After:   []string{"func main() {", "\trun()", "}"},
+	}}, output.Matches)
+
+	output, err = tool.Execute(context.Background(), GrepInput{Pattern: "FIXME", Structured: true})
+	require.NoError(t, err)
+	assert.Empty(t, output.Matches)
+}
+
+func TestGrepToolLimits(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "a.go"), []byte("match 1\nmatch 2\nmatch 3\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "b.go"), []byte("match 4\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "generated.go"), []byte(strings.Repeat("match\n", 100)), 0644))
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	require.NoError(t, err)
+
+	tool := &GrepTool{FilteredFS: filteredFS, Config: GrepConfig{MaxFileSize: 100}}
+
+	output, err := tool.Execute(context.Background(), GrepInput{Pattern: "match", Structured: true})
+	require.NoError(t, err)
+	assert.False(t, output.Truncated)
+	assert.Len(t, output.Matches, 4, "generated.go should be skipped")
+
+	output, err = tool.Execute(context.Background(), GrepInput{Pattern: "match", MaxMatches: 2})
+	require.NoError(t, err)
+	assert.True(t, output.Truncated)
+	assert.Contains(t, output.Result, "Found 2 matches")
+	assert.Contains(t, output.Result, "Results truncated after 2 matches")
+
+	output, err = tool.Execute(context.Background(), GrepInput{Pattern: "match", MaxMatches: 4})
+	require.NoError(t, err)
+	assert.False(t, output.Truncated, "reaching the cap exactly is not truncation")
+
+	_, err = tool.Execute(context.Background(), GrepInput{Pattern: "match", MaxMatches: -1})
+	assert.Error(t, err)
+}
+
+func TestCompileGrepPattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   GrepInput
+		matches []string
+		misses  []string
+	}{
+		{
+			name:    "regex",
+			input:   GrepInput{Pattern: `foo.Bar\(`},
+			matches: []string{"x := foo.Bar()", "fooxBar("},
+			misses:  []string{"FOO.BAR()"},
+		},
+		{
+			name:    "literal",
+			input:   GrepInput{Pattern: "foo.Bar(", Literal: true},
+			matches: []string{"x := foo.Bar()"},
+			misses:  []string{"fooxBar(", "FOO.BAR()"},
+		},
+		{
+			name:    "ignore case",
+			input:   GrepInput{Pattern: "todo", IgnoreCase: true},
+			matches: []string{"// TODO: fix", "// todo"},
+			misses:  []string{"// FIXME"},
+		},
+		{
+			name:    "literal ignore case",
+			input:   GrepInput{Pattern: "foo.Bar(", Literal: true, IgnoreCase: true},
+			matches: []string{"FOO.BAR()", "foo.bar("},
+			misses:  []string{"fooxBar("},
+		},
+		{
+			name:    "anchored regex",
+			input:   GrepInput{Pattern: "^func"},
+			matches: []string{"func main() {"},
+			misses:  []string{"\tfunc() {}()"},
+		},
+		{
+			name:    "literal anchors match literally",
+			input:   GrepInput{Pattern: "^func$", Literal: true},
+			matches: []string{"s := \"^func$\""},
+			misses:  []string{"func", "func main() {"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			re, err := compileGrepPattern(tt.input)
+			require.NoError(t, err)
+
+			for _, line := range tt.matches {
+				assert.True(t, re.MatchString(line), "expected %q to match", line)
+			}
+			for _, line := range tt.misses {
+				assert.False(t, re.MatchString(line), "expected %q not to match", line)
+			}
+		})
+	}
+
+	_, err := compileGrepPattern(GrepInput{Pattern: "foo("})
+	assert.Error(t, err, "unbalanced parentheses are invalid as a regex")
+
+	_, err = compileGrepPattern(GrepInput{Pattern: "foo(", Literal: true})
+	assert.NoError(t, err, "but valid as a literal")
+}
+
+func TestGrepToolGlobs(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+	for _, name := range []string{"main.go", "main_test.go", "README.md", "pkg/util.go", "pkg/util_test.go", "third_party/lib.go"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(rootDir, filepath.Dir(name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(rootDir, name), []byte("needle\n"), 0644))
+	}
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	require.NoError(t, err)
+
+	tool := &GrepTool{FilteredFS: filteredFS}
+
+	files := func(input GrepInput) []string {
+		input.Pattern = "needle"
+		input.Structured = true
+
+		output, err := tool.Execute(context.Background(), input)
+		require.NoError(t, err)
+
+		var files []string
+		for _, match := range output.Matches {
+			files = append(files, match.File)
+		}
+		return files
+	}
+
+	assert.ElementsMatch(t, []string{"main.go", "main_test.go", "pkg/util.go", "pkg/util_test.go", "third_party/lib.go"},
+		files(GrepInput{Include: []string{"*.go"}}))
+	assert.ElementsMatch(t, []string{"main_test.go", "pkg/util_test.go"},
+		files(GrepInput{Include: []string{"*_test.go"}}))
+	assert.ElementsMatch(t, []string{"main.go", "pkg/util.go"},
+		files(GrepInput{Include: []string{"*.go"}, Exclude: []string{"*_test.go", "third_party"}}))
+	assert.ElementsMatch(t, []string{"pkg/util.go", "pkg/util_test.go"},
+		files(GrepInput{Include: []string{"pkg/*.go"}}))
+	assert.ElementsMatch(t, []string{"pkg/util.go", "pkg/util_test.go"},
+		files(GrepInput{Path: "pkg", Exclude: []string{"pkg"}}), "the search root is never excluded")
+
+	_, err = tool.Execute(context.Background(), GrepInput{Pattern: "needle", Include: []string{"[.go"}})
+	assert.Error(t, err)
+}
+
+func TestGrepToolChangedOnly(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+	runGit := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=Test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = rootDir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+
+	runGit("init", "-q", "-b", "main")
+	for _, name := range []string{"changed.go", "unchanged.go"} {
+		require.NoError(t, os.WriteFile(filepath.Join(rootDir, name), []byte("package main\n\n// needle\n"), 0644))
+	}
+	runGit("add", ".")
+	runGit("commit", "-q", "-m", "Initial commit")
+
+	// Change one file on a branch, and add an untracked file
+	runGit("checkout", "-q", "-b", "feature")
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "changed.go"), []byte("package main\n\n// needle, changed\n"), 0644))
+	runGit("commit", "-q", "-am", "Change a file")
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "new.go"), []byte("package main\n\n// needle\n"), 0644))
+
+	repoFS := repo.NewRepoFS(rootDir)
+	filteredFS, err := repoFS.Filter()
+	require.NoError(t, err)
+
+	tool := &GrepTool{FilteredFS: filteredFS, RepoFS: repoFS}
+
+	output, err := tool.Execute(context.Background(), GrepInput{Pattern: "needle", Structured: true})
+	require.NoError(t, err)
+	assert.Len(t, output.Matches, 3)
+
+	output, err = tool.Execute(context.Background(), GrepInput{Pattern: "needle", Structured: true, ChangedOnly: true})
+	require.NoError(t, err)
+
+	var files []string
+	for _, match := range output.Matches {
+		files = append(files, match.File)
+	}
+	assert.Equal(t, []string{"changed.go", "new.go"}, files)
+}
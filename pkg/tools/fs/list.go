@@ -4,12 +4,16 @@ import (
 	"context"
 	"fmt"
 	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/google/wire"
 	"github.com/invopop/jsonschema"
 	"github.com/russellhaering/autoswe/pkg/log"
 	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/russellhaering/autoswe/pkg/tools/toolerr"
 	"go.uber.org/zap"
 
 	_ "embed"
@@ -18,9 +22,26 @@ import (
 //go:embed list.md
 var listToolDescription string
 
+const (
+	// DefaultListMaxDepth is how deep a recursive listing goes when ListInput.MaxDepth is unset
+	DefaultListMaxDepth = 3
+
+	// ListMaxEntries is the most entries returned by a recursive listing
+	ListMaxEntries = 1000
+
+	// ListFormatEntries returns a list of file details, and is the default
+	ListFormatEntries = "entries"
+
+	// ListFormatTree returns an indented tree of file names, like the tree command
+	ListFormatTree = "tree"
+)
+
 // ListInput represents the input parameters for the List tool
 type ListInput struct {
-	Path string `json:"path" jsonschema_description:"Path to list contents of"`
+	Path      string `json:"path" jsonschema_description:"Path to list contents of"`
+	Recursive bool   `json:"recursive,omitempty" jsonschema_description:"If true, also list the contents of subdirectories, returning paths relative to the listed directory"`
+	MaxDepth  int    `json:"max_depth,omitempty" jsonschema_description:"Optional number of directory levels to list when recursive or rendering a tree, where 1 lists only the directory itself. Defaults to 3."`
+	Format    string `json:"format,omitempty" jsonschema:"enum=entries,enum=tree" jsonschema_description:"Output format: 'entries' (default) returns file details, 'tree' returns an indented tree of the directory and its subdirectories"`
 }
 
 // FileInfo represents information about a file or directory
@@ -34,7 +55,9 @@ type FileInfo struct {
 
 // ListOutput represents the output of the List tool
 type ListOutput struct {
-	Files []FileInfo `json:"files,omitempty"`
+	Files     []FileInfo `json:"files,omitempty"`
+	Tree      string     `json:"tree,omitempty"`
+	Truncated bool       `json:"truncated,omitempty"`
 }
 
 type ListTool struct {
@@ -60,7 +83,19 @@ func (t *ListTool) Schema() *jsonschema.Schema {
 
 // Execute implements the list operation
 func (t *ListTool) Execute(_ context.Context, input ListInput) (ListOutput, error) {
-	log.Info("Starting list operation", zap.String("path", input.Path))
+	log.Info("Starting list operation",
+		zap.String("path", input.Path),
+		zap.Bool("recursive", input.Recursive),
+		zap.Int("max_depth", input.MaxDepth),
+		zap.String("format", input.Format))
+
+	if input.MaxDepth < 0 {
+		return ListOutput{}, toolerr.New(toolerr.InvalidInput, "max_depth must not be negative")
+	}
+
+	if input.Format != "" && input.Format != ListFormatEntries && input.Format != ListFormatTree {
+		return ListOutput{}, toolerr.New(toolerr.InvalidInput, "unknown format %q, must be %q or %q", input.Format, ListFormatEntries, ListFormatTree)
+	}
 
 	// Check if path exists in the filtered FS
 	_, err := fs.Stat(t.FilteredFS, input.Path)
@@ -69,6 +104,27 @@ func (t *ListTool) Execute(_ context.Context, input ListInput) (ListOutput, erro
 		return ListOutput{}, fmt.Errorf("failed to access path: %w", err)
 	}
 
+	maxDepth := input.MaxDepth
+	if maxDepth == 0 {
+		maxDepth = DefaultListMaxDepth
+	}
+
+	if input.Format == ListFormatTree {
+		output, err := t.listRecursive(input.Path, maxDepth)
+		if err != nil {
+			return ListOutput{}, err
+		}
+
+		return ListOutput{
+			Tree:      renderTree(input.Path, output.Files, output.Truncated),
+			Truncated: output.Truncated,
+		}, nil
+	}
+
+	if input.Recursive {
+		return t.listRecursive(input.Path, maxDepth)
+	}
+
 	// Read directory entries
 	entries, err := fs.ReadDir(t.FilteredFS, input.Path)
 	if err != nil {
@@ -100,3 +156,102 @@ func (t *ListTool) Execute(_ context.Context, input ListInput) (ListOutput, erro
 		Files: files,
 	}, nil
 }
+
+// listRecursive lists the contents of root and its subdirectories, down to maxDepth levels,
+// stopping once ListMaxEntries entries have been found
+func (t *ListTool) listRecursive(root string, maxDepth int) (ListOutput, error) {
+	var output ListOutput
+
+	err := fs.WalkDir(t.FilteredFS, root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			log.Warn("Failed to list path", zap.String("path", path), zap.Error(err))
+			return nil
+		}
+
+		if path == root {
+			return nil
+		}
+
+		if len(output.Files) == ListMaxEntries {
+			output.Truncated = true
+			return fs.SkipAll
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			log.Warn("Failed to get file info", zap.String("name", path), zap.Error(err))
+			return nil
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		output.Files = append(output.Files, FileInfo{
+			Name:    filepath.ToSlash(relPath),
+			Size:    info.Size(),
+			IsDir:   info.IsDir(),
+			Mode:    info.Mode(),
+			ModTime: info.ModTime(),
+		})
+
+		// Directories at the depth limit are listed, but not their contents
+		if entry.IsDir() && strings.Count(filepath.ToSlash(relPath), "/")+1 >= maxDepth {
+			return fs.SkipDir
+		}
+
+		return nil
+	})
+	if err != nil {
+		return ListOutput{}, fmt.Errorf("failed to list directory: %w", err)
+	}
+
+	log.Info("Recursive list operation completed",
+		zap.Int("files", len(output.Files)),
+		zap.Bool("truncated", output.Truncated),
+		zap.String("path", root))
+
+	return output, nil
+}
+
+// renderTree draws the entries of a recursive listing of root as an indented tree, in the
+// style of the tree command
+func renderTree(root string, files []FileInfo, truncated bool) string {
+	children := make(map[string][]FileInfo)
+	for _, file := range files {
+		parent := path.Dir(file.Name)
+		children[parent] = append(children[parent], file)
+	}
+
+	var b strings.Builder
+	b.WriteString(root + "\n")
+
+	var render func(dir, prefix string)
+	render = func(dir, prefix string) {
+		entries := children[dir]
+		for idx, entry := range entries {
+			connector, indent := "├── ", "│   "
+			if idx == len(entries)-1 {
+				connector, indent = "└── ", "    "
+			}
+
+			name := path.Base(entry.Name)
+			if entry.IsDir {
+				name += "/"
+			}
+			b.WriteString(prefix + connector + name + "\n")
+
+			if entry.IsDir {
+				render(entry.Name, prefix+indent)
+			}
+		}
+	}
+	render(".", "")
+
+	if truncated {
+		fmt.Fprintf(&b, "[truncated after %d entries, list a subdirectory or lower max_depth to see the rest]\n", ListMaxEntries)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,101 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListToolRecursive(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+	for _, path := range []string{"main.go", "pkg/a/a.go", "pkg/a/deep/deep.go", "pkg/b.go", ".git/config"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(rootDir, filepath.Dir(path)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(rootDir, path), []byte("package x"), 0644))
+	}
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	require.NoError(t, err)
+
+	tool := &ListTool{FilteredFS: filteredFS}
+	list := func(input ListInput) []string {
+		output, err := tool.Execute(context.Background(), input)
+		require.NoError(t, err)
+		assert.False(t, output.Truncated)
+
+		var names []string
+		for _, file := range output.Files {
+			names = append(names, file.Name)
+		}
+		return names
+	}
+
+	// The default is a single level
+	assert.Equal(t, []string{"main.go", "pkg"}, list(ListInput{Path: "."}))
+
+	assert.Equal(t, []string{"main.go", "pkg", "pkg/a", "pkg/a/a.go", "pkg/a/deep", "pkg/b.go"}, list(ListInput{Path: ".", Recursive: true}))
+	assert.Equal(t, []string{"main.go", "pkg", "pkg/a", "pkg/b.go"}, list(ListInput{Path: ".", Recursive: true, MaxDepth: 2}))
+	assert.Equal(t, []string{"a", "a/a.go", "a/deep", "a/deep/deep.go", "b.go"}, list(ListInput{Path: "pkg", Recursive: true, MaxDepth: 10}))
+
+	_, err = tool.Execute(context.Background(), ListInput{Path: ".", Recursive: true, MaxDepth: -1})
+	assert.Error(t, err)
+}
+
+func TestListToolRecursiveTruncates(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+	for i := 0; i <= ListMaxEntries; i++ {
+		require.NoError(t, os.WriteFile(filepath.Join(rootDir, fmt.Sprintf("file%04d.txt", i)), []byte("x"), 0644))
+	}
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	require.NoError(t, err)
+
+	output, err := (&ListTool{FilteredFS: filteredFS}).Execute(context.Background(), ListInput{Path: ".", Recursive: true})
+	require.NoError(t, err)
+	assert.True(t, output.Truncated)
+	assert.Len(t, output.Files, ListMaxEntries)
+}
+
+func TestListToolTree(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+	for _, path := range []string{"main.go", "pkg/a/a.go", "pkg/a/deep/deep.go", "pkg/b.go", "vendor/lib/lib.go"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(rootDir, filepath.Dir(path)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(rootDir, path), []byte("package x"), 0644))
+	}
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	require.NoError(t, err)
+
+	tool := &ListTool{FilteredFS: filteredFS}
+
+	output, err := tool.Execute(context.Background(), ListInput{Path: ".", Format: ListFormatTree})
+	require.NoError(t, err)
+	assert.Empty(t, output.Files)
+	assert.Equal(t, `.
+├── main.go
+└── pkg/
+    ├── a/
+    │   ├── a.go
+    │   └── deep/
+    └── b.go
+`, output.Tree)
+
+	output, err = tool.Execute(context.Background(), ListInput{Path: "pkg", Format: ListFormatTree, MaxDepth: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "pkg\n├── a/\n└── b.go\n", output.Tree)
+
+	_, err = tool.Execute(context.Background(), ListInput{Path: ".", Format: "xml"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,74 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/wire"
+	"github.com/invopop/jsonschema"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/russellhaering/autoswe/pkg/tools/toolerr"
+	"go.uber.org/zap"
+
+	_ "embed"
+)
+
+//go:embed mkdir.md
+var mkdirToolDescription string
+
+// MkdirInput represents the input parameters for the Mkdir tool
+type MkdirInput struct {
+	Path      string `json:"path" jsonschema_description:"Path of the directory to create"`
+	Recursive bool   `json:"recursive,omitempty" jsonschema_description:"If true, create any missing parent directories and succeed if the directory already exists"`
+}
+
+// MkdirOutput represents the output of the Mkdir tool
+type MkdirOutput struct{}
+
+type MkdirTool struct {
+	FilteredFS repo.FilteredFS
+}
+
+var ProvideMkdirTool = wire.Struct(new(MkdirTool), "*")
+
+// Name returns the name of the tool
+func (t *MkdirTool) Name() string {
+	return "fs_mkdir"
+}
+
+// Description returns a description of the mkdir tool
+func (t *MkdirTool) Description() string {
+	return mkdirToolDescription
+}
+
+// Schema returns the JSON schema for the mkdir tool
+func (t *MkdirTool) Schema() *jsonschema.Schema {
+	return jsonschema.Reflect(&MkdirInput{})
+}
+
+// Execute implements the mkdir operation
+func (t *MkdirTool) Execute(_ context.Context, input MkdirInput) (MkdirOutput, error) {
+	log.Info("Starting mkdir operation", zap.String("path", input.Path), zap.Bool("recursive", input.Recursive))
+
+	if input.Path == "" {
+		log.Error("No path provided")
+		return MkdirOutput{}, toolerr.New(toolerr.InvalidInput, "path is required")
+	}
+
+	var err error
+	if input.Recursive {
+		err = t.FilteredFS.MkdirAll(input.Path, 0755)
+	} else {
+		err = t.FilteredFS.Mkdir(input.Path, 0755)
+	}
+
+	if err != nil {
+		log.Error("Failed to create directory", zap.String("path", input.Path), zap.Error(err))
+		return MkdirOutput{}, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	log.Info("Successfully created directory", zap.String("path", input.Path))
+
+	return MkdirOutput{}, nil
+}
@@ -0,0 +1,46 @@
+package fs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMkdirTool(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	require.NoError(t, err)
+
+	tool := &MkdirTool{FilteredFS: filteredFS}
+	mkdir := func(input MkdirInput) error {
+		_, err := tool.Execute(context.Background(), input)
+		return err
+	}
+
+	require.NoError(t, mkdir(MkdirInput{Path: "testdata"}))
+	assert.DirExists(t, filepath.Join(rootDir, "testdata"))
+
+	// Without recursive the directory must be new and its parent must exist
+	assert.Error(t, mkdir(MkdirInput{Path: "testdata"}))
+	assert.Error(t, mkdir(MkdirInput{Path: "pkg/scaffold"}))
+	assert.NoDirExists(t, filepath.Join(rootDir, "pkg"))
+
+	require.NoError(t, mkdir(MkdirInput{Path: "pkg/scaffold", Recursive: true}))
+	assert.DirExists(t, filepath.Join(rootDir, "pkg/scaffold"))
+	require.NoError(t, mkdir(MkdirInput{Path: "pkg/scaffold", Recursive: true}))
+
+	// Filtered and out of tree paths are rejected
+	assert.Error(t, mkdir(MkdirInput{Path: ".git/hooks", Recursive: true}))
+	assert.Error(t, mkdir(MkdirInput{Path: "../escaped"}))
+	assert.Error(t, mkdir(MkdirInput{}))
+	_, err = os.Stat(filepath.Join(rootDir, "..", "escaped"))
+	assert.True(t, os.IsNotExist(err))
+}
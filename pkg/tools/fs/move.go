@@ -0,0 +1,88 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/google/wire"
+	"github.com/invopop/jsonschema"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"go.uber.org/zap"
+
+	_ "embed"
+)
+
+//go:embed move.md
+var moveToolDescription string
+
+// MoveInput represents the input parameters for the Move tool
+type MoveInput struct {
+	Source      string `json:"source" jsonschema_description:"Path of the file or directory to move"`
+	Destination string `json:"destination" jsonschema_description:"New path for the file or directory. Missing parent directories are created."`
+	Overwrite   bool   `json:"overwrite,omitempty" jsonschema_description:"If true, replace an existing file at the destination"`
+}
+
+// MoveOutput represents the output of the Move tool
+type MoveOutput struct{}
+
+type MoveTool struct {
+	FilteredFS repo.FilteredFS
+}
+
+var ProvideMoveTool = wire.Struct(new(MoveTool), "*")
+
+// Name returns the name of the tool
+func (t *MoveTool) Name() string {
+	return "fs_move"
+}
+
+// Description returns a description of the move tool
+func (t *MoveTool) Description() string {
+	return moveToolDescription
+}
+
+// Schema returns the JSON schema for the move tool
+func (t *MoveTool) Schema() *jsonschema.Schema {
+	return jsonschema.Reflect(&MoveInput{})
+}
+
+// Execute implements the move operation
+func (t *MoveTool) Execute(_ context.Context, input MoveInput) (MoveOutput, error) {
+	log.Info("Starting move operation",
+		zap.String("source", input.Source),
+		zap.String("destination", input.Destination),
+		zap.Bool("overwrite", input.Overwrite))
+
+	if input.Source == "" || input.Destination == "" {
+		log.Error("Source and destination are required")
+		return MoveOutput{}, fmt.Errorf("source and destination are required")
+	}
+
+	if _, err := fs.Stat(t.FilteredFS, input.Source); err != nil {
+		log.Error("Failed to access source", zap.String("source", input.Source), zap.Error(err))
+		return MoveOutput{}, fmt.Errorf("failed to access source: %w", err)
+	}
+
+	info, err := fs.Stat(t.FilteredFS, input.Destination)
+	switch {
+	case err == nil && info.IsDir():
+		return MoveOutput{}, fmt.Errorf("destination is an existing directory: %s", input.Destination)
+	case err == nil && !input.Overwrite:
+		return MoveOutput{}, fmt.Errorf("destination already exists, set overwrite to replace it: %s", input.Destination)
+	case err != nil && !errors.Is(err, fs.ErrNotExist):
+		log.Error("Failed to access destination", zap.String("destination", input.Destination), zap.Error(err))
+		return MoveOutput{}, fmt.Errorf("failed to access destination: %w", err)
+	}
+
+	if err := t.FilteredFS.Rename(input.Source, input.Destination); err != nil {
+		log.Error("Failed to move", zap.String("source", input.Source), zap.Error(err))
+		return MoveOutput{}, fmt.Errorf("failed to move: %w", err)
+	}
+
+	log.Info("Successfully moved", zap.String("source", input.Source), zap.String("destination", input.Destination))
+
+	return MoveOutput{}, nil
+}
@@ -0,0 +1,59 @@
+package fs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoveTool(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "old.go"), []byte("package old"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "existing.go"), []byte("package existing"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, ".env"), []byte("SECRET=1"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, ".autosweignore"), []byte(".env\n"), 0644))
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	require.NoError(t, err)
+
+	tool := &MoveTool{FilteredFS: filteredFS}
+	move := func(input MoveInput) error {
+		_, err := tool.Execute(context.Background(), input)
+		return err
+	}
+
+	// Moving into a new directory creates it and keeps the file's permissions
+	require.NoError(t, move(MoveInput{Source: "old.go", Destination: "pkg/new/new.go"}))
+	assert.NoFileExists(t, filepath.Join(rootDir, "old.go"))
+	info, err := os.Stat(filepath.Join(rootDir, "pkg/new/new.go"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+	// Existing destinations are only replaced with overwrite
+	err = move(MoveInput{Source: "pkg/new/new.go", Destination: "existing.go"})
+	assert.ErrorContains(t, err, "already exists")
+	require.NoError(t, move(MoveInput{Source: "pkg/new/new.go", Destination: "existing.go", Overwrite: true}))
+	content, err := os.ReadFile(filepath.Join(rootDir, "existing.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package old", string(content))
+
+	// Directories can be moved
+	require.NoError(t, move(MoveInput{Source: "pkg", Destination: "internal"}))
+	assert.DirExists(t, filepath.Join(rootDir, "internal/new"))
+
+	// Filtered and out of tree paths are rejected on either side
+	assert.Error(t, move(MoveInput{Source: ".env", Destination: "env.txt"}))
+	assert.Error(t, move(MoveInput{Source: "existing.go", Destination: ".env", Overwrite: true}))
+	assert.Error(t, move(MoveInput{Source: "existing.go", Destination: "../escaped.go"}))
+	assert.Error(t, move(MoveInput{Source: "missing.go", Destination: "other.go"}))
+	assert.FileExists(t, filepath.Join(rootDir, "existing.go"))
+	assert.FileExists(t, filepath.Join(rootDir, ".env"))
+}
@@ -0,0 +1,168 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	iofs "io/fs"
+	"path/filepath"
+	"sort"
+
+	"github.com/google/wire"
+	"github.com/invopop/jsonschema"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/russellhaering/autoswe/pkg/tools/fs/simplediff"
+	"github.com/russellhaering/autoswe/pkg/tools/toolerr"
+	"go.uber.org/zap"
+
+	_ "embed"
+)
+
+//go:embed multi_patch.md
+var multiPatchToolDescription string
+
+// MultiPatchEdit is a single edit within a MultiPatch operation
+type MultiPatchEdit struct {
+	Path string `json:"path" jsonschema_description:"Path to the file to patch"`
+	Diff string `json:"diff" jsonschema_description:"A search-and-replace diff using the markers <<<<<<< SEARCH, =======, and >>>>>>> REPLACE"`
+}
+
+// MultiPatchInput represents the input parameters for the MultiPatch tool
+type MultiPatchInput struct {
+	Edits []MultiPatchEdit `json:"edits" jsonschema_description:"Edits to apply, in order. Either all of them are applied or none are."`
+}
+
+// MultiPatchOutput represents the output of the MultiPatch tool
+type MultiPatchOutput struct {
+	ChangedFiles []string `json:"changed_files"`
+}
+
+// MultiPatchTool applies several patches, writing them only if every patch applies cleanly
+type MultiPatchTool struct {
+	FilteredFS repo.FilteredFS
+}
+
+var ProvideMultiPatchTool = wire.Struct(new(MultiPatchTool), "*")
+
+// Name returns the name of the tool
+func (t *MultiPatchTool) Name() string {
+	return "fs_multi_patch"
+}
+
+// Description returns a description of the multi patch tool
+func (t *MultiPatchTool) Description() string {
+	return multiPatchToolDescription
+}
+
+// Schema returns the JSON schema for the multi patch tool
+func (t *MultiPatchTool) Schema() *jsonschema.Schema {
+	// Tool schemas must have a single definition, so the edit type is embedded directly
+	reflector := jsonschema.Reflector{
+		DoNotReference: true,
+	}
+
+	schema := reflector.Reflect(&MultiPatchInput{})
+	schema.Version = ""
+	schema.ID = ""
+
+	return &jsonschema.Schema{
+		Ref: "#/$defs/MultiPatchInput",
+		Definitions: jsonschema.Definitions{
+			"MultiPatchInput": schema,
+		},
+	}
+}
+
+// Execute implements the multi patch operation
+func (t *MultiPatchTool) Execute(_ context.Context, input MultiPatchInput) (MultiPatchOutput, error) {
+	log.Info("Starting multi patch operation", zap.Int("edits", len(input.Edits)))
+
+	if len(input.Edits) == 0 {
+		log.Error("No edits provided")
+		return MultiPatchOutput{}, toolerr.New(toolerr.InvalidInput, "at least one edit is required")
+	}
+
+	for idx, edit := range input.Edits {
+		if edit.Path == "" {
+			return MultiPatchOutput{}, fmt.Errorf("edit %d: path is required", idx+1)
+		}
+		if edit.Diff == "" {
+			return MultiPatchOutput{}, fmt.Errorf("edit %d (%s): diff is required", idx+1, edit.Path)
+		}
+	}
+
+	// Hold every touched path until all files are written. Locks are taken in sorted order
+	// so that two multi patches over the same files can't deadlock.
+	for _, path := range lockOrder(input.Edits) {
+		unlock := t.FilteredFS.Lock(path)
+		defer unlock()
+	}
+
+	// Compute every new file content before writing anything. Later edits to the same file
+	// apply on top of earlier ones.
+	original := make(map[string][]byte)
+	updated := make(map[string]string)
+	var order []string
+
+	for idx, edit := range input.Edits {
+		current, ok := updated[edit.Path]
+		if !ok {
+			content, err := iofs.ReadFile(t.FilteredFS, edit.Path)
+			if err != nil {
+				log.Error("Failed to read file", zap.String("path", edit.Path), zap.Error(err))
+				return MultiPatchOutput{}, fmt.Errorf("edit %d (%s): failed to read file: %w", idx+1, edit.Path, err)
+			}
+
+			original[edit.Path] = content
+			current = string(content)
+			order = append(order, edit.Path)
+		}
+
+		result, err := simplediff.ApplyDiff(current, edit.Diff)
+		if err != nil {
+			log.Error("Failed to apply edit", zap.Int("edit", idx+1), zap.String("path", edit.Path), zap.Error(err))
+			return MultiPatchOutput{}, fmt.Errorf("edit %d (%s) failed to apply, no files were changed: %w", idx+1, edit.Path, err)
+		}
+
+		updated[edit.Path] = result
+	}
+
+	for idx, path := range order {
+		if err := t.FilteredFS.WriteFile(path, []byte(updated[path]), 0644); err != nil {
+			log.Error("Failed to write file, rolling back", zap.String("path", path), zap.Error(err))
+			t.rollback(order[:idx], original)
+			return MultiPatchOutput{}, fmt.Errorf("failed to write %s, no files were changed: %w", path, err)
+		}
+	}
+
+	log.Info("Multi patch completed successfully", zap.Strings("changed_files", order))
+
+	return MultiPatchOutput{
+		ChangedFiles: order,
+	}, nil
+}
+
+// rollback restores the original contents of files that were already written
+func (t *MultiPatchTool) rollback(paths []string, original map[string][]byte) {
+	for _, path := range paths {
+		if err := t.FilteredFS.WriteFile(path, original[path], 0644); err != nil {
+			log.Error("Failed to restore file", zap.String("path", path), zap.Error(err))
+		}
+	}
+}
+
+// lockOrder returns the distinct paths touched by edits, sorted
+func lockOrder(edits []MultiPatchEdit) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, edit := range edits {
+		path := filepath.Clean(edit.Path)
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+
+	sort.Strings(paths)
+	return paths
+}
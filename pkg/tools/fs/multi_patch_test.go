@@ -0,0 +1,71 @@
+package fs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiPatchTool(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+	files := map[string]string{
+		"a.go": "package a\n\nfunc Old() {}\n",
+		"b.go": "package b\n\nvar _ = a.Old\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(rootDir, name), []byte(content), 0644))
+	}
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	require.NoError(t, err)
+
+	tool := &MultiPatchTool{FilteredFS: filteredFS}
+
+	readFile := func(name string) string {
+		content, err := os.ReadFile(filepath.Join(rootDir, name))
+		require.NoError(t, err)
+		return string(content)
+	}
+
+	rename := "<<<<<<< SEARCH\nOld\n=======\nNew\n>>>>>>> REPLACE"
+
+	// A failing edit leaves every file untouched
+	_, err = tool.Execute(context.Background(), MultiPatchInput{Edits: []MultiPatchEdit{
+		{Path: "a.go", Diff: "<<<<<<< SEARCH\nfunc Old() {}\n=======\nfunc New() {}\n>>>>>>> REPLACE"},
+		{Path: "b.go", Diff: "<<<<<<< SEARCH\nmissing\n=======\nNew\n>>>>>>> REPLACE"},
+	}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "edit 2 (b.go)")
+	assert.Equal(t, files["a.go"], readFile("a.go"))
+	assert.Equal(t, files["b.go"], readFile("b.go"))
+
+	// A missing file also aborts before anything is written
+	_, err = tool.Execute(context.Background(), MultiPatchInput{Edits: []MultiPatchEdit{
+		{Path: "a.go", Diff: rename},
+		{Path: "missing.go", Diff: rename},
+	}})
+	require.Error(t, err)
+	assert.Equal(t, files["a.go"], readFile("a.go"))
+
+	// Successful edits are all written, with later edits to a file applying on top of earlier ones
+	output, err := tool.Execute(context.Background(), MultiPatchInput{Edits: []MultiPatchEdit{
+		{Path: "a.go", Diff: "<<<<<<< SEARCH\nfunc Old() {}\n=======\nfunc New() {}\n>>>>>>> REPLACE"},
+		{Path: "b.go", Diff: "<<<<<<< SEARCH\na.Old\n=======\na.New\n>>>>>>> REPLACE"},
+		{Path: "a.go", Diff: "<<<<<<< SEARCH\nfunc New() {}\n=======\n// New is new\nfunc New() {}\n>>>>>>> REPLACE"},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.go", "b.go"}, output.ChangedFiles)
+	assert.Equal(t, "package a\n\n// New is new\nfunc New() {}\n", readFile("a.go"))
+	assert.Equal(t, "package b\n\nvar _ = a.New\n", readFile("b.go"))
+
+	_, err = tool.Execute(context.Background(), MultiPatchInput{})
+	assert.Error(t, err)
+}
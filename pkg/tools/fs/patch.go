@@ -1,10 +1,10 @@
 package fs
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	iofs "io/fs"
-	"os"
 	"strings"
 
 	"github.com/google/generative-ai-go/genai"
@@ -12,7 +12,9 @@ import (
 	"github.com/invopop/jsonschema"
 	"github.com/russellhaering/autoswe/pkg/log"
 	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/russellhaering/autoswe/pkg/retry"
 	"github.com/russellhaering/autoswe/pkg/tools/fs/simplediff"
+	"github.com/russellhaering/autoswe/pkg/tools/toolerr"
 	"go.uber.org/zap"
 
 	_ "embed"
@@ -28,11 +30,29 @@ type PatchInput struct {
 }
 
 // PatchOutput represents the output of the Patch tool
-type PatchOutput struct{}
+type PatchOutput struct {
+	// Diff is a line-level diff of the changes, set when the patch was applied by the AI fallback
+	Diff string `json:"diff,omitempty"`
+}
+
+// DefaultPatchModel is the Gemini model used to apply patches that can't be applied directly
+const DefaultPatchModel = "gemini-2.0-flash"
+
+// PatchConfig configures the patch tool's AI fallback
+type PatchConfig struct {
+	// Model is the Gemini model used by the fallback
+	// Default: DefaultPatchModel
+	Model string
+}
 
 type PatchTool struct {
 	Gemini     *genai.Client
 	FilteredFS repo.FilteredFS
+	Retry      retry.Config
+	Config     PatchConfig
+
+	// generate replaces the Gemini call made by the fallback, and is only set in tests
+	generate func(ctx context.Context, prompt string) (string, error) `wire:"-"`
 }
 
 var ProvidePatchTool = wire.Struct(new(PatchTool), "*")
@@ -54,8 +74,6 @@ func (t *PatchTool) Schema() *jsonschema.Schema {
 
 // applyPatchWithGemini uses the Gemini AI model to apply the patch when simplediff fails
 func (t *PatchTool) applyPatchWithGemini(ctx context.Context, originalContent, diffContent string) (string, error) {
-	model := t.Gemini.GenerativeModel("gemini-2.0-flash")
-
 	// Build the prompt for Gemini
 	prompt := fmt.Sprintf(`You are a precise code editing tool. Given a file's content and a diff in the simplediff format, apply the changes exactly as specified in the diff to the file content. Return ONLY the modified file content, with no additional text or explanation.
 
@@ -80,27 +98,13 @@ Remember:
 5. Preserve all whitespace and formatting in unchanged parts
 6. If the diff cannot be applied, return an error message starting with "ERROR:"`, originalContent, diffContent)
 
-	// Generate response
-	resp, err := model.GenerateContent(ctx, genai.Text(prompt))
+	content, err := t.generateContent(ctx, prompt)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate content: %v", err)
-	}
-
-	if resp == nil || len(resp.Candidates) == 0 {
-		return "", fmt.Errorf("no response generated")
-	}
-
-	// Extract text from the response
-	var content string
-	for _, part := range resp.Candidates[0].Content.Parts {
-		if text, ok := part.(genai.Text); ok {
-			content = string(text)
-			break
-		}
+		return "", err
 	}
 
 	if len(content) == 0 {
-		return "", fmt.Errorf("empty response from Gemini")
+		return "", toolerr.New(toolerr.Transient, "empty response from Gemini")
 	}
 	// Strip language-specific code block markers if present
 	if strings.HasPrefix(content, "```") {
@@ -124,15 +128,49 @@ Remember:
 	return content, nil
 }
 
+// generateContent sends the prompt to Gemini and returns the text of the first candidate
+func (t *PatchTool) generateContent(ctx context.Context, prompt string) (string, error) {
+	if t.generate != nil {
+		return t.generate(ctx, prompt)
+	}
+
+	model := t.Gemini.GenerativeModel(cmp.Or(t.Config.Model, DefaultPatchModel))
+
+	// Generate response
+	resp, err := retry.Do(ctx, t.Retry, func(ctx context.Context) (*genai.GenerateContentResponse, error) {
+		return model.GenerateContent(ctx, genai.Text(prompt))
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to generate content: %v", err)
+	}
+
+	if resp == nil || len(resp.Candidates) == 0 {
+		return "", toolerr.New(toolerr.Transient, "no response generated")
+	}
+
+	// Extract text from the response
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if text, ok := part.(genai.Text); ok {
+			return string(text), nil
+		}
+	}
+
+	return "", nil
+}
+
 func (t *PatchTool) Execute(ctx context.Context, input PatchInput) (PatchOutput, error) {
 	log.Info("Starting patch operation", zap.String("path", input.Path))
 	log.Debug("Diff content", zap.String("diff", input.Diff))
 
 	if input.Diff == "" {
 		log.Error("Empty diff provided")
-		return PatchOutput{}, fmt.Errorf("diff is required")
+		return PatchOutput{}, toolerr.New(toolerr.InvalidInput, "diff is required")
 	}
 
+	// Hold the path until the result is written so concurrent changes aren't lost
+	unlock := t.FilteredFS.Lock(input.Path)
+	defer unlock()
+
 	// Read the original file
 	content, err := iofs.ReadFile(t.FilteredFS, input.Path)
 	if err != nil {
@@ -142,6 +180,8 @@ func (t *PatchTool) Execute(ctx context.Context, input PatchInput) (PatchOutput,
 	originalContent := string(content)
 	log.Debug("Read file content", zap.String("path", input.Path), zap.Int("bytes", len(content)))
 
+	var output PatchOutput
+
 	// First try to apply the patch using simplediff
 	log.Debug("Attempting to apply patch programmatically")
 	result, err := simplediff.ApplyDiff(originalContent, input.Diff)
@@ -153,15 +193,18 @@ func (t *PatchTool) Execute(ctx context.Context, input PatchInput) (PatchOutput,
 			log.Error("Failed to apply patch with Gemini", zap.Error(err))
 			return PatchOutput{}, fmt.Errorf("failed to apply patch: %w", err)
 		}
+
+		// The AI rewrote the whole file, so report exactly what it changed
+		output.Diff = simplediff.LineDiff(originalContent, result)
 	}
 
 	// Write the modified content back to the file
-	err = os.WriteFile(input.Path, []byte(result), 0644)
+	err = t.FilteredFS.WriteFile(input.Path, []byte(result), 0644)
 	if err != nil {
 		log.Error("Failed to write file", zap.String("path", input.Path), zap.Error(err))
 		return PatchOutput{}, fmt.Errorf("failed to write file: %w", err)
 	}
 	log.Info("Successfully wrote modified content", zap.String("path", input.Path), zap.Int("bytes", len(result)))
 
-	return PatchOutput{}, nil
+	return output, nil
 }
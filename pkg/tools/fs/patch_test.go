@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
 	"github.com/russellhaering/autoswe/pkg/tools/fs/simplediff"
 )
 
@@ -195,3 +198,115 @@ Replacement content
 	// Clean up at the end of all tests
 	defer os.RemoveAll("testdata/temp")
 }
+
+func TestPatchWithGeminiFallbackReturnsDiff(t *testing.T) {
+	// Initialize logger
+	if err := log.Init(true); err != nil {
+		t.Fatalf("Failed to initialize logger: %v", err)
+	}
+
+	rootDir := t.TempDir()
+	initialContent := "line 1\nline 2\nline 3\n"
+	if err := os.WriteFile(filepath.Join(rootDir, "test.txt"), []byte(initialContent), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	if err != nil {
+		t.Fatalf("Failed to create filtered FS: %v", err)
+	}
+
+	// The search content doesn't match, so the fake Gemini fallback is used
+	patchedContent := "line 1\nline two\nline 3\n"
+	generateCalled := false
+	patchTool := &PatchTool{
+		FilteredFS: filteredFS,
+		generate: func(_ context.Context, _ string) (string, error) {
+			generateCalled = true
+			return patchedContent, nil
+		},
+	}
+
+	output, err := patchTool.Execute(context.Background(), PatchInput{
+		Path: "test.txt",
+		Diff: "<<<<<<< SEARCH\nline  2\n=======\nline two\n>>>>>>> REPLACE",
+	})
+	if err != nil {
+		t.Fatalf("Patch failed: %s", err)
+	}
+
+	if !generateCalled {
+		t.Fatalf("Expected the Gemini fallback to be used")
+	}
+
+	wantDiff := "@@ -1,3 +1,3 @@\n line 1\n-line 2\n+line two\n line 3\n"
+	if output.Diff != wantDiff {
+		t.Errorf("Returned diff doesn't match the change.\nGot: %q\nWant: %q", output.Diff, wantDiff)
+	}
+
+	written, err := os.ReadFile(filepath.Join(rootDir, "test.txt"))
+	if err != nil {
+		t.Fatalf("Failed to read patched file: %v", err)
+	}
+	if string(written) != patchedContent {
+		t.Errorf("Patched content doesn't match.\nGot: %q\nWant: %q", string(written), patchedContent)
+	}
+}
+
+func TestPatchConcurrentSameFile(t *testing.T) {
+	// Initialize logger
+	if err := log.Init(true); err != nil {
+		t.Fatalf("Failed to initialize logger: %v", err)
+	}
+
+	const patches = 20
+
+	rootDir := t.TempDir()
+	var initialContent strings.Builder
+	for i := 0; i < patches; i++ {
+		fmt.Fprintf(&initialContent, "line %d\n", i)
+	}
+	if err := os.WriteFile(filepath.Join(rootDir, "test.txt"), []byte(initialContent.String()), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	if err != nil {
+		t.Fatalf("Failed to create filtered FS: %v", err)
+	}
+
+	patchTool := &PatchTool{FilteredFS: filteredFS}
+
+	// Each patch changes a different line, so a lost update leaves one of them unpatched
+	var wg sync.WaitGroup
+	errs := make(chan error, patches)
+	for i := 0; i < patches; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := patchTool.Execute(context.Background(), PatchInput{
+				Path: "test.txt",
+				Diff: fmt.Sprintf("<<<<<<< SEARCH\nline %d\n=======\npatched %d\n>>>>>>> REPLACE", i, i),
+			})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Patch failed: %s", err)
+		}
+	}
+
+	written, err := os.ReadFile(filepath.Join(rootDir, "test.txt"))
+	if err != nil {
+		t.Fatalf("Failed to read patched file: %v", err)
+	}
+	for i := 0; i < patches; i++ {
+		if !strings.Contains(string(written), fmt.Sprintf("patched %d\n", i)) {
+			t.Errorf("Patch %d was lost, file content:\n%s", i, written)
+		}
+	}
+}
@@ -2,12 +2,15 @@ package fs
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 
 	"github.com/google/wire"
 	"github.com/invopop/jsonschema"
 	"github.com/russellhaering/autoswe/pkg/log"
 	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/russellhaering/autoswe/pkg/tools/toolerr"
 	"go.uber.org/zap"
 
 	_ "embed"
@@ -20,6 +23,15 @@ var putToolDescription string
 type PutInput struct {
 	Path    string `json:"path" jsonschema_description:"Path to the file to write"`
 	Content string `json:"content" jsonschema_description:"Content to write to the file"`
+
+	// Overwrite is a pointer so that a missing value can keep the original behavior of
+	// replacing existing files
+	Overwrite *bool `json:"overwrite,omitempty" jsonschema_description:"If false, fail rather than replace an existing file. Defaults to true."`
+}
+
+// overwrite reports whether an existing file may be replaced
+func (i PutInput) overwrite() bool {
+	return i.Overwrite == nil || *i.Overwrite
 }
 
 // PutOutput represents the output of the Put tool
@@ -50,16 +62,30 @@ func (t *PutTool) Schema() *jsonschema.Schema {
 func (t *PutTool) Execute(_ context.Context, input PutInput) (PutOutput, error) {
 	log.Debug("Starting put operation",
 		zap.String("path", input.Path),
-		zap.Int("contentLength", len(input.Content)))
+		zap.Int("contentLength", len(input.Content)),
+		zap.Bool("overwrite", input.overwrite()))
 
 	if input.Content == "" {
 		log.Error("Empty content provided", zap.String("path", input.Path))
-		return PutOutput{}, fmt.Errorf("content is required")
+		return PutOutput{}, toolerr.New(toolerr.InvalidInput, "content is required")
 	}
 
 	// Write the file using FilteredFS
-	err := t.FilteredFS.WriteFile(input.Path, []byte(input.Content), 0644)
-	if err != nil {
+	unlock := t.FilteredFS.Lock(input.Path)
+	defer unlock()
+
+	if !input.overwrite() {
+		_, err := fs.Stat(t.FilteredFS, input.Path)
+		if err == nil {
+			log.Error("File already exists", zap.String("path", input.Path))
+			return PutOutput{}, toolerr.New(toolerr.InvalidInput, "file already exists, set overwrite to replace it: %s", input.Path)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			log.Error("Failed to access file", zap.String("path", input.Path), zap.Error(err))
+			return PutOutput{}, fmt.Errorf("failed to access file: %w", err)
+		}
+	}
+
+	if err := t.FilteredFS.WriteFile(input.Path, []byte(input.Content), 0644); err != nil {
 		log.Error("Failed to write file", zap.String("path", input.Path), zap.Error(err))
 		return PutOutput{}, fmt.Errorf("failed to write file: %w", err)
 	}
@@ -0,0 +1,52 @@
+package fs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/russellhaering/autoswe/pkg/tools/toolerr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutTool(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	require.NoError(t, err)
+
+	tool := &PutTool{FilteredFS: filteredFS}
+	put := func(input PutInput) error {
+		_, err := tool.Execute(context.Background(), input)
+		return err
+	}
+	no, yes := false, true
+
+	// New files are created whether or not overwriting is allowed
+	require.NoError(t, put(PutInput{Path: "new.go", Content: "package a\n", Overwrite: &no}))
+	require.NoError(t, put(PutInput{Path: "other.go", Content: "package a\n"}))
+
+	// Existing files are left alone unless overwriting is allowed
+	err = put(PutInput{Path: "new.go", Content: "package b\n", Overwrite: &no})
+	require.Error(t, err)
+	assert.Equal(t, toolerr.InvalidInput, toolerr.CategoryOf(err))
+	content, err := os.ReadFile(filepath.Join(rootDir, "new.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package a\n", string(content))
+
+	// Overwriting is allowed by default
+	for _, input := range []PutInput{
+		{Path: "new.go", Content: "package b\n"},
+		{Path: "new.go", Content: "package c\n", Overwrite: &yes},
+	} {
+		require.NoError(t, put(input))
+		content, err = os.ReadFile(filepath.Join(rootDir, "new.go"))
+		require.NoError(t, err)
+		assert.Equal(t, input.Content, string(content))
+	}
+}
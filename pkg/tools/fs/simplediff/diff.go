@@ -106,3 +106,180 @@ func ApplyMultipleDiffs(fileContent string, diffs []string) (string, error) {
 
 	return result, nil
 }
+
+// diffContextLines is the number of unchanged lines shown around each change by LineDiff
+const diffContextLines = 3
+
+// diffOp is a single line of a line-level diff
+type diffOp struct {
+	kind    byte // ' ' for unchanged, '-' for removed, '+' for added
+	text    string
+	oldLine int // 1-based position in the old content
+	newLine int // 1-based position in the new content
+}
+
+// LineDiff computes a line-level diff between two versions of a file, formatted as unified
+// diff hunks with a few lines of context. It returns an empty string if the contents are equal.
+func LineDiff(oldContent, newContent string) string {
+	if oldContent == newContent {
+		return ""
+	}
+
+	oldLines, newLines := strings.Split(oldContent, "\n"), strings.Split(newContent, "\n")
+
+	// A final newline ends the last line rather than starting another. If only one version
+	// has one, the extra empty line is kept so that the difference is shown.
+	if strings.HasSuffix(oldContent, "\n") && strings.HasSuffix(newContent, "\n") {
+		oldLines, newLines = oldLines[:len(oldLines)-1], newLines[:len(newLines)-1]
+	}
+
+	ops := diffLines(oldLines, newLines)
+
+	var sb strings.Builder
+	for idx := 0; idx < len(ops); {
+		// Skip to the next change
+		for idx < len(ops) && ops[idx].kind == ' ' {
+			idx++
+		}
+		if idx == len(ops) {
+			break
+		}
+
+		start := idx - diffContextLines
+		if start < 0 {
+			start = 0
+		}
+
+		// Extend the hunk while the next change is close enough for the context to overlap
+		end := idx
+		for {
+			for end < len(ops) && ops[end].kind != ' ' {
+				end++
+			}
+
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' && next-end < 2*diffContextLines {
+				next++
+			}
+
+			if next < len(ops) && ops[next].kind != ' ' {
+				end = next
+				continue
+			}
+			break
+		}
+
+		stop := end + diffContextLines
+		if stop > len(ops) {
+			stop = len(ops)
+		}
+
+		writeHunk(&sb, ops[start:stop])
+		idx = stop
+	}
+
+	return sb.String()
+}
+
+// writeHunk writes a single unified diff hunk, including its header
+func writeHunk(sb *strings.Builder, ops []diffOp) {
+	oldStart, newStart := ops[0].oldLine, ops[0].newLine
+	var oldCount, newCount int
+	for _, op := range ops {
+		if op.kind != '+' {
+			oldCount++
+		}
+		if op.kind != '-' {
+			newCount++
+		}
+	}
+
+	// By convention an empty range refers to the line before the change
+	if oldCount == 0 {
+		oldStart--
+	}
+	if newCount == 0 {
+		newStart--
+	}
+
+	sb.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount))
+	for _, op := range ops {
+		sb.WriteByte(op.kind)
+		sb.WriteString(op.text)
+		sb.WriteByte('\n')
+	}
+}
+
+// diffLines computes the shortest edit script between two sets of lines using a longest
+// common subsequence table. Common prefixes and suffixes are stripped first, which keeps
+// the table small for the typical case of a localized edit.
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	midA := a[prefix : len(a)-suffix]
+	midB := b[prefix : len(b)-suffix]
+
+	// lcs[i][j] is the length of the longest common subsequence of midA[i:] and midB[j:]
+	lcs := make([][]int, len(midA)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(midB)+1)
+	}
+	for i := len(midA) - 1; i >= 0; i-- {
+		for j := len(midB) - 1; j >= 0; j-- {
+			switch {
+			case midA[i] == midB[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var ops []diffOp
+	oldLine, newLine := 1, 1
+	emit := func(kind byte, text string) {
+		ops = append(ops, diffOp{kind: kind, text: text, oldLine: oldLine, newLine: newLine})
+		if kind != '+' {
+			oldLine++
+		}
+		if kind != '-' {
+			newLine++
+		}
+	}
+
+	for _, line := range a[:prefix] {
+		emit(' ', line)
+	}
+
+	i, j := 0, 0
+	for i < len(midA) || j < len(midB) {
+		switch {
+		case i < len(midA) && j < len(midB) && midA[i] == midB[j]:
+			emit(' ', midA[i])
+			i++
+			j++
+		case j < len(midB) && (i == len(midA) || lcs[i][j+1] > lcs[i+1][j]):
+			emit('+', midB[j])
+			j++
+		default:
+			emit('-', midA[i])
+			i++
+		}
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		emit(' ', line)
+	}
+
+	return ops
+}
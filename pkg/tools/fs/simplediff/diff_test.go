@@ -383,3 +383,51 @@ func main() {
 		}
 	})
 }
+
+func TestLineDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want string
+	}{
+		{
+			name: "identical",
+			old:  "a\nb\n",
+			new:  "a\nb\n",
+			want: "",
+		},
+		{
+			name: "single line changed",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n9",
+			new:  "1\n2\n3\n4\nfive\n6\n7\n8\n9",
+			want: "@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "trailing newlines aren't an extra line",
+			old:  "a\nb\n",
+			new:  "a\nB\n",
+			want: "@@ -1,2 +1,2 @@\n a\n-b\n+B\n",
+		},
+		{
+			name: "insertion at start",
+			old:  "b\nc",
+			new:  "a\nb\nc",
+			want: "@@ -1,2 +1,3 @@\n+a\n b\n c\n",
+		},
+		{
+			name: "distant changes produce separate hunks",
+			old:  "a\n1\n2\n3\n4\n5\n6\n7\nb",
+			new:  "A\n1\n2\n3\n4\n5\n6\n7\nB",
+			want: "@@ -1,4 +1,4 @@\n-a\n+A\n 1\n 2\n 3\n@@ -6,4 +6,4 @@\n 5\n 6\n 7\n-b\n+B\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LineDiff(tt.old, tt.new); got != tt.want {
+				t.Errorf("LineDiff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
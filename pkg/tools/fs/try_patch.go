@@ -0,0 +1,140 @@
+package fs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	iofs "io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/google/wire"
+	"github.com/invopop/jsonschema"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/russellhaering/autoswe/pkg/tools/fs/simplediff"
+	"github.com/russellhaering/autoswe/pkg/tools/toolerr"
+	"go.uber.org/zap"
+
+	_ "embed"
+)
+
+//go:embed try_patch.md
+var tryPatchToolDescription string
+
+// TryPatchInput represents the input parameters for the TryPatch tool
+type TryPatchInput struct {
+	Path    string `json:"path" jsonschema_description:"Path to the file to patch"`
+	Diff    string `json:"diff" jsonschema_description:"A search-and-replace diff using the markers <<<<<<< SEARCH, =======, and >>>>>>> REPLACE"`
+	Package string `json:"package,omitempty" jsonschema_description:"Optional package pattern to test, such as ./pkg/... (defaults to the package containing the file)"`
+}
+
+// TryPatchOutput represents the output of the TryPatch tool
+type TryPatchOutput struct {
+	Passed bool   `json:"passed"`
+	Output string `json:"output"`
+}
+
+// TryPatchTool runs tests against a patched copy of a file without modifying the file
+type TryPatchTool struct {
+	RepoFS     *repo.RepositoryFS
+	FilteredFS repo.FilteredFS
+}
+
+var ProvideTryPatchTool = wire.Struct(new(TryPatchTool), "*")
+
+// Name returns the name of the tool
+func (t *TryPatchTool) Name() string {
+	return "fs_try_patch"
+}
+
+// Description returns a description of the try patch tool
+func (t *TryPatchTool) Description() string {
+	return tryPatchToolDescription
+}
+
+// Schema returns the JSON schema for the try patch tool
+func (t *TryPatchTool) Schema() *jsonschema.Schema {
+	return jsonschema.Reflect(&TryPatchInput{})
+}
+
+// goOverlay is the format of the file passed to `go test -overlay`
+type goOverlay struct {
+	Replace map[string]string `json:"Replace"`
+}
+
+// Execute applies the patch to a temporary copy of the file and runs tests against it using
+// a Go build overlay, leaving the working tree untouched
+func (t *TryPatchTool) Execute(ctx context.Context, input TryPatchInput) (TryPatchOutput, error) {
+	log.Info("Starting try patch operation", zap.String("path", input.Path))
+
+	if input.Diff == "" {
+		log.Error("Empty diff provided")
+		return TryPatchOutput{}, toolerr.New(toolerr.InvalidInput, "diff is required")
+	}
+
+	content, err := iofs.ReadFile(t.FilteredFS, input.Path)
+	if err != nil {
+		log.Error("Failed to read file", zap.String("path", input.Path), zap.Error(err))
+		return TryPatchOutput{}, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	result, err := simplediff.ApplyDiff(string(content), input.Diff)
+	if err != nil {
+		log.Error("Failed to apply patch", zap.Error(err))
+		return TryPatchOutput{}, fmt.Errorf("failed to apply patch: %w", err)
+	}
+
+	rootDir, err := filepath.Abs(t.RepoFS.Path())
+	if err != nil {
+		return TryPatchOutput{}, fmt.Errorf("failed to resolve repository path: %w", err)
+	}
+
+	tempDir, err := os.MkdirTemp("", "autoswe-try-patch-*")
+	if err != nil {
+		return TryPatchOutput{}, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	patchedPath := filepath.Join(tempDir, filepath.Base(input.Path))
+	if err := os.WriteFile(patchedPath, []byte(result), 0644); err != nil {
+		return TryPatchOutput{}, fmt.Errorf("failed to write patched file: %w", err)
+	}
+
+	overlay, err := json.Marshal(goOverlay{
+		Replace: map[string]string{
+			filepath.Join(rootDir, filepath.FromSlash(input.Path)): patchedPath,
+		},
+	})
+	if err != nil {
+		return TryPatchOutput{}, fmt.Errorf("failed to marshal overlay: %w", err)
+	}
+
+	overlayPath := filepath.Join(tempDir, "overlay.json")
+	if err := os.WriteFile(overlayPath, overlay, 0644); err != nil {
+		return TryPatchOutput{}, fmt.Errorf("failed to write overlay: %w", err)
+	}
+
+	pkg := input.Package
+	if pkg == "" {
+		pkg = "./" + filepath.ToSlash(filepath.Dir(filepath.FromSlash(input.Path)))
+	}
+
+	cmd := exec.CommandContext(ctx, "go", "test", "-overlay="+overlayPath, pkg)
+	cmd.Dir = rootDir
+	out, err := cmd.CombinedOutput()
+
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		log.Error("Failed to run tests", zap.Error(err))
+		return TryPatchOutput{}, fmt.Errorf("failed to run tests: %w", err)
+	}
+
+	passed := err == nil
+	log.Info("Try patch completed", zap.String("package", pkg), zap.Bool("passed", passed))
+
+	return TryPatchOutput{
+		Passed: passed,
+		Output: string(out),
+	}, nil
+}
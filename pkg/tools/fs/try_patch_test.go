@@ -0,0 +1,76 @@
+package fs
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+)
+
+func TestTryPatch(t *testing.T) {
+	if err := log.Init(true); err != nil {
+		t.Fatalf("Failed to initialize logger: %v", err)
+	}
+
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not found in PATH")
+	}
+
+	rootDir := t.TempDir()
+	buggy := "package calc\n\nfunc Add(a, b int) int {\n\treturn a - b\n}\n"
+	files := map[string]string{
+		"go.mod":           "module example.com/calc\n\ngo 1.21\n",
+		"calc/add.go":      buggy,
+		"calc/add_test.go": "package calc\n\nimport \"testing\"\n\nfunc TestAdd(t *testing.T) {\n\tif got := Add(1, 2); got != 3 {\n\t\tt.Fatalf(\"Add(1, 2) = %d, want 3\", got)\n\t}\n}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(rootDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repoFS := repo.NewRepoFS(rootDir)
+	filteredFS, err := repoFS.Filter()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tool := &TryPatchTool{
+		RepoFS:     repoFS,
+		FilteredFS: filteredFS,
+	}
+
+	fix := "<<<<<<< SEARCH\n\treturn a - b\n=======\n\treturn a + b\n>>>>>>> REPLACE"
+	output, err := tool.Execute(context.Background(), TryPatchInput{Path: "calc/add.go", Diff: fix})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if !output.Passed {
+		t.Errorf("Execute() passed = false, want true; output:\n%s", output.Output)
+	}
+
+	content, err := os.ReadFile(filepath.Join(rootDir, "calc/add.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != buggy {
+		t.Errorf("file was modified:\n%s", content)
+	}
+
+	breakIt := "<<<<<<< SEARCH\n\treturn a - b\n=======\n\treturn a * b\n>>>>>>> REPLACE"
+	output, err = tool.Execute(context.Background(), TryPatchInput{Path: "calc/add.go", Diff: breakIt})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if output.Passed {
+		t.Errorf("Execute() passed = true for a patch that fails tests")
+	}
+}
@@ -0,0 +1,193 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/wire"
+	"github.com/invopop/jsonschema"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/russellhaering/autoswe/pkg/tools/toolerr"
+	"go.uber.org/zap"
+
+	_ "embed"
+)
+
+//go:embed blame.md
+var blameToolDescription string
+
+// BlameInput represents the input parameters for the Blame tool
+type BlameInput struct {
+	Path      string `json:"path" jsonschema_description:"Path to the file to blame"`
+	StartLine int    `json:"start_line,omitempty" jsonschema_description:"Optional first line (1-based, inclusive) to blame"`
+	EndLine   int    `json:"end_line,omitempty" jsonschema_description:"Optional last line (1-based, inclusive) to blame"`
+}
+
+// BlameLine represents the attribution of a single line
+type BlameLine struct {
+	Commit     string `json:"commit"`
+	Author     string `json:"author"`
+	Date       string `json:"date"`
+	LineNumber int    `json:"line_number"`
+	Line       string `json:"line"`
+}
+
+// BlameOutput represents the output of the Blame tool
+type BlameOutput struct {
+	Lines []BlameLine `json:"lines"`
+}
+
+// BlameTool implements the git blame tool
+type BlameTool struct {
+	RepoFS *repo.RepositoryFS
+}
+
+var ProvideBlameTool = wire.Struct(new(BlameTool), "*")
+
+// Name returns the name of the tool
+func (t *BlameTool) Name() string {
+	return "git_blame"
+}
+
+// Description returns a description of the git blame tool
+func (t *BlameTool) Description() string {
+	return blameToolDescription
+}
+
+// Schema returns the JSON schema for the git blame tool
+func (t *BlameTool) Schema() *jsonschema.Schema {
+	return jsonschema.Reflect(&BlameInput{})
+}
+
+// Execute implements the git blame operation
+func (t *BlameTool) Execute(ctx context.Context, input BlameInput) (BlameOutput, error) {
+	log.Info("Starting git blame operation",
+		zap.String("path", input.Path),
+		zap.Int("start_line", input.StartLine),
+		zap.Int("end_line", input.EndLine))
+
+	if input.Path == "" {
+		log.Error("No path provided")
+		return BlameOutput{}, toolerr.New(toolerr.InvalidInput, "path is required")
+	}
+
+	if input.StartLine < 0 || input.EndLine < 0 {
+		return BlameOutput{}, toolerr.New(toolerr.InvalidInput, "line numbers must be positive")
+	}
+
+	if input.EndLine > 0 && input.StartLine > input.EndLine {
+		return BlameOutput{}, toolerr.New(toolerr.InvalidInput, "start_line %d is after end_line %d", input.StartLine, input.EndLine)
+	}
+
+	args := []string{"blame", "--porcelain"}
+	if input.StartLine > 0 || input.EndLine > 0 {
+		start := input.StartLine
+		if start == 0 {
+			start = 1
+		}
+
+		lineRange := fmt.Sprintf("%d,", start)
+		if input.EndLine > 0 {
+			lineRange += strconv.Itoa(input.EndLine)
+		}
+
+		args = append(args, "-L", lineRange)
+	}
+	args = append(args, "--", input.Path)
+
+	cfg := &Config{
+		WorkDir: t.RepoFS.Path(),
+	}
+
+	out, err := ExecGit(ctx, cfg, args...)
+	if err != nil {
+		log.Error("Git blame failed", zap.Error(err), zap.String("output", out))
+		return BlameOutput{}, fmt.Errorf("git blame failed: %w", err)
+	}
+
+	lines, err := parseBlamePorcelain(out)
+	if err != nil {
+		return BlameOutput{}, fmt.Errorf("failed to parse git blame output: %w", err)
+	}
+
+	log.Info("Git blame completed successfully", zap.Int("lines", len(lines)))
+
+	return BlameOutput{
+		Lines: lines,
+	}, nil
+}
+
+// parseBlamePorcelain parses the output of `git blame --porcelain`. Commit details are only
+// printed the first time a commit appears, so they are remembered for subsequent lines.
+func parseBlamePorcelain(out string) ([]BlameLine, error) {
+	type commitInfo struct {
+		author string
+		date   string
+	}
+
+	commits := make(map[string]*commitInfo)
+	var result []BlameLine
+	var current *BlameLine
+
+	for _, line := range strings.Split(out, "\n") {
+		if current == nil {
+			if line == "" {
+				continue
+			}
+
+			// Header: <sha> <original line> <final line> [<lines in group>]
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("unexpected blame header: %q", line)
+			}
+
+			lineNumber, err := strconv.Atoi(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("invalid line number in blame header %q: %w", line, err)
+			}
+
+			if commits[fields[0]] == nil {
+				commits[fields[0]] = &commitInfo{}
+			}
+
+			current = &BlameLine{
+				Commit:     fields[0],
+				LineNumber: lineNumber,
+			}
+			continue
+		}
+
+		info := commits[current.Commit]
+
+		switch {
+		case strings.HasPrefix(line, "\t"):
+			current.Line = strings.TrimPrefix(line, "\t")
+			current.Author = info.author
+			current.Date = info.date
+			result = append(result, *current)
+			current = nil
+		case strings.HasPrefix(line, "author "):
+			info.author = strings.TrimPrefix(line, "author ")
+		case strings.HasPrefix(line, "author-time "):
+			seconds, err := strconv.ParseInt(strings.TrimPrefix(line, "author-time "), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid author time %q: %w", line, err)
+			}
+			info.date = time.Unix(seconds, 0).UTC().Format(time.RFC3339)
+		}
+	}
+
+	// ExecGit trims trailing whitespace, which swallows the content line of a final blank line
+	if current != nil {
+		info := commits[current.Commit]
+		current.Author = info.author
+		current.Date = info.date
+		result = append(result, *current)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,128 @@
+package git
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// blamePorcelain is `git blame --porcelain` output for a file with lines from two commits,
+// one of them the boundary commit, and an uncommitted last line
+const blamePorcelain = `fecb445841fdf529505d7f410671ee92a37a4c66 1 1 1
+author Ada Lovelace
+author-mail <ada@example.com>
+author-time 1704164645
+author-tz +0000
+committer Ada Lovelace
+committer-mail <ada@example.com>
+committer-time 1704164645
+committer-tz +0000
+summary first
+boundary
+filename f.txt
+	one
+06df8807961fff9134f8ce72e0d011802fa3e2fd 2 2 1
+author Bob
+author-mail <bob@example.com>
+author-time 1706933106
+author-tz +0000
+committer Bob
+committer-mail <bob@example.com>
+committer-time 1706933106
+committer-tz +0000
+summary second
+previous fecb445841fdf529505d7f410671ee92a37a4c66 f.txt
+filename f.txt
+	2
+fecb445841fdf529505d7f410671ee92a37a4c66 3 3 1
+	three
+06df8807961fff9134f8ce72e0d011802fa3e2fd 4 4 2
+	four
+06df8807961fff9134f8ce72e0d011802fa3e2fd 5 5
+	
+0000000000000000000000000000000000000000 6 6 1
+author Not Committed Yet
+author-mail <not.committed.yet>
+author-time 1792218932
+author-tz +0000
+committer Not Committed Yet
+committer-mail <not.committed.yet>
+committer-time 1792218932
+committer-tz +0000
+summary Version of f.txt from f.txt
+previous 06df8807961fff9134f8ce72e0d011802fa3e2fd f.txt
+filename f.txt
+	uncommitted
+`
+
+func TestParseBlamePorcelain(t *testing.T) {
+	const (
+		first       = "fecb445841fdf529505d7f410671ee92a37a4c66"
+		second      = "06df8807961fff9134f8ce72e0d011802fa3e2fd"
+		uncommitted = "0000000000000000000000000000000000000000"
+	)
+
+	firstLines := []BlameLine{
+		{Commit: first, Author: "Ada Lovelace", Date: "2024-01-02T03:04:05Z", LineNumber: 1, Line: "one"},
+		{Commit: second, Author: "Bob", Date: "2024-02-03T04:05:06Z", LineNumber: 2, Line: "2"},
+		{Commit: first, Author: "Ada Lovelace", Date: "2024-01-02T03:04:05Z", LineNumber: 3, Line: "three"},
+		{Commit: second, Author: "Bob", Date: "2024-02-03T04:05:06Z", LineNumber: 4, Line: "four"},
+		{Commit: second, Author: "Bob", Date: "2024-02-03T04:05:06Z", LineNumber: 5, Line: ""},
+	}
+
+	tests := []struct {
+		name    string
+		out     string
+		want    []BlameLine
+		wantErr bool
+	}{
+		{
+			name: "repeated and uncommitted lines",
+			out:  blamePorcelain,
+			want: append(firstLines[:5:5], BlameLine{Commit: uncommitted, Author: "Not Committed Yet", Date: "2026-10-17T06:35:32Z", LineNumber: 6, Line: "uncommitted"}),
+		},
+		{
+			name: "without a trailing newline",
+			out:  strings.TrimSuffix(blamePorcelain, "\n"),
+			want: append(firstLines[:5:5], BlameLine{Commit: uncommitted, Author: "Not Committed Yet", Date: "2026-10-17T06:35:32Z", LineNumber: 6, Line: "uncommitted"}),
+		},
+		{
+			// ExecGit trims trailing whitespace, removing the tab of a final blank line
+			name: "trimmed final blank line",
+			out:  blamePorcelain[:strings.Index(blamePorcelain, "\t\n")],
+			want: firstLines,
+		},
+		{
+			name: "empty output",
+			out:  "",
+			want: nil,
+		},
+		{
+			name:    "malformed header",
+			out:     "fecb445841fdf529505d7f410671ee92a37a4c66\n\tone\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid line number",
+			out:     "fecb445841fdf529505d7f410671ee92a37a4c66 1 one 1\n\tone\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid author time",
+			out:     "fecb445841fdf529505d7f410671ee92a37a4c66 1 1 1\nauthor-time yesterday\n\tone\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseBlamePorcelain(tt.out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseBlamePorcelain() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseBlamePorcelain() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/wire"
+	"github.com/invopop/jsonschema"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/russellhaering/autoswe/pkg/tools/toolerr"
+	"go.uber.org/zap"
+
+	_ "embed"
+)
+
+//go:embed branch.md
+var branchToolDescription string
+
+// BranchInput represents the input parameters for the Branch tool
+type BranchInput struct {
+	Name   string `json:"name" jsonschema_description:"Name of the branch to switch to"`
+	Create bool   `json:"create,omitempty" jsonschema_description:"If true, create the branch before switching to it"`
+	Base   string `json:"base,omitempty" jsonschema_description:"Commit or branch to create the new branch from (defaults to HEAD)"`
+	Force  bool   `json:"force,omitempty" jsonschema_description:"If true, switch even if the working tree has uncommitted changes"`
+}
+
+// BranchOutput represents the output of the Branch tool
+type BranchOutput struct {
+	Output string `json:"output"`
+}
+
+// BranchTool implements the git branch tool
+type BranchTool struct {
+	RepoFS *repo.RepositoryFS
+}
+
+var ProvideBranchTool = wire.Struct(new(BranchTool), "*")
+
+// Name returns the name of the tool
+func (t *BranchTool) Name() string {
+	return "git_branch"
+}
+
+// Description returns a description of the git branch tool
+func (t *BranchTool) Description() string {
+	return branchToolDescription
+}
+
+// Schema returns the JSON schema for the git branch tool
+func (t *BranchTool) Schema() *jsonschema.Schema {
+	return jsonschema.Reflect(&BranchInput{})
+}
+
+// Execute implements the git branch operation
+func (t *BranchTool) Execute(ctx context.Context, input BranchInput) (BranchOutput, error) {
+	log.Info("Starting git branch operation",
+		zap.String("name", input.Name),
+		zap.Bool("create", input.Create),
+		zap.String("base", input.Base))
+
+	if input.Name == "" {
+		log.Error("No branch name provided")
+		return BranchOutput{}, toolerr.New(toolerr.InvalidInput, "branch name is required")
+	}
+
+	if input.Base != "" && !input.Create {
+		return BranchOutput{}, fmt.Errorf("base can only be specified when creating a branch")
+	}
+
+	cfg := &Config{
+		WorkDir: t.RepoFS.Path(),
+	}
+
+	if out, err := ExecGit(ctx, cfg, "check-ref-format", "--branch", input.Name); err != nil {
+		log.Error("Invalid branch name", zap.String("name", input.Name), zap.String("output", out))
+		return BranchOutput{}, toolerr.New(toolerr.InvalidInput, "invalid branch name: %s", input.Name)
+	}
+
+	if !input.Force {
+		status, err := ExecGit(ctx, cfg, "status", "--porcelain")
+		if err != nil {
+			log.Error("Failed to get git status", zap.Error(err), zap.String("output", status))
+			return BranchOutput{}, fmt.Errorf("failed to get git status: %w", err)
+		}
+
+		if status != "" {
+			return BranchOutput{}, fmt.Errorf("working tree has uncommitted changes; commit them or set force to switch anyway:\n%s", status)
+		}
+	}
+
+	// Unlike checkout, switch never treats the name as a path, so a name that isn't a branch
+	// can't discard changes to a file
+	args := []string{"switch"}
+	if input.Create {
+		args = append(args, "-c")
+	}
+	args = append(args, input.Name)
+	if input.Base != "" {
+		args = append(args, input.Base)
+	}
+
+	out, err := ExecGit(ctx, cfg, args...)
+	if err != nil {
+		log.Error("Git switch failed", zap.Error(err), zap.String("output", out))
+		return BranchOutput{}, fmt.Errorf("git switch failed: %w: %s", err, strings.TrimSpace(out))
+	}
+
+	log.Info("Git branch operation completed successfully", zap.String("name", input.Name))
+
+	return BranchOutput{
+		Output: out,
+	}, nil
+}
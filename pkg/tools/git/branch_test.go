@@ -0,0 +1,82 @@
+package git
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/tools/toolerr"
+)
+
+func TestBranchTool(t *testing.T) {
+	dir, repoFS := newTestRepo(t)
+	commitFile(t, dir, "a.go", "package a\n", "Add a")
+
+	tool := &BranchTool{RepoFS: repoFS}
+	branch := func(input BranchInput) error {
+		_, err := tool.Execute(context.Background(), input)
+		return err
+	}
+	branches := func() string {
+		return strings.TrimRight(runGit(t, dir, "branch", "--list", "--format=%(HEAD) %(refname:short)"), "\n")
+	}
+
+	// Creating a branch switches to it
+	if err := branch(BranchInput{Name: "feature", Create: true}); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if got, want := branches(), "* feature\n  main"; got != want {
+		t.Errorf("branches = %q, want %q", got, want)
+	}
+
+	// Creating a branch that already exists fails, leaving the current branch alone
+	if err := branch(BranchInput{Name: "main", Create: true}); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Execute() error = %v, want an error for an existing branch", err)
+	}
+	if got, want := branches(), "* feature\n  main"; got != want {
+		t.Errorf("branches = %q, want %q", got, want)
+	}
+
+	// Switching to an existing branch
+	if err := branch(BranchInput{Name: "main"}); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if got, want := branches(), "  feature\n* main"; got != want {
+		t.Errorf("branches = %q, want %q", got, want)
+	}
+
+	// Uncommitted changes prevent switching unless forced
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a // changed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := branch(BranchInput{Name: "feature"}); err == nil || !strings.Contains(err.Error(), "uncommitted changes") {
+		t.Errorf("Execute() error = %v, want an error for uncommitted changes", err)
+	}
+	if err := branch(BranchInput{Name: "feature", Force: true}); err != nil {
+		t.Errorf("Execute() with force error = %v", err)
+	}
+
+	// A name that is a path rather than a branch fails, rather than discarding the changes
+	// to the file
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a // changed again\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := branch(BranchInput{Name: "a.go", Force: true}); err == nil {
+		t.Error("Execute() with a file name succeeded, want an error")
+	}
+	if content, err := os.ReadFile(filepath.Join(dir, "a.go")); err != nil || string(content) != "package a // changed again\n" {
+		t.Errorf("a.go = %q, %v, want the uncommitted change kept", content, err)
+	}
+
+	// Invalid input
+	for _, input := range []BranchInput{{}, {Name: "bad..name", Create: true}} {
+		if err := branch(input); toolerr.CategoryOf(err) != toolerr.InvalidInput {
+			t.Errorf("Execute(%+v) error = %v, want invalid input", input, err)
+		}
+	}
+	if err := branch(BranchInput{Name: "other", Base: "main"}); err == nil {
+		t.Error("Execute() with a base but not create succeeded, want an error")
+	}
+}
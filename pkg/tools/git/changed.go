@@ -0,0 +1,53 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// defaultBranches are the branches tried, in order, as the base for ChangedFiles
+var defaultBranches = []string{"origin/HEAD", "main", "master"}
+
+// ChangedFiles returns the paths, relative to cfg.WorkDir, of files beneath it that differ
+// from base, including uncommitted and untracked files. If base is empty, the merge base
+// of HEAD and the default branch is used, so the result is everything changed on the
+// current branch.
+func ChangedFiles(ctx context.Context, cfg *Config, base string) ([]string, error) {
+	if base == "" {
+		base = defaultBase(ctx, cfg)
+	}
+
+	// Without --relative, diff paths are relative to the top of the repository rather than
+	// the working directory, which differ when the working directory is a subdirectory
+	diff, err := ExecGitOutput(ctx, cfg, "diff", "--name-only", "--relative", base, "--")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files changed since %s: %w", base, err)
+	}
+
+	untracked, err := ExecGitOutput(ctx, cfg, "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list untracked files: %w", err)
+	}
+
+	var paths []string
+	for _, line := range strings.Split(diff+"\n"+untracked, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			paths = append(paths, line)
+		}
+	}
+
+	return paths, nil
+}
+
+// defaultBase returns the merge base of HEAD and the first default branch that exists,
+// falling back to HEAD so that only uncommitted changes are considered
+func defaultBase(ctx context.Context, cfg *Config) string {
+	for _, branch := range defaultBranches {
+		if base, err := ExecGitOutput(ctx, cfg, "merge-base", "HEAD", branch); err == nil {
+			return base
+		}
+	}
+
+	return "HEAD"
+}
@@ -0,0 +1,50 @@
+package git
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestChangedFiles(t *testing.T) {
+	dir, _ := newTestRepo(t)
+	if err := os.MkdirAll(filepath.Join(dir, "sub", "pkg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, dir, "top.go", "package top\n", "Add top")
+	commitFile(t, dir, "sub/pkg/a.go", "package pkg\n", "Add a")
+	commitFile(t, dir, "sub/pkg/b.go", "package pkg\n", "Add b")
+
+	// Change a committed file, and add an untracked one, both inside and outside sub
+	runGit(t, dir, "checkout", "-q", "-b", "feature")
+	commitFile(t, dir, "sub/pkg/a.go", "package pkg // changed\n", "Change a")
+	commitFile(t, dir, "top.go", "package top // changed\n", "Change top")
+	for _, path := range []string{"sub/new.go", "untracked.go"} {
+		if err := os.WriteFile(filepath.Join(dir, path), []byte("package new\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	changed := func(workDir string) []string {
+		t.Helper()
+
+		paths, err := ChangedFiles(context.Background(), &Config{WorkDir: workDir}, "")
+		if err != nil {
+			t.Fatalf("ChangedFiles() error = %v", err)
+		}
+		sort.Strings(paths)
+		return paths
+	}
+
+	if got, want := changed(dir), []string{"sub/new.go", "sub/pkg/a.go", "top.go", "untracked.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ChangedFiles() = %v, want %v", got, want)
+	}
+
+	// From a subdirectory, paths are relative to it, and files outside it are left out
+	if got, want := changed(filepath.Join(dir, "sub")), []string{"new.go", "pkg/a.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ChangedFiles() in a subdirectory = %v, want %v", got, want)
+	}
+}
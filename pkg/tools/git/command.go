@@ -8,6 +8,7 @@ import (
 	"github.com/invopop/jsonschema"
 	"github.com/russellhaering/autoswe/pkg/log"
 	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/russellhaering/autoswe/pkg/tools/toolerr"
 	"go.uber.org/zap"
 
 	_ "embed"
@@ -49,12 +50,12 @@ func (t *CommandTool) Schema() *jsonschema.Schema {
 }
 
 // Execute implements the git command operation
-func (t *CommandTool) Execute(_ context.Context, input CommandInput) (CommandOutput, error) {
+func (t *CommandTool) Execute(ctx context.Context, input CommandInput) (CommandOutput, error) {
 	log.Info("Starting git command operation", zap.Any("args", input.Args))
 
 	if len(input.Args) == 0 {
 		log.Error("No git command arguments provided")
-		return CommandOutput{}, fmt.Errorf("no git command arguments provided")
+		return CommandOutput{}, toolerr.New(toolerr.InvalidInput, "no git command arguments provided")
 	}
 
 	cfg := &Config{
@@ -62,7 +63,7 @@ func (t *CommandTool) Execute(_ context.Context, input CommandInput) (CommandOut
 	}
 
 	// Execute git command directly
-	out, err := ExecGit(cfg, input.Args...)
+	out, err := ExecGit(ctx, cfg, input.Args...)
 	if err != nil {
 		log.Error("Git command failed", zap.Error(err), zap.String("output", out))
 		return CommandOutput{}, fmt.Errorf("git command failed: %w", err)
@@ -18,7 +18,23 @@ var commitToolDescription string
 
 // CommitInput represents the input parameters for the Commit tool
 type CommitInput struct {
-	Message string `json:"message" jsonschema_description:"Commit message"`
+	Message    string   `json:"message" jsonschema_description:"Commit message"`
+	Paths      []string `json:"paths,omitempty" jsonschema_description:"Optional paths to stage and commit. Only these paths are committed, even if other changes were already staged. If not specified, all changes are staged and committed."`
+	AllowEmpty bool     `json:"allow_empty,omitempty" jsonschema_description:"If true, create the commit even if there are no changes"`
+
+	AuthorName  string `json:"author_name,omitempty" jsonschema_description:"Optional author and committer name, overriding the configured identity"`
+	AuthorEmail string `json:"author_email,omitempty" jsonschema_description:"Optional author and committer email, overriding the configured identity"`
+	Sign        bool   `json:"sign,omitempty" jsonschema_description:"If true, GPG-sign the commit"`
+}
+
+// CommitIdentity holds the default identity and signing behavior for commits
+type CommitIdentity struct {
+	// AuthorName is used as both the author and committer name
+	AuthorName string
+	// AuthorEmail is used as both the author and committer email
+	AuthorEmail string
+	// Sign GPG-signs every commit
+	Sign bool
 }
 
 // CommitOutput represents the output of the Commit tool
@@ -28,7 +44,8 @@ type CommitOutput struct {
 
 // CommitTool implements the git commit tool
 type CommitTool struct {
-	RepoFS *repo.RepositoryFS
+	RepoFS   *repo.RepositoryFS
+	Identity CommitIdentity
 }
 
 var ProvideCommitTool = wire.Struct(new(CommitTool), "*")
@@ -49,22 +66,22 @@ func (t *CommitTool) Schema() *jsonschema.Schema {
 }
 
 // Execute implements the git commit operation
-func (t *CommitTool) Execute(_ context.Context, input CommitInput) (CommitOutput, error) {
-	log.Info("Starting git commit operation", zap.String("message", input.Message))
+func (t *CommitTool) Execute(ctx context.Context, input CommitInput) (CommitOutput, error) {
+	log.Info("Starting git commit operation", zap.String("message", input.Message), zap.Strings("paths", input.Paths))
 
 	cfg := &Config{
 		WorkDir: t.RepoFS.Path(),
 	}
 
-	// First stage all changes using direct git execution
-	out, err := ExecGit(cfg, "add", ".")
+	// First stage the requested paths, or all changes, using direct git execution
+	out, err := ExecGit(ctx, cfg, stageArgs(input.Paths)...)
 	if err != nil {
 		log.Error("Failed to stage changes", zap.Error(err), zap.String("output", out))
 		return CommitOutput{}, fmt.Errorf("failed to stage changes: %w", err)
 	}
 
 	// Then create the commit using direct git execution
-	out, err = ExecGit(cfg, "commit", "-m", input.Message)
+	out, err = ExecGit(ctx, cfg, commitArgs(input, t.Identity)...)
 	if err != nil {
 		log.Error("Commit failed", zap.Error(err), zap.String("output", out))
 		return CommitOutput{}, fmt.Errorf("commit failed: %w", err)
@@ -76,3 +93,49 @@ func (t *CommitTool) Execute(_ context.Context, input CommitInput) (CommitOutput
 		Output: out,
 	}, nil
 }
+
+// stageArgs builds the git arguments to stage the given paths, or everything if none are given
+func stageArgs(paths []string) []string {
+	if len(paths) == 0 {
+		return []string{"add", "."}
+	}
+
+	return append([]string{"add", "--"}, paths...)
+}
+
+// commitArgs builds the git arguments to create a commit. Identity fields set on the input
+// take precedence over the configured defaults. If the input has paths, only they are
+// committed, leaving anything else that was staged for a later commit.
+func commitArgs(input CommitInput, defaults CommitIdentity) []string {
+	name := defaults.AuthorName
+	if input.AuthorName != "" {
+		name = input.AuthorName
+	}
+
+	email := defaults.AuthorEmail
+	if input.AuthorEmail != "" {
+		email = input.AuthorEmail
+	}
+
+	var args []string
+	if name != "" {
+		args = append(args, "-c", "user.name="+name)
+	}
+	if email != "" {
+		args = append(args, "-c", "user.email="+email)
+	}
+
+	args = append(args, "commit", "-m", input.Message)
+	if input.AllowEmpty {
+		args = append(args, "--allow-empty")
+	}
+	if input.Sign || defaults.Sign {
+		args = append(args, "-S")
+	}
+	if len(input.Paths) > 0 {
+		args = append(args, "--only", "--")
+		args = append(args, input.Paths...)
+	}
+
+	return args
+}
@@ -0,0 +1,101 @@
+package git
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStageArgs(t *testing.T) {
+	if got, want := stageArgs(nil), []string{"add", "."}; !reflect.DeepEqual(got, want) {
+		t.Errorf("stageArgs(nil) = %v, want %v", got, want)
+	}
+
+	if got, want := stageArgs([]string{"a.go", "b.go"}), []string{"add", "--", "a.go", "b.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("stageArgs(paths) = %v, want %v", got, want)
+	}
+}
+
+func TestCommitArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    CommitInput
+		defaults CommitIdentity
+		want     []string
+	}{
+		{
+			name:  "message only",
+			input: CommitInput{Message: "Fix bug"},
+			want:  []string{"commit", "-m", "Fix bug"},
+		},
+		{
+			name:  "allow empty",
+			input: CommitInput{Message: "Empty", AllowEmpty: true},
+			want:  []string{"commit", "-m", "Empty", "--allow-empty"},
+		},
+		{
+			name:     "configured identity and signing",
+			input:    CommitInput{Message: "Fix bug"},
+			defaults: CommitIdentity{AuthorName: "CI Bot", AuthorEmail: "ci@example.com", Sign: true},
+			want:     []string{"-c", "user.name=CI Bot", "-c", "user.email=ci@example.com", "commit", "-m", "Fix bug", "-S"},
+		},
+		{
+			name:     "input overrides configured identity",
+			input:    CommitInput{Message: "Fix bug", AuthorName: "Jane", AuthorEmail: "jane@example.com"},
+			defaults: CommitIdentity{AuthorName: "CI Bot", AuthorEmail: "ci@example.com"},
+			want:     []string{"-c", "user.name=Jane", "-c", "user.email=jane@example.com", "commit", "-m", "Fix bug"},
+		},
+		{
+			name:  "only the given paths",
+			input: CommitInput{Message: "Fix bug", Paths: []string{"a.go", "b.go"}},
+			want:  []string{"commit", "-m", "Fix bug", "--only", "--", "a.go", "b.go"},
+		},
+		{
+			name:  "sign from input",
+			input: CommitInput{Message: "Fix bug", Sign: true},
+			want:  []string{"commit", "-m", "Fix bug", "-S"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := commitArgs(tt.input, tt.defaults); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("commitArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommitToolPaths(t *testing.T) {
+	dir, repoFS := newTestRepo(t)
+	commitFile(t, dir, "a.go", "package a\n", "Add a")
+	commitFile(t, dir, "staged.go", "package staged\n", "Add staged")
+
+	// An unrelated change is already staged when the tool commits other paths
+	if err := os.WriteFile(filepath.Join(dir, "staged.go"), []byte("package staged // changed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, "add", "staged.go")
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a // changed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "new.go"), []byte("package a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tool := &CommitTool{RepoFS: repoFS}
+	if _, err := tool.Execute(context.Background(), CommitInput{Message: "Change a", Paths: []string{"a.go", "new.go"}}); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	if got, want := runGit(t, dir, "show", "--name-only", "--format=%s", "HEAD"), "Change a\n\na.go\nnew.go\n"; got != want {
+		t.Errorf("committed = %q, want %q", got, want)
+	}
+
+	// The unrelated change is still staged
+	if got, want := runGit(t, dir, "diff", "--cached", "--name-only"), "staged.go\n"; got != want {
+		t.Errorf("staged = %q, want %q", got, want)
+	}
+}
@@ -1,6 +1,8 @@
 package git
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"os/exec"
 	"strings"
@@ -16,9 +18,9 @@ type Config struct {
 }
 
 // ExecGit executes a git command directly on the local system
-func ExecGit(cfg *Config, args ...string) (string, error) {
+func ExecGit(ctx context.Context, cfg *Config, args ...string) (string, error) {
 	// Create the command with git and the provided arguments
-	cmd := exec.Command("git", args...)
+	cmd := exec.CommandContext(ctx, "git", args...)
 
 	// Set the working directory
 	cmd.Dir = cfg.WorkDir
@@ -37,3 +39,25 @@ func ExecGit(cfg *Config, args ...string) (string, error) {
 
 	return strings.TrimSpace(string(out)), nil
 }
+
+// ExecGitOutput executes a git command like ExecGit, but returns only its standard output, so
+// that warnings on standard error can't be mistaken for output that is parsed. Standard error
+// is included in the error if the command fails.
+func ExecGitOutput(ctx context.Context, cfg *Config, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = cfg.WorkDir
+
+	log.Info("Executing git command",
+		zap.String("dir", cmd.Dir),
+		zap.Strings("args", append([]string{"git"}, args...)),
+	)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
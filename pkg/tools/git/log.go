@@ -0,0 +1,135 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/wire"
+	"github.com/invopop/jsonschema"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"go.uber.org/zap"
+
+	_ "embed"
+)
+
+//go:embed log.md
+var logToolDescription string
+
+const (
+	defaultLogLimit = 20
+	maxLogLimit     = 200
+
+	// logFieldSeparator separates fields in the custom log format, and logRecordSeparator ends
+	// each commit; neither can appear in commit metadata
+	logFieldSeparator  = "\x1f"
+	logRecordSeparator = "\x1e"
+)
+
+// LogInput represents the input parameters for the Log tool
+type LogInput struct {
+	Path  string `json:"path,omitempty" jsonschema_description:"Optional path to limit the history to"`
+	Limit int    `json:"limit,omitempty" jsonschema_description:"Maximum number of commits to return (default 20, max 200)"`
+}
+
+// LogEntry represents a single commit in the history
+type LogEntry struct {
+	Hash    string `json:"hash"`
+	Subject string `json:"subject"`
+	Author  string `json:"author"`
+	Date    string `json:"date"`
+}
+
+// LogOutput represents the output of the Log tool
+type LogOutput struct {
+	Entries []LogEntry `json:"entries"`
+}
+
+// LogTool implements the git log tool
+type LogTool struct {
+	RepoFS *repo.RepositoryFS
+}
+
+var ProvideLogTool = wire.Struct(new(LogTool), "*")
+
+// Name returns the name of the tool
+func (t *LogTool) Name() string {
+	return "git_log"
+}
+
+// Description returns a description of the git log tool
+func (t *LogTool) Description() string {
+	return logToolDescription
+}
+
+// Schema returns the JSON schema for the git log tool
+func (t *LogTool) Schema() *jsonschema.Schema {
+	return jsonschema.Reflect(&LogInput{})
+}
+
+// Execute implements the git log operation
+func (t *LogTool) Execute(ctx context.Context, input LogInput) (LogOutput, error) {
+	log.Info("Starting git log operation", zap.String("path", input.Path), zap.Int("limit", input.Limit))
+
+	limit := input.Limit
+	if limit <= 0 {
+		limit = defaultLogLimit
+	}
+	if limit > maxLogLimit {
+		limit = maxLogLimit
+	}
+
+	format := strings.Join([]string{"%H", "%an", "%aI", "%s"}, logFieldSeparator) + logRecordSeparator
+	args := []string{"log", "--format=" + format, "-n", strconv.Itoa(limit)}
+	if input.Path != "" {
+		args = append(args, "--", input.Path)
+	}
+
+	cfg := &Config{
+		WorkDir: t.RepoFS.Path(),
+	}
+
+	out, err := ExecGit(ctx, cfg, args...)
+	if err != nil {
+		log.Error("Git log failed", zap.Error(err), zap.String("output", out))
+		return LogOutput{}, fmt.Errorf("git log failed: %w", err)
+	}
+
+	entries, err := parseLog(out)
+	if err != nil {
+		return LogOutput{}, err
+	}
+
+	log.Info("Git log completed successfully", zap.Int("entries", len(entries)))
+
+	return LogOutput{
+		Entries: entries,
+	}, nil
+}
+
+// parseLog parses the output of git log in the custom format, one record per commit
+func parseLog(out string) ([]LogEntry, error) {
+	entries := []LogEntry{}
+	for _, record := range strings.Split(out, logRecordSeparator) {
+		record = strings.Trim(record, "\n")
+		if record == "" {
+			continue
+		}
+
+		fields := strings.SplitN(record, logFieldSeparator, 4)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected git log output: %q", record)
+		}
+
+		entries = append(entries, LogEntry{
+			Hash:    fields[0],
+			Author:  fields[1],
+			Date:    fields[2],
+			Subject: fields[3],
+		})
+	}
+
+	return entries, nil
+}
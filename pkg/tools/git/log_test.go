@@ -0,0 +1,115 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+)
+
+// newTestRepo creates a git repository with a main branch in a temporary directory
+func newTestRepo(t *testing.T) (string, *repo.RepositoryFS) {
+	t.Helper()
+
+	if err := log.Init(true); err != nil {
+		t.Fatalf("failed to initialize logger: %v", err)
+	}
+
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q", "-b", "main")
+	runGit(t, dir, "config", "user.name", "Ada Lovelace")
+	runGit(t, dir, "config", "user.email", "ada@example.com")
+	runGit(t, dir, "config", "commit.gpgsign", "false")
+
+	return dir, repo.NewRepoFS(dir)
+}
+
+// commitFile writes a file in a test repository and commits it with the given message
+func commitFile(t *testing.T, dir, path, content, message string) {
+	t.Helper()
+
+	if err := os.WriteFile(filepath.Join(dir, path), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, "add", path)
+	runGit(t, dir, "commit", "-q", "-m", message)
+}
+
+// runGit runs git in dir, failing the test if it fails
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, out)
+	}
+	return string(out)
+}
+
+func TestParseLog(t *testing.T) {
+	// Captured output of git log in the tool's format, which ExecGit trims
+	out := "06df8807961fff9134f8ce72e0d011802fa3e2fd\x1fBob\x1f2024-02-03T04:05:06+00:00\x1fRetry failed requests: back off\x1e\n" +
+		"fecb445841fdf529505d7f410671ee92a37a4c66\x1fAda Lovelace\x1f2024-01-02T03:04:05+00:00\x1fInitial commit\x1e"
+
+	got, err := parseLog(out)
+	if err != nil {
+		t.Fatalf("parseLog() error = %v", err)
+	}
+
+	want := []LogEntry{
+		{Hash: "06df8807961fff9134f8ce72e0d011802fa3e2fd", Author: "Bob", Date: "2024-02-03T04:05:06+00:00", Subject: "Retry failed requests: back off"},
+		{Hash: "fecb445841fdf529505d7f410671ee92a37a4c66", Author: "Ada Lovelace", Date: "2024-01-02T03:04:05+00:00", Subject: "Initial commit"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseLog() = %+v, want %+v", got, want)
+	}
+
+	if got, err := parseLog(""); err != nil || len(got) != 0 {
+		t.Errorf("parseLog(\"\") = %+v, %v, want no entries", got, err)
+	}
+
+	if _, err := parseLog("fecb445841fdf529505d7f410671ee92a37a4c66 Initial commit\x1e"); err == nil {
+		t.Error("parseLog() of malformed output succeeded, want an error")
+	}
+}
+
+func TestLogTool(t *testing.T) {
+	dir, repoFS := newTestRepo(t)
+	commitFile(t, dir, "a.go", "package a\n", "Add a")
+	commitFile(t, dir, "b.go", "package b\n", "Add b\n\nThe body has\n\nblank lines between paragraphs.\n")
+	commitFile(t, dir, "a.go", "package a // changed\n", "Change a")
+
+	tool := &LogTool{RepoFS: repoFS}
+	subjects := func(input LogInput) []string {
+		t.Helper()
+
+		output, err := tool.Execute(context.Background(), input)
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+
+		var subjects []string
+		for _, entry := range output.Entries {
+			if len(entry.Hash) != 40 || entry.Author != "Ada Lovelace" || entry.Date == "" {
+				t.Errorf("Execute() entry = %+v, want a hash, author and date", entry)
+			}
+			subjects = append(subjects, entry.Subject)
+		}
+		return subjects
+	}
+
+	// The body of a commit message isn't mistaken for another commit
+	if got, want := subjects(LogInput{}), []string{"Change a", "Add b", "Add a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Execute() subjects = %v, want %v", got, want)
+	}
+	if got, want := subjects(LogInput{Path: "a.go", Limit: 1}), []string{"Change a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Execute() subjects = %v, want %v", got, want)
+	}
+}
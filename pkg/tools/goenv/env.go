@@ -0,0 +1,114 @@
+package goenv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	iofs "io/fs"
+	"os/exec"
+
+	"github.com/google/wire"
+	"github.com/invopop/jsonschema"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"go.uber.org/zap"
+	"golang.org/x/mod/modfile"
+
+	_ "embed"
+)
+
+//go:embed env.md
+var envToolDescription string
+
+// EnvInput represents the input parameters for the Env tool
+type EnvInput struct {
+	// No parameters needed
+}
+
+// EnvOutput represents the output of the Env tool
+type EnvOutput struct {
+	ModulePath         string `json:"module_path"`
+	GoVersion          string `json:"go_version"`
+	ToolchainDirective string `json:"toolchain_directive,omitempty"`
+	ToolchainVersion   string `json:"toolchain_version"`
+	GOOS               string `json:"goos"`
+	GOARCH             string `json:"goarch"`
+	GOFLAGS            string `json:"goflags,omitempty"`
+}
+
+// EnvTool reports the module and Go toolchain the project is built with
+type EnvTool struct {
+	FilteredFS repo.FilteredFS
+	RepoFS     *repo.RepositoryFS
+}
+
+var ProvideEnvTool = wire.Struct(new(EnvTool), "*")
+
+// Name returns the name of the tool
+func (t *EnvTool) Name() string {
+	return "go_env"
+}
+
+// Description returns a description of the env tool
+func (t *EnvTool) Description() string {
+	return envToolDescription
+}
+
+// Schema returns the JSON schema for the env tool
+func (t *EnvTool) Schema() *jsonschema.Schema {
+	return jsonschema.Reflect(&EnvInput{})
+}
+
+// Execute implements the env operation
+func (t *EnvTool) Execute(ctx context.Context, _ EnvInput) (EnvOutput, error) {
+	log.Info("Starting go env operation")
+
+	data, err := iofs.ReadFile(t.FilteredFS, "go.mod")
+	if err != nil {
+		log.Error("Failed to read go.mod", zap.Error(err))
+		return EnvOutput{}, fmt.Errorf("failed to read go.mod: %w", err)
+	}
+
+	mod, err := modfile.ParseLax("go.mod", data, nil)
+	if err != nil {
+		log.Error("Failed to parse go.mod", zap.Error(err))
+		return EnvOutput{}, fmt.Errorf("failed to parse go.mod: %w", err)
+	}
+
+	var output EnvOutput
+	if mod.Module != nil {
+		output.ModulePath = mod.Module.Mod.Path
+	}
+	if mod.Go != nil {
+		output.GoVersion = mod.Go.Version
+	}
+	if mod.Toolchain != nil {
+		output.ToolchainDirective = mod.Toolchain.Name
+	}
+
+	// Run in the repository so that its go and toolchain directives select the toolchain
+	cmd := exec.CommandContext(ctx, "go", "env", "-json", "GOVERSION", "GOOS", "GOARCH", "GOFLAGS")
+	cmd.Dir = t.RepoFS.Path()
+	out, err := cmd.Output()
+	if err != nil {
+		log.Error("Failed to run go env", zap.Error(err))
+		return EnvOutput{}, fmt.Errorf("failed to run go env: %w", err)
+	}
+
+	var env map[string]string
+	if err := json.Unmarshal(out, &env); err != nil {
+		return EnvOutput{}, fmt.Errorf("failed to parse go env output: %w", err)
+	}
+
+	output.ToolchainVersion = env["GOVERSION"]
+	output.GOOS = env["GOOS"]
+	output.GOARCH = env["GOARCH"]
+	output.GOFLAGS = env["GOFLAGS"]
+
+	log.Info("Go env completed successfully",
+		zap.String("module", output.ModulePath),
+		zap.String("go_version", output.GoVersion),
+		zap.String("toolchain_version", output.ToolchainVersion))
+
+	return output, nil
+}
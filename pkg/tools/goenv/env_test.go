@@ -0,0 +1,46 @@
+package goenv
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvTool(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "go.mod"), []byte("module example.com/fixture\n\ngo 1.21\n"), 0644))
+
+	repoFS := repo.NewRepoFS(rootDir)
+	filteredFS, err := repoFS.Filter()
+	require.NoError(t, err)
+
+	output, err := (&EnvTool{FilteredFS: filteredFS, RepoFS: repoFS}).Execute(context.Background(), EnvInput{})
+	require.NoError(t, err)
+
+	assert.Equal(t, "example.com/fixture", output.ModulePath)
+	assert.Equal(t, "1.21", output.GoVersion)
+	assert.Empty(t, output.ToolchainDirective)
+	assert.Regexp(t, `^go1\.`, output.ToolchainVersion)
+	assert.Equal(t, runtime.GOOS, output.GOOS)
+	assert.Equal(t, runtime.GOARCH, output.GOARCH)
+}
+
+func TestEnvToolNoModule(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	repoFS := repo.NewRepoFS(t.TempDir())
+	filteredFS, err := repoFS.Filter()
+	require.NoError(t, err)
+
+	_, err = (&EnvTool{FilteredFS: filteredFS, RepoFS: repoFS}).Execute(context.Background(), EnvInput{})
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -42,10 +42,10 @@ func (t *Tool) Schema() *jsonschema.Schema {
 }
 
 // Execute implements the lint operation
-func (t *Tool) Execute(_ context.Context, _ Input) (Output, error) {
+func (t *Tool) Execute(ctx context.Context, _ Input) (Output, error) {
 	log.Info("Starting lint operation")
 
-	cmd := exec.Command("golangci-lint", "run")
+	cmd := exec.CommandContext(ctx, "golangci-lint", "run")
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
@@ -0,0 +1,75 @@
+package query
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/wire"
+	"github.com/invopop/jsonschema"
+	"github.com/russellhaering/autoswe/pkg/index"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"go.uber.org/zap"
+
+	_ "embed"
+)
+
+//go:embed index_list.md
+var indexListToolDescription string
+
+// IndexListInput represents the input parameters for the IndexList tool
+type IndexListInput struct {
+	Namespace  string `json:"namespace,omitempty" jsonschema_description:"Only list files in this namespace, eg repo or extra"`
+	Language   string `json:"language,omitempty" jsonschema_description:"Only list files in this language, eg Go or Python"`
+	PathPrefix string `json:"path_prefix,omitempty" jsonschema_description:"Only list files whose path starts with this prefix, eg a directory such as pkg/"`
+}
+
+// IndexListOutput represents the output of the IndexList tool
+type IndexListOutput struct {
+	Files []index.IndexedFile `json:"files"`
+}
+
+// IndexListTool implements the IndexList tool
+type IndexListTool struct {
+	Indexer *index.Indexer
+}
+
+var ProvideIndexListTool = wire.Struct(new(IndexListTool), "*")
+
+// Name returns the name of the tool
+func (t *IndexListTool) Name() string {
+	return "index_list"
+}
+
+// Description returns a description of the index list tool
+func (t *IndexListTool) Description() string {
+	return indexListToolDescription
+}
+
+// Schema returns the JSON schema for the index list tool
+func (t *IndexListTool) Schema() *jsonschema.Schema {
+	return jsonschema.Reflect(&IndexListInput{})
+}
+
+// Execute implements the index list operation
+func (t *IndexListTool) Execute(ctx context.Context, input IndexListInput) (IndexListOutput, error) {
+	log.Info("Starting index list operation",
+		zap.String("namespace", input.Namespace),
+		zap.String("language", input.Language),
+		zap.String("path_prefix", input.PathPrefix))
+
+	files, err := t.Indexer.ListIndexedFiles(ctx, index.FileFilter{
+		Namespace:  input.Namespace,
+		Language:   input.Language,
+		PathPrefix: input.PathPrefix,
+	})
+	if err != nil {
+		log.Error("Failed to list indexed files", zap.Error(err))
+		return IndexListOutput{}, fmt.Errorf("failed to list indexed files: %w", err)
+	}
+
+	log.Info("Index list completed successfully", zap.Int("files", len(files)))
+
+	return IndexListOutput{
+		Files: files,
+	}, nil
+}
@@ -0,0 +1,57 @@
+package query
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/wire"
+	"github.com/invopop/jsonschema"
+	"github.com/russellhaering/autoswe/pkg/index"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"go.uber.org/zap"
+
+	_ "embed"
+)
+
+//go:embed index_stats.md
+var indexStatsToolDescription string
+
+// IndexStatsInput represents the input parameters for the IndexStats tool
+type IndexStatsInput struct{}
+
+// IndexStatsTool implements the IndexStats tool
+type IndexStatsTool struct {
+	Indexer *index.Indexer
+}
+
+var ProvideIndexStatsTool = wire.Struct(new(IndexStatsTool), "*")
+
+// Name returns the name of the tool
+func (t *IndexStatsTool) Name() string {
+	return "index_stats"
+}
+
+// Description returns a description of the index stats tool
+func (t *IndexStatsTool) Description() string {
+	return indexStatsToolDescription
+}
+
+// Schema returns the JSON schema for the index stats tool
+func (t *IndexStatsTool) Schema() *jsonschema.Schema {
+	return jsonschema.Reflect(&IndexStatsInput{})
+}
+
+// Execute implements the index stats operation
+func (t *IndexStatsTool) Execute(ctx context.Context, _ IndexStatsInput) (*index.IndexStats, error) {
+	log.Info("Starting index stats operation")
+
+	stats, err := t.Indexer.Stats(ctx)
+	if err != nil {
+		log.Error("Failed to get index stats", zap.Error(err))
+		return nil, fmt.Errorf("failed to get index stats: %w", err)
+	}
+
+	log.Info("Index stats completed successfully", zap.Int("documents", stats.Documents))
+
+	return stats, nil
+}
@@ -0,0 +1,64 @@
+package query
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/wire"
+	"github.com/invopop/jsonschema"
+	"github.com/russellhaering/autoswe/pkg/index"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"go.uber.org/zap"
+
+	_ "embed"
+)
+
+//go:embed namespaces.md
+var namespacesToolDescription string
+
+// NamespacesInput represents the input parameters for the ListNamespaces tool
+type NamespacesInput struct{}
+
+// NamespacesOutput represents the output of the ListNamespaces tool
+type NamespacesOutput struct {
+	Namespaces []index.NamespaceInfo `json:"namespaces"`
+}
+
+// ListNamespacesTool implements the ListNamespaces tool
+type ListNamespacesTool struct {
+	Indexer *index.Indexer
+}
+
+var ProvideListNamespacesTool = wire.Struct(new(ListNamespacesTool), "*")
+
+// Name returns the name of the tool
+func (t *ListNamespacesTool) Name() string {
+	return "list_namespaces"
+}
+
+// Description returns a description of the list namespaces tool
+func (t *ListNamespacesTool) Description() string {
+	return namespacesToolDescription
+}
+
+// Schema returns the JSON schema for the list namespaces tool
+func (t *ListNamespacesTool) Schema() *jsonschema.Schema {
+	return jsonschema.Reflect(&NamespacesInput{})
+}
+
+// Execute implements the list namespaces operation
+func (t *ListNamespacesTool) Execute(ctx context.Context, _ NamespacesInput) (NamespacesOutput, error) {
+	log.Info("Starting list namespaces operation")
+
+	namespaces, err := t.Indexer.Namespaces(ctx)
+	if err != nil {
+		log.Error("Failed to list namespaces", zap.Error(err))
+		return NamespacesOutput{}, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	log.Info("List namespaces completed successfully", zap.Int("namespaces", len(namespaces)))
+
+	return NamespacesOutput{
+		Namespaces: namespaces,
+	}, nil
+}
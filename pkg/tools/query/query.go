@@ -2,12 +2,16 @@ package query
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/google/wire"
 	"github.com/invopop/jsonschema"
 	"github.com/russellhaering/autoswe/pkg/index"
 	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/russellhaering/autoswe/pkg/tools/git"
+	"github.com/russellhaering/autoswe/pkg/tools/toolerr"
 	"go.uber.org/zap"
 
 	_ "embed"
@@ -19,13 +23,26 @@ var queryToolDescription string
 // Input represents the input parameters for the Query tool
 type Input struct {
 	Query string `json:"query" jsonschema_description:"The query to search for in the codebase"`
+	Mode  string `json:"mode,omitempty" jsonschema_description:"Either 'answer' (default) to return relevant code snippets, or 'spans' to return only the relevant file paths and line ranges"`
+
+	ChangedOnly bool `json:"changed_only,omitempty" jsonschema_description:"If true, only search files changed on the current branch, including uncommitted and untracked files"`
+
+	Namespace string `json:"namespace,omitempty" jsonschema_description:"Only search this namespace, eg 'repo' for code or 'extra' for additional context such as documentation. Searches every namespace by default."`
 }
 
 // Output represents the output of the Query tool
 type Output struct {
-	Answer string `json:"answer,omitempty"`
+	Answer string            `json:"answer,omitempty"`
+	Spans  []index.CitedSpan `json:"spans,omitempty"`
 }
 
+const (
+	// ModeAnswer returns an answer quoting the relevant code
+	ModeAnswer = "answer"
+	// ModeSpans returns only the relevant file spans, without quoting code
+	ModeSpans = "spans"
+)
+
 // CodeExample represents a specific code example from the codebase
 type CodeExample struct {
 	Path      string `json:"path"`       // Path to the file
@@ -37,6 +54,7 @@ type CodeExample struct {
 // Tool implements the Query tool
 type Tool struct {
 	Indexer *index.Indexer
+	RepoFS  *repo.RepositoryFS
 }
 
 var ProvideQueryTool = wire.Struct(new(Tool), "*")
@@ -58,11 +76,46 @@ func (t *Tool) Schema() *jsonschema.Schema {
 
 // Execute implements the query operation
 func (t *Tool) Execute(ctx context.Context, input Input) (Output, error) {
-	log.Info("Starting codebase query operation", zap.String("query", input.Query))
+	log.Info("Starting codebase query operation",
+		zap.String("query", input.Query),
+		zap.String("mode", input.Mode),
+		zap.Bool("changed_only", input.ChangedOnly),
+		zap.String("namespace", input.Namespace))
+
+	var result *index.QueryResult
+	var err error
+
+	// Only the repository has branch changes, so other namespaces would never match
+	if input.ChangedOnly && input.Namespace != "" && input.Namespace != index.RepoNamespace {
+		return Output{}, toolerr.New(toolerr.InvalidInput, "changed_only only searches the %q namespace, so it can't be combined with namespace %q", index.RepoNamespace, input.Namespace)
+	}
+
+	opts := index.QueryOptions{Namespace: input.Namespace}
+	if input.ChangedOnly {
+		opts.Paths, err = git.ChangedFiles(ctx, &git.Config{WorkDir: t.RepoFS.Path()}, "")
+		if err != nil {
+			log.Error("Failed to list changed files", zap.Error(err))
+			return Output{}, err
+		}
+
+		// Nothing has changed, so nothing can match
+		if len(opts.Paths) == 0 {
+			return Output{Answer: "No files have changed on the current branch."}, nil
+		}
+	}
 
 	// Perform the query
-	result, err := t.Indexer.Query(ctx, input.Query)
-	if err != nil {
+	switch input.Mode {
+	case "", ModeAnswer:
+		result, err = t.Indexer.Query(ctx, input.Query, opts)
+	case ModeSpans:
+		result, err = t.Indexer.QuerySpans(ctx, input.Query, opts)
+	default:
+		return Output{}, toolerr.New(toolerr.InvalidInput, "unknown mode %q (expected %q or %q)", input.Mode, ModeAnswer, ModeSpans)
+	}
+	if errors.Is(err, index.ErrUnknownNamespace) {
+		return Output{}, toolerr.Wrap(toolerr.InvalidInput, err)
+	} else if err != nil {
 		log.Error("Failed to query codebase", zap.Error(err))
 		return Output{}, fmt.Errorf("failed to query codebase: %w", err)
 	}
@@ -71,5 +124,6 @@ func (t *Tool) Execute(ctx context.Context, input Input) (Output, error) {
 
 	return Output{
 		Answer: result.Answer,
+		Spans:  result.Spans,
 	}, nil
 }
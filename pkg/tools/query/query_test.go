@@ -0,0 +1,35 @@
+package query
+
+import (
+	"context"
+	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/index"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/tools/toolerr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryChangedOnlyNamespace(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	// The input is rejected before the index or repository are used
+	tool := &Tool{}
+	_, err := tool.Execute(context.Background(), Input{Query: "logging", ChangedOnly: true, Namespace: index.ExtraContextNamespace})
+	require.Error(t, err)
+	assert.Equal(t, toolerr.InvalidInput, toolerr.CategoryOf(err))
+	assert.Contains(t, err.Error(), "changed_only")
+}
+
+func TestCodeSearchChangedOnlyNamespace(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	tool := &CodeSearchTool{}
+	_, err := tool.Execute(context.Background(), SearchInput{Query: "logging", ChangedOnly: true, Namespace: index.ExtraContextNamespace})
+	require.Error(t, err)
+	assert.Equal(t, toolerr.InvalidInput, toolerr.CategoryOf(err))
+
+	_, err = tool.Execute(context.Background(), SearchInput{})
+	assert.Equal(t, toolerr.InvalidInput, toolerr.CategoryOf(err))
+}
@@ -0,0 +1,109 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/wire"
+	"github.com/invopop/jsonschema"
+	"github.com/russellhaering/autoswe/pkg/index"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/russellhaering/autoswe/pkg/tools/git"
+	"github.com/russellhaering/autoswe/pkg/tools/toolerr"
+	"go.uber.org/zap"
+
+	_ "embed"
+)
+
+//go:embed search.md
+var searchToolDescription string
+
+// SearchInput represents the input parameters for the CodeSearch tool
+type SearchInput struct {
+	Query string `json:"query" jsonschema_description:"The query to search for in the codebase"`
+
+	ChangedOnly bool `json:"changed_only,omitempty" jsonschema_description:"If true, only search files changed on the current branch, including uncommitted and untracked files"`
+
+	Namespace string `json:"namespace,omitempty" jsonschema_description:"Only search this namespace, eg 'repo' for code or 'extra' for additional context such as documentation. Searches every namespace by default."`
+}
+
+// SearchOutput represents the output of the CodeSearch tool
+type SearchOutput struct {
+	Snippets []index.CodeExample `json:"snippets"`
+}
+
+// CodeSearchTool implements the CodeSearch tool
+type CodeSearchTool struct {
+	Indexer *index.Indexer
+	RepoFS  *repo.RepositoryFS
+}
+
+var ProvideCodeSearchTool = wire.Struct(new(CodeSearchTool), "*")
+
+// Name returns the name of the tool
+func (t *CodeSearchTool) Name() string {
+	return "code_search"
+}
+
+// Description returns a description of the code search tool
+func (t *CodeSearchTool) Description() string {
+	return searchToolDescription
+}
+
+// Schema returns the JSON schema for the code search tool
+func (t *CodeSearchTool) Schema() *jsonschema.Schema {
+	return jsonschema.Reflect(&SearchInput{})
+}
+
+// Execute implements the code search operation
+func (t *CodeSearchTool) Execute(ctx context.Context, input SearchInput) (SearchOutput, error) {
+	log.Info("Starting code search operation",
+		zap.String("query", input.Query),
+		zap.Bool("changed_only", input.ChangedOnly),
+		zap.String("namespace", input.Namespace))
+
+	if input.Query == "" {
+		return SearchOutput{}, toolerr.New(toolerr.InvalidInput, "query is required")
+	}
+
+	// Only the repository has branch changes, so other namespaces would never match
+	if input.ChangedOnly && input.Namespace != "" && input.Namespace != index.RepoNamespace {
+		return SearchOutput{}, toolerr.New(toolerr.InvalidInput, "changed_only only searches the %q namespace, so it can't be combined with namespace %q", index.RepoNamespace, input.Namespace)
+	}
+
+	var err error
+	opts := index.QueryOptions{Namespace: input.Namespace}
+	if input.ChangedOnly {
+		opts.Paths, err = git.ChangedFiles(ctx, &git.Config{WorkDir: t.RepoFS.Path()}, "")
+		if err != nil {
+			log.Error("Failed to list changed files", zap.Error(err))
+			return SearchOutput{}, err
+		}
+
+		// Nothing has changed, so nothing can match
+		if len(opts.Paths) == 0 {
+			return SearchOutput{Snippets: []index.CodeExample{}}, nil
+		}
+	}
+
+	snippets, err := t.Indexer.SearchSnippets(ctx, input.Query, opts)
+	if errors.Is(err, index.ErrUnknownNamespace) {
+		return SearchOutput{}, toolerr.Wrap(toolerr.InvalidInput, err)
+	} else if err != nil {
+		log.Error("Failed to search codebase", zap.Error(err))
+		return SearchOutput{}, fmt.Errorf("failed to search codebase: %w", err)
+	}
+
+	// An empty list, rather than null, tells the caller that nothing matched
+	if snippets == nil {
+		snippets = []index.CodeExample{}
+	}
+
+	log.Info("Code search completed successfully", zap.Int("snippets", len(snippets)))
+
+	return SearchOutput{
+		Snippets: snippets,
+	}, nil
+}
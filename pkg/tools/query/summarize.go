@@ -0,0 +1,78 @@
+package query
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/wire"
+	"github.com/invopop/jsonschema"
+	"github.com/russellhaering/autoswe/pkg/index"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/tools/toolerr"
+	"go.uber.org/zap"
+
+	_ "embed"
+)
+
+//go:embed summarize.md
+var summarizeToolDescription string
+
+// SummarizeInput represents the input parameters for the SummarizeFile tool
+type SummarizeInput struct {
+	Path string `json:"path" jsonschema_description:"Path of the file to summarize"`
+}
+
+// SummarizeOutput represents the output of the SummarizeFile tool
+type SummarizeOutput struct {
+	Summary  string                 `json:"summary"`
+	Sections []index.SectionSummary `json:"sections"`
+	Indexed  bool                   `json:"indexed"`
+}
+
+// SummarizeFileTool implements the SummarizeFile tool
+type SummarizeFileTool struct {
+	Indexer *index.Indexer
+}
+
+var ProvideSummarizeFileTool = wire.Struct(new(SummarizeFileTool), "*")
+
+// Name returns the name of the tool
+func (t *SummarizeFileTool) Name() string {
+	return "summarize_file"
+}
+
+// Description returns a description of the summarize file tool
+func (t *SummarizeFileTool) Description() string {
+	return summarizeToolDescription
+}
+
+// Schema returns the JSON schema for the summarize file tool
+func (t *SummarizeFileTool) Schema() *jsonschema.Schema {
+	return jsonschema.Reflect(&SummarizeInput{})
+}
+
+// Execute implements the summarize file operation
+func (t *SummarizeFileTool) Execute(ctx context.Context, input SummarizeInput) (SummarizeOutput, error) {
+	log.Info("Starting summarize file operation", zap.String("path", input.Path))
+
+	if input.Path == "" {
+		log.Error("No path provided")
+		return SummarizeOutput{}, toolerr.New(toolerr.InvalidInput, "path is required")
+	}
+
+	summary, err := t.Indexer.SummarizeFile(ctx, input.Path)
+	if err != nil {
+		log.Error("Failed to summarize file", zap.Error(err))
+		return SummarizeOutput{}, fmt.Errorf("failed to summarize file: %w", err)
+	}
+
+	log.Info("Summarize file completed successfully",
+		zap.Int("sections", len(summary.Sections)),
+		zap.Bool("indexed", summary.Indexed))
+
+	return SummarizeOutput{
+		Summary:  summary.String(),
+		Sections: summary.Sections,
+		Indexed:  summary.Indexed,
+	}, nil
+}
@@ -0,0 +1,109 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+	"github.com/russellhaering/autoswe/pkg/tools/toolerr"
+)
+
+// InputError is returned when a tool's input can't be decoded. It describes what was wrong
+// with the input and carries the tool's input schema.
+type InputError struct {
+	Tool    string
+	Problem string
+	Schema  *jsonschema.Schema
+	Err     error
+}
+
+// Error returns a description of the problem with the input
+func (e *InputError) Error() string {
+	return fmt.Sprintf("invalid input for tool %s: %s", e.Tool, e.Problem)
+}
+
+// Unwrap returns the underlying decoding error
+func (e *InputError) Unwrap() error {
+	return e.Err
+}
+
+// newInputError describes a failure to decode a tool's input, categorized as invalid input
+func newInputError(tool string, schema *jsonschema.Schema, err error) error {
+	return toolerr.Wrap(toolerr.InvalidInput, &InputError{
+		Tool:    tool,
+		Problem: describeDecodeError(err),
+		Schema:  inputSchema(schema),
+		Err:     err,
+	})
+}
+
+// describeDecodeError explains a JSON decoding error in terms of the input's fields and
+// JSON types, rather than Go types
+func describeDecodeError(err error) string {
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+
+	switch {
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return fmt.Sprintf("input must be %s, got %s", jsonTypeName(typeErr.Type), typeErr.Value)
+		}
+		return fmt.Sprintf("field %q must be %s, got %s", fieldPath(typeErr.Field), jsonTypeName(typeErr.Type), typeErr.Value)
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("input is not valid JSON at offset %d: %s", syntaxErr.Offset, syntaxErr)
+	default:
+		return err.Error()
+	}
+}
+
+// fieldPath returns the path of a field in a decoding error without any array indices, eg
+// include rather than include.0, as only some Go versions include them
+func fieldPath(field string) string {
+	var names []string
+	for _, name := range strings.Split(field, ".") {
+		if strings.Trim(name, "0123456789") != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ".")
+}
+
+// jsonTypeName returns the JSON type that decodes into t, with an article
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	case reflect.Pointer:
+		return jsonTypeName(t.Elem())
+	default:
+		return t.String()
+	}
+}
+
+// inputSchema returns the definition of a tool's input from its schema, which is what the
+// model is given as the tool's input schema
+func inputSchema(schema *jsonschema.Schema) *jsonschema.Schema {
+	if schema == nil || len(schema.Definitions) != 1 {
+		return schema
+	}
+
+	for _, definition := range schema.Definitions {
+		return definition
+	}
+
+	return schema
+}
@@ -3,9 +3,11 @@ package registry
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"unicode/utf8"
 
-	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/google/wire"
 	"github.com/invopop/jsonschema"
 	"github.com/russellhaering/autoswe/pkg/log"
@@ -16,13 +18,16 @@ import (
 	"github.com/russellhaering/autoswe/pkg/tools/format"
 	"github.com/russellhaering/autoswe/pkg/tools/fs"
 	"github.com/russellhaering/autoswe/pkg/tools/git"
+	"github.com/russellhaering/autoswe/pkg/tools/goenv"
 	"github.com/russellhaering/autoswe/pkg/tools/lint"
 	"github.com/russellhaering/autoswe/pkg/tools/query"
 	"github.com/russellhaering/autoswe/pkg/tools/test"
+	"github.com/russellhaering/autoswe/pkg/tools/toolerr"
 	"go.uber.org/zap"
 )
 
-// To register a new tool, add it to the ToolSet and as a field in the ToolRegistry struct
+// To add a built-in tool, add it to the ToolSet and as a parameter of ProvideToolRegistry.
+// Tools defined outside this package can be added to any registry with Register.
 var ToolSet = wire.NewSet(
 	astgrep.ProvideASTGrepTool,
 	build.ProvideBuildTool,
@@ -30,17 +35,31 @@ var ToolSet = wire.NewSet(
 	dependencies.ProvideListTool,
 	exec.ProvideExecTool,
 	format.ProvideFormatTool,
+	goenv.ProvideEnvTool,
 	git.ProvideCommandTool,
 	git.ProvideCommitTool,
+	git.ProvideBlameTool,
+	git.ProvideLogTool,
+	git.ProvideBranchTool,
 	lint.ProvideLintTool,
 	test.ProvideTestTool,
 	query.ProvideQueryTool,
+	query.ProvideCodeSearchTool,
+	query.ProvideSummarizeFileTool,
+	query.ProvideListNamespacesTool,
+	query.ProvideIndexListTool,
+	query.ProvideIndexStatsTool,
 	fs.ProvideFetchTool,
 	fs.ProvideGrepTool,
 	fs.ProvideListTool,
 	fs.ProvidePatchTool,
+	fs.ProvideMultiPatchTool,
+	fs.ProvideTryPatchTool,
 	fs.ProvidePutTool,
 	fs.ProvideRmTool,
+	fs.ProvideMoveTool,
+	fs.ProvideMkdirTool,
+	fs.ProvideConfigRefTool,
 	ProvideToolRegistry,
 )
 
@@ -52,76 +71,213 @@ type Tool[I, O any] interface {
 	Execute(ctx context.Context, input I) (O, error)
 }
 
-type toolRegistration struct {
+// Registration is a tool prepared for registration, with its input and output types erased
+type Registration struct {
 	name        string
 	description string
 	schema      *jsonschema.Schema
 	execute     func(ctx context.Context, input json.RawMessage) (interface{}, error)
 }
 
+// NewRegistration prepares a tool for registration. Tool inputs are decoded from JSON into
+// I, and outputs are encoded to JSON.
+func NewRegistration[I, O any](tool Tool[I, O]) Registration {
+	return Registration{
+		name:        tool.Name(),
+		description: tool.Description(),
+		schema:      tool.Schema(),
+		execute: func(ctx context.Context, rawInput json.RawMessage) (interface{}, error) {
+			var input I
+			if err := json.Unmarshal(rawInput, &input); err != nil {
+				return nil, newInputError(tool.Name(), tool.Schema(), err)
+			}
+			result, err := tool.Execute(ctx, input)
+			return result, err
+		},
+	}
+}
+
+// ToolRegistry holds the tools available to the AI
 type ToolRegistry struct {
-	tools map[string]toolRegistration
+	tools map[string]Registration
+
+	// maxResultBytes is the size above which results are truncated, if non-zero
+	maxResultBytes int
+}
+
+// NewToolRegistry returns a registry with no tools. Use ProvideToolRegistry for one with
+// the built-in tools.
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{
+		tools: make(map[string]Registration),
+	}
+}
+
+// Register adds tools to the registry, returning an error if a tool has no name or its
+// name is already registered. Either all of the tools are added or none are.
+func (r *ToolRegistry) Register(registrations ...Registration) error {
+	seen := make(map[string]bool)
+	for _, registration := range registrations {
+		if registration.name == "" {
+			return fmt.Errorf("tool has no name")
+		}
+
+		if _, ok := r.tools[registration.name]; ok || seen[registration.name] {
+			return fmt.Errorf("tool %s is already registered", registration.name)
+		}
+		seen[registration.name] = true
+	}
+
+	if r.tools == nil {
+		r.tools = make(map[string]Registration)
+	}
+
+	for _, registration := range registrations {
+		r.tools[registration.name] = registration
+	}
+
+	return nil
+}
+
+// DefaultMaxResultBytes is the default size above which tool results are truncated. It is a
+// safety net above the limits of individual tools, like fs_fetch, which page their output.
+const DefaultMaxResultBytes = 256 * 1024
+
+// ToolsConfig selects which tools are available to the AI and limits what they return
+type ToolsConfig struct {
+	// Enabled lists the only tools that are available, if set
+	Enabled []string
+
+	// Disabled lists tools that are never available, even if they are also enabled
+	Disabled []string
+
+	// MaxResultBytes is the size above which a tool result is truncated, keeping its start
+	// and end, before it is returned to the AI. Zero disables the limit.
+	MaxResultBytes int
 }
 
+// Allows reports whether the config makes the named tool available
+func (c ToolsConfig) Allows(name string) bool {
+	if len(c.Enabled) > 0 && !slices.Contains(c.Enabled, name) {
+		return false
+	}
+
+	return !slices.Contains(c.Disabled, name)
+}
+
+// ProvideToolRegistry returns a registry with every built-in tool that config allows
 func ProvideToolRegistry(
+	config ToolsConfig,
 	astGrepTool *astgrep.Tool,
 	buildTool *build.Tool,
 	fetchTool *dependencies.FetchTool,
 	listTool *dependencies.ListTool,
 	execTool *exec.Tool,
 	formatTool *format.Tool,
+	goEnvTool *goenv.EnvTool,
 	gitCommandTool *git.CommandTool,
 	gitCommitTool *git.CommitTool,
+	gitBlameTool *git.BlameTool,
+	gitLogTool *git.LogTool,
+	gitBranchTool *git.BranchTool,
 	lintTool *lint.Tool,
 	testTool *test.Tool,
 	queryTool *query.Tool,
+	codeSearchTool *query.CodeSearchTool,
+	summarizeFileTool *query.SummarizeFileTool,
+	listNamespacesTool *query.ListNamespacesTool,
+	indexListTool *query.IndexListTool,
+	indexStatsTool *query.IndexStatsTool,
 	fsFetchTool *fs.FetchTool,
 	fsGrepTool *fs.GrepTool,
 	fsListTool *fs.ListTool,
 	fsPatchTool *fs.PatchTool,
+	fsMultiPatchTool *fs.MultiPatchTool,
+	fsTryPatchTool *fs.TryPatchTool,
 	fsPutTool *fs.PutTool,
 	fsRmTool *fs.RmTool,
+	fsMoveTool *fs.MoveTool,
+	fsMkdirTool *fs.MkdirTool,
+	fsConfigRefTool *fs.ConfigRefTool,
 ) *ToolRegistry {
-	registry := &ToolRegistry{
-		tools: make(map[string]toolRegistration),
+	registry := NewToolRegistry()
+
+	// The built-in tools have distinct names, so this can only fail if one is added twice
+	err := registry.Register(
+		NewRegistration(astGrepTool),
+		NewRegistration(buildTool),
+		NewRegistration(fetchTool),
+		NewRegistration(listTool),
+		NewRegistration(execTool),
+		NewRegistration(formatTool),
+		NewRegistration(goEnvTool),
+		NewRegistration(gitCommandTool),
+		NewRegistration(gitCommitTool),
+		NewRegistration(gitBlameTool),
+		NewRegistration(gitLogTool),
+		NewRegistration(gitBranchTool),
+		NewRegistration(lintTool),
+		NewRegistration(testTool),
+		NewRegistration(queryTool),
+		NewRegistration(codeSearchTool),
+		NewRegistration(summarizeFileTool),
+		NewRegistration(listNamespacesTool),
+		NewRegistration(indexListTool),
+		NewRegistration(indexStatsTool),
+		NewRegistration(fsFetchTool),
+		NewRegistration(fsGrepTool),
+		NewRegistration(fsListTool),
+		NewRegistration(fsPatchTool),
+		NewRegistration(fsMultiPatchTool),
+		NewRegistration(fsTryPatchTool),
+		NewRegistration(fsPutTool),
+		NewRegistration(fsRmTool),
+		NewRegistration(fsMoveTool),
+		NewRegistration(fsMkdirTool),
+		NewRegistration(fsConfigRefTool),
+	)
+	if err != nil {
+		panic(err)
 	}
 
-	RegisterTool(registry, astGrepTool)
-	RegisterTool(registry, buildTool)
-	RegisterTool(registry, fetchTool)
-	RegisterTool(registry, listTool)
-	RegisterTool(registry, execTool)
-	RegisterTool(registry, formatTool)
-	RegisterTool(registry, gitCommandTool)
-	RegisterTool(registry, gitCommitTool)
-	RegisterTool(registry, lintTool)
-	RegisterTool(registry, testTool)
-	RegisterTool(registry, queryTool)
-	RegisterTool(registry, fsFetchTool)
-	RegisterTool(registry, fsGrepTool)
-	RegisterTool(registry, fsListTool)
-	RegisterTool(registry, fsPatchTool)
-	RegisterTool(registry, fsPutTool)
-	RegisterTool(registry, fsRmTool)
+	for _, name := range registry.Restrict(config) {
+		log.Warn("unknown tool in tool configuration", zap.String("tool", name))
+	}
+
+	registry.SetMaxResultBytes(config.MaxResultBytes)
 
 	return registry
 }
 
-// RegisterTool is a function with type parameters that registers a tool with the registry
-func RegisterTool[I, O any](registry *ToolRegistry, tool Tool[I, O]) {
-	registry.tools[tool.Name()] = toolRegistration{
-		name:        tool.Name(),
-		description: tool.Description(),
-		schema:      tool.Schema(),
-		execute: func(ctx context.Context, rawInput json.RawMessage) (interface{}, error) {
-			var input I
-			if err := json.Unmarshal(rawInput, &input); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal input: %w", err)
-			}
-			result, err := tool.Execute(ctx, input)
-			return result, err
-		},
+// Restrict removes the tools that config doesn't allow, returning any names in config that
+// don't match a registered tool
+func (r *ToolRegistry) Restrict(config ToolsConfig) []string {
+	var unknown []string
+	for _, name := range append(slices.Clone(config.Enabled), config.Disabled...) {
+		if _, ok := r.tools[name]; !ok && !slices.Contains(unknown, name) {
+			unknown = append(unknown, name)
+		}
+	}
+
+	for name := range r.tools {
+		if !config.Allows(name) {
+			log.Debug("tool disabled by configuration", zap.String("tool", name))
+			delete(r.tools, name)
+		}
 	}
+
+	return unknown
+}
+
+// SetMaxResultBytes sets the size above which tool results are truncated. Zero disables
+// the limit.
+func (r *ToolRegistry) SetMaxResultBytes(maxBytes int) {
+	r.maxResultBytes = maxBytes
+}
+
+// RegisterTool registers a single tool with the registry
+func RegisterTool[I, O any](registry *ToolRegistry, tool Tool[I, O]) error {
+	return registry.Register(NewRegistration(tool))
 }
 
 func (r *ToolRegistry) getTool(name string) (*toolWrapper, bool) {
@@ -185,10 +341,18 @@ func (t *toolWrapper) Execute(ctx context.Context, input any) (any, error) {
 	return t.execute(ctx, inputJSON)
 }
 
-func (r *ToolRegistry) GetToolParams() []anthropic.ToolUnionUnionParam {
+// ToolDefinition describes a tool to the model
+type ToolDefinition struct {
+	Name        string
+	Description string
+	InputSchema *jsonschema.Schema
+}
+
+// ToolDefinitions describes every registered tool, in the form given to the model
+func (r *ToolRegistry) ToolDefinitions() []ToolDefinition {
 	toolsByName := r.getToolsByName()
 
-	result := []anthropic.ToolUnionUnionParam{}
+	result := []ToolDefinition{}
 
 	for _, wrapper := range toolsByName {
 		// Get the schema from the tool, then extract the actual definition
@@ -198,14 +362,10 @@ func (r *ToolRegistry) GetToolParams() []anthropic.ToolUnionUnionParam {
 			panic(fmt.Sprintf("tool %s has %d definitions, expected 1", wrapper.Name(), len(schema.Definitions)))
 		}
 
-		for _, v := range schema.Definitions {
-			schema = v
-		}
-
-		result = append(result, anthropic.ToolParam{
-			Name:        anthropic.F(wrapper.Name()),
-			Description: anthropic.F(wrapper.Description()),
-			InputSchema: anthropic.F(interface{}(schema)),
+		result = append(result, ToolDefinition{
+			Name:        wrapper.Name(),
+			Description: wrapper.Description(),
+			InputSchema: inputSchema(schema),
 		})
 	}
 
@@ -218,23 +378,57 @@ type ToolCall struct {
 	Input json.RawMessage
 }
 
-// ExecuteToolCall handles a single tool call and returns the result
+// ErrorResult is the tool result returned to the model when a tool call fails
+type ErrorResult struct {
+	Error    string           `json:"error"`
+	Category toolerr.Category `json:"category"`
+
+	// Schema is the tool's input schema, included when the input couldn't be decoded so
+	// that the model can correct its next call
+	Schema *jsonschema.Schema `json:"schema,omitempty"`
+}
+
+// FormatError serializes err into an ErrorResult, so the model can tell from the category
+// whether to retry, fix its input or give up
+func FormatError(err error) string {
+	errorResult := ErrorResult{
+		Error:    err.Error(),
+		Category: toolerr.CategoryOf(err),
+	}
+
+	var inputErr *InputError
+	if errors.As(err, &inputErr) {
+		errorResult.Schema = inputErr.Schema
+	}
+
+	result, marshalErr := json.Marshal(errorResult)
+	if marshalErr != nil {
+		return fmt.Sprintf("Error: %s", err)
+	}
+
+	return string(result)
+}
+
+// ExecuteToolCall handles a single tool call and returns the result. If the call fails the
+// error is returned along with its serialized ErrorResult.
 func (r *ToolRegistry) ExecuteToolCall(ctx context.Context, call ToolCall) (string, error) {
 	tool, ok := r.getTool(call.Name)
 	if !ok {
-		return "", fmt.Errorf("unknown tool: %s", call.Name)
+		err := toolerr.New(toolerr.InvalidInput, "unknown tool: %s", call.Name)
+		return FormatError(err), err
 	}
 
 	var input interface{}
 	if err := json.Unmarshal(call.Input, &input); err != nil {
-		log.Error("failed to decode tool input for logging",
+		log.Error("failed to decode tool input",
 			zap.String("tool", tool.Name()),
 			zap.String("id", call.ID),
 			zap.String("input", string(call.Input)),
 			zap.Error(err),
 		)
 
-		return "", fmt.Errorf("failed to decode tool input for logging: %w", err)
+		err = newInputError(tool.Name(), tool.Schema(), err)
+		return FormatError(err), err
 	}
 
 	// Execute the tool
@@ -248,7 +442,7 @@ func (r *ToolRegistry) ExecuteToolCall(ctx context.Context, call ToolCall) (stri
 			zap.Error(err),
 		)
 
-		return "", err
+		return FormatError(err), err
 	}
 
 	responseJSON, err := json.Marshal(response)
@@ -256,5 +450,34 @@ func (r *ToolRegistry) ExecuteToolCall(ctx context.Context, call ToolCall) (stri
 		return "", fmt.Errorf("failed to marshal response: %w", err)
 	}
 
-	return string(responseJSON), nil
+	result := string(responseJSON)
+	if r.maxResultBytes > 0 && len(result) > r.maxResultBytes {
+		log.Warn("truncating tool result",
+			zap.String("tool", tool.Name()),
+			zap.String("id", call.ID),
+			zap.Int("bytes", len(result)),
+			zap.Int("max_bytes", r.maxResultBytes),
+		)
+
+		result = truncateMiddle(result, r.maxResultBytes)
+	}
+
+	return result, nil
+}
+
+// truncateMiddle shortens s to about maxBytes by replacing its middle with a marker, since
+// the start and end of a large result are usually the most informative parts
+func truncateMiddle(s string, maxBytes int) string {
+	head := maxBytes / 2
+	tail := len(s) - (maxBytes - head)
+
+	// Don't split a multi-byte character
+	for head > 0 && !utf8.RuneStart(s[head]) {
+		head--
+	}
+	for tail < len(s) && !utf8.RuneStart(s[tail]) {
+		tail++
+	}
+
+	return fmt.Sprintf("%s\n[...truncated %d bytes...]\n%s", s[:head], tail-head, s[tail:])
 }
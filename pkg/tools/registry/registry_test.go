@@ -0,0 +1,263 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/invopop/jsonschema"
+	"github.com/russellhaering/autoswe/pkg/log"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/russellhaering/autoswe/pkg/tools/fs"
+	"github.com/russellhaering/autoswe/pkg/tools/toolerr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteToolCallErrorCategories(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	rootDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(rootDir, "main.go"), []byte("package main"), 0644))
+
+	filteredFS, err := repo.NewRepoFS(rootDir).Filter()
+	require.NoError(t, err)
+
+	registry := NewToolRegistry()
+	require.NoError(t, registry.Register(
+		NewRegistration(&fs.FetchTool{FilteredFS: filteredFS}),
+		NewRegistration(&fs.PutTool{FilteredFS: filteredFS}),
+		NewRegistration(&fs.GrepTool{FilteredFS: filteredFS}),
+	))
+
+	tests := []struct {
+		name     string
+		tool     string
+		input    string
+		category toolerr.Category
+	}{
+		{"missing file", "fs_fetch", `{"path":"missing.go"}`, toolerr.NotFound},
+		{"filtered path", "fs_put", `{"path":".git/config","content":"x"}`, toolerr.Filtered},
+		{"outside root", "fs_put", `{"path":"../escaped.go","content":"x"}`, toolerr.PermissionDenied},
+		{"missing pattern", "fs_grep", `{"pattern":""}`, toolerr.InvalidInput},
+		{"bad regex", "fs_grep", `{"pattern":"("}`, toolerr.InvalidInput},
+		{"malformed input", "fs_fetch", `{"path":42}`, toolerr.InvalidInput},
+		{"unknown tool", "fs_teleport", `{}`, toolerr.InvalidInput},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := registry.ExecuteToolCall(context.Background(), ToolCall{
+				Name:  tt.tool,
+				ID:    "call-1",
+				Input: json.RawMessage(tt.input),
+			})
+			require.Error(t, err)
+			assert.Equal(t, tt.category, toolerr.CategoryOf(err))
+
+			var errorResult ErrorResult
+			require.NoError(t, json.Unmarshal([]byte(result), &errorResult))
+			assert.Equal(t, tt.category, errorResult.Category)
+			assert.Equal(t, err.Error(), errorResult.Error)
+		})
+	}
+
+	result, err := registry.ExecuteToolCall(context.Background(), ToolCall{
+		Name:  "fs_fetch",
+		ID:    "call-2",
+		Input: json.RawMessage(`{"path":"main.go"}`),
+	})
+	require.NoError(t, err)
+	assert.Contains(t, result, "package main")
+}
+
+func TestExecuteToolCallInvalidInput(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	filteredFS, err := repo.NewRepoFS(t.TempDir()).Filter()
+	require.NoError(t, err)
+
+	registry := NewToolRegistry()
+	require.NoError(t, RegisterTool(registry, &fs.GrepTool{FilteredFS: filteredFS}))
+
+	tests := []struct {
+		name    string
+		input   string
+		problem string
+	}{
+		{"wrong type", `{"pattern":"main","max_matches":"ten"}`, `invalid input for tool fs_grep: field "max_matches" must be an integer, got string`},
+		{"wrong element type", `{"pattern":"main","include":[1]}`, `invalid input for tool fs_grep: field "include" must be a string, got number`},
+		{"not an object", `["main"]`, `invalid input for tool fs_grep: input must be an object, got array`},
+		{"syntax error", `{"pattern":}`, `invalid input for tool fs_grep: input is not valid JSON at offset 12`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := registry.ExecuteToolCall(context.Background(), ToolCall{
+				Name:  "fs_grep",
+				ID:    "call-1",
+				Input: json.RawMessage(tt.input),
+			})
+			require.Error(t, err)
+
+			var errorResult struct {
+				Error    string           `json:"error"`
+				Category toolerr.Category `json:"category"`
+				Schema   struct {
+					Properties map[string]struct {
+						Type string `json:"type"`
+					} `json:"properties"`
+					Required []string `json:"required"`
+				} `json:"schema"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(result), &errorResult))
+
+			assert.Contains(t, errorResult.Error, tt.problem)
+			assert.Equal(t, toolerr.InvalidInput, errorResult.Category)
+			assert.Equal(t, "integer", errorResult.Schema.Properties["max_matches"].Type)
+			assert.Contains(t, errorResult.Schema.Required, "pattern")
+		})
+	}
+}
+
+func TestToolSchemas(t *testing.T) {
+	// Every tool only needs a name and schema to be registered, so zero values will do
+	provide := reflect.ValueOf(ProvideToolRegistry)
+	var args []reflect.Value
+	for i := 0; i < provide.Type().NumIn(); i++ {
+		if in := provide.Type().In(i); in.Kind() == reflect.Pointer {
+			args = append(args, reflect.New(in.Elem()))
+		} else {
+			args = append(args, reflect.Zero(in))
+		}
+	}
+	registry := provide.Call(args)[0].Interface().(*ToolRegistry)
+
+	for name, tool := range registry.tools {
+		assert.Len(t, tool.schema.Definitions, 1, "tool %s must have a single schema definition", name)
+	}
+
+	assert.NotPanics(t, func() { registry.ToolDefinitions() })
+}
+
+type echoInput struct {
+	Message string `json:"message"`
+}
+
+type echoOutput struct {
+	Echo string `json:"echo"`
+}
+
+// echoTool is a tool defined outside the built-in set
+type echoTool struct{}
+
+func (echoTool) Name() string        { return "echo" }
+func (echoTool) Description() string { return "Echoes the message" }
+func (echoTool) Schema() *jsonschema.Schema {
+	return jsonschema.Reflect(&echoInput{})
+}
+func (echoTool) Execute(_ context.Context, input echoInput) (echoOutput, error) {
+	return echoOutput{Echo: input.Message}, nil
+}
+
+func TestRegisterCustomTool(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	registry := NewToolRegistry()
+	require.NoError(t, registry.Register(NewRegistration[echoInput, echoOutput](echoTool{})))
+
+	result, err := registry.ExecuteToolCall(context.Background(), ToolCall{
+		Name:  "echo",
+		Input: json.RawMessage(`{"message": "hello"}`),
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"echo": "hello"}`, result)
+	assert.Len(t, registry.ToolDefinitions(), 1)
+
+	// Names must be unique, and a failed registration adds nothing
+	err = registry.Register(NewRegistration[echoInput, echoOutput](echoTool{}))
+	assert.ErrorContains(t, err, "tool echo is already registered")
+
+	registry = NewToolRegistry()
+	err = registry.Register(
+		NewRegistration[echoInput, echoOutput](echoTool{}),
+		NewRegistration[echoInput, echoOutput](echoTool{}),
+	)
+	assert.Error(t, err)
+	assert.Empty(t, registry.ToolDefinitions())
+}
+
+func TestRestrict(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	newRegistry := func() *ToolRegistry {
+		registry := NewToolRegistry()
+		require.NoError(t, registry.Register(
+			NewRegistration(&fs.FetchTool{}),
+			NewRegistration(&fs.PutTool{}),
+			NewRegistration(&fs.RmTool{}),
+		))
+		return registry
+	}
+
+	toolNames := func(registry *ToolRegistry) []string {
+		var names []string
+		for _, tool := range registry.ToolDefinitions() {
+			names = append(names, tool.Name)
+		}
+		return names
+	}
+
+	registry := newRegistry()
+	unknown := registry.Restrict(ToolsConfig{Disabled: []string{"fs_rm", "exec"}})
+	assert.Equal(t, []string{"exec"}, unknown)
+	assert.ElementsMatch(t, []string{"fs_fetch", "fs_put"}, toolNames(registry))
+
+	_, err := registry.ExecuteToolCall(context.Background(), ToolCall{Name: "fs_rm", Input: json.RawMessage(`{}`)})
+	assert.ErrorContains(t, err, "unknown tool: fs_rm")
+
+	registry = newRegistry()
+	unknown = registry.Restrict(ToolsConfig{Enabled: []string{"fs_fetch", "fs_rm"}, Disabled: []string{"fs_rm"}})
+	assert.Empty(t, unknown)
+	assert.Equal(t, []string{"fs_fetch"}, toolNames(registry))
+}
+
+func TestExecuteToolCallTruncatesResult(t *testing.T) {
+	require.NoError(t, log.Init(true))
+
+	registry := NewToolRegistry()
+	require.NoError(t, registry.Register(NewRegistration[echoInput, echoOutput](echoTool{})))
+
+	message := "start" + strings.Repeat("é", 500) + "end"
+	input, err := json.Marshal(echoInput{Message: message})
+	require.NoError(t, err)
+
+	call := ToolCall{Name: "echo", Input: input}
+
+	// Results are returned verbatim by default
+	result, err := registry.ExecuteToolCall(context.Background(), call)
+	require.NoError(t, err)
+	assert.Contains(t, result, message)
+
+	registry.SetMaxResultBytes(100)
+	result, err = registry.ExecuteToolCall(context.Background(), call)
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(result, `{"echo":"start`), result)
+	assert.True(t, strings.HasSuffix(result, `end"}`), result)
+	assert.Regexp(t, `\n\[\.\.\.truncated \d+ bytes\.\.\.\]\n`, result)
+	assert.True(t, utf8.ValidString(result))
+	assert.Less(t, len(result), 150)
+}
+
+func TestFieldPath(t *testing.T) {
+	assert.Equal(t, "include", fieldPath("include"))
+	assert.Equal(t, "include", fieldPath("include.0"))
+	assert.Equal(t, "edits.old_text", fieldPath("edits.12.old_text"))
+	assert.Equal(t, "", fieldPath(""))
+}
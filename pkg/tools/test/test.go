@@ -42,10 +42,10 @@ func (t *Tool) Schema() *jsonschema.Schema {
 }
 
 // Execute implements the test operation
-func (t *Tool) Execute(_ context.Context, _ Input) (Output, error) {
+func (t *Tool) Execute(ctx context.Context, _ Input) (Output, error) {
 	log.Info("Starting test operation")
 
-	cmd := exec.Command("go", "test", "-v", "./...")
+	cmd := exec.CommandContext(ctx, "go", "test", "-v", "./...")
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		log.Error("Tests failed", zap.Error(err), zap.String("output", string(out)))
@@ -0,0 +1,95 @@
+// Package toolerr defines the categories of errors returned by tools, so that the agent
+// can decide whether to retry, fix its input or give up without matching on error strings.
+package toolerr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/russellhaering/autoswe/pkg/db"
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/russellhaering/autoswe/pkg/tools/fs/simplediff"
+)
+
+// Category classifies a tool error by how the caller should react to it
+type Category string
+
+const (
+	// NotFound means the requested file, directory or object doesn't exist
+	NotFound Category = "not_found"
+	// PermissionDenied means the operation isn't allowed, e.g. a path outside the repository
+	PermissionDenied Category = "permission_denied"
+	// InvalidInput means the input was malformed and should be corrected before retrying
+	InvalidInput Category = "invalid_input"
+	// Filtered means the path is excluded by the repository's ignore rules
+	Filtered Category = "filtered"
+	// Transient means the operation may succeed if retried unchanged
+	Transient Category = "transient"
+	// Internal is used for errors that don't fit any other category
+	Internal Category = "internal"
+)
+
+// Error is an error with a category
+type Error struct {
+	Category Category
+	Err      error
+}
+
+// Error returns the message of the wrapped error
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New returns an error with the given category and formatted message. As with fmt.Errorf,
+// a %w verb wraps its argument.
+func New(category Category, format string, args ...any) error {
+	return &Error{
+		Category: category,
+		Err:      fmt.Errorf(format, args...),
+	}
+}
+
+// Wrap attaches a category to err. It returns nil if err is nil.
+func Wrap(category Category, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &Error{
+		Category: category,
+		Err:      err,
+	}
+}
+
+// CategoryOf returns the category of err. Errors that weren't created by this package are
+// categorized by the well known errors they wrap, falling back to Internal.
+func CategoryOf(err error) Category {
+	var toolErr *Error
+	if errors.As(err, &toolErr) {
+		return toolErr.Category
+	}
+
+	switch {
+	case errors.Is(err, repo.ErrFiltered):
+		return Filtered
+	case errors.Is(err, repo.ErrOutsideRoot), errors.Is(err, fs.ErrPermission):
+		return PermissionDenied
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, db.ErrNotFound):
+		return NotFound
+	case errors.Is(err, fs.ErrInvalid),
+		errors.Is(err, simplediff.ErrInvalidDiffFormat),
+		errors.Is(err, simplediff.ErrSearchNotFound):
+		return InvalidInput
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, db.ErrIndexLocked):
+		return Transient
+	default:
+		return Internal
+	}
+}
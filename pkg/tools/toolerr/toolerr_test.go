@@ -0,0 +1,47 @@
+package toolerr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+
+	"github.com/russellhaering/autoswe/pkg/repo"
+	"github.com/russellhaering/autoswe/pkg/tools/fs/simplediff"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCategoryOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Category
+	}{
+		{"explicit", New(InvalidInput, "pattern is required"), InvalidInput},
+		{"wrapped explicit", fmt.Errorf("outer: %w", New(Transient, "rate limited")), Transient},
+		{"filtered", fmt.Errorf("failed to write file: %w", repo.ErrFiltered), Filtered},
+		{"outside root", repo.ErrOutsideRoot, PermissionDenied},
+		{"permission", fs.ErrPermission, PermissionDenied},
+		{"not exist", &fs.PathError{Op: "open", Path: "missing.go", Err: fs.ErrNotExist}, NotFound},
+		{"bad diff", simplediff.ErrSearchNotFound, InvalidInput},
+		{"deadline", context.DeadlineExceeded, Transient},
+		{"unknown", errors.New("boom"), Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, CategoryOf(tt.err))
+		})
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	err := New(NotFound, "no such branch: %s", "main")
+	assert.Equal(t, "no such branch: main", err.Error())
+
+	wrapped := Wrap(InvalidInput, simplediff.ErrInvalidDiffFormat)
+	assert.ErrorIs(t, wrapped, simplediff.ErrInvalidDiffFormat)
+	assert.Equal(t, simplediff.ErrInvalidDiffFormat.Error(), wrapped.Error())
+	assert.NoError(t, Wrap(Internal, nil))
+}